@@ -0,0 +1,279 @@
+package main
+
+import (
+	"code.google.com/p/gorilla/mux"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// discrepancyThreshold is the number of points that a scouted alliance total
+// may differ from the official score before the alliance is flagged.
+// Scouted totals never include penalties or the coopertition bonus, so small
+// differences are expected.
+const discrepancyThreshold = 10
+
+// An AllianceAccuracy compares the scouted total of an alliance in a match
+// with the official alliance score.
+type AllianceAccuracy struct {
+	Match    *Match
+	Alliance Alliance
+	Scouted  int
+	Official int
+	Scouts   []string
+}
+
+// Error returns the difference between the scouted and the official score.
+// A negative error means the scouts missed points.
+func (a AllianceAccuracy) Error() int {
+	return a.Scouted - a.Official
+}
+
+// AbsError returns the absolute value of Error.
+func (a AllianceAccuracy) AbsError() int {
+	e := a.Error()
+	if e < 0 {
+		return -e
+	}
+	return e
+}
+
+// Flagged reports whether the discrepancy is large enough to review.
+func (a AllianceAccuracy) Flagged() bool {
+	return a.AbsError() > discrepancyThreshold
+}
+
+// matchScouts returns the names of the scouts that scouted a team in a match.
+func matchScouts(match *Match, info *TeamInfo) []string {
+	if reports := match.ScoutReports(info.Team); len(reports) > 0 {
+		names := make([]string, 0, len(reports))
+		for _, r := range reports {
+			if r.ScoutName != "" {
+				names = append(names, r.ScoutName)
+			}
+		}
+		return names
+	}
+	if info.ScoutName == "" {
+		return nil
+	}
+	return strings.Split(info.ScoutName, ", ")
+}
+
+// matchAccuracy compares the scouted totals of a match's alliances with the
+// official scores.  Unscored matches and alliances with a robot that nobody
+// scouted are skipped, since the missing robot's points would be charged to
+// the alliance's scouts.
+func matchAccuracy(match *Match) []AllianceAccuracy {
+	if match.Score == nil {
+		return nil
+	}
+	var result []AllianceAccuracy
+	for _, alliance := range []Alliance{Red, Blue} {
+		a := AllianceAccuracy{
+			Match:    match,
+			Alliance: alliance,
+			Official: match.Score[string(alliance)],
+		}
+		scouted := false
+		for i := range match.Teams {
+			info := &match.Teams[i]
+			if info.Alliance != alliance {
+				continue
+			}
+			if !info.scouted() {
+				scouted = false
+				break
+			}
+			scouted = true
+			a.Scouted += info.Score
+			for _, name := range matchScouts(match, info) {
+				a.Scouts = appendUnique(a.Scouts, name)
+			}
+		}
+		if scouted {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, t := range list {
+		if t == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// A ScoutAccuracy summarizes the error of every alliance a scout worked on.
+type ScoutAccuracy struct {
+	Name       string
+	Alliances  int
+	Flagged    int
+	TotalError int
+	AbsError   int
+}
+
+// MeanError returns the average signed error.  A negative value means the
+// scout tends to miss points.
+func (s ScoutAccuracy) MeanError() float64 {
+	if s.Alliances == 0 {
+		return 0
+	}
+	return float64(s.TotalError) / float64(s.Alliances)
+}
+
+// MeanAbsError returns the average absolute error.
+func (s ScoutAccuracy) MeanAbsError() float64 {
+	if s.Alliances == 0 {
+		return 0
+	}
+	return float64(s.AbsError) / float64(s.Alliances)
+}
+
+// scoutAccuracy totals alliance errors by scout.  Each scout on an alliance
+// is charged with the alliance's entire error.  The result is sorted with
+// the least accurate scout first.
+func scoutAccuracy(alliances []AllianceAccuracy) []ScoutAccuracy {
+	byName := make(map[string]*ScoutAccuracy)
+	var scouts []*ScoutAccuracy
+	for _, a := range alliances {
+		for _, name := range a.Scouts {
+			s := byName[name]
+			if s == nil {
+				s = &ScoutAccuracy{Name: name}
+				byName[name] = s
+				scouts = append(scouts, s)
+			}
+			s.Alliances++
+			s.TotalError += a.Error()
+			s.AbsError += a.AbsError()
+			if a.Flagged() {
+				s.Flagged++
+			}
+		}
+	}
+
+	result := make([]ScoutAccuracy, len(scouts))
+	for i := range scouts {
+		result[i] = *scouts[i]
+	}
+	sort.Sort(byScoutError(result))
+	return result
+}
+
+type byScoutError []ScoutAccuracy
+
+func (slice byScoutError) Len() int {
+	return len(slice)
+}
+
+func (slice byScoutError) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice byScoutError) Less(i, j int) bool {
+	ei, ej := slice[i].MeanAbsError(), slice[j].MeanAbsError()
+	if ei != ej {
+		return ei > ej
+	}
+	return slice[i].Name < slice[j].Name
+}
+
+// eventAccuracy shows how well the scouted totals at an event match the
+// official scores.
+func eventAccuracy(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Fetch matches
+	matches, err := server.Store().FetchMatches(event.Tag())
+	if err != nil {
+		return err
+	}
+
+	var alliances []AllianceAccuracy
+	for _, m := range matches {
+		alliances = append(alliances, matchAccuracy(m)...)
+	}
+
+	return server.Templates().ExecuteTemplate(w, "event-accuracy.html", map[string]interface{}{
+		"Server":    server,
+		"Request":   req,
+		"Event":     event,
+		"Alliances": alliances,
+		"Scouts":    scoutAccuracy(alliances),
+		"Threshold": discrepancyThreshold,
+	})
+}
+
+// scoutIndex ranks scouts by their error across every event of a season,
+// or of every season if the year is "all".  The newest season is shown by
+// default.
+func scoutIndex(server *Server, w http.ResponseWriter, req *http.Request) error {
+	// Choose season
+	years, err := server.Store().EventYears()
+	if err != nil {
+		return err
+	}
+	year := time.Now().Year()
+	if len(years) > 0 {
+		year = years[0]
+	}
+	allSeasons := req.FormValue("year") == "all"
+	if s := req.FormValue("year"); s != "" && !allSeasons {
+		year, err = strconv.Atoi(s)
+		if err != nil || year <= 0 {
+			http.NotFound(w, req)
+			return nil
+		}
+	}
+
+	// Fetch events
+	seasonYears := []int{year}
+	if allSeasons {
+		seasonYears = years
+	}
+	var eventList []Event
+	for _, y := range seasonYears {
+		var events []Event
+		if err := server.Store().Events(y).All(&events); err != nil {
+			return err
+		}
+		eventList = append(eventList, events...)
+	}
+
+	// Fetch matches
+	var alliances []AllianceAccuracy
+	for i := range eventList {
+		matches, err := server.Store().FetchMatches(eventList[i].Tag())
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			alliances = append(alliances, matchAccuracy(m)...)
+		}
+	}
+
+	return server.Templates().ExecuteTemplate(w, "scout-index.html", map[string]interface{}{
+		"Server":     server,
+		"Request":    req,
+		"Years":      years,
+		"Year":       year,
+		"AllSeasons": allSeasons,
+		"EventList":  eventList,
+		"Scouts":     scoutAccuracy(alliances),
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchAccuracy(t *testing.T) {
+	match := &Match{
+		Type:   Qualification,
+		Number: 1,
+		Teams: []TeamInfo{
+			{Team: 1, Alliance: Red, Score: 10, ScoutName: "Alice"},
+			{Team: 2, Alliance: Red, Score: 12, ScoutName: "Bob, Carol"},
+			{Team: 3, Alliance: Red, Score: 0, ScoutName: "Alice"},
+			{Team: 4, Alliance: Blue, Score: 6},
+			{Team: 5, Alliance: Blue},
+			{Team: 6, Alliance: Blue},
+		},
+		Score: map[string]int{"red": 40, "blue": 6},
+	}
+	result := matchAccuracy(match)
+	if len(result) != 1 {
+		t.Fatalf("len(matchAccuracy(...)) = %d (expected 1)", len(result))
+	}
+	a := result[0]
+	if a.Alliance != Red || a.Scouted != 22 || a.Official != 40 || a.Error() != -18 || a.AbsError() != 18 || !a.Flagged() {
+		t.Errorf("red accuracy = %+v", a)
+	}
+	if expected := []string{"Alice", "Bob", "Carol"}; !reflect.DeepEqual(a.Scouts, expected) {
+		t.Errorf("red scouts = %v (expected %v)", a.Scouts, expected)
+	}
+
+	// Reports name the scouts of a double-scouted team.
+	match.Reports = []TeamInfo{{Team: 2, ScoutName: "Dave"}}
+	if a := matchAccuracy(match)[0]; !reflect.DeepEqual(a.Scouts, []string{"Alice", "Dave"}) {
+		t.Errorf("red scouts with reports = %v", a.Scouts)
+	}
+
+	// An alliance with a robot that nobody scouted is skipped.
+	match.Teams[2].ScoutName = ""
+	if result := matchAccuracy(match); len(result) != 0 {
+		t.Errorf("matchAccuracy with unscouted robot = %+v", result)
+	}
+	match.Teams[2].ScoutName = "Alice"
+
+	match.Score = nil
+	if result := matchAccuracy(match); len(result) != 0 {
+		t.Errorf("matchAccuracy of unscored match = %+v", result)
+	}
+}
+
+func TestScoutAccuracy(t *testing.T) {
+	alliances := []AllianceAccuracy{
+		{Scouted: 30, Official: 40, Scouts: []string{"Alice", "Bob"}},
+		{Scouted: 50, Official: 30, Scouts: []string{"Bob"}},
+		{Scouted: 20, Official: 22, Scouts: []string{"Carol", "Alice"}},
+	}
+	scouts := scoutAccuracy(alliances)
+	expected := []ScoutAccuracy{
+		{Name: "Bob", Alliances: 2, Flagged: 1, TotalError: 10, AbsError: 30},
+		{Name: "Alice", Alliances: 2, Flagged: 0, TotalError: -12, AbsError: 12},
+		{Name: "Carol", Alliances: 1, Flagged: 0, TotalError: -2, AbsError: 2},
+	}
+	if !reflect.DeepEqual(scouts, expected) {
+		t.Errorf("scoutAccuracy(...) = %+v (expected %+v)", scouts, expected)
+	}
+	if m := scouts[1].MeanError(); m != -6 {
+		t.Errorf("Alice MeanError() = %v (expected -6)", m)
+	}
+}
+
+func TestEventAccuracyPage(t *testing.T) {
+	store := newTestServer(t)
+	event, match := seedTestEvent(t, store)
+	mtag := MatchTag{event.Tag(), match.Type, uint(match.Number)}
+
+	// Every red robot is scouted, but team 3's points were missed.
+	red := []TeamInfo{
+		{Team: 1, Alliance: Red, Score: 10, ScoutName: "Alice"},
+		{Team: 2, Alliance: Red, Score: 10, ScoutName: "Bob"},
+		{Team: 3, Alliance: Red, Score: 0, ScoutName: "Carol"},
+	}
+	for _, info := range red {
+		if err := store.UpdateMatchTeam(mtag, info.Team, info, testEditor); err != nil {
+			t.Fatalf("UpdateMatchTeam(%d) error: %v", info.Team, err)
+		}
+	}
+	// Only one blue robot is scouted.
+	if err := store.UpdateMatchTeam(mtag, 4, TeamInfo{Team: 4, Alliance: Blue, Score: 5, ScoutName: "Dave"}, testEditor); err != nil {
+		t.Fatalf("UpdateMatchTeam(4) error: %v", err)
+	}
+	if err := store.UpdateMatchScore(mtag, 33, 40, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+
+	const path = "/event/2012/sdc/accuracy"
+	rec := serveTestRequest(t, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+	body := rec.Body.String()
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		if !strings.Contains(body, "<td>"+name+"</td>") {
+			t.Errorf("Accuracy page is missing %s", name)
+		}
+	}
+	if !strings.Contains(body, "flagged") {
+		t.Errorf("Accuracy page does not flag the red alliance:\n%s", body)
+	}
+	if strings.Contains(body, "Dave") {
+		t.Error("Accuracy page charges Dave for the unscouted blue robots")
+	}
+}
+
+func TestScoutIndexYears(t *testing.T) {
+	store := newTestServer(t)
+	event, match := seedTestEvent(t, store)
+	mustUpsertEvent(t, store, newTestEvent("ca", 2011, 3, 1, 1, 2, 3, 4, 5, 6))
+	mtag := MatchTag{event.Tag(), match.Type, uint(match.Number)}
+	for n := 1; n <= 3; n++ {
+		if err := store.UpdateMatchTeam(mtag, n, TeamInfo{Team: n, Alliance: Red, Score: 10, ScoutName: "Alice"}, testEditor); err != nil {
+			t.Fatalf("UpdateMatchTeam(%d) error: %v", n, err)
+		}
+	}
+	if err := store.UpdateMatchScore(mtag, 30, 0, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+
+	tests := []struct {
+		Query string
+		Alice bool
+	}{
+		{"", true},
+		{"?year=2012", true},
+		{"?year=2011", false},
+		{"?year=all", true},
+	}
+	for _, test := range tests {
+		rec := serveTestRequest(t, "/scout/"+test.Query, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET /scout/%s code = %d", test.Query, rec.Code)
+			continue
+		}
+		if alice := strings.Contains(rec.Body.String(), "Alice"); alice != test.Alice {
+			t.Errorf("GET /scout/%s lists Alice = %t (expected %t)", test.Query, alice, test.Alice)
+		}
+	}
+	if rec := serveTestRequest(t, "/scout/?year=x", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /scout/?year=x code = %d (expected %d)", rec.Code, http.StatusNotFound)
+	}
+}
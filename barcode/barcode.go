@@ -10,6 +10,10 @@ import (
 // Barcode is a 1-dimensional barcode.
 type Barcode []bool
 
+// QuietZone is the number of blank modules that a barcode needs on either side
+// to be read reliably.
+const QuietZone = 10
+
 func (code Barcode) String() string {
 	chars := make([]byte, len(code))
 	for i, b := range code {
@@ -22,6 +26,24 @@ func (code Barcode) String() string {
 	return string(chars)
 }
 
+// Widths returns the widths of the barcode's bars and spaces in modules,
+// alternating and starting with a bar.  If the barcode starts with a space,
+// the first width is zero.
+func (code Barcode) Widths() []int {
+	var widths []int
+	dark := true
+	for i := 0; i < len(code); {
+		n := 0
+		for i < len(code) && code[i] == dark {
+			i++
+			n++
+		}
+		widths = append(widths, n)
+		dark = !dark
+	}
+	return widths
+}
+
 // Image renders a barcode through the image interface.
 type Image struct {
 	Barcode
@@ -0,0 +1,45 @@
+package barcode
+
+import (
+	"testing"
+)
+
+func TestWidths(t *testing.T) {
+	tests := []struct {
+		Code   string
+		Widths []int
+	}{
+		{"", nil},
+		{"1", []int{1}},
+		{"0", []int{0, 1}},
+		{"11010010000", []int{2, 1, 1, 2, 1, 4}},
+		{"0011100", []int{0, 2, 3, 2}},
+	}
+	for _, test := range tests {
+		code := make(Barcode, len(test.Code))
+		for i := range test.Code {
+			code[i] = test.Code[i] == '1'
+		}
+		widths := code.Widths()
+		if len(widths) != len(test.Widths) {
+			t.Errorf("Barcode(%s).Widths() = %v; want %v", test.Code, widths, test.Widths)
+			continue
+		}
+		for i := range widths {
+			if widths[i] != test.Widths[i] {
+				t.Errorf("Barcode(%s).Widths() = %v; want %v", test.Code, widths, test.Widths)
+				break
+			}
+		}
+	}
+
+	// The widths of an encoded barcode add up to its length.
+	code := Encode("sdc20121001973")
+	n := 0
+	for _, w := range code.Widths() {
+		n += w
+	}
+	if n != len(code) {
+		t.Errorf("Widths of %q add up to %d modules; want %d", "sdc20121001973", n, len(code))
+	}
+}
@@ -0,0 +1,447 @@
+// decode.go
+
+package barcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Decoding errors
+var (
+	ErrNotFound = errors.New("barcode: no Code 128 barcode found")
+	ErrChecksum = errors.New("barcode: checksum mismatch")
+)
+
+// Decode reads a Code 128 barcode from its modules.  Quiet zones are
+// optional.
+func Decode(code Barcode) (string, error) {
+	// Pad the scanline so that the outer bars have edges.
+	line := make([]uint8, len(code)+2)
+	for i := range line {
+		line[i] = 0xff
+	}
+	for i, b := range code {
+		if b {
+			line[i+1] = 0x00
+		}
+	}
+	return DecodeScanline(line)
+}
+
+// DecodeScanline reads a Code 128 barcode from a line of gray levels, where
+// 0 is black and 255 is white.  The barcode may be any size, blurred, and
+// surrounded by noise, but it must be the right way up: scan a reversed line
+// to read a barcode upside down.
+func DecodeScanline(line []uint8) (string, error) {
+	s, _, err := decodeRuns(scanRuns(line))
+	return s, err
+}
+
+// DecodeImage reads the first Code 128 barcode found in an image, trying
+// rows from top to bottom in both directions.
+func DecodeImage(img image.Image) (string, error) {
+	results := scanImage(img, true)
+	if len(results) == 0 {
+		return "", ErrNotFound
+	}
+	if results[0].Err != nil {
+		return "", results[0].Err
+	}
+	return results[0].Text, nil
+}
+
+// A Result is a barcode found in an image.
+type Result struct {
+	Text string
+
+	// Bounds covers the barcode's bars from the first row that it was read
+	// on to the last.
+	Bounds image.Rectangle
+
+	// Reversed is true if the barcode was read from right to left, as it is
+	// in an upside-down image.
+	Reversed bool
+
+	// Err is ErrChecksum if a barcode was found but couldn't be read.  Only
+	// DecodeImage returns such results.
+	Err error
+}
+
+// FindAll reads every Code 128 barcode in an image.  Barcodes are returned in
+// the order that they are found, from top to bottom.  A barcode that can be
+// read upside down has its bounds in the image's coordinates.
+func FindAll(img image.Image) []Result {
+	return scanImage(img, false)
+}
+
+// rowsPerScan is the number of scanlines tried in an image of any height.
+const rowsPerScan = 200
+
+// rowsPerLine is the number of neighboring rows averaged into each scanline to
+// smooth out noise.
+const rowsPerLine = 3
+
+// scanImage reads barcodes from evenly spaced rows of an image.  If first is
+// true, scanning stops at the first barcode, and a checksum error is reported
+// if nothing could be read.
+func scanImage(img image.Image, first bool) []Result {
+	bounds := img.Bounds()
+	step := bounds.Dy() / rowsPerScan
+	if step < 1 {
+		step = 1
+	}
+	var results []Result
+	checksumFailed := false
+	line := make([]uint8, bounds.Dx())
+	for y := bounds.Min.Y + step/2; y < bounds.Max.Y; y += step {
+		readRow(line, img, y)
+		for _, reversed := range []bool{false, true} {
+			if reversed {
+				reverse(line)
+			}
+			s, span, err := decodeRuns(scanRuns(line))
+			if err == ErrChecksum {
+				checksumFailed = true
+			}
+			if err != nil {
+				continue
+			}
+			x0, x1 := int(math.Floor(span[0])), int(math.Ceil(span[1]))
+			if reversed {
+				x0, x1 = len(line)-x1, len(line)-x0
+			}
+			r := image.Rect(bounds.Min.X+x0, y, bounds.Min.X+x1, y+1)
+			if first {
+				return []Result{{Text: s, Bounds: r, Reversed: reversed}}
+			}
+			results = addResult(results, Result{Text: s, Bounds: r, Reversed: reversed})
+			break
+		}
+	}
+	if first && checksumFailed {
+		return []Result{{Err: ErrChecksum}}
+	}
+	return results
+}
+
+// addResult merges a barcode read on a row into the results.  Reads of the
+// same text that overlap horizontally are the same barcode.
+func addResult(results []Result, r Result) []Result {
+	for i := range results {
+		b := &results[i].Bounds
+		if results[i].Text == r.Text && r.Bounds.Min.X < b.Max.X && b.Min.X < r.Bounds.Max.X {
+			*b = b.Union(r.Bounds)
+			return results
+		}
+	}
+	return append(results, r)
+}
+
+// readRow averages the gray levels of rows of an image around y into line.
+func readRow(line []uint8, img image.Image, y int) {
+	bounds := img.Bounds()
+	y0, y1 := y-rowsPerLine/2, y-rowsPerLine/2+rowsPerLine
+	if y0 < bounds.Min.Y {
+		y0 = bounds.Min.Y
+	}
+	if y1 > bounds.Max.Y {
+		y1 = bounds.Max.Y
+	}
+	for x := range line {
+		sum := 0
+		for yy := y0; yy < y1; yy++ {
+			sum += int(color.GrayModel.Convert(img.At(bounds.Min.X+x, yy)).(color.Gray).Y)
+		}
+		line[x] = uint8(sum / (y1 - y0))
+	}
+}
+
+func reverse(line []uint8) {
+	for i, j := 0, len(line)-1; i < j; i, j = i+1, j-1 {
+		line[i], line[j] = line[j], line[i]
+	}
+}
+
+// A run is a bar or space in a scanline, with its edges measured to a
+// fraction of a sample.
+type run struct {
+	Bar        bool
+	Start, End float64
+}
+
+func (r run) Width() float64 {
+	return r.End - r.Start
+}
+
+// edgeContrast is the smallest change in gray level, relative to the
+// scanline's full range, that is considered an edge.  Smaller changes are
+// paper texture and sensor noise.
+const edgeContrast = 0.25
+
+// scanRuns splits a scanline into bars and spaces.  Each edge is placed where
+// the line crosses halfway between the lightest and darkest levels on either
+// side of it, rather than at a fixed threshold, so that blur, which keeps
+// narrow bars from reaching full black, doesn't move their edges.
+func scanRuns(line []uint8) []run {
+	if len(line) < 2 {
+		return nil
+	}
+	lo, hi := line[0], line[0]
+	for _, v := range line {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	delta := edgeContrast * float64(hi-lo)
+	if delta == 0 {
+		return nil
+	}
+
+	// Find alternating lightest and darkest points, ignoring changes smaller
+	// than delta.
+	var extremes []int
+	ext, rising := 0, false
+	lightest, darkest := 0, 0
+	i := 0
+	for ; i < len(line) && extremes == nil; i++ {
+		v := line[i]
+		if v > line[lightest] {
+			lightest = i
+		}
+		if v < line[darkest] {
+			darkest = i
+		}
+		switch {
+		case float64(line[lightest])-float64(v) >= delta:
+			extremes, ext, rising = []int{lightest}, i, false
+		case float64(v)-float64(line[darkest]) >= delta:
+			extremes, ext, rising = []int{darkest}, i, true
+		}
+	}
+	for ; i < len(line); i++ {
+		v := line[i]
+		switch {
+		case rising && v > line[ext], !rising && v < line[ext]:
+			ext = i
+		case rising && float64(line[ext])-float64(v) >= delta, !rising && float64(v)-float64(line[ext]) >= delta:
+			extremes = append(extremes, ext)
+			ext, rising = i, !rising
+		}
+	}
+	extremes = append(extremes, ext)
+
+	var runs []run
+	start := 0.0
+	for j := 0; j+1 < len(extremes); j++ {
+		a, b := extremes[j], extremes[j+1]
+		// A falling edge ends a space and a rising edge ends a bar.
+		falling := line[a] > line[b]
+		pos := crossing(line[a:b+1], (float64(line[a])+float64(line[b]))/2) + float64(a)
+		runs = append(runs, run{Bar: !falling, Start: start, End: pos})
+		start = pos
+	}
+	if len(runs) > 0 {
+		runs = append(runs, run{Bar: !runs[len(runs)-1].Bar, Start: start, End: float64(len(line))})
+	}
+	return runs
+}
+
+// crossing returns where a section of scanline crosses a gray level, to a
+// fraction of a sample.  If noise makes it cross several times, the middle of
+// the crossings is used.
+func crossing(section []uint8, level float64) float64 {
+	first, last := -1.0, -1.0
+	for i := 0; i+1 < len(section); i++ {
+		v0, v1 := float64(section[i]), float64(section[i+1])
+		if (v0 < level) == (v1 < level) {
+			continue
+		}
+		// Sample centers are at half-sample offsets.
+		x := float64(i) + 0.5 + (level-v0)/(v1-v0)
+		if first < 0 {
+			first = x
+		}
+		last = x
+	}
+	if first < 0 {
+		return float64(len(section)) / 2
+	}
+	return (first + last) / 2
+}
+
+// Symbol widths in modules
+const (
+	symbolModules  = 11
+	stopBarModules = 2
+)
+
+// maxSymbolError is the most that a measured symbol's edge-to-edge widths can
+// differ from a pattern's, in total modules, and still match it.
+const maxSymbolError = 1.5
+
+// matchSymbol returns the symbol value of six runs starting with a bar, or -1
+// if they don't look like any symbol.  Symbols are matched on the distances
+// between the leading edges of adjacent bars and of adjacent spaces, which
+// don't change when blur or ink spread makes bars wider or narrower.
+func matchSymbol(runs []run) int {
+	if len(runs) < 6 || !runs[0].Bar {
+		return -1
+	}
+	total := runs[5].End - runs[0].Start
+	if total <= 0 {
+		return -1
+	}
+	var measured [4]float64
+	for i := range measured {
+		measured[i] = (runs[i].Width() + runs[i+1].Width()) * symbolModules / total
+	}
+	best, bestError := -1, maxSymbolError
+	for v, w := range weights {
+		var e float64
+		for i := range measured {
+			e += math.Abs(measured[i] - float64(w[i]+w[i+1]))
+		}
+		if e < bestError {
+			best, bestError = v, e
+		}
+	}
+	return best
+}
+
+// decodeRuns finds a Code 128 barcode in a scanline's runs and returns its
+// text and the positions of its first and last edges.
+func decodeRuns(runs []run) (string, [2]float64, error) {
+	err := ErrNotFound
+	for i := range runs {
+		if v := matchSymbol(runs[i:]); v < startA || v > startC {
+			continue
+		}
+		values, n := readSymbols(runs[i:])
+		if values == nil {
+			continue
+		}
+		s, e := decodeValues(values)
+		if e == nil {
+			return s, [2]float64{runs[i].Start, runs[i+n-1].End}, nil
+		}
+		if e == ErrChecksum {
+			err = e
+		}
+	}
+	return "", [2]float64{}, err
+}
+
+// readSymbols reads symbol values from the start symbol through the stop
+// symbol.  It returns the values, not including the stop symbol, and the
+// number of runs read, or nil if no stop symbol was found.
+func readSymbols(runs []run) ([]int, int) {
+	var values []int
+	for i := 0; i+6 <= len(runs); i += 6 {
+		v := matchSymbol(runs[i:])
+		switch {
+		case v < 0:
+			return nil, 0
+		case v == stop:
+			// The stop symbol has a final bar.
+			if i+7 > len(runs) || !runs[i+6].Bar {
+				return nil, 0
+			}
+			module := (runs[i+5].End - runs[i].Start) / symbolModules
+			if w := runs[i+6].Width() / module; w < stopBarModules-1 || w > stopBarModules+1 {
+				return nil, 0
+			}
+			return values, i + 7
+		}
+		values = append(values, v)
+	}
+	return nil, 0
+}
+
+// Code sets
+const (
+	codeA = iota
+	codeB
+	codeC
+)
+
+// Symbol values that mean the same thing in several code sets
+const (
+	shift      = 98
+	codeCValue = 99
+	codeBValue = 100 // in code sets A and C
+	codeAValue = 101 // in code sets B and C
+)
+
+// decodeValues checks the checksum of a barcode's symbol values, from the
+// start symbol through the check symbol, and decodes its text.
+func decodeValues(values []int) (string, error) {
+	if len(values) < 2 {
+		return "", ErrNotFound
+	}
+	data, check := values[1:len(values)-1], values[len(values)-1]
+	sum := values[0]
+	for i, v := range data {
+		sum += (i + 1) * v
+	}
+	if sum%103 != check {
+		return "", ErrChecksum
+	}
+
+	var set int
+	switch values[0] {
+	case startA:
+		set = codeA
+	case startB:
+		set = codeB
+	case startC:
+		set = codeC
+	}
+	s := make([]byte, 0, len(data)*2)
+	for i := 0; i < len(data); i++ {
+		v := data[i]
+		if set == codeC {
+			switch {
+			case v < 100:
+				s = append(s, byte('0'+v/10), byte('0'+v%10))
+			case v == codeBValue:
+				set = codeB
+			case v == codeAValue:
+				set = codeA
+			default:
+				return "", errors.New("barcode: function characters are not supported")
+			}
+			continue
+		}
+
+		switch {
+		case v < 96:
+			s = append(s, charValue(set, v))
+		case v == shift && i+1 < len(data) && data[i+1] < 96:
+			// The next character is in the other of code sets A and B.
+			i++
+			s = append(s, charValue(codeA+codeB-set, data[i]))
+		case v == codeCValue:
+			set = codeC
+		case set == codeA && v == codeBValue, set == codeB && v == codeAValue:
+			set = codeA + codeB - set
+		default:
+			return "", errors.New("barcode: function characters are not supported")
+		}
+	}
+	return string(s), nil
+}
+
+// charValue returns the character for a value below 96 in code set A or B.
+func charValue(set int, v int) byte {
+	if set == codeA && v >= 64 {
+		// Control characters
+		return byte(v - 64)
+	}
+	return byte(' ' + v)
+}
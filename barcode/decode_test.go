@@ -0,0 +1,217 @@
+package barcode
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var roundTripTests = []string{
+	"sdc20121001973",
+	"ca20112001",
+	"sdc2012p973",
+	"Hello, World!",
+	"abc123456def",
+	"12345",
+	"7",
+	"a`b{c}~",
+	"LINE\tFEED\n",
+	"\x01\x02abc",
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	for _, s := range roundTripTests {
+		code := Encode(s)
+		if d, err := Decode(code); err != nil {
+			t.Errorf("Decode(Encode(%q)) error: %v", s, err)
+		} else if d != s {
+			t.Errorf("Decode(Encode(%q)) = %q", s, d)
+		}
+	}
+}
+
+// scanTestImage renders a barcode the way a scanner would see it: scale pixels
+// per module (which needn't be whole), blurred by a box filter blur pixels
+// wide, with quiet zones of quiet pixels, specks of dirt and sensor noise.
+func scanTestImage(code Barcode, scale float64, blur int, quiet int, noise int) *image.Gray {
+	width := int(math.Ceil(float64(len(code))*scale)) + quiet*2
+	const height = 12
+
+	// Average each pixel's coverage by the bars.
+	line := make([]float64, width)
+	for x := range line {
+		const samples = 8
+		dark := 0
+		for i := 0; i < samples; i++ {
+			m := int(math.Floor((float64(x) + (float64(i)+0.5)/samples - float64(quiet)) / scale))
+			if m >= 0 && m < len(code) && code[m] {
+				dark++
+			}
+		}
+		line[x] = 255 * (1 - float64(dark)/samples)
+	}
+
+	// Specks in the quiet zones
+	if quiet > 6 {
+		line[quiet/2] = 0
+		line[width-quiet/2] = 40
+	}
+
+	if blur > 1 {
+		blurred := make([]float64, width)
+		for x := range line {
+			sum, n := 0.0, 0
+			for i := x - blur/2; i < x-blur/2+blur; i++ {
+				if i >= 0 && i < width {
+					sum += line[i]
+					n++
+				}
+			}
+			blurred[x] = sum / float64(n)
+		}
+		line = blurred
+	}
+
+	r := rand.New(rand.NewSource(1))
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x, v := range line {
+			if noise > 0 {
+				v += float64(r.Intn(2*noise+1) - noise)
+			}
+			img.SetGray(x, y, color.Gray{uint8(math.Max(0, math.Min(255, v)))})
+		}
+	}
+	return img
+}
+
+func TestDecodeImage(t *testing.T) {
+	tests := []struct {
+		Scale float64
+		Blur  int
+		Quiet int
+		Noise int
+	}{
+		{1, 0, 0, 0},
+		{2, 0, 20, 0},
+		{3, 3, 30, 0},
+		{2.5, 2, 30, 10},
+		{3.7, 4, 40, 20},
+		{5, 5, 12, 30},
+	}
+	for _, test := range tests {
+		for _, s := range roundTripTests {
+			img := scanTestImage(Encode(s), test.Scale, test.Blur, test.Quiet, test.Noise)
+			if d, err := DecodeImage(img); err != nil {
+				t.Errorf("DecodeImage(%q at %+v) error: %v", s, test, err)
+			} else if d != s {
+				t.Errorf("DecodeImage(%q at %+v) = %q", s, test, d)
+			}
+		}
+	}
+}
+
+func TestDecodeUpsideDown(t *testing.T) {
+	const s = "sdc20121001973"
+	img := scanTestImage(Encode(s), 3, 2, 20, 0)
+	b := img.Bounds()
+	flipped := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			flipped.SetGray(b.Max.X-1-x, b.Max.Y-1-y, img.GrayAt(x, y))
+		}
+	}
+	if d, err := DecodeImage(flipped); err != nil || d != s {
+		t.Errorf("DecodeImage(upside down) = %q, %v (expected %q)", d, err, s)
+	}
+	if results := FindAll(flipped); len(results) != 1 || !results[0].Reversed {
+		t.Errorf("FindAll(upside down) = %+v (expected one reversed barcode)", results)
+	}
+	if results := FindAll(img); len(results) != 1 || results[0].Reversed {
+		t.Errorf("FindAll(upright) = %+v (expected one barcode that isn't reversed)", results)
+	}
+}
+
+func TestDecodeChecksum(t *testing.T) {
+	// Change the first character from "s" to "t" without fixing the check
+	// symbol.
+	code := Encode("sdc2012")
+	bad := append(Barcode(nil), code[:11]...)
+	bad = append(bad, getBits(charsetB["t"])...)
+	bad = append(bad, code[22:]...)
+	if _, err := Decode(bad); err != ErrChecksum {
+		t.Errorf("Decode with bad checksum error = %v (expected %v)", err, ErrChecksum)
+	}
+
+	if _, err := Decode(code[:len(code)-13]); err != ErrNotFound {
+		t.Errorf("Decode without stop symbol error = %v (expected %v)", err, ErrNotFound)
+	}
+	if _, err := DecodeScanline(make([]uint8, 100)); err != ErrNotFound {
+		t.Errorf("DecodeScanline(blank) error = %v (expected %v)", err, ErrNotFound)
+	}
+}
+
+func TestDecodeValues(t *testing.T) {
+	tests := []struct {
+		Values   []int
+		Expected string
+	}{
+		// Code set A with a shift to B
+		{[]int{startA, 33, shift, 65, 33}, "AaA"},
+		// Code set B shifting to A for a control character
+		{[]int{startB, 65, shift, 64, 65}, "a\x00a"},
+		// Code set C switching to B and back
+		{[]int{startC, 12, codeBValue, 33, codeCValue, 34}, "12A34"},
+		// Code set B switching to A
+		{[]int{startB, 65, codeAValue, 65}, "a\x01"},
+	}
+	for _, test := range tests {
+		sum := test.Values[0]
+		for i, v := range test.Values[1:] {
+			sum += (i + 1) * v
+		}
+		values := append(test.Values, sum%103)
+		if s, err := decodeValues(values); err != nil {
+			t.Errorf("decodeValues(%v) error: %v", values, err)
+		} else if s != test.Expected {
+			t.Errorf("decodeValues(%v) = %q (expected %q)", values, s, test.Expected)
+		}
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	const quiet = 20
+	top := scanTestImage(Encode("sdc20121001973"), 2, 0, quiet, 0)
+	bottom := scanTestImage(Encode("sdc20121002254"), 2, 0, quiet, 0)
+	img := image.NewGray(image.Rect(0, 0, top.Bounds().Dx()+50, 60))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	draw := func(src *image.Gray, dx, dy int) {
+		b := src.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				img.SetGray(x+dx, y+dy, src.GrayAt(x, y))
+			}
+		}
+	}
+	draw(top, 0, 5)
+	draw(bottom, 50, 40)
+
+	results := FindAll(img)
+	if len(results) != 2 {
+		t.Fatalf("FindAll found %d barcodes: %+v", len(results), results)
+	}
+	if results[0].Text != "sdc20121001973" || results[1].Text != "sdc20121002254" {
+		t.Errorf("FindAll texts = %q, %q", results[0].Text, results[1].Text)
+	}
+	b := results[0].Bounds
+	if b.Min.X < quiet-1 || b.Min.X > quiet+1 || b.Min.Y < 5-rowsPerLine/2 || b.Max.Y > 5+12+rowsPerLine/2 {
+		t.Errorf("First barcode bounds = %v", b)
+	}
+	if b := results[1].Bounds; b.Min.X < 50+quiet-1 || b.Min.Y < 40-rowsPerLine/2 {
+		t.Errorf("Second barcode bounds = %v", b)
+	}
+}
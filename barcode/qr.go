@@ -0,0 +1,600 @@
+// qr.go
+
+package barcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// A QRLevel is the error correction level of a QR code: how much of the code
+// can be damaged and still be read.
+type QRLevel int
+
+const (
+	QRLevelL QRLevel = iota // about 7% can be recovered
+	QRLevelM                // about 15%
+	QRLevelQ                // about 25%
+	QRLevelH                // about 30%
+)
+
+func (level QRLevel) String() string {
+	switch level {
+	case QRLevelL:
+		return "L"
+	case QRLevelM:
+		return "M"
+	case QRLevelQ:
+		return "Q"
+	case QRLevelH:
+		return "H"
+	}
+	return "QRLevel(" + strconv.Itoa(int(level)) + ")"
+}
+
+// formatBits returns the level's two-bit code in the format information,
+// which isn't in the order of the levels.
+func (level QRLevel) formatBits() int {
+	return [...]int{1, 0, 3, 2}[level]
+}
+
+// ErrTooLong is returned when text doesn't fit in the largest QR code.
+var ErrTooLong = errors.New("barcode: text is too long for a QR code")
+
+// A QRCode is a two-dimensional barcode.
+type QRCode struct {
+	Version int // from 1 to 40
+	Level   QRLevel
+	Size    int // modules on each side
+
+	modules []bool // dark modules, row by row
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (qr *QRCode) Dark(x, y int) bool {
+	return qr.modules[y*qr.Size+x]
+}
+
+// QRImage renders a QR code through the image interface.  Like Image, it
+// doesn't include a quiet zone: leave at least four modules of white around
+// it.
+type QRImage struct {
+	*QRCode
+	Scale int
+}
+
+func (img *QRImage) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (img *QRImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, img.Size*img.Scale, img.Size*img.Scale)
+}
+
+func (img *QRImage) At(x, y int) color.Color {
+	if x < 0 || y < 0 || x >= img.Size*img.Scale || y >= img.Size*img.Scale {
+		return nil
+	}
+
+	if img.Dark(x/img.Scale, y/img.Scale) {
+		return color.Gray{0x00}
+	}
+	return color.Gray{0xff}
+}
+
+// qrAlphanumeric lists the characters of alphanumeric mode by value.
+const qrAlphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// Mode indicators
+const (
+	qrModeNumeric      = 0x1
+	qrModeAlphanumeric = 0x2
+	qrModeByte         = 0x4
+)
+
+// EncodeQR returns the smallest QR code that holds text at an error
+// correction level.  Text made only of digits, uppercase letters and
+// " $%*+-./:" is encoded in the denser alphanumeric mode; other text is
+// encoded as bytes.
+func EncodeQR(text string, level QRLevel) (*QRCode, error) {
+	mode, dataBits := qrModeByte, len(text)*8
+	if isQRAlphanumeric(text) {
+		mode, dataBits = qrModeAlphanumeric, len(text)/2*11+len(text)%2*6
+	}
+
+	version := 0
+	for v := 1; v <= 40; v++ {
+		n := qrCountBits(mode, v)
+		if len(text) < 1<<uint(n) && 4+n+dataBits <= qrDataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	// Segment, terminator and padding
+	var b bitBuffer
+	b.append(mode, 4)
+	b.append(len(text), qrCountBits(mode, version))
+	if mode == qrModeAlphanumeric {
+		for i := 0; i+1 < len(text); i += 2 {
+			b.append(strings.IndexByte(qrAlphanumeric, text[i])*45+strings.IndexByte(qrAlphanumeric, text[i+1]), 11)
+		}
+		if len(text)%2 == 1 {
+			b.append(strings.IndexByte(qrAlphanumeric, text[len(text)-1]), 6)
+		}
+	} else {
+		for i := 0; i < len(text); i++ {
+			b.append(int(text[i]), 8)
+		}
+	}
+	capacity := qrDataCodewords(version, level) * 8
+	terminator := capacity - len(b)
+	if terminator > 4 {
+		terminator = 4
+	}
+	b.append(0, terminator)
+	b.append(0, (8-len(b)%8)%8)
+	for pad := 0xec; len(b) < capacity; pad ^= 0xec ^ 0x11 {
+		b.append(pad, 8)
+	}
+
+	qr := &QRCode{Version: version, Level: level, Size: version*4 + 17}
+	qr.draw(qrInterleave(b.bytes(), version, level))
+	return qr, nil
+}
+
+func isQRAlphanumeric(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if strings.IndexByte(qrAlphanumeric, text[i]) == -1 {
+			return false
+		}
+	}
+	return true
+}
+
+// qrCountBits returns the width of a segment's character count.
+func qrCountBits(mode int, version int) int {
+	i := 0
+	if version >= 27 {
+		i = 2
+	} else if version >= 10 {
+		i = 1
+	}
+	switch mode {
+	case qrModeNumeric:
+		return [...]int{10, 12, 14}[i]
+	case qrModeAlphanumeric:
+		return [...]int{9, 11, 13}[i]
+	}
+	return [...]int{8, 16, 16}[i]
+}
+
+// A bitBuffer is a sequence of bits, most significant first.
+type bitBuffer []bool
+
+func (b *bitBuffer) append(v int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>uint(i)&1 != 0)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	bytes := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			bytes[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return bytes
+}
+
+// Error correction codewords per block and number of blocks, by level and
+// version.  Index 0 is unused.
+var (
+	qrECCPerBlock = [4][41]int{
+		{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+	qrBlocks = [4][41]int{
+		{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+)
+
+// qrRawModules returns the number of modules in a version's symbol that hold
+// codewords, after the function patterns are left out.
+func qrRawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+// qrDataCodewords returns the number of data codewords a symbol holds.
+func qrDataCodewords(version int, level QRLevel) int {
+	return qrRawModules(version)/8 - qrECCPerBlock[level][version]*qrBlocks[level][version]
+}
+
+// qrInterleave splits data into blocks, adds each block's error correction
+// codewords, and interleaves the blocks' codewords.
+func qrInterleave(data []byte, version int, level QRLevel) []byte {
+	numBlocks := qrBlocks[level][version]
+	eccLen := qrECCPerBlock[level][version]
+	raw := qrRawModules(version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	// The later blocks are one data codeword longer.  Short blocks get a
+	// placeholder so that the blocks can be interleaved by column.
+	divisor := rsGenerator(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		block := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(block, divisor)
+		if i < numShort {
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := 0; i <= shortLen; i++ {
+		for j, block := range blocks {
+			// Skip the placeholders.
+			if i != shortLen-eccLen || j >= numShort {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// rsGenerator returns the coefficients of the Reed-Solomon generator
+// polynomial of a degree, highest power first, without the leading one.
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the Reed-Solomon error correction codewords of data.
+func rsRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ z>>7*0x11d
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// draw lays out the symbol: the function patterns, then the codewords with
+// whichever mask scores best.
+func (qr *QRCode) draw(codewords []byte) {
+	qr.modules = make([]bool, qr.Size*qr.Size)
+	function := qr.drawFunctions()
+	set := func(x, y int, dark bool) {
+		qr.modules[y*qr.Size+x] = dark
+	}
+	for i, m := range qrDataOrder(qr.Size, function) {
+		if i >= len(codewords)*8 {
+			break
+		}
+		qr.modules[m] = codewords[i/8]>>uint(7-i%8)&1 != 0
+	}
+
+	// Try each mask.  Masks are their own inverse, so each is removed by
+	// applying it again.
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask, function)
+		qr.drawFormat(mask, set)
+		if p := qr.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		qr.applyMask(mask, function)
+	}
+	qr.applyMask(best, function)
+	qr.drawFormat(best, set)
+}
+
+// drawFunctions draws the patterns that locate and describe the symbol,
+// reserving the format information, and returns which modules it drew.
+func (qr *QRCode) drawFunctions() []bool {
+	function := make([]bool, qr.Size*qr.Size)
+	set := func(x, y int, dark bool) {
+		qr.modules[y*qr.Size+x] = dark
+		function[y*qr.Size+x] = true
+	}
+
+	// Timing patterns, then finder patterns over their ends
+	for i := 0; i < qr.Size; i++ {
+		set(6, i, i%2 == 0)
+		set(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {qr.Size - 4, 3}, {3, qr.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x >= 0 && x < qr.Size && y >= 0 && y < qr.Size {
+					d := max(abs(dx), abs(dy))
+					set(x, y, d != 2 && d != 4)
+				}
+			}
+		}
+	}
+
+	// Alignment patterns, except where the finder patterns are
+	align := qrAlignment(qr.Version)
+	for i, ay := range align {
+		for j, ax := range align {
+			if i == 0 && j == 0 || i == 0 && j == len(align)-1 || i == len(align)-1 && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					set(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format information and draw the version information.
+	qr.drawFormat(0, set)
+	if qr.Version >= 7 {
+		bits := qrVersionBits(qr.Version)
+		for i := 0; i < 18; i++ {
+			a, b := qr.Size-11+i%3, i/3
+			dark := bits>>uint(i)&1 != 0
+			set(a, b, dark)
+			set(b, a, dark)
+		}
+	}
+
+	return function
+}
+
+// qrDataOrder returns the indices of the modules that hold codeword bits, in
+// order: two-module columns zigzagging up and down from the right, skipping
+// the vertical timing pattern.
+func qrDataOrder(size int, function []bool) []int {
+	order := make([]int, 0, len(function))
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if !function[y*size+x] {
+					order = append(order, y*size+x)
+				}
+			}
+		}
+	}
+	return order
+}
+
+// qrAlignment returns the centers of a version's alignment patterns on
+// either axis.
+func qrAlignment(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	result := make([]int, n)
+	result[0] = 6
+	for i, pos := n-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// drawFormat draws both copies of the format information and the dark module.
+func (qr *QRCode) drawFormat(mask int, set func(x, y int, dark bool)) {
+	bits := qrFormatBits(qr.Level, mask)
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+	for i := 0; i <= 5; i++ {
+		set(8, i, bit(i))
+	}
+	set(8, 7, bit(6))
+	set(8, 8, bit(7))
+	set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		set(qr.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		set(8, qr.Size-15+i, bit(i))
+	}
+	set(8, qr.Size-8, true)
+}
+
+// qrFormatBits returns the 15-bit format information: the level and mask
+// protected by a BCH code and masked so that it is never all zero.
+func qrFormatBits(level QRLevel, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ rem>>9*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// qrVersionBits returns the 18-bit version information.
+func qrVersionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ rem>>11*0x1f25
+	}
+	return version<<12 | rem
+}
+
+// applyMask inverts the data modules selected by a mask pattern.
+func (qr *QRCode) applyMask(mask int, function []bool) {
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !function[y*qr.Size+x] {
+				qr.modules[y*qr.Size+x] = !qr.modules[y*qr.Size+x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard a symbol is to read: long runs, blocks of one
+// color, patterns that look like finders, and unbalanced dark and light.
+func (qr *QRCode) penalty() int {
+	penalty := 0
+	line := make([]bool, qr.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < qr.Size; i++ {
+			for j := range line {
+				if vertical {
+					line[j] = qr.Dark(i, j)
+				} else {
+					line[j] = qr.Dark(j, i)
+				}
+			}
+			penalty += linePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			c := qr.Dark(x, y)
+			if c {
+				dark++
+			}
+			if x+1 < qr.Size && y+1 < qr.Size && c == qr.Dark(x+1, y) && c == qr.Dark(x, y+1) && c == qr.Dark(x+1, y+1) {
+				penalty += 3
+			}
+		}
+	}
+	total := qr.Size * qr.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return penalty + k*10
+}
+
+// qrFinderLike is a 1:1:3:1:1 pattern with four light modules on one side.
+var qrFinderLike = [...][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// linePenalty scores a row or column for runs of five or more modules of one
+// color and for patterns that look like finders.  Modules outside the symbol
+// are light.
+func linePenalty(line []bool) int {
+	penalty := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += 3 + run - 5
+		}
+		run = 1
+	}
+
+	at := func(i int) bool { return i >= 0 && i < len(line) && line[i] }
+	for start := -4; start < len(line); start++ {
+		for _, pattern := range qrFinderLike {
+			match := true
+			for j, dark := range pattern {
+				if at(start+j) != dark {
+					match = false
+					break
+				}
+			}
+			if match {
+				penalty += 40
+			}
+		}
+	}
+	return penalty
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,268 @@
+package barcode
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestQRCodewords(t *testing.T) {
+	// The example from ISO/IEC 18004 Annex I: "HELLO WORLD" at 1-Q
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236}
+	ecc := []byte{168, 72, 22, 82, 217, 54, 156, 0, 46, 15, 180, 122, 16}
+
+	var b bitBuffer
+	b.append(qrModeAlphanumeric, 4)
+	b.append(11, qrCountBits(qrModeAlphanumeric, 1))
+	for _, pair := range []string{"HE", "LL", "O ", "WO", "RL"} {
+		b.append(strings.IndexByte(qrAlphanumeric, pair[0])*45+strings.IndexByte(qrAlphanumeric, pair[1]), 11)
+	}
+	b.append(strings.IndexByte(qrAlphanumeric, 'D'), 6)
+	b.append(0, 4)
+	b.append(0, (8-len(b)%8)%8)
+	for pad := 0xec; len(b) < qrDataCodewords(1, QRLevelQ)*8; pad ^= 0xec ^ 0x11 {
+		b.append(pad, 8)
+	}
+	if got := b.bytes(); !bytes.Equal(got, data) {
+		t.Fatalf("data codewords = %v; want %v", got, data)
+	}
+
+	want := append(append([]byte(nil), data...), ecc...)
+	if got := qrInterleave(data, 1, QRLevelQ); !bytes.Equal(got, want) {
+		t.Errorf("qrInterleave = %v; want %v", got, want)
+	}
+}
+
+func TestQRInterleave(t *testing.T) {
+	// 5-Q has two blocks of 15 data codewords and two of 16.
+	data := make([]byte, qrDataCodewords(5, QRLevelQ))
+	for i := range data {
+		data[i] = byte(i)
+	}
+	result := qrInterleave(data, 5, QRLevelQ)
+	if len(result) != qrRawModules(5)/8 {
+		t.Fatalf("len(qrInterleave(...)) = %d; want %d", len(result), qrRawModules(5)/8)
+	}
+	want := []byte{0, 15, 30, 46, 1, 16, 31, 47}
+	if !bytes.Equal(result[:len(want)], want) {
+		t.Errorf("qrInterleave(...)[:%d] = %v; want %v", len(want), result[:len(want)], want)
+	}
+	// The long blocks' last codewords come after every block's 15th.
+	want = []byte{14, 29, 44, 60, 45, 61}
+	if got := result[56:62]; !bytes.Equal(got, want) {
+		t.Errorf("qrInterleave(...)[56:62] = %v; want %v", got, want)
+	}
+}
+
+func TestQRFormatBits(t *testing.T) {
+	tests := []struct {
+		Level QRLevel
+		Mask  int
+		Bits  int
+	}{
+		{QRLevelL, 0, 0x77c4},
+		{QRLevelM, 0, 0x5412},
+		{QRLevelQ, 0, 0x355f},
+		{QRLevelH, 0, 0x1689},
+		{QRLevelM, 5, 0x40ce},
+		{QRLevelH, 7, 0x083b},
+	}
+	for _, test := range tests {
+		if bits := qrFormatBits(test.Level, test.Mask); bits != test.Bits {
+			t.Errorf("qrFormatBits(%v, %d) = %015b; want %015b", test.Level, test.Mask, bits, test.Bits)
+		}
+	}
+}
+
+func TestQRVersionBits(t *testing.T) {
+	tests := []struct {
+		Version int
+		Bits    int
+	}{
+		{7, 0x07c94},
+		{21, 0x15683},
+		{40, 0x28c69},
+	}
+	for _, test := range tests {
+		if bits := qrVersionBits(test.Version); bits != test.Bits {
+			t.Errorf("qrVersionBits(%d) = %018b; want %018b", test.Version, bits, test.Bits)
+		}
+	}
+}
+
+func TestQRDataCodewords(t *testing.T) {
+	tests := []struct {
+		Version int
+		Level   QRLevel
+		N       int
+	}{
+		{1, QRLevelL, 19},
+		{1, QRLevelM, 16},
+		{1, QRLevelQ, 13},
+		{1, QRLevelH, 9},
+		{7, QRLevelM, 124},
+		{10, QRLevelH, 122},
+		{40, QRLevelL, 2956},
+		{40, QRLevelH, 1276},
+	}
+	for _, test := range tests {
+		if n := qrDataCodewords(test.Version, test.Level); n != test.N {
+			t.Errorf("qrDataCodewords(%d, %v) = %d; want %d", test.Version, test.Level, n, test.N)
+		}
+	}
+}
+
+func TestQRAlignment(t *testing.T) {
+	tests := []struct {
+		Version   int
+		Positions []int
+	}{
+		{1, nil},
+		{2, []int{6, 18}},
+		{7, []int{6, 22, 38}},
+		{32, []int{6, 34, 60, 86, 112, 138}},
+		{40, []int{6, 30, 58, 86, 114, 142, 170}},
+	}
+	for _, test := range tests {
+		pos := qrAlignment(test.Version)
+		if len(pos) != len(test.Positions) {
+			t.Errorf("qrAlignment(%d) = %v; want %v", test.Version, pos, test.Positions)
+			continue
+		}
+		for i := range pos {
+			if pos[i] != test.Positions[i] {
+				t.Errorf("qrAlignment(%d) = %v; want %v", test.Version, pos, test.Positions)
+				break
+			}
+		}
+	}
+}
+
+func TestEncodeQRVersion(t *testing.T) {
+	tests := []struct {
+		Text    string
+		Level   QRLevel
+		Version int
+	}{
+		{"HELLO WORLD", QRLevelQ, 1},
+		// 25 alphanumeric characters fill 1-L, but the same bytes don't.
+		{strings.Repeat("A", 25), QRLevelL, 1},
+		{strings.Repeat("a", 25), QRLevelL, 2},
+		{strings.Repeat("a", 17), QRLevelL, 1},
+		{strings.Repeat("a", 14), QRLevelM, 1},
+		{strings.Repeat("a", 15), QRLevelM, 2},
+		{"http://localhost:8080/2012/sdc/match/qualification/12/team/973/edit", QRLevelM, 5},
+		{strings.Repeat("a", 2953), QRLevelL, 40},
+	}
+	for _, test := range tests {
+		qr, err := EncodeQR(test.Text, test.Level)
+		if err != nil {
+			t.Errorf("EncodeQR(%q, %v) error: %v", test.Text, test.Level, err)
+			continue
+		}
+		if qr.Version != test.Version || qr.Size != test.Version*4+17 {
+			t.Errorf("EncodeQR(%q, %v) version = %d, size = %d; want %d, %d", test.Text, test.Level, qr.Version, qr.Size, test.Version, test.Version*4+17)
+		}
+	}
+
+	if _, err := EncodeQR(strings.Repeat("a", 2954), QRLevelL); err != ErrTooLong {
+		t.Errorf("EncodeQR(2954 bytes, L) error = %v; want %v", err, ErrTooLong)
+	}
+}
+
+// readQRFormat reads the format information beside the top-left finder.
+func readQRFormat(qr *QRCode) int {
+	bits := 0
+	set := func(i int, dark bool) {
+		if dark {
+			bits |= 1 << uint(i)
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		set(i, qr.Dark(8, i))
+	}
+	set(6, qr.Dark(8, 7))
+	set(7, qr.Dark(8, 8))
+	set(8, qr.Dark(7, 8))
+	for i := 9; i < 15; i++ {
+		set(i, qr.Dark(14-i, 8))
+	}
+	return bits
+}
+
+func TestEncodeQRPatterns(t *testing.T) {
+	qr, err := EncodeQR("https://example.com/2012/sdc/match/qualification/1/team/973/edit", QRLevelH)
+	if err != nil {
+		t.Fatal("EncodeQR error:", err)
+	}
+	if qr.Version < 7 {
+		t.Fatalf("qr.Version = %d; want at least 7 to test version information", qr.Version)
+	}
+
+	// Each finder's dark center is ringed by light, then dark.
+	for _, c := range [][2]int{{3, 3}, {qr.Size - 4, 3}, {3, qr.Size - 4}} {
+		for i := -3; i <= 3; i++ {
+			dark := i == -3 || i == 3 || i >= -1 && i <= 1
+			if qr.Dark(c[0]+i, c[1]) != dark || qr.Dark(c[0], c[1]+i) != dark {
+				t.Errorf("finder at %v is wrong at offset %d", c, i)
+			}
+		}
+	}
+	for i := 8; i < qr.Size-8; i++ {
+		if qr.Dark(i, 6) != (i%2 == 0) || qr.Dark(6, i) != (i%2 == 0) {
+			t.Errorf("timing pattern is wrong at %d", i)
+		}
+	}
+	if !qr.Dark(8, qr.Size-8) {
+		t.Error("dark module is light")
+	}
+
+	format := readQRFormat(qr)
+	if format>>13^0x5412>>13 != QRLevelH.formatBits() {
+		t.Errorf("format level bits = %02b; want %02b", format>>13^0x5412>>13, QRLevelH.formatBits())
+	}
+	mask := (format ^ 0x5412) >> 10 & 7
+	if format != qrFormatBits(QRLevelH, mask) {
+		t.Errorf("format = %015b; want %015b", format, qrFormatBits(QRLevelH, mask))
+	}
+
+	// Both copies of the version information
+	version := 0
+	for i := 17; i >= 0; i-- {
+		a, b := qr.Size-11+i%3, i/3
+		if qr.Dark(a, b) != qr.Dark(b, a) {
+			t.Errorf("version information copies differ at bit %d", i)
+		}
+		version <<= 1
+		if qr.Dark(a, b) {
+			version |= 1
+		}
+	}
+	if version != qrVersionBits(qr.Version) {
+		t.Errorf("version information = %018b; want %018b", version, qrVersionBits(qr.Version))
+	}
+}
+
+func TestQRImage(t *testing.T) {
+	qr, err := EncodeQR("HELLO WORLD", QRLevelQ)
+	if err != nil {
+		t.Fatal("EncodeQR error:", err)
+	}
+	img := &QRImage{qr, 4}
+	if b := img.Bounds(); b.Dx() != 84 || b.Dy() != 84 || b.Min.X != 0 || b.Min.Y != 0 {
+		t.Errorf("img.Bounds() = %v; want (0,0)-(84,84)", b)
+	}
+	for _, p := range [][2]int{{0, 0}, {3, 3}, {20, 4}, {35, 9}, {83, 83}} {
+		want := color.Gray{0xff}
+		if qr.Dark(p[0]/4, p[1]/4) {
+			want = color.Gray{0x00}
+		}
+		if c := img.At(p[0], p[1]); c != want {
+			t.Errorf("img.At(%d, %d) = %v; want %v", p[0], p[1], c, want)
+		}
+	}
+	if c := img.At(84, 0); c != nil {
+		t.Errorf("img.At(84, 0) = %v; want nil", c)
+	}
+}
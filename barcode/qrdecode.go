@@ -0,0 +1,877 @@
+// qrdecode.go
+
+package barcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+var (
+	ErrQRNotFound = errors.New("barcode: no QR code found")
+	ErrQRDamaged  = errors.New("barcode: QR code is too damaged to read")
+)
+
+// DecodeQR reads the text of a QR code in an image, such as a photo or
+// screenshot of a screen.  The code may be rotated and seen at a slight
+// angle, but it needs a quiet zone around it.
+func DecodeQR(img image.Image) (string, error) {
+	g := binarize(img)
+	finders := g.findFinders()
+	if len(finders) < 3 {
+		return "", ErrQRNotFound
+	}
+	err := ErrQRNotFound
+	for _, c := range qrFinderTriples(finders) {
+		text, e := g.decodeAt(c)
+		if e == nil {
+			return text, nil
+		}
+		if e != ErrQRNotFound {
+			err = e
+		}
+	}
+	return "", err
+}
+
+// A bitImage is a black and white image.
+type bitImage struct {
+	w, h int
+	dark []bool
+}
+
+func (g *bitImage) at(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.w && y < g.h && g.dark[y*g.w+x]
+}
+
+// binarize thresholds an image at the gray level that best separates its
+// light and dark pixels (Otsu's method).
+func binarize(img image.Image) *bitImage {
+	r := img.Bounds()
+	g := &bitImage{w: r.Dx(), h: r.Dy(), dark: make([]bool, r.Dx()*r.Dy())}
+	gray := make([]uint8, len(g.dark))
+	var hist [256]int
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			v := color.GrayModel.Convert(img.At(r.Min.X+x, r.Min.Y+y)).(color.Gray).Y
+			gray[y*g.w+x] = v
+			hist[v]++
+		}
+	}
+
+	total := float64(len(gray))
+	var sum float64
+	for v, n := range hist {
+		sum += float64(v * n)
+	}
+	threshold, best := 0, -1.0
+	var n0, sum0 float64
+	for v := 0; v < 255; v++ {
+		n0 += float64(hist[v])
+		sum0 += float64(v * hist[v])
+		if n0 == 0 || n0 == total {
+			continue
+		}
+		m0, m1 := sum0/n0, (sum-sum0)/(total-n0)
+		if between := n0 * (total - n0) * (m0 - m1) * (m0 - m1); between > best {
+			threshold, best = v, between
+		}
+	}
+	for i, v := range gray {
+		g.dark[i] = int(v) <= threshold
+	}
+	return g
+}
+
+// runs returns the lengths of the runs of one color along a line of n pixels,
+// starting with a light run (which may be empty).
+func runs(n int, dark func(int) bool) []int {
+	result := []int{0}
+	color := false
+	for i := 0; i < n; i++ {
+		if dark(i) != color {
+			color = !color
+			result = append(result, 0)
+		}
+		result[len(result)-1]++
+	}
+	return result
+}
+
+// A qrPoint is a position in an image, in pixels.
+type qrPoint struct {
+	X, Y float64
+}
+
+func (p qrPoint) dist(q qrPoint) float64 {
+	return math.Hypot(p.X-q.X, p.Y-q.Y)
+}
+
+// A qrFinder is the center of a finder pattern candidate.
+type qrFinder struct {
+	qrPoint
+	Module float64
+	Count  int // number of rows it was seen in
+}
+
+// isFinderRatio reports whether five runs are in the 1:1:3:1:1 proportion of
+// a finder pattern.
+func isFinderRatio(w []int) bool {
+	total := 0
+	for _, n := range w[:5] {
+		total += n
+	}
+	if total < 7 {
+		return false
+	}
+	m := float64(total) / 7
+	for i, n := range w[:5] {
+		want := m
+		if i == 2 {
+			want = 3 * m
+		}
+		if math.Abs(float64(n)-want) >= want/2 {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlignmentRatio reports whether five runs could cross an alignment
+// pattern with modules near m: three runs of one module, between dark runs
+// that may continue into the data beside the pattern.
+func isAlignmentRatio(w []int, m float64) bool {
+	for _, n := range w[1:4] {
+		if math.Abs(float64(n)-m) >= m/2 {
+			return false
+		}
+	}
+	return float64(w[0]) >= m/2 && float64(w[4]) >= m/2
+}
+
+// crossCheck looks along a line through a dark pixel for five runs centered
+// on the pixel's run, stepping by (dx, dy).  The outer runs are cut off at
+// maxRun pixels; any other run longer than that fails.  It returns the runs
+// and the position of the middle run's center relative to the pixel's
+// top-left corner.
+func (g *bitImage) crossCheck(x, y, dx, dy int, maxRun int) (w []int, center float64, ok bool) {
+	if !g.at(x, y) {
+		return nil, 0, false
+	}
+	// run counts up to maxRun+1 pixels of one color from the ith pixel
+	// along the line, going in direction dir.
+	run := func(i, dir int, dark bool) int {
+		n := 0
+		for ; n <= maxRun; n++ {
+			px, py := x+(i+n*dir)*dx, y+(i+n*dir)*dy
+			if px < 0 || py < 0 || px >= g.w || py >= g.h || g.dark[py*g.w+px] != dark {
+				break
+			}
+		}
+		return n
+	}
+	back := run(0, -1, true)
+	light1 := run(-back, -1, false)
+	outer1 := run(-back-light1, -1, true)
+	fwd := run(1, 1, true)
+	light2 := run(1+fwd, 1, false)
+	outer2 := run(1+fwd+light2, 1, true)
+
+	w = []int{outer1, light1, back + fwd, light2, outer2}
+	for i, n := range w {
+		if n == 0 || n > maxRun && i != 0 && i != 4 {
+			return nil, 0, false
+		}
+		if n > maxRun {
+			w[i] = maxRun
+		}
+	}
+	return w, float64(fwd-back+2) / 2, true
+}
+
+// findFinders scans the rows of the image for finder patterns and confirms
+// them along the columns.
+func (g *bitImage) findFinders() []qrFinder {
+	var finders []qrFinder
+	for y := 0; y < g.h; y++ {
+		row := runs(g.w, func(x int) bool { return g.at(x, y) })
+		x := row[0]
+		for i := 1; i+4 < len(row); i += 2 {
+			w := row[i : i+5]
+			if isFinderRatio(w) {
+				cx := x + w[0] + w[1] + w[2]/2
+				total := w[0] + w[1] + w[2] + w[3] + w[4]
+				g.addFinder(&finders, cx, y, total)
+			}
+			x += row[i] + row[i+1]
+		}
+	}
+	return finders
+}
+
+// addFinder confirms a finder pattern seen on a row centered at x, and merges
+// it into the finders found so far.
+func (g *bitImage) addFinder(finders *[]qrFinder, x, y int, width int) {
+	vw, dy, ok := g.crossCheck(x, y, 0, 1, width)
+	if !ok || !isFinderRatio(vw) {
+		return
+	}
+	cy := int(float64(y) + dy)
+	hw, dx, ok := g.crossCheck(x, cy, 1, 0, width)
+	if !ok || !isFinderRatio(hw) {
+		return
+	}
+	height := vw[0] + vw[1] + vw[2] + vw[3] + vw[4]
+	if 5*abs(height-width) >= 2*width {
+		return
+	}
+	f := qrFinder{
+		qrPoint: qrPoint{float64(x) + dx, float64(y) + dy},
+		Module:  float64(width+height) / 14,
+		Count:   1,
+	}
+	for i := range *finders {
+		old := &(*finders)[i]
+		if old.dist(f.qrPoint) < old.Module*2 && math.Abs(old.Module-f.Module) < old.Module/2 {
+			n := float64(old.Count)
+			old.X = (old.X*n + f.X) / (n + 1)
+			old.Y = (old.Y*n + f.Y) / (n + 1)
+			old.Module = (old.Module*n + f.Module) / (n + 1)
+			old.Count++
+			return
+		}
+	}
+	*finders = append(*finders, f)
+}
+
+// qrFinderTriples returns the likeliest sets of three finder patterns, in
+// the order top-left, top-right and bottom-left, best first.
+func qrFinderTriples(finders []qrFinder) [][3]qrFinder {
+	// Prefer finders seen on more rows.
+	sort.Sort(byFinderCount(finders))
+	if len(finders) > 10 {
+		finders = finders[:10]
+	}
+
+	var triples []finderTriple
+	for i := 0; i < len(finders); i++ {
+		for j := i + 1; j < len(finders); j++ {
+			for k := j + 1; k < len(finders); k++ {
+				f := [3]qrFinder{finders[i], finders[j], finders[k]}
+				minModule := math.Min(f[0].Module, math.Min(f[1].Module, f[2].Module))
+				maxModule := math.Max(f[0].Module, math.Max(f[1].Module, f[2].Module))
+				if maxModule > minModule*1.5 {
+					continue
+				}
+
+				// The top-left corner is opposite the longest side.
+				d01, d02, d12 := f[0].dist(f[1].qrPoint), f[0].dist(f[2].qrPoint), f[1].dist(f[2].qrPoint)
+				switch {
+				case d12 >= d01 && d12 >= d02:
+				case d02 >= d01 && d02 >= d12:
+					f[0], f[1] = f[1], f[0]
+				default:
+					f[0], f[2] = f[2], f[0]
+				}
+				a, b := f[0].dist(f[1].qrPoint), f[0].dist(f[2].qrPoint)
+				c := f[1].dist(f[2].qrPoint)
+				if a < maxModule*10 {
+					continue
+				}
+				score := math.Abs(a-b)/math.Max(a, b) + math.Abs(c*c-a*a-b*b)/(c*c)
+
+				// With y increasing downward, top-right to bottom-left turns
+				// clockwise about the top-left.
+				cross := (f[1].X-f[0].X)*(f[2].Y-f[0].Y) - (f[1].Y-f[0].Y)*(f[2].X-f[0].X)
+				if cross < 0 {
+					f[1], f[2] = f[2], f[1]
+				}
+				triples = append(triples, finderTriple{f, score})
+			}
+		}
+	}
+	sort.Sort(byTripleScore(triples))
+
+	result := make([][3]qrFinder, 0, len(triples))
+	for _, t := range triples {
+		if t.score < 0.5 {
+			result = append(result, t.f)
+		}
+	}
+	return result
+}
+
+// A finderTriple is a possible set of a QR code's finder patterns.  Its score
+// is how far the patterns are from an isosceles right triangle.
+type finderTriple struct {
+	f     [3]qrFinder
+	score float64
+}
+
+type byTripleScore []finderTriple
+
+func (t byTripleScore) Len() int           { return len(t) }
+func (t byTripleScore) Less(i, j int) bool { return t[i].score < t[j].score }
+func (t byTripleScore) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+type byFinderCount []qrFinder
+
+func (f byFinderCount) Len() int           { return len(f) }
+func (f byFinderCount) Less(i, j int) bool { return f[i].Count > f[j].Count }
+func (f byFinderCount) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// maxAlignmentCandidates is the most alignment pattern positions that are
+// tried for each version.
+const maxAlignmentCandidates = 4
+
+// decodeAt reads the QR code whose finder patterns are f.
+func (g *bitImage) decodeAt(f [3]qrFinder) (string, error) {
+	module := (g.finderWidth(f[0], f[1].qrPoint) + g.finderWidth(f[1], f[0].qrPoint) +
+		g.finderWidth(f[0], f[2].qrPoint) + g.finderWidth(f[2], f[0].qrPoint)) / 28
+	side := (f[0].dist(f[1].qrPoint) + f[0].dist(f[2].qrPoint)) / 2 / module
+	estimate := int(math.Floor((side+7-17)/4 + 0.5))
+
+	// The estimate can be off by a version or two for large codes.  From
+	// version 7 on, the version information can say which it is, if it can
+	// be read before the code's corners are known.
+	versions := []int{estimate, estimate - 1, estimate + 1, estimate - 2, estimate + 2}
+	if estimate >= 7 {
+		if v, ok := g.readVersion(newAffine(f, estimate*4+17), estimate*4+17); ok && v != estimate {
+			versions = append([]int{v}, versions...)
+		}
+	}
+	err := ErrQRNotFound
+	tried := make(map[int]bool)
+	for _, version := range versions {
+		if version < 1 || version > 40 || tried[version] {
+			continue
+		}
+		tried[version] = true
+		size := version*4 + 17
+		t := newAffine(f, size)
+
+		// Codes seen at an angle need the bottom-right corner's position,
+		// which the alignment pattern closest to it gives.
+		var transforms []qrTransform
+		if version >= 2 {
+			align := g.findAlignment(t, size, module)
+			if len(align) > maxAlignmentCandidates {
+				align = align[:maxAlignmentCandidates]
+			}
+			for _, p := range align {
+				transforms = append(transforms, newPerspective(f, p, size))
+			}
+		}
+		transforms = append(transforms, t)
+		for _, t := range transforms {
+			qr := &QRCode{Version: version, Size: size, modules: make([]bool, size*size)}
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					p := t.Map(float64(x)+0.5, float64(y)+0.5)
+					qr.modules[y*size+x] = g.at(int(math.Floor(p.X)), int(math.Floor(p.Y)))
+				}
+			}
+			text, e := qr.decode()
+			if e == nil {
+				return text, nil
+			}
+			err = e
+		}
+	}
+	return "", err
+}
+
+// finderWidth measures a finder pattern across its center toward a point.
+// The rows and columns of the image needn't line up with the code's, so the
+// width can differ from the one the pattern was found with.
+func (g *bitImage) finderWidth(f qrFinder, toward qrPoint) float64 {
+	d := toward.dist(f.qrPoint)
+	dx, dy := (toward.X-f.X)/d, (toward.Y-f.Y)/d
+	limit := int(f.Module * 10)
+
+	// edge returns the distance to the outside of the pattern: past the dark
+	// center, the light ring and the dark ring.
+	edge := func(dir float64) (float64, bool) {
+		i, color := 0, true
+		for run := 0; run < 3; run++ {
+			for i < limit && g.at(int(math.Floor(f.X+float64(i)*dir*dx)), int(math.Floor(f.Y+float64(i)*dir*dy))) == color {
+				i++
+			}
+			color = !color
+		}
+		return float64(i), i < limit
+	}
+	a, ok1 := edge(1)
+	b, ok2 := edge(-1)
+	if !ok1 || !ok2 {
+		return f.Module * 7
+	}
+	return a + b
+}
+
+// A qrTransform maps module coordinates to image positions.
+type qrTransform interface {
+	Map(x, y float64) qrPoint
+}
+
+// An affineTransform maps module coordinates by the positions of the finder
+// patterns, for codes seen straight on.
+type affineTransform struct {
+	origin, dx, dy qrPoint
+}
+
+func newAffine(f [3]qrFinder, size int) affineTransform {
+	n := float64(size) - 7
+	return affineTransform{
+		origin: qrPoint{f[0].X - 3.5*(f[1].X-f[0].X+f[2].X-f[0].X)/n, f[0].Y - 3.5*(f[1].Y-f[0].Y+f[2].Y-f[0].Y)/n},
+		dx:     qrPoint{(f[1].X - f[0].X) / n, (f[1].Y - f[0].Y) / n},
+		dy:     qrPoint{(f[2].X - f[0].X) / n, (f[2].Y - f[0].Y) / n},
+	}
+}
+
+func (t affineTransform) Map(x, y float64) qrPoint {
+	return qrPoint{t.origin.X + x*t.dx.X + y*t.dy.X, t.origin.Y + x*t.dx.Y + y*t.dy.Y}
+}
+
+// A perspectiveTransform is a projective mapping, for codes seen at an angle.
+type perspectiveTransform struct {
+	a11, a12, a13, a21, a22, a23, a31, a32, a33 float64
+}
+
+// newPerspective returns the transform that maps the finder pattern and
+// bottom-right alignment pattern centers to their positions in the image.
+func newPerspective(f [3]qrFinder, align qrPoint, size int) perspectiveTransform {
+	s := float64(size)
+	src := [4]qrPoint{{3.5, 3.5}, {s - 3.5, 3.5}, {s - 6.5, s - 6.5}, {3.5, s - 3.5}}
+	dst := [4]qrPoint{f[0].qrPoint, f[1].qrPoint, align, f[2].qrPoint}
+	return squareToQuad(dst).times(squareToQuad(src).adjoint())
+}
+
+// squareToQuad returns the transform from the unit square's corners, in
+// order around it, to a quadrilateral's.
+func squareToQuad(q [4]qrPoint) perspectiveTransform {
+	dx3 := q[0].X - q[1].X + q[2].X - q[3].X
+	dy3 := q[0].Y - q[1].Y + q[2].Y - q[3].Y
+	if dx3 == 0 && dy3 == 0 {
+		return perspectiveTransform{
+			q[1].X - q[0].X, q[2].X - q[1].X, q[0].X,
+			q[1].Y - q[0].Y, q[2].Y - q[1].Y, q[0].Y,
+			0, 0, 1,
+		}
+	}
+	dx1, dx2 := q[1].X-q[2].X, q[3].X-q[2].X
+	dy1, dy2 := q[1].Y-q[2].Y, q[3].Y-q[2].Y
+	den := dx1*dy2 - dx2*dy1
+	g := (dx3*dy2 - dx2*dy3) / den
+	h := (dx1*dy3 - dx3*dy1) / den
+	return perspectiveTransform{
+		q[1].X - q[0].X + g*q[1].X, q[3].X - q[0].X + h*q[3].X, q[0].X,
+		q[1].Y - q[0].Y + g*q[1].Y, q[3].Y - q[0].Y + h*q[3].Y, q[0].Y,
+		g, h, 1,
+	}
+}
+
+// adjoint returns a multiple of the inverse transform.
+func (t perspectiveTransform) adjoint() perspectiveTransform {
+	return perspectiveTransform{
+		t.a22*t.a33 - t.a23*t.a32, t.a13*t.a32 - t.a12*t.a33, t.a12*t.a23 - t.a13*t.a22,
+		t.a23*t.a31 - t.a21*t.a33, t.a11*t.a33 - t.a13*t.a31, t.a13*t.a21 - t.a11*t.a23,
+		t.a21*t.a32 - t.a22*t.a31, t.a12*t.a31 - t.a11*t.a32, t.a11*t.a22 - t.a12*t.a21,
+	}
+}
+
+// times returns the transform that applies u, then t.
+func (t perspectiveTransform) times(u perspectiveTransform) perspectiveTransform {
+	return perspectiveTransform{
+		t.a11*u.a11 + t.a12*u.a21 + t.a13*u.a31, t.a11*u.a12 + t.a12*u.a22 + t.a13*u.a32, t.a11*u.a13 + t.a12*u.a23 + t.a13*u.a33,
+		t.a21*u.a11 + t.a22*u.a21 + t.a23*u.a31, t.a21*u.a12 + t.a22*u.a22 + t.a23*u.a32, t.a21*u.a13 + t.a22*u.a23 + t.a23*u.a33,
+		t.a31*u.a11 + t.a32*u.a21 + t.a33*u.a31, t.a31*u.a12 + t.a32*u.a22 + t.a33*u.a32, t.a31*u.a13 + t.a32*u.a23 + t.a33*u.a33,
+	}
+}
+
+func (t perspectiveTransform) Map(x, y float64) qrPoint {
+	w := t.a31*x + t.a32*y + t.a33
+	return qrPoint{(t.a11*x + t.a12*y + t.a13) / w, (t.a21*x + t.a22*y + t.a23) / w}
+}
+
+// findAlignment looks for the alignment pattern nearest the bottom-right
+// corner around where t expects it, widening the search if it isn't there.
+// Data can look like an alignment pattern, so it returns every candidate,
+// nearest the expected position first.
+func (g *bitImage) findAlignment(t qrTransform, size int, module float64) []qrPoint {
+	want := t.Map(float64(size)-6.5, float64(size)-6.5)
+	for _, allowance := range []float64{8, 16} {
+		radius := int(module * allowance)
+		var found byDistance
+		x0 := int(want.X) - radius
+		for y := int(want.Y) - radius; y <= int(want.Y)+radius; y++ {
+			row := runs(radius*2+1, func(i int) bool { return g.at(x0+i, y) })
+			x := x0 + row[0]
+			for i := 1; i+4 < len(row); i += 2 {
+				w := row[i : i+5]
+				if isAlignmentRatio(w, module) {
+					cx := x + w[0] + w[1] + w[2]/2
+					if p, ok := g.checkAlignment(cx, y, module); ok {
+						found.add(p, module)
+					}
+				}
+				x += row[i] + row[i+1]
+			}
+		}
+		if len(found.points) > 0 {
+			found.from = want
+			sort.Sort(found)
+			return found.points
+		}
+	}
+	return nil
+}
+
+// byDistance sorts points by their distance from a point.
+type byDistance struct {
+	points []qrPoint
+	from   qrPoint
+}
+
+// add adds a point unless it is within a module of one already added.
+func (d *byDistance) add(p qrPoint, module float64) {
+	for _, q := range d.points {
+		if p.dist(q) < module {
+			return
+		}
+	}
+	d.points = append(d.points, p)
+}
+
+func (d byDistance) Len() int { return len(d.points) }
+func (d byDistance) Less(i, j int) bool {
+	return d.points[i].dist(d.from) < d.points[j].dist(d.from)
+}
+func (d byDistance) Swap(i, j int) { d.points[i], d.points[j] = d.points[j], d.points[i] }
+
+// checkAlignment confirms an alignment pattern seen on a row centered at x,
+// and returns its center.
+func (g *bitImage) checkAlignment(x, y int, module float64) (qrPoint, bool) {
+	vw, dy, ok := g.crossCheck(x, y, 0, 1, int(module*2))
+	if !ok || !isAlignmentRatio(vw, module) {
+		return qrPoint{}, false
+	}
+	hw, dx, ok := g.crossCheck(x, int(float64(y)+dy), 1, 0, int(module*2))
+	if !ok || !isAlignmentRatio(hw, module) {
+		return qrPoint{}, false
+	}
+	return qrPoint{float64(x) + dx, float64(y) + dy}, true
+}
+
+// readVersion reads the version information beside the top-right finder
+// pattern, correcting up to three wrong bits.
+func (g *bitImage) readVersion(t qrTransform, size int) (int, bool) {
+	bits := 0
+	for i := 17; i >= 0; i-- {
+		p := t.Map(float64(size-11+i%3)+0.5, float64(i/3)+0.5)
+		bits <<= 1
+		if g.at(int(math.Floor(p.X)), int(math.Floor(p.Y))) {
+			bits |= 1
+		}
+	}
+	for v := 7; v <= 40; v++ {
+		if bitCount(bits^qrVersionBits(v)) <= 3 {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func bitCount(x int) int {
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
+
+// decode reads the text of a symbol whose modules have been sampled.
+func (qr *QRCode) decode() (string, error) {
+	// Format information, from whichever copy is closer to a valid one
+	var copies [2]int
+	bit := func(copy, i int, x, y int) {
+		if qr.Dark(x, y) {
+			copies[copy] |= 1 << uint(i)
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		bit(0, i, 8, i)
+	}
+	bit(0, 6, 8, 7)
+	bit(0, 7, 8, 8)
+	bit(0, 8, 7, 8)
+	for i := 9; i < 15; i++ {
+		bit(0, i, 14-i, 8)
+	}
+	for i := 0; i < 8; i++ {
+		bit(1, i, qr.Size-1-i, 8)
+	}
+	for i := 8; i < 15; i++ {
+		bit(1, i, 8, qr.Size-15+i)
+	}
+	mask, bestDist := -1, 4
+	for level := QRLevelL; level <= QRLevelH; level++ {
+		for m := 0; m < 8; m++ {
+			for _, c := range copies {
+				if d := bitCount(c ^ qrFormatBits(level, m)); d < bestDist {
+					qr.Level, mask, bestDist = level, m, d
+				}
+			}
+		}
+	}
+	if mask < 0 {
+		return "", ErrQRNotFound
+	}
+
+	// Codewords
+	sampled := qr.modules
+	qr.modules = make([]bool, len(sampled))
+	function := qr.drawFunctions()
+	qr.modules = sampled
+	qr.applyMask(mask, function)
+	codewords := make([]byte, qrRawModules(qr.Version)/8)
+	for i, m := range qrDataOrder(qr.Size, function) {
+		if i >= len(codewords)*8 {
+			break
+		}
+		if qr.modules[m] {
+			codewords[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	data, err := qrDeinterleave(codewords, qr.Version, qr.Level)
+	if err != nil {
+		return "", err
+	}
+	return qrSegments(data, qr.Version)
+}
+
+// qrDeinterleave separates interleaved codewords into blocks, corrects each
+// block's errors, and returns the data codewords.
+func qrDeinterleave(codewords []byte, version int, level QRLevel) ([]byte, error) {
+	numBlocks := qrBlocks[level][version]
+	eccLen := qrECCPerBlock[level][version]
+	numShort := numBlocks - len(codewords)%numBlocks
+	shortLen := len(codewords) / numBlocks
+
+	blocks := make([][]byte, numBlocks)
+	for i := range blocks {
+		n := shortLen
+		if i >= numShort {
+			n++
+		}
+		blocks[i] = make([]byte, 0, n)
+	}
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := range blocks {
+			// Short blocks have no codeword where the long blocks' last
+			// data codeword is.
+			if i == shortLen-eccLen && j < numShort {
+				continue
+			}
+			if k < len(codewords) {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+
+	var data []byte
+	for _, block := range blocks {
+		if !rsCorrect(block, eccLen) {
+			return nil, ErrQRDamaged
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+	return data, nil
+}
+
+// rsCorrect corrects the errors in a block of codewords that ends with ecc
+// Reed-Solomon codewords.  It reports false if there are too many errors.
+func rsCorrect(block []byte, ecc int) bool {
+	// Syndromes: the block's polynomial evaluated at the generator's roots
+	syndromes := make([]byte, ecc)
+	clean := true
+	for i := range syndromes {
+		x := gfPow(2, i)
+		var s byte
+		for _, c := range block {
+			s = gfMul(s, x) ^ c
+		}
+		syndromes[i] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return true
+	}
+
+	// Error locator polynomial by Berlekamp-Massey, lowest power first
+	locator, prev := []byte{1}, []byte{1}
+	errs, shift, scale := 0, 1, byte(1)
+	for n := 0; n < ecc; n++ {
+		d := syndromes[n]
+		for i := 1; i <= errs && i < len(locator); i++ {
+			d ^= gfMul(locator[i], syndromes[n-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		next := append([]byte(nil), locator...)
+		for len(next) < len(prev)+shift {
+			next = append(next, 0)
+		}
+		f := gfMul(d, gfInv(scale))
+		for i, c := range prev {
+			next[i+shift] ^= gfMul(f, c)
+		}
+		if 2*errs <= n {
+			errs, prev, scale, shift = n+1-errs, locator, d, 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if errs > ecc/2 {
+		return false
+	}
+
+	// Error evaluator: syndromes times locator, modulo x^ecc
+	evaluator := make([]byte, ecc)
+	for i := range evaluator {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMul(locator[j], syndromes[i-j])
+		}
+	}
+
+	// Find the locator's roots by trying every position (Chien search) and
+	// their error values (Forney's formula).
+	found := 0
+	for pos := 0; pos < len(block); pos++ {
+		x := gfPow(2, len(block)-1-pos)
+		xInv := gfInv(x)
+		if gfEval(locator, xInv) != 0 {
+			continue
+		}
+		var derivative byte
+		for i := 1; i < len(locator); i += 2 {
+			derivative ^= gfMul(locator[i], gfPow(xInv, i-1))
+		}
+		if derivative == 0 {
+			return false
+		}
+		block[pos] ^= gfMul(x, gfMul(gfEval(evaluator, xInv), gfInv(derivative)))
+		found++
+	}
+	return found == errs
+}
+
+// gfEval evaluates a polynomial, lowest power first, at x.
+func gfEval(p []byte, x byte) byte {
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return y
+}
+
+func gfPow(x byte, n int) byte {
+	y := byte(1)
+	for ; n > 0; n-- {
+		y = gfMul(y, x)
+	}
+	return y
+}
+
+func gfInv(x byte) byte {
+	return gfPow(x, 254)
+}
+
+// qrSegments decodes the segments of a symbol's data codewords.  Numeric,
+// alphanumeric and byte segments are supported; bytes are taken as UTF-8.
+func qrSegments(data []byte, version int) (string, error) {
+	pos := 0
+	read := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v <<= 1
+			if pos < len(data)*8 && data[pos/8]&(0x80>>uint(pos%8)) != 0 {
+				v |= 1
+			}
+			pos++
+		}
+		return v
+	}
+	left := func() int { return len(data)*8 - pos }
+
+	var text bytes.Buffer
+	for left() >= 4 {
+		mode := read(4)
+		switch mode {
+		case 0:
+			return text.String(), nil
+		case 0x7:
+			// Extended channel interpretation: assume UTF-8 anyway.
+			if read(8)&0x80 != 0 {
+				read(8)
+			}
+		case qrModeNumeric:
+			n := read(qrCountBits(qrModeNumeric, version))
+			for ; n >= 3; n -= 3 {
+				v := read(10)
+				text.WriteByte(byte('0' + v/100))
+				text.WriteByte(byte('0' + v/10%10))
+				text.WriteByte(byte('0' + v%10))
+			}
+			if n == 2 {
+				v := read(7)
+				text.WriteByte(byte('0' + v/10))
+				text.WriteByte(byte('0' + v%10))
+			} else if n == 1 {
+				text.WriteByte(byte('0' + read(4)))
+			}
+		case qrModeAlphanumeric:
+			n := read(qrCountBits(qrModeAlphanumeric, version))
+			for ; n >= 2; n -= 2 {
+				v := read(11)
+				if v >= 45*45 {
+					return "", ErrQRDamaged
+				}
+				text.WriteByte(qrAlphanumeric[v/45])
+				text.WriteByte(qrAlphanumeric[v%45])
+			}
+			if n == 1 {
+				v := read(6)
+				if v >= 45 {
+					return "", ErrQRDamaged
+				}
+				text.WriteByte(qrAlphanumeric[v])
+			}
+		case qrModeByte:
+			n := read(qrCountBits(qrModeByte, version))
+			if n*8 > left() {
+				return "", ErrQRDamaged
+			}
+			for ; n > 0; n-- {
+				text.WriteByte(byte(read(8)))
+			}
+		default:
+			return "", errors.New("barcode: unsupported QR code segment")
+		}
+		if left() < 0 {
+			return "", ErrQRDamaged
+		}
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,177 @@
+package barcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// qrTestImage renders a QR code with a quiet zone, mapping each pixel of the
+// result back through inverse to the code's module coordinates.  Pixels are
+// supersampled, so edges are gray as they are in photos.
+func qrTestImage(qr *QRCode, width, height int, inverse func(x, y float64) (float64, float64)) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	const samples = 3
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dark := 0
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					mx, my := inverse(float64(x)+(float64(sx)+0.5)/samples, float64(y)+(float64(sy)+0.5)/samples)
+					i, j := int(math.Floor(mx)), int(math.Floor(my))
+					if i >= 0 && j >= 0 && i < qr.Size && j < qr.Size && qr.Dark(i, j) {
+						dark++
+					}
+				}
+			}
+			img.SetGray(x, y, color.Gray{uint8(230 - 200*dark/(samples*samples))})
+		}
+	}
+	return img
+}
+
+// rotatedQRImage renders a QR code scaled to scale pixels per module and
+// rotated by angle radians about its center.
+func rotatedQRImage(qr *QRCode, scale, angle float64) *image.Gray {
+	size := int(math.Ceil((float64(qr.Size) + 8) * scale * (math.Abs(math.Cos(angle)) + math.Abs(math.Sin(angle)))))
+	c := float64(size) / 2
+	sin, cos := math.Sincos(-angle)
+	return qrTestImage(qr, size, size, func(x, y float64) (float64, float64) {
+		x, y = x-c, y-c
+		x, y = x*cos-y*sin, x*sin+y*cos
+		return x/scale + float64(qr.Size)/2, y/scale + float64(qr.Size)/2
+	})
+}
+
+func TestDecodeQRRoundTrip(t *testing.T) {
+	tests := []struct {
+		Text  string
+		Level QRLevel
+	}{
+		{"HELLO WORLD", QRLevelQ},
+		{"http://localhost:8080/2012/sdc/match/qualification/12/team/973/edit", QRLevelM},
+		{strings.Repeat("0123456789abcdef", 20), QRLevelL},
+		{strings.Repeat("GREYHOUND SCOUTING ", 40), QRLevelH},
+		{"\x00\xffé", QRLevelM},
+	}
+	for _, test := range tests {
+		qr, err := EncodeQR(test.Text, test.Level)
+		if err != nil {
+			t.Errorf("EncodeQR(%q, %v) error: %v", test.Text, test.Level, err)
+			continue
+		}
+		for _, scale := range []float64{3, 4.4} {
+			for _, angle := range []float64{0, 0.2, math.Pi / 2, math.Pi, 4.5} {
+				img := rotatedQRImage(qr, scale, angle)
+				if text, err := DecodeQR(img); err != nil {
+					t.Errorf("DecodeQR(version %d-%v at %.1fpx, %.1f rad) error: %v", qr.Version, test.Level, scale, angle, err)
+				} else if text != test.Text {
+					t.Errorf("DecodeQR(version %d-%v at %.1fpx, %.1f rad) = %q; want %q", qr.Version, test.Level, scale, angle, text, test.Text)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeQRPerspective(t *testing.T) {
+	const text = "sdc20121001973 3 1,0,2,4,0,1 Scout"
+	qr, err := EncodeQR(text, QRLevelM)
+	if err != nil {
+		t.Fatal("EncodeQR error:", err)
+	}
+	// A photo of a screen tilted away at the top and turned a little
+	n := float64(qr.Size)
+	corners := squareToQuad([4]qrPoint{{80, 50}, {225, 60}, {250, 235}, {55, 220}})
+	toImage := corners.times(perspectiveTransform{1 / n, 0, 0, 0, 1 / n, 0, 0, 0, 1})
+	toModules := toImage.adjoint()
+	img := qrTestImage(qr, 300, 300, func(x, y float64) (float64, float64) {
+		p := toModules.Map(x, y)
+		return p.X, p.Y
+	})
+	if got, err := DecodeQR(img); err != nil {
+		t.Error("DecodeQR error:", err)
+	} else if got != text {
+		t.Errorf("DecodeQR = %q; want %q", got, text)
+	}
+}
+
+func TestDecodeQRDamaged(t *testing.T) {
+	const text = "https://example.com/2012/sdc/match/qualification/1/team/973/edit"
+	qr, err := EncodeQR(text, QRLevelH)
+	if err != nil {
+		t.Fatal("EncodeQR error:", err)
+	}
+	// A blot over the middle of the code
+	for y := qr.Size/2 - 3; y < qr.Size/2+3; y++ {
+		for x := qr.Size/2 - 3; x < qr.Size/2+3; x++ {
+			qr.modules[y*qr.Size+x] = true
+		}
+	}
+	img := rotatedQRImage(qr, 4, 0)
+	if got, err := DecodeQR(img); err != nil {
+		t.Error("DecodeQR error:", err)
+	} else if got != text {
+		t.Errorf("DecodeQR = %q; want %q", got, text)
+	}
+}
+
+func TestDecodeQRNotFound(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	if _, err := DecodeQR(img); err != ErrQRNotFound {
+		t.Errorf("DecodeQR(blank) error = %v; want %v", err, ErrQRNotFound)
+	}
+	if _, err := DecodeQR(scanTestImage(Encode("sdc20121001973"), 3, 1, 20, 0)); err != ErrQRNotFound {
+		t.Errorf("DecodeQR(Code 128) error = %v; want %v", err, ErrQRNotFound)
+	}
+}
+
+func TestRSCorrect(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, ecc := range []int{7, 10, 22, 30} {
+		data := make([]byte, 40)
+		r.Read(data)
+		block := append(append([]byte(nil), data...), rsRemainder(data, rsGenerator(ecc))...)
+		for errs := 0; errs <= ecc/2+1; errs++ {
+			damaged := append([]byte(nil), block...)
+			for _, i := range r.Perm(len(block))[:errs] {
+				damaged[i] ^= byte(1 + r.Intn(255))
+			}
+			ok := rsCorrect(damaged, ecc)
+			if errs <= ecc/2 {
+				if !ok || !bytes.Equal(damaged, block) {
+					t.Errorf("rsCorrect(%d errors, %d ecc) = %t, corrected = %t; want true, true", errs, ecc, ok, bytes.Equal(damaged, block))
+				}
+			} else if ok && bytes.Equal(damaged, block) {
+				t.Errorf("rsCorrect(%d errors, %d ecc) corrected more errors than it can", errs, ecc)
+			}
+		}
+	}
+}
+
+func TestQRSegments(t *testing.T) {
+	// Numeric "01234567" then byte "é", as other encoders write them
+	var b bitBuffer
+	b.append(qrModeNumeric, 4)
+	b.append(8, qrCountBits(qrModeNumeric, 1))
+	b.append(12, 10)
+	b.append(345, 10)
+	b.append(67, 7)
+	b.append(qrModeByte, 4)
+	b.append(2, 8)
+	b.append(0xc3, 8)
+	b.append(0xa9, 8)
+	b.append(0, 4)
+	b.append(0, (8-len(b)%8)%8)
+	if text, err := qrSegments(b.bytes(), 1); err != nil {
+		t.Error("qrSegments error:", err)
+	} else if text != "01234567é" {
+		t.Errorf("qrSegments = %q; want %q", text, "01234567é")
+	}
+}
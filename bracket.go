@@ -0,0 +1,490 @@
+package main
+
+import (
+	"code.google.com/p/gorilla/mux"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An EliminationAlliance is a group of teams that play together in the
+// elimination matches.  Alliances are seeded in the order that their
+// captains were chosen.
+type EliminationAlliance struct {
+	Captain int
+	Picks   []int `bson:",omitempty"`
+}
+
+// Teams returns the captain followed by the picks.
+func (a EliminationAlliance) Teams() []int {
+	return append([]int{a.Captain}, a.Picks...)
+}
+
+// Elimination bracket limits
+const (
+	maxAlliances  = 8
+	alliancePicks = 2
+	seriesWins    = 2 // wins needed to take a best-of-three series
+)
+
+// eliminationRounds lists the elimination match types in the order that they
+// are played.  Brackets with fewer alliances skip the early rounds.
+var eliminationRounds = []MatchType{QuarterFinal, SemiFinal, Final}
+
+// A Bracket is the state of an event's elimination tournament.
+type Bracket struct {
+	Rounds []BracketRound
+}
+
+// A BracketRound is the series played with one match type.
+type BracketRound struct {
+	Type   MatchType
+	Series []*Series
+}
+
+// A Series is a best-of-three set of matches between two alliances.
+// Matches in a round are numbered by game and then by series: with four
+// series, game 1 of each series is numbered 1-4 and game 2 is numbered 5-8.
+type Series struct {
+	Type   MatchType
+	Number int // position within the round, starting at 1
+
+	// Red and Blue are nil until the alliance is known.  The higher seed
+	// plays as red.
+	Red  *SeriesAlliance
+	Blue *SeriesAlliance
+
+	// Bye is true if the series has only one alliance, which advances
+	// without playing.
+	Bye bool
+
+	Matches []*Match
+	Winner  *SeriesAlliance
+}
+
+// A SeriesAlliance is an alliance's place in a series.
+type SeriesAlliance struct {
+	Seed  int
+	Teams []int
+	Wins  int
+}
+
+// Decided reports whether the series has a winner.
+func (s *Series) Decided() bool {
+	return s.Winner != nil
+}
+
+// Won reports whether an alliance won the series.
+func (s *Series) Won(a *SeriesAlliance) bool {
+	return a != nil && s.Winner == a
+}
+
+// bracketSeeds returns the first-round seed order for a bracket of size
+// alliances, so that the top seeds meet as late as possible.  Adjacent seeds
+// play each other.
+func bracketSeeds(size int) []int {
+	seeds := []int{1, 2}
+	for n := 4; n <= size; n *= 2 {
+		next := make([]int, 0, n)
+		for _, s := range seeds {
+			next = append(next, s, n+1-s)
+		}
+		seeds = next
+	}
+	return seeds
+}
+
+// buildBracket fills in an event's elimination bracket from its alliances
+// and the scored elimination matches.  It returns nil if the event doesn't
+// have at least two alliances.
+func buildBracket(event *Event, matches []*Match) *Bracket {
+	n := len(event.Alliances)
+	if n < 2 {
+		return nil
+	}
+	size := 2
+	for size < n {
+		size *= 2
+	}
+	rounds := 0
+	for s := size; s > 1; s /= 2 {
+		rounds++
+	}
+	types := eliminationRounds[len(eliminationRounds)-rounds:]
+
+	seriesAlliance := func(seed int) *SeriesAlliance {
+		if seed > n {
+			return nil
+		}
+		return &SeriesAlliance{Seed: seed, Teams: event.Alliances[seed-1].Teams()}
+	}
+
+	b := &Bracket{Rounds: make([]BracketRound, len(types))}
+	seeds := bracketSeeds(size)
+	for r, t := range types {
+		count := size >> uint(r+1)
+		round := &b.Rounds[r]
+		round.Type = t
+		round.Series = make([]*Series, count)
+		for i := range round.Series {
+			s := &Series{Type: t, Number: i + 1}
+			if r == 0 {
+				s.Red, s.Blue = seriesAlliance(seeds[2*i]), seriesAlliance(seeds[2*i+1])
+			} else {
+				prev := b.Rounds[r-1].Series
+				s.Red, s.Blue = prev[2*i].Winner, prev[2*i+1].Winner
+				if s.Red != nil && s.Blue != nil && s.Blue.Seed < s.Red.Seed {
+					s.Red, s.Blue = s.Blue, s.Red
+				}
+				if s.Red != nil {
+					s.Red = &SeriesAlliance{Seed: s.Red.Seed, Teams: s.Red.Teams}
+				}
+				if s.Blue != nil {
+					s.Blue = &SeriesAlliance{Seed: s.Blue.Seed, Teams: s.Blue.Teams}
+				}
+			}
+			round.Series[i] = s
+		}
+
+		// Tally matches
+		for _, m := range matches {
+			if m.Type != t || m.Number < 1 {
+				continue
+			}
+			s := round.Series[(m.Number-1)%count]
+			s.Matches = append(s.Matches, m)
+		}
+		for _, s := range round.Series {
+			sort.Sort(matchesByNumber(s.Matches))
+			s.tally(r == 0)
+		}
+	}
+	return b
+}
+
+// tally counts the series' wins and decides its winner.  Byes are only
+// possible in the first round.
+func (s *Series) tally(firstRound bool) {
+	if firstRound && (s.Red == nil) != (s.Blue == nil) {
+		s.Bye = true
+		s.Winner = s.Red
+		if s.Winner == nil {
+			s.Winner = s.Blue
+		}
+		return
+	}
+	if s.Red == nil || s.Blue == nil {
+		return
+	}
+	for _, m := range s.Matches {
+		if s.Winner != nil {
+			break
+		}
+		switch m.Winner() {
+		case Red:
+			s.Red.Wins++
+		case Blue:
+			s.Blue.Wins++
+		}
+		if s.Red.Wins >= seriesWins {
+			s.Winner = s.Red
+		} else if s.Blue.Wins >= seriesWins {
+			s.Winner = s.Blue
+		}
+	}
+}
+
+type matchesByNumber []*Match
+
+func (slice matchesByNumber) Len() int {
+	return len(slice)
+}
+
+func (slice matchesByNumber) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice matchesByNumber) Less(i, j int) bool {
+	return slice[i].Number < slice[j].Number
+}
+
+// Champion returns the alliance that won the final, or nil if the final
+// hasn't been decided.
+func (b *Bracket) Champion() *SeriesAlliance {
+	final := b.Rounds[len(b.Rounds)-1].Series[0]
+	return final.Winner
+}
+
+// nextMatches returns the matches that should be scheduled next: the first
+// two games of every series whose alliances are known, and another game for
+// every undecided series whose matches have all been scored.  Ties are
+// replayed, so a series may need more than three games.
+func (b *Bracket) nextMatches() []*Match {
+	var next []*Match
+	for _, round := range b.Rounds {
+		count := len(round.Series)
+		for _, s := range round.Series {
+			if s.Red == nil || s.Blue == nil || s.Decided() {
+				continue
+			}
+			games := 0
+			pending := false
+			for _, m := range s.Matches {
+				if g := (m.Number-1)/count + 1; g > games {
+					games = g
+				}
+				pending = pending || m.Score == nil
+			}
+			for games < seriesWins || !pending {
+				games++
+				next = append(next, s.newMatch((games-1)*count+s.Number))
+				pending = true
+			}
+		}
+	}
+	return next
+}
+
+// newMatch returns a match in the series between the two alliances.
+func (s *Series) newMatch(number int) *Match {
+	m := &Match{Type: s.Type, Number: number}
+	for _, t := range s.Red.Teams {
+		m.Teams = append(m.Teams, TeamInfo{Team: t, Alliance: Red})
+	}
+	for _, t := range s.Blue.Teams {
+		m.Teams = append(m.Teams, TeamInfo{Team: t, Alliance: Blue})
+	}
+	return m
+}
+
+// unplayedMatch returns the match that should be scheduled with a type and
+// number, or nil if the match's series is decided or doesn't know both of
+// its alliances yet.
+func (b *Bracket) unplayedMatch(t MatchType, number int) *Match {
+	if number < 1 {
+		return nil
+	}
+	for _, round := range b.Rounds {
+		if round.Type != t {
+			continue
+		}
+		s := round.Series[(number-1)%len(round.Series)]
+		if s.Red == nil || s.Blue == nil || s.Decided() {
+			return nil
+		}
+		return s.newMatch(number)
+	}
+	return nil
+}
+
+// sameTeams reports whether two matches have the same teams on the same
+// alliances, in order.
+func sameTeams(m1, m2 *Match) bool {
+	if len(m1.Teams) != len(m2.Teams) {
+		return false
+	}
+	for i := range m1.Teams {
+		if m1.Teams[i].Team != m2.Teams[i].Team || m1.Teams[i].Alliance != m2.Teams[i].Alliance {
+			return false
+		}
+	}
+	return true
+}
+
+// advanceBracket brings the unplayed elimination matches at an event up to
+// date with its alliances and scores.  Unplayed matches of a series that
+// has been decided or is missing an alliance are deleted, unplayed matches
+// whose alliances changed are rewritten, and then the next matches are
+// scheduled.  Scored matches are never changed.  It returns the number of
+// matches that were deleted, rewritten or added.
+func advanceBracket(store Datastore, event *Event) (int, error) {
+	matches, err := store.FetchMatches(event.Tag())
+	if err != nil {
+		return 0, err
+	}
+	b := buildBracket(event, matches)
+	if b == nil {
+		return 0, nil
+	}
+
+	// Fix unplayed matches
+	n := 0
+	for _, m := range matches {
+		if m.Type == Qualification || m.Score != nil {
+			continue
+		}
+		want := b.unplayedMatch(m.Type, m.Number)
+		switch {
+		case want == nil:
+			if err := store.DeleteMatch(MatchTag{event.Tag(), m.Type, uint(m.Number)}); err != nil {
+				return n, err
+			}
+			n++
+		case !sameTeams(m, want):
+			if err := store.UpsertMatch(event.Tag(), want); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		if matches, err = store.FetchMatches(event.Tag()); err != nil {
+			return n, err
+		}
+		b = buildBracket(event, matches)
+	}
+
+	// Schedule next matches
+	for _, m := range b.nextMatches() {
+		if err := store.UpsertMatch(event.Tag(), m); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// validateAlliances checks that alliances only have teams from the event
+// and that no team is on more than one alliance.
+func validateAlliances(event *Event, alliances []EliminationAlliance) error {
+	if len(alliances) == 1 || len(alliances) > maxAlliances {
+		return errors.New("There must be between 2 and " + strconv.Itoa(maxAlliances) + " alliances")
+	}
+	atEvent := make(map[int]bool, len(event.Teams))
+	for _, t := range event.Teams {
+		atEvent[t] = true
+	}
+	seen := make(map[int]bool)
+	for i, a := range alliances {
+		for _, t := range a.Teams() {
+			if !atEvent[t] {
+				return errors.New("Alliance " + strconv.Itoa(i+1) + ": team " + strconv.Itoa(t) + " is not at this event")
+			}
+			if seen[t] {
+				return errors.New("Alliance " + strconv.Itoa(i+1) + ": team " + strconv.Itoa(t) + " is already on an alliance")
+			}
+			seen[t] = true
+		}
+	}
+	return nil
+}
+
+// parseAlliancesForm parses the alliance selection form.  Alliances are
+// numbered by seed from 1 and have a "Captain" value and several "Pick"
+// values.  Blank picks are skipped, and the alliances end at the first blank
+// captain.
+func parseAlliancesForm(req *http.Request) ([]EliminationAlliance, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	parseTeam := func(seed int, s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, errors.New("Alliance " + strconv.Itoa(seed) + ": bad team number " + strconv.Quote(s))
+		}
+		return n, nil
+	}
+
+	var alliances []EliminationAlliance
+	for seed := 1; seed <= maxAlliances; seed++ {
+		prefix := "Alliance." + strconv.Itoa(seed) + "."
+		s := strings.TrimSpace(req.Form.Get(prefix + "Captain"))
+		if s == "" {
+			break
+		}
+		captain, err := parseTeam(seed, s)
+		if err != nil {
+			return nil, err
+		}
+		a := EliminationAlliance{Captain: captain}
+		for _, s := range req.Form[prefix+"Pick"] {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			pick, err := parseTeam(seed, s)
+			if err != nil {
+				return nil, err
+			}
+			a.Picks = append(a.Picks, pick)
+		}
+		alliances = append(alliances, a)
+	}
+	return alliances, nil
+}
+
+// An allianceRow is a row of the alliance selection form.
+type allianceRow struct {
+	Seed    int
+	Captain int
+	Picks   []int
+}
+
+// eventAlliances records the alliances chosen for the elimination matches
+// and schedules the first elimination round.
+func eventAlliances(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var formError error
+	alliances := event.Alliances
+	if req.Method == "POST" {
+		alliances, formError = parseAlliancesForm(req)
+		if formError == nil {
+			formError = validateAlliances(event, alliances)
+		}
+		if formError == nil {
+			// Save
+			event.Alliances = alliances
+			if err := server.Store().UpsertEvent(event); err != nil {
+				return err
+			}
+			if _, err := advanceBracket(server.Store(), event); err != nil {
+				return err
+			}
+
+			// Redirect
+			u, err := server.GetRoute("event.view").URL("year", strconv.Itoa(event.Date.Year), "location", event.Location.Code)
+			if err != nil {
+				return err
+			}
+			u.Fragment = "bracket"
+			http.Redirect(w, req, u.String(), http.StatusFound)
+			return nil
+		}
+	}
+
+	rows := make([]allianceRow, maxAlliances)
+	for i := range rows {
+		rows[i] = allianceRow{Seed: i + 1, Picks: make([]int, alliancePicks)}
+		if i < len(alliances) {
+			rows[i].Captain = alliances[i].Captain
+			copy(rows[i].Picks, alliances[i].Picks)
+		}
+	}
+
+	// Fetch matches for rankings
+	matches, err := server.Store().FetchMatches(event.Tag())
+	if err != nil {
+		return err
+	}
+
+	return server.Templates().ExecuteTemplate(w, "event-alliances.html", map[string]interface{}{
+		"Server":   server,
+		"Request":  req,
+		"Event":    event,
+		"Rows":     rows,
+		"Rankings": computeStandings(eventGame(event), matches),
+		"Error":    formError,
+	})
+}
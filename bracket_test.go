@@ -0,0 +1,306 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testAlliances returns n alliances where alliance k has captain k and picks
+// n+k and 2n+k.
+func testAlliances(n int) []EliminationAlliance {
+	alliances := make([]EliminationAlliance, n)
+	for i := range alliances {
+		alliances[i] = EliminationAlliance{Captain: i + 1, Picks: []int{n + i + 1, 2*n + i + 1}}
+	}
+	return alliances
+}
+
+func scoredMatch(m *Match, red, blue int) *Match {
+	m.Score = map[string]int{"red": red, "blue": blue}
+	return m
+}
+
+func seriesSeeds(s *Series) [2]int {
+	var seeds [2]int
+	if s.Red != nil {
+		seeds[0] = s.Red.Seed
+	}
+	if s.Blue != nil {
+		seeds[1] = s.Blue.Seed
+	}
+	return seeds
+}
+
+func TestBracketSeeds(t *testing.T) {
+	tests := []struct {
+		Size  int
+		Seeds []int
+	}{
+		{2, []int{1, 2}},
+		{4, []int{1, 4, 2, 3}},
+		{8, []int{1, 8, 4, 5, 2, 7, 3, 6}},
+	}
+	for _, tt := range tests {
+		if seeds := bracketSeeds(tt.Size); !reflect.DeepEqual(seeds, tt.Seeds) {
+			t.Errorf("bracketSeeds(%d) = %v (expected %v)", tt.Size, seeds, tt.Seeds)
+		}
+	}
+}
+
+func TestBuildBracket(t *testing.T) {
+	event := newTestEvent("sdc", 2012, 3, 15)
+	event.Alliances = testAlliances(8)
+	b := buildBracket(event, nil)
+	var seeds [][2]int
+	for _, s := range b.Rounds[0].Series {
+		seeds = append(seeds, seriesSeeds(s))
+	}
+	if expected := [][2]int{{1, 8}, {4, 5}, {2, 7}, {3, 6}}; !reflect.DeepEqual(seeds, expected) {
+		t.Fatalf("quarter-final seeds = %v (expected %v)", seeds, expected)
+	}
+	next := b.nextMatches()
+	if len(next) != 8 {
+		t.Fatalf("len(nextMatches) = %d (expected 8)", len(next))
+	}
+	if m := next[1]; m.Type != QuarterFinal || m.Number != 5 || !reflect.DeepEqual(allianceTeams(m, Red), []int{1, 9, 17}) || !reflect.DeepEqual(allianceTeams(m, Blue), []int{8, 16, 24}) {
+		t.Errorf("nextMatches()[1] = %+v", m)
+	}
+
+	// 5 beats 4 in two games, 1 and 8 split two games, and 2 sweeps 7.
+	matches := []*Match{
+		scoredMatch(newTestMatch(QuarterFinal, 1, 1, 9, 17, 8, 16, 24), 50, 20),
+		scoredMatch(newTestMatch(QuarterFinal, 2, 4, 12, 20, 5, 13, 21), 10, 20),
+		scoredMatch(newTestMatch(QuarterFinal, 3, 2, 10, 18, 7, 15, 23), 30, 20),
+		scoredMatch(newTestMatch(QuarterFinal, 5, 1, 9, 17, 8, 16, 24), 20, 50),
+		scoredMatch(newTestMatch(QuarterFinal, 6, 4, 12, 20, 5, 13, 21), 10, 20),
+		scoredMatch(newTestMatch(QuarterFinal, 7, 2, 10, 18, 7, 15, 23), 30, 20),
+		newTestMatch(QuarterFinal, 4, 3, 11, 19, 6, 14, 22),
+		newTestMatch(QuarterFinal, 8, 3, 11, 19, 6, 14, 22),
+	}
+	b = buildBracket(event, matches)
+	qf := b.Rounds[0].Series
+	if qf[0].Decided() || qf[0].Red.Wins != 1 || qf[0].Blue.Wins != 1 {
+		t.Errorf("QF1 = %+v (expected tied 1-1)", qf[0])
+	}
+	if !qf[1].Won(qf[1].Blue) || qf[1].Blue.Wins != 2 {
+		t.Errorf("QF2 winner = %+v (expected seed 5)", qf[1].Winner)
+	}
+	sf := b.Rounds[1].Series
+	if seeds := seriesSeeds(sf[1]); seeds != [2]int{2, 0} {
+		t.Errorf("SF2 seeds = %v (expected [2 0])", seeds)
+	}
+	if sf[1].Red.Wins != 0 {
+		t.Errorf("SF2 red wins = %d (expected a fresh series)", sf[1].Red.Wins)
+	}
+
+	next = b.nextMatches()
+	var numbers []int
+	for _, m := range next {
+		numbers = append(numbers, m.Number)
+	}
+	if !reflect.DeepEqual(numbers, []int{9}) || next[0].Type != QuarterFinal {
+		t.Errorf("nextMatches() numbers = %v (expected [9])", numbers)
+	}
+	if b.Champion() != nil {
+		t.Errorf("Champion() = %+v (expected nil)", b.Champion())
+	}
+}
+
+func TestBuildBracketByes(t *testing.T) {
+	event := newTestEvent("sdc", 2012, 3, 15)
+	event.Alliances = testAlliances(3)
+	matches := []*Match{
+		scoredMatch(newTestMatch(SemiFinal, 2, 2, 5, 8, 3, 6, 9), 10, 10),
+		scoredMatch(newTestMatch(SemiFinal, 4, 2, 5, 8, 3, 6, 9), 10, 20),
+		scoredMatch(newTestMatch(SemiFinal, 6, 2, 5, 8, 3, 6, 9), 10, 20),
+	}
+	b := buildBracket(event, matches)
+	if len(b.Rounds) != 2 || b.Rounds[0].Type != SemiFinal || b.Rounds[1].Type != Final {
+		t.Fatalf("rounds = %+v", b.Rounds)
+	}
+	if s := b.Rounds[0].Series[0]; !s.Bye || s.Winner.Seed != 1 {
+		t.Errorf("SF1 = %+v (expected bye for seed 1)", s)
+	}
+	if s := b.Rounds[0].Series[1]; !s.Won(s.Blue) {
+		t.Errorf("SF2 winner = %+v (expected seed 3)", s.Winner)
+	}
+	final := b.Rounds[1].Series[0]
+	if seeds := seriesSeeds(final); seeds != [2]int{1, 3} {
+		t.Errorf("final seeds = %v (expected [1 3])", seeds)
+	}
+	if next := b.nextMatches(); len(next) != 2 || next[0].Type != Final || next[0].Number != 1 || next[1].Number != 2 {
+		t.Errorf("nextMatches() = %+v", next)
+	}
+}
+
+func TestNextMatchesReplaysTies(t *testing.T) {
+	event := newTestEvent("sdc", 2012, 3, 15)
+	event.Alliances = testAlliances(2)
+	matches := []*Match{
+		scoredMatch(newTestMatch(Final, 1, 1, 3, 5, 2, 4, 6), 20, 10),
+		scoredMatch(newTestMatch(Final, 2, 1, 3, 5, 2, 4, 6), 10, 20),
+		scoredMatch(newTestMatch(Final, 3, 1, 3, 5, 2, 4, 6), 15, 15),
+	}
+	b := buildBracket(event, matches)
+	if next := b.nextMatches(); len(next) != 1 || next[0].Number != 4 {
+		t.Errorf("nextMatches() = %+v (expected final 4)", next)
+	}
+	matches = append(matches, scoredMatch(newTestMatch(Final, 4, 1, 3, 5, 2, 4, 6), 30, 15))
+	b = buildBracket(event, matches)
+	if c := b.Champion(); c == nil || c.Seed != 1 || c.Wins != 2 {
+		t.Errorf("Champion() = %+v (expected seed 1)", c)
+	}
+	if next := b.nextMatches(); len(next) != 0 {
+		t.Errorf("nextMatches() after final = %+v", next)
+	}
+}
+
+func TestEventAlliancesForm(t *testing.T) {
+	store := newTestServer(t)
+	event := newTestEvent("sdc", 2012, 3, 15, 1, 2, 3, 4, 5, 6, 7)
+	mustUpsertEvent(t, store, event)
+	const path = "/event/2012/sdc/+alliances"
+
+	if rec := serveTestRequest(t, path, nil); rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+
+	rec := serveTestRequest(t, path, url.Values{
+		"Alliance.1.Captain": {"1"},
+		"Alliance.1.Pick":    {"7", "3"},
+		"Alliance.2.Captain": {"2"},
+		"Alliance.2.Pick":    {"3"},
+	})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "already on an alliance") {
+		t.Errorf("POST with duplicate team code = %d", rec.Code)
+	}
+
+	rec = serveTestRequest(t, path, url.Values{
+		"Alliance.1.Captain": {"1"},
+		"Alliance.1.Pick":    {"7", "3"},
+		"Alliance.2.Captain": {"2"},
+		"Alliance.2.Pick":    {"", "4"},
+	})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("POST %s code = %d", path, rec.Code)
+	}
+	e, err := store.FetchEvent(event.Tag())
+	if err != nil {
+		t.Fatalf("FetchEvent error: %v", err)
+	}
+	expected := []EliminationAlliance{{1, []int{7, 3}}, {2, []int{4}}}
+	if !reflect.DeepEqual(e.Alliances, expected) {
+		t.Errorf("Alliances = %+v (expected %+v)", e.Alliances, expected)
+	}
+	matches, err := store.FetchMatches(event.Tag())
+	if err != nil {
+		t.Fatalf("FetchMatches error: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Type != Final {
+		t.Fatalf("matches = %+v (expected 2 finals)", matches)
+	}
+
+	// Scoring the finals decides the event.
+	for _, m := range matches {
+		p := "/event/2012/sdc/match/final/" + strconv.Itoa(m.Number) + "/+score"
+		if rec := serveTestRequest(t, p, url.Values{"RedScore": {"20"}, "BlueScore": {"10"}}); rec.Code != http.StatusFound {
+			t.Fatalf("POST %s code = %d", p, rec.Code)
+		}
+	}
+	rec = serveTestRequest(t, "/event/2012/sdc/", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `class="champion"`) {
+		t.Errorf("Event page is missing champion:\n%s", rec.Body.String())
+	}
+
+	// Restoring a blue win in the second final ties the series, so a third
+	// final is scheduled.
+	const final2 = "/event/2012/sdc/match/final/2/"
+	f2 := MatchTag{event.Tag(), Final, 2}
+	for _, score := range [][2]int{{10, 20}, {20, 10}} {
+		if err := store.UpdateMatchScore(f2, score[0], score[1], testEditor); err != nil {
+			t.Fatalf("UpdateMatchScore(%v, %d, %d) error: %v", f2, score[0], score[1], err)
+		}
+	}
+	if rec := serveTestRequest(t, final2+"history/1/+restore", url.Values{}); rec.Code != http.StatusFound {
+		t.Fatalf("POST %shistory/1/+restore code = %d", final2, rec.Code)
+	}
+	if _, err := store.FetchMatch(MatchTag{event.Tag(), Final, 3}); err != nil {
+		t.Errorf("FetchMatch(final 3) after restoring a tie error: %v", err)
+	}
+}
+
+func TestAdvanceBracketStaleMatches(t *testing.T) {
+	store := newMemoryDatastore()
+	event := newTestEvent("sdc", 2012, 3, 15, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+	event.Alliances = testAlliances(4)
+	mustUpsertEvent(t, store, event)
+	if n, err := advanceBracket(store, event); n != 4 || err != nil {
+		t.Fatalf("advanceBracket(...) = %d, %v (expected 4, <nil>)", n, err)
+	}
+
+	// Resubmitted alliances rewrite the unplayed matches.
+	event.Alliances[3].Picks = []int{5, 12}
+	event.Alliances[0].Picks = []int{9, 8}
+	mustUpsertEvent(t, store, event)
+	if n, err := advanceBracket(store, event); n != 2 || err != nil {
+		t.Errorf("advanceBracket(...) after changing an alliance = %d, %v (expected 2, <nil>)", n, err)
+	}
+	for _, number := range []int{1, 3} {
+		m, err := store.FetchMatch(MatchTag{event.Tag(), SemiFinal, uint(number)})
+		if err != nil {
+			t.Fatalf("FetchMatch(semifinal %d) error: %v", number, err)
+		}
+		if want := []int{1, 9, 8, 4, 5, 12}; !reflect.DeepEqual(matchTeamNumbers(m), want) {
+			t.Errorf("semifinal %d teams = %v (expected %v)", number, matchTeamNumbers(m), want)
+		}
+	}
+
+	// Fewer alliances drop the semifinals.
+	event.Alliances = testAlliances(2)
+	mustUpsertEvent(t, store, event)
+	if _, err := advanceBracket(store, event); err != nil {
+		t.Fatalf("advanceBracket(...) error: %v", err)
+	}
+	matches, err := store.FetchMatches(event.Tag())
+	if err != nil {
+		t.Fatalf("FetchMatches error: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Type != Final || matches[1].Type != Final {
+		t.Errorf("matches with two alliances = %+v (expected 2 finals)", matches)
+	}
+
+	// A correction that decides the series deletes the extra final.
+	for i, winner := range []Alliance{Red, Blue} {
+		red, blue := 20, 10
+		if winner == Blue {
+			red, blue = 10, 20
+		}
+		if err := store.UpdateMatchScore(MatchTag{event.Tag(), Final, uint(i + 1)}, red, blue, testEditor); err != nil {
+			t.Fatalf("UpdateMatchScore error: %v", err)
+		}
+	}
+	if n, err := advanceBracket(store, event); n != 1 || err != nil {
+		t.Errorf("advanceBracket(...) after a split = %d, %v (expected 1, <nil>)", n, err)
+	}
+	if err := store.UpdateMatchScore(MatchTag{event.Tag(), Final, 2}, 20, 10, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+	if n, err := advanceBracket(store, event); n != 1 || err != nil {
+		t.Errorf("advanceBracket(...) after a correction = %d, %v (expected 1, <nil>)", n, err)
+	}
+	if _, err := store.FetchMatch(MatchTag{event.Tag(), Final, 3}); err != StoreNotFound {
+		t.Errorf("FetchMatch(final 3) after the series was decided error = %v (expected %v)", err, StoreNotFound)
+	}
+}
+
+func matchTeamNumbers(m *Match) []int {
+	nums := make([]int, len(m.Teams))
+	for i := range m.Teams {
+		nums[i] = m.Teams[i].Team
+	}
+	return nums
+}
@@ -0,0 +1,394 @@
+// chart.go
+
+// Package chart draws simple statistical charts without any client-side
+// scripting.  Each chart type draws itself into a Drawing, which can be
+// written as SVG or replayed onto a PDF canvas.
+package chart
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// A Chart lays itself out in a drawing of the given size.
+type Chart interface {
+	Draw(width, height float64) *Drawing
+}
+
+// Palette is the sequence of colors given to series that don't have one.
+var Palette = []color.RGBA{
+	{0xb0, 0x14, 0x26, 0xff},
+	{0x4f, 0x57, 0xb8, 0xff},
+	{0x2a, 0x8c, 0x3c, 0xff},
+	{0xd6, 0x8a, 0x00, 0xff},
+	{0x7a, 0x3f, 0xa0, 0xff},
+	{0x33, 0x33, 0x33, 0xff},
+	{0x1f, 0x9e, 0xa8, 0xff},
+	{0xc4, 0x5a, 0x9a, 0xff},
+}
+
+var (
+	textColor  = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	axisColor  = color.RGBA{0x99, 0x99, 0x99, 0xff}
+	gridColor  = color.RGBA{0xe5, 0xe5, 0xe5, 0xff}
+	trackColor = color.RGBA{0xee, 0xee, 0xee, 0xff}
+)
+
+// Layout sizes
+const (
+	fontSize      = 10
+	smallFontSize = 8
+	legendHeight  = 14
+	tickLength    = 3
+	yTicks        = 4
+)
+
+// A Series is a named sequence of values.  A zero Color is replaced by a
+// color from the Palette.
+type Series struct {
+	Label  string
+	Color  color.RGBA
+	Values []float64
+}
+
+func seriesColor(s Series, i int) color.RGBA {
+	if s.Color.A == 0 {
+		return Palette[i%len(Palette)]
+	}
+	return s.Color
+}
+
+// Timeline is a line chart of values in match order.  Each series is a line;
+// the x axis is the match index, starting at 1.
+type Timeline struct {
+	Series []Series
+}
+
+// Draw lays out the timeline at the given size.
+func (c *Timeline) Draw(width, height float64) *Drawing {
+	d := &Drawing{Width: width, Height: height}
+	n, lo, hi := 0, 0.0, 0.0
+	for _, s := range c.Series {
+		if len(s.Values) > n {
+			n = len(s.Values)
+		}
+		for _, v := range s.Values {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	p := newPlot(d, newScale(lo, hi), hasLabels(c.Series))
+	step := 0.0
+	if n > 1 {
+		step = p.Dx() / float64(n-1)
+	}
+	x := func(i int) float64 {
+		if n == 1 {
+			return p.Min.X + p.Dx()/2
+		}
+		return p.Min.X + float64(i)*step
+	}
+
+	// Match numbers
+	every := labelInterval(n, p.Dx(), textWidth(len(strconv.Itoa(n)), fontSize)+6)
+	for i := 0; i < n; i += every {
+		p.xLabel(x(i), strconv.Itoa(i+1))
+	}
+
+	for i, s := range c.Series {
+		col := seriesColor(s, i)
+		line := Polyline{Color: col, Width: 2}
+		for j, v := range s.Values {
+			pt := Point{x(j), p.y(v)}
+			line.Points = append(line.Points, pt)
+			d.add(Circle{pt, 2.5, col})
+		}
+		if len(line.Points) > 1 {
+			d.add(line)
+		}
+	}
+	p.legend(c.Series)
+	return d
+}
+
+// StackedBars is a bar chart where each bar is divided into segments.  Each
+// stack is a series with one value per category, drawn bottom to top in
+// order.
+type StackedBars struct {
+	Categories []string
+	Stacks     []Series
+}
+
+// Draw lays out the bar chart at the given size.
+func (c *StackedBars) Draw(width, height float64) *Drawing {
+	d := &Drawing{Width: width, Height: height}
+	totals := make([]float64, len(c.Categories))
+	hi := 0.0
+	for i := range c.Categories {
+		for _, s := range c.Stacks {
+			if i < len(s.Values) && s.Values[i] > 0 {
+				totals[i] += s.Values[i]
+			}
+		}
+		hi = math.Max(hi, totals[i])
+	}
+	p := newPlot(d, newScale(0, hi), hasLabels(c.Stacks))
+	if len(c.Categories) == 0 {
+		return d
+	}
+	slot := p.Dx() / float64(len(c.Categories))
+	barWidth := slot * 0.6
+	every := labelInterval(len(c.Categories), p.Dx(), textWidth(maxLength(c.Categories), fontSize)+4)
+	for i, cat := range c.Categories {
+		center := p.Min.X + slot*(float64(i)+0.5)
+		if i%every == 0 {
+			p.xLabel(center, cat)
+		}
+		base := 0.0
+		for j, s := range c.Stacks {
+			if i >= len(s.Values) || s.Values[i] <= 0 {
+				continue
+			}
+			top := base + s.Values[i]
+			d.add(Rect{
+				Min:  Point{center - barWidth/2, p.y(top)},
+				Max:  Point{center + barWidth/2, p.y(base)},
+				Fill: seriesColor(s, j),
+			})
+			base = top
+		}
+	}
+	p.legend(c.Stacks)
+	return d
+}
+
+// A Rate is a bar of a Rates chart.
+type Rate struct {
+	Label string
+	Value float64 // from 0 to 1
+	Note  string  // shown after the percentage, like "3/4"
+}
+
+// Rates is a horizontal bar chart of percentages, like success rates.
+type Rates struct {
+	Bars  []Rate
+	Color color.RGBA
+}
+
+// Draw lays out the rates at the given size.
+func (c *Rates) Draw(width, height float64) *Drawing {
+	d := &Drawing{Width: width, Height: height}
+	if len(c.Bars) == 0 {
+		return d
+	}
+	col := c.Color
+	if col.A == 0 {
+		col = Palette[2]
+	}
+	labels := make([]string, len(c.Bars))
+	notes := make([]string, len(c.Bars))
+	for i, b := range c.Bars {
+		labels[i] = b.Label
+		notes[i] = percent(b.Value)
+		if b.Note != "" {
+			notes[i] += " (" + b.Note + ")"
+		}
+	}
+	left := textWidth(maxLength(labels), fontSize) + 6
+	right := width - textWidth(maxLength(notes), fontSize) - 6
+	row := height / float64(len(c.Bars))
+	for i, b := range c.Bars {
+		top := float64(i) * row
+		baseline := top + row/2 + fontSize/3
+		v := math.Max(0, math.Min(1, b.Value))
+		barTop, barBottom := top+row*0.2, top+row*0.8
+		d.add(Text{Point{left - 6, baseline}, b.Label, fontSize, End, textColor})
+		d.add(Rect{Point{left, barTop}, Point{right, barBottom}, trackColor})
+		if v > 0 {
+			d.add(Rect{Point{left, barTop}, Point{left + (right-left)*v, barBottom}, col})
+		}
+		d.add(Text{Point{right + 6, baseline}, notes[i], fontSize, Start, textColor})
+	}
+	return d
+}
+
+// A ScatterPoint is a labeled point on a Scatter chart.
+type ScatterPoint struct {
+	X, Y  float64
+	Label string
+}
+
+// Scatter plots points against two axes.
+type Scatter struct {
+	XLabel, YLabel string
+	Points         []ScatterPoint
+	Color          color.RGBA
+}
+
+// Draw lays out the scatter plot at the given size.
+func (c *Scatter) Draw(width, height float64) *Drawing {
+	d := &Drawing{Width: width, Height: height}
+	col := c.Color
+	if col.A == 0 {
+		col = Palette[1]
+	}
+	xlo, xhi, ylo, yhi := 0.0, 0.0, 0.0, 0.0
+	for _, pt := range c.Points {
+		xlo, xhi = math.Min(xlo, pt.X), math.Max(xhi, pt.X)
+		ylo, yhi = math.Min(ylo, pt.Y), math.Max(yhi, pt.Y)
+	}
+	p := newPlot(d, newScale(ylo, yhi), true)
+	xs := newScale(xlo, xhi)
+	x := func(v float64) float64 {
+		return p.Min.X + (v-xs.Min)/(xs.Max-xs.Min)*p.Dx()
+	}
+	for v := xs.Min; v <= xs.Max+xs.Step/2; v += xs.Step {
+		p.xLabel(x(v), xs.label(v))
+	}
+	d.add(Text{Point{p.Min.X, legendHeight - 4}, c.YLabel, fontSize, Start, textColor})
+	d.add(Text{Point{p.Max.X, p.Max.Y - 4}, c.XLabel, fontSize, End, textColor})
+	for _, pt := range c.Points {
+		center := Point{x(pt.X), p.y(pt.Y)}
+		d.add(Circle{center, 3, col})
+		if pt.Label != "" {
+			d.add(Text{Point{center.X + 4, center.Y - 3}, pt.Label, smallFontSize, Start, textColor})
+		}
+	}
+	return d
+}
+
+// A scale maps values onto an axis with round tick marks.
+type scale struct {
+	Min, Max, Step float64
+}
+
+// newScale returns a scale that covers lo to hi with about yTicks ticks at
+// round numbers.
+func newScale(lo, hi float64) scale {
+	if hi <= lo {
+		hi = lo + 1
+	}
+	raw := (hi - lo) / yTicks
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := 10 * mag
+	for _, m := range []float64{1, 2, 5} {
+		if m*mag >= raw {
+			step = m * mag
+			break
+		}
+	}
+	return scale{math.Floor(lo/step) * step, math.Ceil(hi/step) * step, step}
+}
+
+// label formats a tick value, rounded to the scale's step to hide
+// floating-point error.
+func (s scale) label(v float64) string {
+	digits := 0
+	if s.Step < 1 {
+		digits = int(math.Ceil(-math.Log10(s.Step)))
+	}
+	return strconv.FormatFloat(v, 'f', digits, 64)
+}
+
+// A plot is the area of a drawing inside the axes.
+type plot struct {
+	d        *Drawing
+	Min, Max Point
+	ys       scale
+}
+
+// newPlot draws the y axis, grid lines and x axis of a chart and returns the
+// area inside them.  Room is left at the top for a legend if requested.
+func newPlot(d *Drawing, s scale, legend bool) *plot {
+	top := 6.0
+	if legend {
+		top += legendHeight
+	}
+	left := textWidth(len(s.label(s.Max))+1, fontSize) + tickLength
+	p := &plot{
+		d:   d,
+		Min: Point{left, top},
+		Max: Point{d.Width - 8, d.Height - fontSize - 8},
+		ys:  s,
+	}
+	for v := s.Min; v <= s.Max+s.Step/2; v += s.Step {
+		y := p.y(v)
+		if v != s.Min {
+			d.add(Line{Point{p.Min.X, y}, Point{p.Max.X, y}, gridColor, 1})
+		}
+		d.add(Line{Point{p.Min.X - tickLength, y}, Point{p.Min.X, y}, axisColor, 1})
+		d.add(Text{Point{p.Min.X - tickLength - 2, y + fontSize/3}, s.label(v), fontSize, End, textColor})
+	}
+	d.add(Line{Point{p.Min.X, p.Min.Y}, Point{p.Min.X, p.Max.Y}, axisColor, 1})
+	d.add(Line{Point{p.Min.X, p.Max.Y}, Point{p.Max.X, p.Max.Y}, axisColor, 1})
+	return p
+}
+
+func (p *plot) Dx() float64 { return p.Max.X - p.Min.X }
+func (p *plot) Dy() float64 { return p.Max.Y - p.Min.Y }
+
+// y returns the vertical position of a value.
+func (p *plot) y(v float64) float64 {
+	return p.Max.Y - (v-p.ys.Min)/(p.ys.Max-p.ys.Min)*p.Dy()
+}
+
+// xLabel draws a tick and label under the x axis.
+func (p *plot) xLabel(x float64, s string) {
+	p.d.add(Line{Point{x, p.Max.Y}, Point{x, p.Max.Y + tickLength}, axisColor, 1})
+	p.d.add(Text{Point{x, p.Max.Y + tickLength + fontSize}, s, fontSize, Middle, textColor})
+}
+
+// legend draws a swatch and label for each labeled series above the plot.
+func (p *plot) legend(series []Series) {
+	x := p.Min.X
+	for i, s := range series {
+		if s.Label == "" {
+			continue
+		}
+		p.d.add(Rect{Point{x, 4}, Point{x + 8, 12}, seriesColor(s, i)})
+		p.d.add(Text{Point{x + 11, 12}, s.Label, fontSize, Start, textColor})
+		x += 11 + textWidth(len(s.Label), fontSize) + 10
+	}
+}
+
+// hasLabels reports whether any of the series are labeled.
+func hasLabels(series []Series) bool {
+	for _, s := range series {
+		if s.Label != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// labelInterval returns how many of n evenly spaced x labels to skip between
+// labels so that labels of the given width don't overlap.
+func labelInterval(n int, width, labelWidth float64) int {
+	if n == 0 || labelWidth <= 0 {
+		return 1
+	}
+	fit := int(width / labelWidth)
+	if fit < 1 {
+		fit = 1
+	}
+	return (n + fit - 1) / fit
+}
+
+// textWidth estimates the width of n characters of text.
+func textWidth(n int, size float64) float64 {
+	return float64(n) * size * 0.6
+}
+
+func maxLength(labels []string) int {
+	max := 0
+	for _, s := range labels {
+		if len(s) > max {
+			max = len(s)
+		}
+	}
+	return max
+}
+
+func percent(v float64) string {
+	return strconv.FormatFloat(v*100, 'f', 0, 64) + "%"
+}
@@ -0,0 +1,166 @@
+package chart
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestNewScale(t *testing.T) {
+	tests := []struct {
+		Lo, Hi   float64
+		Expected scale
+	}{
+		{0, 0, scale{0, 1, 0.5}},
+		{0, 1, scale{0, 1, 0.5}},
+		{0, 37, scale{0, 40, 10}},
+		{0, 100, scale{0, 100, 50}},
+		{0, 7, scale{0, 8, 2}},
+		{-3, 12, scale{-5, 15, 5}},
+	}
+	for _, test := range tests {
+		if s := newScale(test.Lo, test.Hi); s != test.Expected {
+			t.Errorf("newScale(%v, %v) = %+v (expected %+v)", test.Lo, test.Hi, s, test.Expected)
+		}
+	}
+}
+
+func TestScaleLabel(t *testing.T) {
+	tests := []struct {
+		Scale    scale
+		V        float64
+		Expected string
+	}{
+		{scale{0, 40, 10}, 30, "30"},
+		{scale{0, 1, 0.2}, 0.6000000000000001, "0.6"},
+		{scale{0, 0.1, 0.05}, 0.05, "0.05"},
+	}
+	for _, test := range tests {
+		if s := test.Scale.label(test.V); s != test.Expected {
+			t.Errorf("%+v.label(%v) = %q (expected %q)", test.Scale, test.V, s, test.Expected)
+		}
+	}
+}
+
+func countShapes(d *Drawing) map[string]int {
+	counts := make(map[string]int)
+	for _, s := range d.Shapes {
+		switch s.(type) {
+		case Line:
+			counts["line"]++
+		case Rect:
+			counts["rect"]++
+		case Polyline:
+			counts["polyline"]++
+		case Circle:
+			counts["circle"]++
+		case Text:
+			counts["text"]++
+		}
+	}
+	return counts
+}
+
+func TestTimeline(t *testing.T) {
+	c := &Timeline{Series: []Series{
+		{Values: []float64{10, 20, 15}},
+		{Values: []float64{5}},
+	}}
+	d := c.Draw(300, 100)
+	counts := countShapes(d)
+	if counts["polyline"] != 1 {
+		t.Errorf("Timeline has %d polylines (expected 1; a single match has no line)", counts["polyline"])
+	}
+	if counts["circle"] != 4 {
+		t.Errorf("Timeline has %d points (expected 4)", counts["circle"])
+	}
+	for _, s := range d.Shapes {
+		if p, ok := s.(Polyline); ok {
+			if p.Points[0].Y <= p.Points[1].Y {
+				t.Errorf("Higher score drawn lower: %v", p.Points)
+			}
+			if p.Color != Palette[0] {
+				t.Errorf("Series color = %v (expected %v)", p.Color, Palette[0])
+			}
+		}
+	}
+}
+
+func TestStackedBars(t *testing.T) {
+	c := &StackedBars{
+		Categories: []string{"A", "B"},
+		Stacks: []Series{
+			{Label: "X", Values: []float64{1, 0}},
+			{Label: "Y", Values: []float64{2, 3}},
+		},
+	}
+	var bars []Rect
+	for _, s := range c.Draw(200, 100).Shapes {
+		if r, ok := s.(Rect); ok && r.Max.X-r.Min.X > 8 {
+			bars = append(bars, r)
+		}
+	}
+	if len(bars) != 3 {
+		t.Fatalf("Drew %d bar segments (expected 3; zero values are skipped)", len(bars))
+	}
+	// The second segment of the first bar sits on top of the first.
+	if bars[1].Max.Y != bars[0].Min.Y {
+		t.Errorf("Stacked segment bottom = %v (expected %v)", bars[1].Max.Y, bars[0].Min.Y)
+	}
+	// Both bars total 3, so they are the same height.
+	if bars[1].Min.Y != bars[2].Min.Y {
+		t.Errorf("Bar tops = %v, %v (expected equal)", bars[1].Min.Y, bars[2].Min.Y)
+	}
+}
+
+func TestRates(t *testing.T) {
+	c := &Rates{Bars: []Rate{{"Bridge", 0.5, "1/2"}, {"Never", 0, "0/0"}}}
+	d := c.Draw(300, 60)
+	counts := countShapes(d)
+	if counts["rect"] != 3 {
+		t.Errorf("Rates drew %d rects (expected 2 tracks and 1 bar)", counts["rect"])
+	}
+	svg := d.SVG()
+	if !strings.Contains(svg, ">50% (1/2)</text>") {
+		t.Errorf("Rates SVG is missing percentage: %s", svg)
+	}
+}
+
+func TestScatter(t *testing.T) {
+	c := &Scatter{
+		XLabel: "Auto",
+		YLabel: "Teleop",
+		Points: []ScatterPoint{{4, 10, "973"}, {12, 6, "254"}},
+	}
+	d := c.Draw(300, 200)
+	var circles []Circle
+	for _, s := range d.Shapes {
+		if c, ok := s.(Circle); ok {
+			circles = append(circles, c)
+		}
+	}
+	if len(circles) != 2 {
+		t.Fatalf("Scatter drew %d points (expected 2)", len(circles))
+	}
+	if circles[0].Center.X >= circles[1].Center.X || circles[0].Center.Y >= circles[1].Center.Y {
+		t.Errorf("Scatter points at %v, %v", circles[0].Center, circles[1].Center)
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	d := &Drawing{
+		Width:  100,
+		Height: 50,
+		Shapes: []Shape{
+			Line{Point{0, 0}, Point{10, 10.125}, color.RGBA{0xff, 0, 0, 0xff}, 1},
+			Text{Point{5, 5}, "<Team & Co>", 10, Middle, color.RGBA{0, 0, 0, 0xff}},
+		},
+	}
+	expected := `<svg xmlns="http://www.w3.org/2000/svg" class="chart" width="100" height="50" viewBox="0 0 100 50" font-family="sans-serif">` +
+		`<line x1="0" y1="0" x2="10" y2="10.13" stroke="#ff0000" stroke-width="1"/>` +
+		`<text x="5" y="5" font-size="10" text-anchor="middle" fill="#000000">&lt;Team &amp; Co&gt;</text>` +
+		`</svg>`
+	if svg := d.SVG(); svg != expected {
+		t.Errorf("SVG() =\n%s\nexpected\n%s", svg, expected)
+	}
+}
@@ -0,0 +1,148 @@
+// drawing.go
+
+package chart
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A Point is a position in a drawing.  The origin is the top left corner and
+// y increases downward, as in SVG.
+type Point struct {
+	X, Y float64
+}
+
+// A Drawing is a list of shapes.  It can be written as SVG or replayed onto
+// any other canvas by switching on the shape types.
+type Drawing struct {
+	Width, Height float64
+	Shapes        []Shape
+}
+
+// A Shape is one of Line, Rect, Polyline, Circle or Text.
+type Shape interface {
+	writeSVG(w *bufio.Writer)
+}
+
+// A Line is a stroked line segment.
+type Line struct {
+	Start, End Point
+	Color      color.RGBA
+	Width      float64
+}
+
+// A Rect is a filled rectangle.
+type Rect struct {
+	Min, Max Point
+	Fill     color.RGBA
+}
+
+// A Polyline is a stroked series of connected line segments.
+type Polyline struct {
+	Points []Point
+	Color  color.RGBA
+	Width  float64
+}
+
+// A Circle is a filled circle.
+type Circle struct {
+	Center Point
+	Radius float64
+	Fill   color.RGBA
+}
+
+// Anchor is the horizontal alignment of text relative to its position.
+type Anchor int
+
+// Anchors
+const (
+	Start Anchor = iota
+	Middle
+	End
+)
+
+var anchorNames = [...]string{Start: "start", Middle: "middle", End: "end"}
+
+// Text is a line of text.  Pos is the left end, center or right end of the
+// text's baseline, depending on Anchor.
+type Text struct {
+	Pos    Point
+	Text   string
+	Size   float64
+	Anchor Anchor
+	Color  color.RGBA
+}
+
+func (d *Drawing) add(s Shape) {
+	d.Shapes = append(d.Shapes, s)
+}
+
+// WriteSVG writes the drawing as a standalone SVG element, suitable for
+// embedding in an HTML page.
+func (d *Drawing) WriteSVG(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" class="chart" width="%s" height="%s" viewBox="0 0 %s %s" font-family="sans-serif">`,
+		svgNumber(d.Width), svgNumber(d.Height), svgNumber(d.Width), svgNumber(d.Height))
+	for _, s := range d.Shapes {
+		s.writeSVG(bw)
+	}
+	bw.WriteString("</svg>")
+	return bw.Flush()
+}
+
+// SVG returns the drawing as an SVG element.
+func (d *Drawing) SVG() string {
+	var buf bytes.Buffer
+	d.WriteSVG(&buf)
+	return buf.String()
+}
+
+func (l Line) writeSVG(w *bufio.Writer) {
+	fmt.Fprintf(w, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"/>`,
+		svgNumber(l.Start.X), svgNumber(l.Start.Y), svgNumber(l.End.X), svgNumber(l.End.Y),
+		svgColor(l.Color), svgNumber(l.Width))
+}
+
+func (r Rect) writeSVG(w *bufio.Writer) {
+	fmt.Fprintf(w, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`,
+		svgNumber(r.Min.X), svgNumber(r.Min.Y), svgNumber(r.Max.X-r.Min.X), svgNumber(r.Max.Y-r.Min.Y),
+		svgColor(r.Fill))
+}
+
+func (p Polyline) writeSVG(w *bufio.Writer) {
+	points := make([]string, len(p.Points))
+	for i, pt := range p.Points {
+		points[i] = svgNumber(pt.X) + "," + svgNumber(pt.Y)
+	}
+	fmt.Fprintf(w, `<polyline fill="none" stroke="%s" stroke-width="%s" points="%s"/>`,
+		svgColor(p.Color), svgNumber(p.Width), strings.Join(points, " "))
+}
+
+func (c Circle) writeSVG(w *bufio.Writer) {
+	fmt.Fprintf(w, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`,
+		svgNumber(c.Center.X), svgNumber(c.Center.Y), svgNumber(c.Radius), svgColor(c.Fill))
+}
+
+func (t Text) writeSVG(w *bufio.Writer) {
+	fmt.Fprintf(w, `<text x="%s" y="%s" font-size="%s" text-anchor="%s" fill="%s">%s</text>`,
+		svgNumber(t.Pos.X), svgNumber(t.Pos.Y), svgNumber(t.Size), anchorNames[t.Anchor],
+		svgColor(t.Color), html.EscapeString(t.Text))
+}
+
+// svgNumber formats a coordinate to two decimal places, dropping trailing
+// zeroes.
+func svgNumber(x float64) string {
+	return strconv.FormatFloat(math.Round(x*100)/100, 'f', -1, 64)
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
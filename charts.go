@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/greyhound-scouting/chart"
+	"html/template"
+	"image/color"
+	"sort"
+	"strconv"
+)
+
+// Chart sizes on web pages, in pixels
+const (
+	chartWidth     = 480
+	wideChartWidth = 600
+	chartHeight    = 200
+	scatterHeight  = 400
+)
+
+// stackColors are the colors of count stacks with these labels, shared by
+// every chart of Rebound Rumble's hoops.  Other stacks use the chart palette.
+var stackColors = map[string]color.RGBA{
+	"High":   {0xb0, 0x14, 0x26, 0xff},
+	"Mid":    {0xd6, 0x8a, 0x00, 0xff},
+	"Low":    {0x4f, 0x57, 0xb8, 0xff},
+	"Missed": {0xcc, 0xcc, 0xcc, 0xff},
+}
+
+// rateBarHeight is the height of each bar in a rates chart on web pages, in
+// pixels.
+const rateBarHeight = 30
+
+// svgChart renders a chart as SVG for a page.
+func svgChart(c chart.Chart, width, height float64) template.HTML {
+	return template.HTML(c.Draw(width, height).SVG())
+}
+
+// scoreTimeline charts a team's score in each match it played.
+func scoreTimeline(stats TeamStats) *chart.Timeline {
+	return &chart.Timeline{
+		Series: []chart.Series{{Values: distributionValues(stats.Scores)}},
+	}
+}
+
+// compareTimeline charts several teams' scores on the same axes, one line per
+// team, in column order.
+func compareTimeline(teams []int, stats []TeamStats) *chart.Timeline {
+	c := new(chart.Timeline)
+	for i, n := range teams {
+		c.Series = append(c.Series, chart.Series{
+			Label:  strconv.Itoa(n),
+			Values: distributionValues(stats[i].Scores),
+		})
+	}
+	return c
+}
+
+func distributionValues(d Distribution) []float64 {
+	values := make([]float64, len(d))
+	for i, v := range d {
+		values[i] = float64(v)
+	}
+	return values
+}
+
+// countStacks returns an empty stack for each label of the game's count
+// fields within their phases, like Rebound Rumble's hoops, and the index of
+// each count field's stack.  Fields with the same label in different phases
+// share a stack.
+func countStacks(game *Game) ([]chart.Series, map[string]int) {
+	var stacks []chart.Series
+	index := make(map[string]int)
+	byLabel := make(map[string]int)
+	for _, f := range game.Fields {
+		if f.Kind != CountField {
+			continue
+		}
+		label := f.ShortLabel()
+		i, ok := byLabel[label]
+		if !ok {
+			i = len(stacks)
+			byLabel[label] = i
+			stacks = append(stacks, chart.Series{Label: label, Color: stackColors[label]})
+		}
+		index[f.Name] = i
+	}
+	return stacks, index
+}
+
+// addCategory appends a zero value to every stack.
+func addCategory(stacks []chart.Series) {
+	for i := range stacks {
+		stacks[i].Values = append(stacks[i].Values, 0)
+	}
+}
+
+// countChart charts the average of each of a team's count fields per match,
+// one bar for each phase of the game that has count fields.
+func countChart(game *Game, stats TeamStats) *chart.StackedBars {
+	c := new(chart.StackedBars)
+	stacks, index := countStacks(game)
+	for _, phase := range game.Phases {
+		added := false
+		for _, f := range game.PhaseFields(phase) {
+			i, ok := index[f.Name]
+			if !ok {
+				continue
+			}
+			if !added {
+				c.Categories = append(c.Categories, phase)
+				addCategory(stacks)
+				added = true
+			}
+			stacks[i].Values[len(c.Categories)-1] += stats.Field(f.Name).Average(stats.MatchCount)
+		}
+	}
+	c.Stacks = stacks
+	return c
+}
+
+// matchCountChart charts a team's count fields in each of its scored
+// matches, with every phase combined.
+func matchCountChart(game *Game, team int, matches []*Match) *chart.StackedBars {
+	c := new(chart.StackedBars)
+	stacks, index := countStacks(game)
+	for _, m := range matches {
+		info := m.TeamInfo(team)
+		if info == nil || info.NoShow || m.Score == nil {
+			continue
+		}
+		c.Categories = append(c.Categories, matchAbbrev(m))
+		addCategory(stacks)
+		for i := range game.Fields {
+			f := &game.Fields[i]
+			if j, ok := index[f.Name]; ok {
+				stacks[j].Values[len(c.Categories)-1] += float64(f.Value(info))
+			}
+		}
+	}
+	c.Stacks = stacks
+	return c
+}
+
+// matchAbbrev returns a short name for a match, like "Q12" or "SF2".
+func matchAbbrev(m *Match) string {
+	prefix := "Q"
+	switch m.Type {
+	case QuarterFinal:
+		prefix = "QF"
+	case SemiFinal:
+		prefix = "SF"
+	case Final:
+		prefix = "F"
+	}
+	return prefix + strconv.Itoa(m.Number)
+}
+
+// attemptChart charts how often a team's attempts at each of the game's
+// attempt fields succeed, like Rebound Rumble's bridges.
+func attemptChart(game *Game, stats TeamStats) *chart.Rates {
+	c := new(chart.Rates)
+	for _, f := range game.Fields {
+		if f.Kind != AttemptField {
+			continue
+		}
+		fs := stats.Field(f.Name)
+		c.Bars = append(c.Bars, chart.Rate{
+			Label: f.Label,
+			Value: fs.SuccessRate(),
+			Note:  strconv.Itoa(fs.Total) + "/" + strconv.Itoa(fs.AttemptCount),
+		})
+	}
+	return c
+}
+
+// phasePoints returns the average number of points a team earned per match
+// in one of the game's phases.
+func phasePoints(game *Game, stats TeamStats, phase string) float64 {
+	points := 0.0
+	for _, f := range game.PhaseFields(phase) {
+		points += float64(f.Points) * stats.Field(f.Name).Average(stats.MatchCount)
+	}
+	return points
+}
+
+// phaseScatter plots each team's average points in the game's first phase
+// against its second, like autonomous against teleoperated.  Teams that
+// haven't played are left out.
+func phaseScatter(game *Game, stats map[int]TeamStats) *chart.Scatter {
+	c := new(chart.Scatter)
+	if len(game.Phases) < 2 {
+		return c
+	}
+	c.XLabel = game.Phases[0] + " Points"
+	c.YLabel = game.Phases[1] + " Points"
+	teams := make([]int, 0, len(stats))
+	for n, s := range stats {
+		if s.MatchCount != 0 {
+			teams = append(teams, n)
+		}
+	}
+	sort.Ints(teams)
+	for _, n := range teams {
+		c.Points = append(c.Points, chart.ScatterPoint{
+			X:     phasePoints(game, stats[n], game.Phases[0]),
+			Y:     phasePoints(game, stats[n], game.Phases[1]),
+			Label: strconv.Itoa(n),
+		})
+	}
+	return c
+}
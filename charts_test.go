@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/greyhound-scouting/chart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPhaseScatter(t *testing.T) {
+	stats := map[int]TeamStats{
+		973: {
+			MatchCount: 2,
+			Fields: map[string]FieldStats{
+				"Autonomous.High":   {Total: 2},
+				"Teleoperated.High": {Total: 4},
+				"Teleoperated.Low":  {Total: 2},
+			},
+		},
+		254: {},
+	}
+	c := phaseScatter(reboundRumble, stats)
+	if c.XLabel != "Autonomous Points" || c.YLabel != "Teleoperated Points" {
+		t.Errorf("phaseScatter labels = %q, %q", c.XLabel, c.YLabel)
+	}
+	if len(c.Points) != 1 {
+		t.Fatalf("phaseScatter has %d points (expected 1; teams that haven't played are left out)", len(c.Points))
+	}
+	p := c.Points[0]
+	if p.Label != "973" || p.X != autoHighPoints || p.Y != 2*teleopHighPoints+teleopLowPoints {
+		t.Errorf("phaseScatter point = %+v", p)
+	}
+}
+
+func TestCountChart(t *testing.T) {
+	stats := TeamStats{
+		MatchCount: 2,
+		Fields: map[string]FieldStats{
+			"AutoDiscs": {Total: 2},
+			"Discs":     {Total: 6},
+		},
+	}
+	c := countChart(testGame, stats)
+	if !reflect.DeepEqual(c.Categories, []string{"Autonomous", "Teleoperated"}) {
+		t.Errorf("Categories = %q", c.Categories)
+	}
+	labels := make([]string, len(c.Stacks))
+	values := make([][]float64, len(c.Stacks))
+	for i, s := range c.Stacks {
+		labels[i], values[i] = s.Label, s.Values
+	}
+	if expected := []string{"Autonomous Discs", "Teleoperated Discs"}; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Stack labels = %q (expected %q)", labels, expected)
+	}
+	if expected := [][]float64{{1, 0}, {0, 3}}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Stack values = %v (expected %v)", values, expected)
+	}
+
+	// Rebound Rumble's hoops are shared between phases, and the bridges
+	// phase has no counts.
+	c = countChart(reboundRumble, TeamStats{})
+	if !reflect.DeepEqual(c.Categories, []string{"Autonomous", "Teleoperated"}) || len(c.Stacks) != 4 {
+		t.Errorf("%s chart categories = %q with %d stacks", reboundRumble.Name, c.Categories, len(c.Stacks))
+	}
+}
+
+func TestMatchCountChart(t *testing.T) {
+	m1 := newTestMatch(Qualification, 1, 973, 2, 3, 4, 5, 6)
+	m1.Score = map[string]int{"red": 10, "blue": 5}
+	m1.Teams[0].Autonomous = BallCount{High: 1}
+	m1.Teams[0].Teleoperated = BallCount{High: 2, Low: 3, Missed: 1}
+	m2 := newTestMatch(SemiFinal, 2, 973, 2, 3, 4, 5, 6)
+	m2.Score = map[string]int{"red": 10, "blue": 5}
+	m2.Teams[0].NoShow = true
+	m3 := newTestMatch(Final, 1, 973, 2, 3, 4, 5, 6)
+
+	c := matchCountChart(reboundRumble, 973, []*Match{m1, m2, m3})
+	if !reflect.DeepEqual(c.Categories, []string{"Q1"}) {
+		t.Errorf("Categories = %q (expected only the scored match)", c.Categories)
+	}
+	values := make([]float64, len(c.Stacks))
+	for i, s := range c.Stacks {
+		values[i] = s.Values[0]
+	}
+	if expected := []float64{3, 0, 3, 1}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Hoop values = %v (expected %v)", values, expected)
+	}
+}
+
+func TestAttemptChart(t *testing.T) {
+	stats := TeamStats{
+		MatchCount: 4,
+		Fields: map[string]FieldStats{
+			"Climb": {Total: 1, AttemptCount: 2},
+		},
+	}
+	c := attemptChart(testGame, stats)
+	expected := []chart.Rate{{Label: "Climb", Value: 0.5, Note: "1/2"}}
+	if !reflect.DeepEqual(c.Bars, expected) {
+		t.Errorf("attemptChart bars = %+v (expected %+v)", c.Bars, expected)
+	}
+}
+
+func TestChartPages(t *testing.T) {
+	store := newTestServer(t)
+	mustUpsertTeams(t, store, 1, 2, 3, 4, 5, 6)
+	event, match := seedTestEvent(t, store)
+	match.Score = map[string]int{"red": 30, "blue": 20}
+	match.Teams[0].Autonomous = BallCount{High: 2}
+	mustUpsertMatch(t, store, event.Tag(), match)
+
+	for _, path := range []string{"/team/1/", "/event/2012/sdc/team/1", "/event/2012/sdc/"} {
+		rec := serveTestRequest(t, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s code = %d", path, rec.Code)
+		} else if !strings.Contains(rec.Body.String(), `<svg xmlns="http://www.w3.org/2000/svg" class="chart"`) {
+			t.Errorf("GET %s is missing a chart", path)
+		}
+	}
+}
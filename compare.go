@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCompareTeams is the most teams that can be compared at once.
+const maxCompareTeams = 6
+
+// A compareMetric is a row of the team comparison table.
+type compareMetric struct {
+	Label  string
+	Format string // Sprintf format for the value
+
+	// LowerIsBetter is true for metrics where the leader has the lowest
+	// value, like failure rate.
+	LowerIsBetter bool
+
+	value func(TeamStats) float64
+}
+
+// compareMetrics returns the metrics compared for a game.  Rates are
+// percentages.
+func compareMetrics(game *Game) []compareMetric {
+	metrics := []compareMetric{
+		{"Matches Played", "%.0f", false, func(s TeamStats) float64 { return float64(s.MatchCount) }},
+		{"Average Score", "%.2f", false, TeamStats.AverageScore},
+		{"Median Score", "%.1f", false, func(s TeamStats) float64 { return s.Scores.Median() }},
+		{"Score Std. Dev.", "%.2f", true, func(s TeamStats) float64 { return s.Scores.StdDev() }},
+		{"Consistency", "%.1f%%", false, func(s TeamStats) float64 { return s.Scores.Consistency() * 100 }},
+		{"Score Trend", "%+.2f", false, func(s TeamStats) float64 { return s.Scores.Trend() }},
+		{"OPR", "%.2f", false, func(s TeamStats) float64 { return s.OPR }},
+		{"DPR", "%.2f", true, func(s TeamStats) float64 { return s.DPR }},
+		{"CCWM", "%.2f", false, func(s TeamStats) float64 { return s.CCWM }},
+		{"Failure Rate", "%.1f%%", true, func(s TeamStats) float64 { return s.FailureRate() * 100 }},
+	}
+	for _, agg := range game.Aggregates {
+		name := agg.Name
+		metrics = append(metrics,
+			compareMetric{"Average " + agg.Label, "%.2f", false, func(s TeamStats) float64 {
+				return s.Field(name).Average(s.MatchCount)
+			}},
+			compareMetric{"Max " + agg.Label, "%.0f", false, func(s TeamStats) float64 {
+				return float64(s.Field(name).Max)
+			}},
+		)
+	}
+	for _, f := range game.Fields {
+		if f.Kind != AttemptField {
+			continue
+		}
+		name := f.Name
+		metrics = append(metrics, compareMetric{f.Label + " Success Rate", "%.1f%%", false, func(s TeamStats) float64 {
+			return s.Field(name).SuccessRate() * 100
+		}})
+	}
+	for _, c := range game.Components {
+		name := c.Name
+		metrics = append(metrics, compareMetric{c.Label + " OPR", "%.2f", false, func(s TeamStats) float64 {
+			return s.Components[name]
+		}})
+	}
+	return metrics
+}
+
+// A compareRow is a metric's values for each team being compared.
+type compareRow struct {
+	Label string
+	Cells []compareCell
+}
+
+// A compareCell is a team's value of a metric.  Leader is true for the teams
+// with the best value, unless every team has the same value.  Teams that
+// haven't played are never leaders.
+type compareCell struct {
+	Value  string
+	Leader bool
+}
+
+// compareRows builds the comparison table for teams' stats, which are given
+// in column order.
+func compareRows(game *Game, stats []TeamStats) []compareRow {
+	metrics := compareMetrics(game)
+	rows := make([]compareRow, len(metrics))
+	values := make([]float64, len(stats))
+	for i, m := range metrics {
+		rows[i] = compareRow{Label: m.Label, Cells: make([]compareCell, len(stats))}
+		best, first, allSame := 0.0, true, true
+		for j, s := range stats {
+			values[j] = m.value(s)
+			rows[i].Cells[j].Value = fmt.Sprintf(m.Format, values[j])
+			if j > 0 && values[j] != values[0] {
+				allSame = false
+			}
+			if s.MatchCount == 0 {
+				continue
+			}
+			if first || (m.LowerIsBetter && values[j] < best) || (!m.LowerIsBetter && values[j] > best) {
+				best, first = values[j], false
+			}
+		}
+		if allSame || first {
+			continue
+		}
+		for j, s := range stats {
+			rows[i].Cells[j].Leader = s.MatchCount != 0 && values[j] == best
+		}
+	}
+	return rows
+}
+
+// parseTeamList parses team numbers separated by commas or spaces.
+// Duplicates are dropped.
+func parseTeamList(s string) ([]int, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	var teams []int
+	seen := make(map[int]bool, len(fields))
+	for _, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n <= 0 {
+			return nil, errors.New("Bad team number " + strconv.Quote(f))
+		}
+		if !seen[n] {
+			seen[n] = true
+			teams = append(teams, n)
+		}
+	}
+	return teams, nil
+}
+
+// compareQueryPattern matches jump bar comparisons like "1234 vs 254", with an
+// optional event like "1234 vs 254 at sdc2012".
+var (
+	compareQueryPattern = regexp.MustCompile(`^\s*([0-9]+(?:\s*vs\.?\s*[0-9]+)+)(?:\s+at\s+([a-z]+[0-9]+))?\s*$`)
+	compareVsPattern    = regexp.MustCompile(`\s*vs\.?\s*`)
+)
+
+// parseCompareQuery parses a jump bar comparison.  It returns the teams and
+// the event tag, which is empty if none was given.
+func parseCompareQuery(q string) (teams []int, event string, ok bool) {
+	m := compareQueryPattern.FindStringSubmatch(strings.ToLower(q))
+	if m == nil {
+		return nil, "", false
+	}
+	teams, err := parseTeamList(compareVsPattern.ReplaceAllString(m[1], ","))
+	if err != nil || len(teams) < 2 {
+		return nil, "", false
+	}
+	return teams, m[2], true
+}
+
+// compareTeams shows several teams' stats side by side.  The "teams" form
+// value lists the teams.  The stats are from the event named by the "event"
+// form value, or else the season named by "year", which defaults to the
+// current season.
+func compareTeams(server *Server, w http.ResponseWriter, req *http.Request) error {
+	numbers, formError := parseTeamList(req.FormValue("teams"))
+	if formError == nil && len(numbers) == 1 {
+		formError = errors.New("Enter another team to compare with")
+	} else if formError == nil && len(numbers) > maxCompareTeams {
+		formError = errors.New("At most " + strconv.Itoa(maxCompareTeams) + " teams can be compared")
+	}
+
+	// Fetch event
+	var event *Event
+	year := time.Now().Year()
+	if s := req.FormValue("event"); s != "" {
+		tag, err := ParseEventTag(s)
+		if err != nil {
+			http.NotFound(w, req)
+			return nil
+		}
+		event, err = server.Store().FetchEvent(tag)
+		if err == StoreNotFound {
+			http.NotFound(w, req)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		year = event.Date.Year
+	} else if s := req.FormValue("year"); s != "" {
+		y, err := strconv.Atoi(s)
+		if err != nil || y <= 0 {
+			http.NotFound(w, req)
+			return nil
+		}
+		year = y
+	}
+	game := seasonGame(year)
+	if event != nil {
+		game = eventGame(event)
+	}
+
+	data := map[string]interface{}{
+		"Server":  server,
+		"Request": req,
+		"Event":   event,
+		"Year":    year,
+		"Error":   formError,
+	}
+	if formError == nil && len(numbers) > 0 {
+		teams, err := server.Store().FetchTeams(numbers)
+		if err != nil {
+			return err
+		}
+		names := make(map[int]*Team, len(teams))
+		for _, t := range teams {
+			names[t.Number] = t
+		}
+		columns := make([]*Team, len(numbers))
+		stats := make([]TeamStats, len(numbers))
+		for i, n := range numbers {
+			columns[i] = names[n]
+			if columns[i] == nil {
+				columns[i] = &Team{Number: n}
+			}
+			if event != nil {
+				stats[i], err = server.Store().TeamEventStats(event.Tag(), n)
+			} else {
+				var season seasonStats
+				season, err = fetchSeasonStats(server.Store(), year, n)
+				stats[i] = season.Total
+			}
+			if err != nil {
+				return err
+			}
+		}
+		data["Teams"] = columns
+		data["Rows"] = compareRows(game, stats)
+		data["Timeline"] = svgChart(compareTimeline(numbers, stats), wideChartWidth, chartHeight)
+	}
+	data["TeamList"] = req.FormValue("teams")
+	return server.Templates().ExecuteTemplate(w, "team-compare.html", data)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTeamList(t *testing.T) {
+	tests := []struct {
+		S        string
+		Expected []int
+		Error    bool
+	}{
+		{"", nil, false},
+		{"973", []int{973}, false},
+		{"973,254", []int{973, 254}, false},
+		{" 973, 254  1538 ", []int{973, 254, 1538}, false},
+		{"973,973,254", []int{973, 254}, false},
+		{"973,abc", nil, true},
+		{"973,0", nil, true},
+		{"-5", nil, true},
+	}
+	for _, test := range tests {
+		teams, err := parseTeamList(test.S)
+		if test.Error {
+			if err == nil {
+				t.Errorf("parseTeamList(%q) = %v; expected error", test.S, teams)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTeamList(%q) error: %v", test.S, err)
+		} else if !reflect.DeepEqual(teams, test.Expected) {
+			t.Errorf("parseTeamList(%q) = %v (expected %v)", test.S, teams, test.Expected)
+		}
+	}
+}
+
+func TestParseCompareQuery(t *testing.T) {
+	tests := []struct {
+		Query string
+		Teams []int
+		Event string
+		OK    bool
+	}{
+		{"1234 vs 254", []int{1234, 254}, "", true},
+		{"1234 VS. 254", []int{1234, 254}, "", true},
+		{"1234vs254", []int{1234, 254}, "", true},
+		{"973 vs 254 vs 1538", []int{973, 254, 1538}, "", true},
+		{"973 vs 254 at sdc2012", []int{973, 254}, "sdc2012", true},
+		{"973 vs 973", nil, "", false},
+		{"973", nil, "", false},
+		{"973 vs", nil, "", false},
+		{"sdc2012", nil, "", false},
+		{"973 vs 254 at", nil, "", false},
+	}
+	for _, test := range tests {
+		teams, event, ok := parseCompareQuery(test.Query)
+		if ok != test.OK || !reflect.DeepEqual(teams, test.Teams) || event != test.Event {
+			t.Errorf("parseCompareQuery(%q) = %v, %q, %t (expected %v, %q, %t)", test.Query, teams, event, ok, test.Teams, test.Event, test.OK)
+		}
+	}
+}
+
+func TestCompareRows(t *testing.T) {
+	game := &Game{}
+	stats := []TeamStats{
+		{MatchCount: 2, TotalPoints: 40, OPR: 12, DPR: 8, Scores: Distribution{15, 25}},
+		{MatchCount: 2, TotalPoints: 60, OPR: 12, DPR: 5, Scores: Distribution{30, 30}},
+		{},
+	}
+	rows := compareRows(game, stats)
+	leaders := make(map[string][]bool, len(rows))
+	for _, row := range rows {
+		l := make([]bool, len(row.Cells))
+		for i, c := range row.Cells {
+			l[i] = c.Leader
+		}
+		leaders[row.Label] = l
+	}
+	tests := []struct {
+		Label   string
+		Leaders []bool
+	}{
+		// Teams that haven't played are never leaders, even with the lowest DPR.
+		{"Average Score", []bool{false, true, false}},
+		{"DPR", []bool{false, true, false}},
+		// Ties are both leaders.
+		{"OPR", []bool{true, true, false}},
+		// Lower is better.
+		{"Score Std. Dev.", []bool{false, true, false}},
+		{"Failure Rate", []bool{false, false, false}},
+	}
+	for _, test := range tests {
+		if l := leaders[test.Label]; !reflect.DeepEqual(l, test.Leaders) {
+			t.Errorf("%s leaders = %v (expected %v)", test.Label, l, test.Leaders)
+		}
+	}
+}
+
+func TestCompareTeams(t *testing.T) {
+	store := newTestServer(t)
+	mustUpsertTeams(t, store, 1, 2, 3, 4, 5, 6)
+	event, match := seedTestEvent(t, store)
+	match.Score = map[string]int{"red": 30, "blue": 20}
+	match.Teams[0].Score = 18
+	match.Teams[3].Score = 12
+	mustUpsertMatch(t, store, event.Tag(), match)
+
+	rec := serveTestRequest(t, "/team/compare?teams=1,4&event=sdc2012", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Compare code = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, s := range []string{`class="leader"`, `class="chart"`, "Team 4"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Compare page is missing %q", s)
+		}
+	}
+
+	for _, q := range []string{"teams=1", "teams=1,2,3,4,5,6,7", "teams=x"} {
+		rec := serveTestRequest(t, "/team/compare?"+q, nil)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `class="error"`) {
+			t.Errorf("Compare %s code = %d, body missing error", q, rec.Code)
+		}
+	}
+	if rec := serveTestRequest(t, "/team/compare?teams=1,4&event=ca2011", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Compare at missing event code = %d (expected %d)", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestJumpCompare(t *testing.T) {
+	newTestServer(t)
+	tests := []struct {
+		Query    string
+		Location string
+	}{
+		{"1234+vs+254", "/team/compare?teams=1234%2C254"},
+		{"1234+vs+254+at+sdc2012", "/team/compare?event=sdc2012&teams=1234%2C254"},
+	}
+	for _, test := range tests {
+		rec := serveTestRequest(t, "/jump?q="+test.Query, nil)
+		if rec.Code != http.StatusFound {
+			t.Errorf("Jump %q code = %d", test.Query, rec.Code)
+			continue
+		}
+		if loc := rec.Header().Get("Location"); loc != test.Location {
+			t.Errorf("Jump %q location = %q (expected %q)", test.Query, loc, test.Location)
+		}
+	}
+}
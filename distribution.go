@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// A Distribution holds a team's value of a metric in each match, in the
+// order that the matches were played.  All of its statistics are zero for an
+// empty distribution.
+type Distribution []int
+
+// Mean returns the average value.
+func (d Distribution) Mean() float64 {
+	if len(d) == 0 {
+		return 0.0
+	}
+	sum := 0
+	for _, v := range d {
+		sum += v
+	}
+	return float64(sum) / float64(len(d))
+}
+
+// Median returns the middle value, or the average of the two middle values
+// if there is an even number of values.
+func (d Distribution) Median() float64 {
+	if len(d) == 0 {
+		return 0.0
+	}
+	sorted := make([]int, len(d))
+	copy(sorted, d)
+	sort.Ints(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return float64(sorted[mid-1]+sorted[mid]) / 2
+	}
+	return float64(sorted[mid])
+}
+
+// Min returns the lowest value.
+func (d Distribution) Min() int {
+	if len(d) == 0 {
+		return 0
+	}
+	min := d[0]
+	for _, v := range d[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// Max returns the highest value.
+func (d Distribution) Max() int {
+	max := 0
+	for i, v := range d {
+		if i == 0 || v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// StdDev returns the sample standard deviation.  Returns 0.0 if there are
+// fewer than two values.
+func (d Distribution) StdDev() float64 {
+	if len(d) < 2 {
+		return 0.0
+	}
+	mean := d.Mean()
+	var sum float64
+	for _, v := range d {
+		dev := float64(v) - mean
+		sum += dev * dev
+	}
+	return math.Sqrt(sum / float64(len(d)-1))
+}
+
+// Consistency returns a score from 0 to 1 of how little the values vary:
+// one minus the coefficient of variation (the standard deviation divided by
+// the mean), limited to zero.  A team that does the same thing every match
+// scores 1.  Returns 0.0 if there are fewer than two values or the mean isn't
+// positive.
+func (d Distribution) Consistency() float64 {
+	mean := d.Mean()
+	if len(d) < 2 || mean <= 0 {
+		return 0.0
+	}
+	return math.Max(0, 1-d.StdDev()/mean)
+}
+
+// Trend returns the slope of the least-squares line through the values in
+// match order: how much the value changes from one match to the next.  A
+// positive trend means the team is improving.  Returns 0.0 if there are
+// fewer than two values.
+func (d Distribution) Trend() float64 {
+	n := float64(len(d))
+	if len(d) < 2 {
+		return 0.0
+	}
+	meanX, meanY := (n-1)/2, d.Mean()
+	var sxy, sxx float64
+	for i, v := range d {
+		dx := float64(i) - meanX
+		sxy += dx * (float64(v) - meanY)
+		sxx += dx * dx
+	}
+	return sxy / sxx
+}
+
+// A MetricDistribution is a named distribution of one of a team's metrics.
+type MetricDistribution struct {
+	Name   string
+	Label  string
+	Values Distribution
+}
+
+// Distributions returns the distributions of a team's score and of each of
+// the game's aggregates.
+func (stats TeamStats) Distributions(game *Game) []MetricDistribution {
+	dists := make([]MetricDistribution, 0, len(game.Aggregates)+1)
+	dists = append(dists, MetricDistribution{"Score", "Score", stats.Scores})
+	for _, agg := range game.Aggregates {
+		dists = append(dists, MetricDistribution{agg.Name, agg.Label, stats.Field(agg.Name).Values})
+	}
+	return dists
+}
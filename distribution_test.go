@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	tests := []struct {
+		D           Distribution
+		Mean        float64
+		Median      float64
+		Min, Max    int
+		StdDev      float64
+		Consistency float64
+		Trend       float64
+	}{
+		{D: nil},
+		{D: Distribution{7}, Mean: 7, Median: 7, Min: 7, Max: 7},
+		{D: Distribution{4, 4, 4}, Mean: 4, Median: 4, Min: 4, Max: 4, Consistency: 1},
+		{D: Distribution{2, 4, 6, 8}, Mean: 5, Median: 5, Min: 2, Max: 8, StdDev: math.Sqrt(20.0 / 3), Consistency: 1 - math.Sqrt(20.0/3)/5, Trend: 2},
+		{D: Distribution{9, 1, 5}, Mean: 5, Median: 5, Min: 1, Max: 9, StdDev: 4, Consistency: 0.2, Trend: -2},
+		{D: Distribution{0, 0, 30}, Mean: 10, Median: 0, Min: 0, Max: 30, StdDev: math.Sqrt(300), Consistency: 0, Trend: 15},
+		{D: Distribution{-3, -1}, Mean: -2, Median: -2, Min: -3, Max: -1, StdDev: math.Sqrt2, Consistency: 0, Trend: 2},
+	}
+	const eps = 1e-9
+	for _, test := range tests {
+		check := func(name string, got, expected float64) {
+			if math.Abs(got-expected) > eps {
+				t.Errorf("%v.%s() = %v (expected %v)", test.D, name, got, expected)
+			}
+		}
+		check("Mean", test.D.Mean(), test.Mean)
+		check("Median", test.D.Median(), test.Median)
+		check("Min", float64(test.D.Min()), float64(test.Min))
+		check("Max", float64(test.D.Max()), float64(test.Max))
+		check("StdDev", test.D.StdDev(), test.StdDev)
+		check("Consistency", test.D.Consistency(), test.Consistency)
+		check("Trend", test.D.Trend(), test.Trend)
+	}
+}
+
+func TestDistributionMedianDoesNotSort(t *testing.T) {
+	d := Distribution{3, 1, 2}
+	d.Median()
+	if d[0] != 3 || d[1] != 1 || d[2] != 2 {
+		t.Errorf("Median reordered the distribution: %v", d)
+	}
+}
@@ -5,9 +5,10 @@ import (
 	"code.google.com/p/gorilla/mux"
 	"code.google.com/p/gorilla/schema"
 	"encoding/csv"
+	"html/template"
 	"log"
 	"net/http"
-	"reflect"
+	"net/url"
 	"strconv"
 	"time"
 )
@@ -62,12 +63,52 @@ func viewEvent(server *Server, w http.ResponseWriter, req *http.Request) error {
 		return err
 	}
 
+	// Fetch ratings
+	ratingList, err := server.Store().EventRatings(event.Tag())
+	if err != nil {
+		return err
+	}
+	ratings := make(map[int]TeamRating, len(ratingList))
+	for _, r := range ratingList {
+		ratings[r.Team] = r
+	}
+
+	// Predict unplayed matches
+	stats, err := fetchTeamStats(server.Store(), event.Tag(), event.Teams)
+	if err != nil {
+		return err
+	}
+	predictions := make([]*Prediction, len(matches))
+	for i, m := range matches {
+		if m.Score == nil {
+			predictions[i] = predictMatch(m, stats)
+		}
+	}
+
+	// Rank teams
+	game := eventGame(event)
+	rankings := computeStandings(game, matches)
+	sortStandings(game, rankings, req.FormValue("sort"))
+
+	// Chart scoring
+	var phaseChart template.HTML
+	if scatter := phaseScatter(game, stats); len(scatter.Points) > 0 {
+		phaseChart = svgChart(scatter, wideChartWidth, scatterHeight)
+	}
+
 	return server.Templates().ExecuteTemplate(w, "event.html", map[string]interface{}{
-		"Server":  server,
-		"Request": req,
-		"Event":   event,
-		"Matches": matches,
-		"Teams":   teams,
+		"Server":      server,
+		"Request":     req,
+		"Event":       event,
+		"Game":        game,
+		"Matches":     matches,
+		"Teams":       teams,
+		"Ratings":     ratings,
+		"Predictions": predictions,
+		"Rankings":    rankings,
+		"Standings":   projectStandings(game, matches, stats),
+		"Bracket":     buildBracket(event, matches),
+		"PhaseChart":  phaseChart,
 	})
 }
 
@@ -102,13 +143,26 @@ func teamMatches(server *Server, w http.ResponseWriter, req *http.Request) error
 	if err != nil {
 		return err
 	}
+	stats, err := server.Store().TeamEventStats(event.Tag(), teamNumber)
+	if err != nil {
+		return err
+	}
+
+	game := eventGame(event)
+	var matchChart template.HTML
+	if c := matchCountChart(game, teamNumber, matches); len(c.Stacks) != 0 {
+		matchChart = svgChart(c, wideChartWidth, chartHeight)
+	}
 
 	return server.Templates().ExecuteTemplate(w, "team-matches.html", map[string]interface{}{
 		"Server":     server,
 		"Request":    req,
 		"Event":      event,
+		"Game":       game,
 		"TeamNumber": teamNumber,
 		"Matches":    matches,
+		"Stats":      stats,
+		"CountChart": matchChart,
 	})
 }
 
@@ -142,11 +196,19 @@ func viewMatch(server *Server, w http.ResponseWriter, req *http.Request) error {
 		return err
 	}
 
+	// Predict outcome
+	stats, err := fetchTeamStats(server.Store(), event.Tag(), matchTeams(match))
+	if err != nil {
+		return err
+	}
+
 	return server.Templates().ExecuteTemplate(w, "match.html", map[string]interface{}{
-		"Server":  server,
-		"Request": req,
-		"Event":   event,
-		"Match":   match,
+		"Server":     server,
+		"Request":    req,
+		"Event":      event,
+		"Match":      match,
+		"Fields":     eventGame(event).ScoutedFields(),
+		"Prediction": predictMatch(match, stats),
 	})
 }
 
@@ -190,9 +252,14 @@ func scoreMatch(server *Server, w http.ResponseWriter, req *http.Request) error
 		// TODO: Show errors in validation
 
 		// Save
-		if err := server.Store().UpdateMatchScore(MatchTag{event.Tag(), match.Type, uint(match.Number)}, form.RedScore, form.BlueScore); err != nil {
+		if err := server.Store().UpdateMatchScore(MatchTag{event.Tag(), match.Type, uint(match.Number)}, form.RedScore, form.BlueScore, requestEditor(req, "")); err != nil {
 			return err
 		}
+		if match.Type != Qualification {
+			if _, err := advanceBracket(server.Store(), event); err != nil {
+				return err
+			}
+		}
 	}
 
 	// Redirect
@@ -204,31 +271,92 @@ func scoreMatch(server *Server, w http.ResponseWriter, req *http.Request) error
 	return nil
 }
 
-func convertBridgeString(s string) reflect.Value {
-	switch s {
-	case "na":
-		return reflect.ValueOf(Bridge{false, false})
-	case "fail":
-		return reflect.ValueOf(Bridge{true, false})
-	case "success":
-		return reflect.ValueOf(Bridge{true, true})
+// teamInfoForm holds the scouted fields of a team info submitted in a form.
+type teamInfoForm struct {
+	ScoutName string
+	Revision  int
+	Values    map[string]int // keyed by field name
+}
+
+// decodeTeamInfoForm parses the team info form for a game in req.
+func decodeTeamInfoForm(req *http.Request, game *Game) (*teamInfoForm, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	form := new(teamInfoForm)
+	if err := form.parse(req.Form, "", game); err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
+// parse sets the form from values whose names start with prefix.  On error,
+// the form holds the values that could be parsed.
+func (form *teamInfoForm) parse(values url.Values, prefix string, game *Game) error {
+	form.ScoutName = values.Get(prefix + "ScoutName")
+	form.Revision = 0
+	form.Values = make(map[string]int)
+	var firstErr error
+	if s := values.Get(prefix + "Revision"); s != "" {
+		rev, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		form.Revision = rev
+	}
+	for _, f := range game.ScoutedFields() {
+		v, err := f.ParseFormValue(values.Get(prefix + f.Name))
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		form.Values[f.Name] = v
+	}
+	return firstErr
+}
+
+// fill sets the form's fields from info.
+func (form *teamInfoForm) fill(game *Game, info *TeamInfo) {
+	form.Revision = info.Revision
+	form.ScoutName = info.ScoutName
+	form.Values = make(map[string]int)
+	for _, f := range game.ScoutedFields() {
+		form.Values[f.Name] = f.Value(info)
+	}
+}
+
+// apply sets the scouted fields of info from the form and recalculates the
+// team's score.
+func (form *teamInfoForm) apply(game *Game, info *TeamInfo) {
+	info.Revision = form.Revision
+	info.ScoutName = form.ScoutName
+	info.Values = nil
+	for _, f := range game.ScoutedFields() {
+		f.SetValue(info, form.Values[f.Name])
+	}
+	info.Score = game.Score(info)
+}
+
+// A formField is a row of the team info form.
+type formField struct {
+	GameField
+	Value string // form encoding of the field's value
+}
+
+// fields returns the rows of the form.
+func (form *teamInfoForm) fields(game *Game) []formField {
+	scoutedFields := game.ScoutedFields()
+	fields := make([]formField, len(scoutedFields))
+	for i, f := range scoutedFields {
+		fields[i] = formField{f, f.FormValue(form.Values[f.Name])}
 	}
-	return reflect.Value{}
+	return fields
 }
 
 func editMatchTeam(server *Server, w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 
-	var form struct {
-		Autonomous   BallCount
-		Teleoperated BallCount
-		CoopBridge   Bridge
-		TeamBridge1  Bridge
-		TeamBridge2  Bridge
-		ScoutName    string
-		Failure      bool
-		NoShow       bool
-	}
+	var form teamInfoForm
+	var saved map[string]*savedField
 
 	// Fetch event
 	event, err := server.Store().FetchEvent(routeEventTag(vars))
@@ -247,16 +375,12 @@ func editMatchTeam(server *Server, w http.ResponseWriter, req *http.Request) err
 	} else if err != nil {
 		return err
 	}
+	mtag := MatchTag{event.Tag(), match.Type, uint(match.Number)}
+	game := eventGame(event)
 
 	// Get team info
 	teamNumber, _ := strconv.Atoi(vars["teamNumber"])
-	var teamInfo *TeamInfo
-	for i := range match.Teams {
-		if match.Teams[i].Team == teamNumber {
-			teamInfo = &match.Teams[i]
-			break
-		}
-	}
+	teamInfo := match.TeamInfo(teamNumber)
 	if teamInfo == nil {
 		http.NotFound(w, req)
 		return nil
@@ -264,48 +388,64 @@ func editMatchTeam(server *Server, w http.ResponseWriter, req *http.Request) err
 
 	// Parse forms
 	if req.Method == "POST" {
-		if err := req.ParseForm(); err != nil {
+		f, err := decodeTeamInfoForm(req, game)
+		if err != nil {
 			// TODO: Bad request status code
 			return err
 		}
+		form = *f
+		// TODO: Show errors in validation
 
-		d := schema.NewDecoder()
-		d.RegisterConverter(Bridge{}, convertBridgeString)
-		if err := d.Decode(&form, req.Form); err != nil {
-			return err
+		info := *teamInfo
+		form.apply(game, &info)
+
+		if form.ScoutName != "" && otherScoutReports(match, teamNumber, form.ScoutName) {
+			// Double-scouted: the canonical info comes from the reports.
+			report := info
+			report.Revision = 0
+			if err := server.Store().UpdateScoutReport(mtag, report); err != nil {
+				return err
+			}
+			return reconcileScoutReports(server, w, req, event, mtag, teamNumber, requestEditor(req, form.ScoutName))
 		}
-		// TODO: Show errors in validation
 
 		// Save
-		teamInfo.Autonomous = form.Autonomous
-		teamInfo.Teleoperated = form.Teleoperated
-		teamInfo.CoopBridge = form.CoopBridge
-		teamInfo.TeamBridge1 = form.TeamBridge1
-		teamInfo.TeamBridge2 = form.TeamBridge2
-		teamInfo.ScoutName = form.ScoutName
-		teamInfo.Failure = form.Failure
-		teamInfo.NoShow = form.NoShow
-		teamInfo.Score = CalculateScore(teamInfo.Autonomous, teamInfo.Teleoperated, teamInfo.CoopBridge, teamInfo.TeamBridge1, teamInfo.TeamBridge2)
-		if err := server.Store().UpdateMatchTeam(MatchTag{event.Tag(), match.Type, uint(match.Number)}, teamNumber, *teamInfo); err != nil {
+		err = server.Store().UpdateMatchTeam(mtag, teamNumber, info, requestEditor(req, form.ScoutName))
+		if err == nil {
+			if form.ScoutName != "" {
+				report := info
+				report.Revision = 0
+				if err := server.Store().UpdateScoutReport(mtag, report); err != nil {
+					return err
+				}
+			}
+
+			// Redirect
+			u, err := server.GetRoute("match.view").URL("year", strconv.Itoa(event.Date.Year), "location", event.Location.Code, "matchType", string(match.Type), "matchNumber", strconv.Itoa(match.Number))
+			if err != nil {
+				return err
+			}
+			http.Redirect(w, req, u.String(), http.StatusFound)
+			return nil
+		} else if err != StoreConflict {
 			return err
 		}
 
-		// Redirect
-		u, err := server.GetRoute("match.view").URL("year", strconv.Itoa(event.Date.Year), "location", event.Location.Code, "matchType", string(match.Type), "matchNumber", strconv.Itoa(match.Number))
+		// Someone else saved while the form was open.  Show the scout both
+		// versions and base a resubmission on the latest revision.
+		match, err = server.Store().FetchMatch(routeMatchTag(vars))
 		if err != nil {
 			return err
 		}
-		http.Redirect(w, req, u.String(), http.StatusFound)
-		return nil
+		teamInfo = match.TeamInfo(teamNumber)
+		if teamInfo == nil {
+			http.NotFound(w, req)
+			return nil
+		}
+		saved = savedFields(game, &info, teamInfo)
+		form.Revision = teamInfo.Revision
 	} else {
-		form.Autonomous = teamInfo.Autonomous
-		form.Teleoperated = teamInfo.Teleoperated
-		form.CoopBridge = teamInfo.CoopBridge
-		form.TeamBridge1 = teamInfo.TeamBridge1
-		form.TeamBridge2 = teamInfo.TeamBridge2
-		form.ScoutName = teamInfo.ScoutName
-		form.Failure = teamInfo.Failure
-		form.NoShow = teamInfo.NoShow
+		form.fill(game, teamInfo)
 	}
 
 	return server.Templates().ExecuteTemplate(w, "match-edit-team.html", map[string]interface{}{
@@ -314,10 +454,163 @@ func editMatchTeam(server *Server, w http.ResponseWriter, req *http.Request) err
 		"Event":    event,
 		"Match":    match,
 		"TeamInfo": teamInfo,
+		"Tag":      MatchTeamTag{mtag, uint(teamNumber)},
 		"Form":     form,
+		"Fields":   form.fields(game),
+		"Saved":    saved,
+	})
+}
+
+// A savedField is the stored value of a form field, shown when a scout's
+// submission conflicts with someone else's edit.
+type savedField struct {
+	Value   string
+	Differs bool
+}
+
+// savedFields compares the scouted fields of a submitted team info against
+// the stored team info.  The result is keyed by form field name.
+func savedFields(game *Game, submitted, stored *TeamInfo) map[string]*savedField {
+	mine, theirs := teamInfoFields(game, submitted), teamInfoFields(game, stored)
+	saved := make(map[string]*savedField, len(theirs))
+	for k, v := range theirs {
+		saved[k] = &savedField{Value: v, Differs: v != mine[k]}
+	}
+	return saved
+}
+
+// teamInfoFields returns display strings for the scouted fields of info,
+// keyed by form field name.
+func teamInfoFields(game *Game, info *TeamInfo) map[string]string {
+	scoutedFields := game.ScoutedFields()
+	fields := make(map[string]string, len(scoutedFields)+1)
+	for _, sf := range scoutedFields {
+		fields[sf.Name] = sf.DisplayValue(sf.Value(info))
+	}
+	fields["ScoutName"] = info.ScoutName
+	return fields
+}
+
+func matchHistory(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Fetch match
+	match, err := server.Store().FetchMatch(routeMatchTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Fetch history
+	history, err := server.Store().MatchHistory(routeMatchTag(vars))
+	if err != nil {
+		return err
+	}
+
+	return server.Templates().ExecuteTemplate(w, "match-history.html", map[string]interface{}{
+		"Server":   server,
+		"Request":  req,
+		"Event":    event,
+		"Match":    match,
+		"Fields":   eventGame(event).ScoutedFields(),
+		"History":  history,
+		"Conflict": req.FormValue("conflict") != "",
 	})
 }
 
+// restoreMatchChange sets a match's score or team info back to the value
+// after a change in its history.  A restored team info is rescored under the
+// event's current rules.  If the match changed while the history page was
+// open, the history page is shown again with a message.
+func restoreMatchChange(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+	tag := routeMatchTag(vars)
+
+	if req.Method != "POST" {
+		http.Error(w, "Restoring must be done with a POST", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(tag.EventTag)
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Fetch match
+	match, err := server.Store().FetchMatch(tag)
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Find change
+	history, err := server.Store().MatchHistory(tag)
+	if err != nil {
+		return err
+	}
+	i, _ := strconv.Atoi(vars["change"])
+	if i < 0 || i >= len(history) {
+		http.NotFound(w, req)
+		return nil
+	}
+	change := history[i]
+
+	// Restore
+	editor := requestEditor(req, req.FormValue("Name"))
+	if change.Team != 0 {
+		current := match.TeamInfo(change.Team)
+		if current == nil || change.NewInfo == nil {
+			http.NotFound(w, req)
+			return nil
+		}
+		info := *change.NewInfo
+		info.Revision = current.Revision
+		info.Score = eventGame(event).Score(&info)
+		err = server.Store().UpdateMatchTeam(tag, change.Team, info, editor)
+	} else {
+		err = server.Store().UpdateMatchScore(tag, change.NewScore[string(Red)], change.NewScore[string(Blue)], editor)
+		if err == nil && match.Type != Qualification {
+			_, err = advanceBracket(server.Store(), event)
+		}
+	}
+	if err != nil && err != StoreConflict {
+		return err
+	}
+
+	// Redirect
+	u, err2 := server.GetRoute("match.history").URL(
+		"year", strconv.FormatUint(uint64(tag.Year), 10),
+		"location", tag.LocationCode,
+		"matchType", string(tag.MatchType),
+		"matchNumber", strconv.FormatUint(uint64(tag.MatchNumber), 10),
+	)
+	if err2 != nil {
+		return err2
+	}
+	if err == StoreConflict {
+		u.RawQuery = "conflict=1"
+	}
+	http.Redirect(w, req, u.String(), http.StatusFound)
+	return nil
+}
+
 func eventScoutForms(server *Server, w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 
@@ -338,7 +631,24 @@ func eventScoutForms(server *Server, w http.ResponseWriter, req *http.Request) e
 
 	w.Header().Set("Content-Type", "application/pdf")
 	doc := pdf.New()
-	renderMultipleScoutForms(doc, pdf.USLetterWidth, pdf.USLetterHeight, event, matches)
+	renderMultipleScoutForms(doc, pdf.USLetterWidth, pdf.USLetterHeight, event, eventGame(event), matches, func(tag MatchTeamTag) string {
+		// Phones need the full URL, so use the host that this request came to.
+		u, err := server.GetRoute("match.editTeam").URL(
+			"year", strconv.FormatUint(uint64(tag.Year), 10),
+			"location", tag.LocationCode,
+			"matchType", string(tag.MatchType),
+			"matchNumber", strconv.FormatUint(uint64(tag.MatchNumber), 10),
+			"teamNumber", strconv.FormatUint(uint64(tag.TeamNumber), 10),
+		)
+		if err != nil {
+			return ""
+		}
+		u.Scheme, u.Host = "http", req.Host
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+		return u.String()
+	})
 	return doc.Encode(w)
 }
 
@@ -363,9 +673,18 @@ func matchSheet(server *Server, w http.ResponseWriter, req *http.Request) error
 		return err
 	}
 
+	teams := make([]int, len(match.Teams))
+	for i := range match.Teams {
+		teams[i] = match.Teams[i].Team
+	}
+	robots, err := fetchRobots(server.Store(), event.Date.Year, teams)
+	if err != nil {
+		return err
+	}
+
 	w.Header().Set("Content-Type", "application/pdf")
 	doc := pdf.New()
-	renderMatchSheet(doc, pdf.USLetterHeight, pdf.USLetterWidth, event, match, server.Store(), server.imagestore)
+	renderMatchSheet(doc, pdf.USLetterHeight, pdf.USLetterWidth, event, match, server.Store(), robots, server.imagestore)
 	return doc.Encode(w)
 }
 
@@ -381,72 +700,91 @@ func eventSpreadsheet(server *Server, w http.ResponseWriter, req *http.Request)
 		return err
 	}
 
+	game := eventGame(event)
+
 	// Write header
 	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
 	w.Header().Set("Content-Disposition", "attachment; filename=teams.csv")
 	cw := csv.NewWriter(w)
-	cw.Write([]string{
+	header := []string{
 		"Team #",
 		"Matches Played",
 		"No-Shows",
 		"Failures",
 		"Average Score",
-		"Average Teleop Scored",
-		"Average Teleop Shot",
-		"Average Auto Scored",
-		"Average Auto Shot",
-		"Max Teleop Scored",
-		"Max Teleop Shot",
-		"Coop Bridge Attempts",
-		"Coop Bridge Successes",
-		"Bridge 1 Attempts",
-		"Bridge 1 Successes",
-		"Bridge 2 Attempts",
-		"Bridge 2 Successes",
-		"Auto High",
-		"Auto Mid",
-		"Auto Low",
-		"Auto Missed",
-		"Teleop High",
-		"Teleop Mid",
-		"Teleop Low",
-		"Teleop Missed",
-	})
+		"OPR",
+		"DPR",
+		"CCWM",
+	}
+	for _, agg := range game.Aggregates {
+		header = append(header, "Average "+agg.Label)
+	}
+	for _, agg := range game.Aggregates {
+		header = append(header, "Max "+agg.Label)
+	}
+	for _, f := range game.Fields {
+		if f.Kind == AttemptField {
+			header = append(header, f.Label+" Attempts", f.Label+" Successes")
+		}
+	}
+	for _, f := range game.Fields {
+		if f.Kind != AttemptField {
+			header = append(header, f.Label)
+		}
+	}
+	for _, c := range game.Components {
+		header = append(header, c.Label+" OPR")
+	}
+	for _, d := range (TeamStats{}).Distributions(game) {
+		header = append(header, "Median "+d.Label, d.Label+" Std Dev", "Min "+d.Label, d.Label+" Consistency", d.Label+" Trend")
+	}
+	cw.Write(header)
 
 	for _, teamNum := range event.Teams {
 		stats, err := server.Store().TeamEventStats(event.Tag(), teamNum)
 		if err != nil {
 			log.Printf("Stats failed for team %d: %v", teamNum, err)
 		}
-		cw.Write([]string{
+		row := []string{
 			strconv.Itoa(teamNum),
 			strconv.Itoa(stats.MatchCount),
 			strconv.Itoa(stats.NoShowCount),
 			strconv.Itoa(stats.FailureCount),
 			strconv.FormatFloat(stats.AverageScore(), 'f', -1, 64),
-			strconv.FormatFloat(stats.AverageTeleoperatedScored(), 'f', -1, 64),
-			strconv.FormatFloat(stats.AverageTeleoperatedShot(), 'f', -1, 64),
-			strconv.FormatFloat(stats.AverageAutonomousScored(), 'f', -1, 64),
-			strconv.FormatFloat(stats.AverageAutonomousShot(), 'f', -1, 64),
-			strconv.Itoa(stats.MaxTeleoperatedScored),
-			strconv.Itoa(stats.MaxTeleoperatedShot),
-
-			strconv.Itoa(stats.CoopBridge.AttemptCount),
-			strconv.Itoa(stats.CoopBridge.SuccessCount),
-			strconv.Itoa(stats.TeamBridge1.AttemptCount),
-			strconv.Itoa(stats.TeamBridge1.SuccessCount),
-			strconv.Itoa(stats.TeamBridge2.AttemptCount),
-			strconv.Itoa(stats.TeamBridge2.SuccessCount),
-
-			strconv.Itoa(stats.AutonomousBalls.High),
-			strconv.Itoa(stats.AutonomousBalls.Mid),
-			strconv.Itoa(stats.AutonomousBalls.Low),
-			strconv.Itoa(stats.AutonomousBalls.Missed),
-			strconv.Itoa(stats.TeleoperatedBalls.High),
-			strconv.Itoa(stats.TeleoperatedBalls.Mid),
-			strconv.Itoa(stats.TeleoperatedBalls.Low),
-			strconv.Itoa(stats.TeleoperatedBalls.Missed),
-		})
+			strconv.FormatFloat(stats.OPR, 'f', 2, 64),
+			strconv.FormatFloat(stats.DPR, 'f', 2, 64),
+			strconv.FormatFloat(stats.CCWM, 'f', 2, 64),
+		}
+		for _, agg := range game.Aggregates {
+			row = append(row, strconv.FormatFloat(stats.Field(agg.Name).Average(stats.MatchCount), 'f', -1, 64))
+		}
+		for _, agg := range game.Aggregates {
+			row = append(row, strconv.Itoa(stats.Field(agg.Name).Max))
+		}
+		for _, f := range game.Fields {
+			if f.Kind == AttemptField {
+				fs := stats.Field(f.Name)
+				row = append(row, strconv.Itoa(fs.AttemptCount), strconv.Itoa(fs.Total))
+			}
+		}
+		for _, f := range game.Fields {
+			if f.Kind != AttemptField {
+				row = append(row, strconv.Itoa(stats.Field(f.Name).Total))
+			}
+		}
+		for _, c := range game.Components {
+			row = append(row, strconv.FormatFloat(stats.Components[c.Name], 'f', 2, 64))
+		}
+		for _, d := range stats.Distributions(game) {
+			row = append(row,
+				strconv.FormatFloat(d.Values.Median(), 'f', -1, 64),
+				strconv.FormatFloat(d.Values.StdDev(), 'f', 2, 64),
+				strconv.Itoa(d.Values.Min()),
+				strconv.FormatFloat(d.Values.Consistency(), 'f', 2, 64),
+				strconv.FormatFloat(d.Values.Trend(), 'f', 2, 64),
+			)
+		}
+		cw.Write(row)
 	}
 
 	cw.Flush()
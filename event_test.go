@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestServer sets up the global server with all routes and templates,
+// backed by an empty in-memory datastore.
+func newTestServer(t *testing.T) *memoryDatastore {
+	store := newMemoryDatastore()
+	server = NewServer(store)
+	server.imagestore = directoryImagestore{"testdata/images", &url.URL{Path: "/team/images/"}}
+	parseTemplates()
+	addRoutes()
+	return store
+}
+
+// serveTestRequest sends a request to the global server.  If form is not
+// nil, the request is sent as a POST.
+func serveTestRequest(t *testing.T, path string, form url.Values) *httptest.ResponseRecorder {
+	var req *http.Request
+	var err error
+	if form == nil {
+		req, err = http.NewRequest("GET", path, nil)
+	} else {
+		req, err = http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		t.Fatalf("NewRequest(%q) error: %v", path, err)
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+// seedTestEvent stores an event with a single qualification match.
+func seedTestEvent(t *testing.T, store Datastore) (*Event, *Match) {
+	event := newTestEvent("sdc", 2012, 3, 15, 1, 2, 3, 4, 5, 6)
+	mustUpsertEvent(t, store, event)
+	match := newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6)
+	mustUpsertMatch(t, store, event.Tag(), match)
+	return event, match
+}
+
+func TestEditMatchTeamConflict(t *testing.T) {
+	store := newTestServer(t)
+	event, _ := seedTestEvent(t, store)
+	const path = "/event/2012/sdc/match/qualification/1/+edit/4"
+	mtag := MatchTag{event.Tag(), Qualification, 1}
+
+	rec := serveTestRequest(t, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `name="Revision" type="hidden" value="0"`) {
+		t.Error("Edit form is missing revision")
+	}
+
+	// First scout saves.
+	rec = serveTestRequest(t, path, url.Values{"Revision": {"0"}, "Teleoperated.High": {"3"}, "ScoutName": {"Alice"}})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("First POST %s code = %d", path, rec.Code)
+	}
+
+	// Same scout submits a form loaded before the first save.  (A different
+	// scout's submission would be a second report, not a conflict.)
+	rec = serveTestRequest(t, path, url.Values{"Revision": {"0"}, "Teleoperated.High": {"5"}, "ScoutName": {"Alice"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Stale POST %s code = %d", path, rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "Someone else saved") {
+		t.Error("Stale POST did not report conflict")
+	}
+	if !strings.Contains(body, `name="Revision" type="hidden" value="1"`) {
+		t.Error("Conflict form is not based on latest revision")
+	}
+	if !strings.Contains(body, `value="5"`) || !strings.Contains(body, `<td class="saved conflict">3</td>`) {
+		t.Error("Conflict form does not show both versions")
+	}
+	m, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if info := m.TeamInfo(4); info.ScoutName != "Alice" || info.Teleoperated.High != 3 {
+		t.Errorf("Stale POST overwrote info: %+v", info)
+	}
+
+	// Scout overrides after seeing the conflict.
+	rec = serveTestRequest(t, path, url.Values{"Revision": {"1"}, "Teleoperated.High": {"5"}, "ScoutName": {"Alice"}})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Override POST %s code = %d", path, rec.Code)
+	}
+	m, err = store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if info := m.TeamInfo(4); info.ScoutName != "Alice" || info.Teleoperated.High != 5 || info.Revision != 2 {
+		t.Errorf("Override POST stored %+v", info)
+	}
+	if reports := m.ScoutReports(4); len(reports) != 1 || reports[0].Teleoperated.High != 5 {
+		t.Errorf("Override POST reports = %+v", reports)
+	}
+}
+
+func TestEditMatchTeamDoubleScouted(t *testing.T) {
+	store := newTestServer(t)
+	event, _ := seedTestEvent(t, store)
+	const (
+		path          = "/event/2012/sdc/match/qualification/1/+edit/4"
+		reconcilePath = "/event/2012/sdc/match/qualification/1/+reconcile/4"
+	)
+	mtag := MatchTag{event.Tag(), Qualification, 1}
+
+	// Both scouts load the form at revision 0 and agree.
+	for _, name := range []string{"Alice", "Bob"} {
+		rec := serveTestRequest(t, path, url.Values{"Revision": {"0"}, "Teleoperated.High": {"3"}, "ScoutName": {name}})
+		if rec.Code != http.StatusFound || strings.Contains(rec.Header().Get("Location"), "+reconcile") {
+			t.Fatalf("POST %s as %s code = %d, Location = %q", path, name, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+	m, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if info := m.TeamInfo(4); info.ScoutName != "Alice, Bob" || info.Teleoperated.High != 3 || info.Score != 9 {
+		t.Errorf("After agreeing reports, info = %+v", info)
+	}
+
+	// Bob corrects his report, so the scouts now disagree.
+	rec := serveTestRequest(t, path, url.Values{"Revision": {"0"}, "Teleoperated.High": {"4"}, "ScoutName": {"Bob"}})
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != reconcilePath {
+		t.Fatalf("Disagreeing POST code = %d, Location = %q", rec.Code, rec.Header().Get("Location"))
+	}
+	m, err = store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if info := m.TeamInfo(4); info.Teleoperated.High != 3 {
+		t.Errorf("Disagreeing report changed info: %+v", info)
+	}
+	if !m.NeedsReconcile(4) {
+		t.Error("NeedsReconcile(4) = false after disagreeing reports")
+	}
+
+	rec = serveTestRequest(t, "/event/2012/sdc/match/qualification/1/", nil)
+	if body := rec.Body.String(); strings.Count(body, `class="edit_link reconcile_link"`) != 1 {
+		t.Error("Match page does not link to reconciliation")
+	}
+
+	rec = serveTestRequest(t, reconcilePath, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", reconcilePath, rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `<tr class="disagree">`) || !strings.Contains(body, "Average (3.5)") {
+		t.Errorf("Reconcile page does not show disagreement:\n%s", body)
+	}
+
+	// Lead picks the average.
+	form := url.Values{"Revision": {strconv.Itoa(m.TeamInfo(4).Revision)}, "ScoutName": {"Alice, Bob"}}
+	for _, f := range reboundRumble.ScoutedFields() {
+		form.Set(f.Name, f.FormValue(f.Value(m.TeamInfo(4))))
+	}
+	form.Set("Teleoperated.High", "4")
+	rec = serveTestRequest(t, reconcilePath, form)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("POST %s code = %d", reconcilePath, rec.Code)
+	}
+	m, err = store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if info := m.TeamInfo(4); info.Teleoperated.High != 4 || info.Score != 12 || info.ScoutName != "Alice, Bob" {
+		t.Errorf("After reconciling, info = %+v", info)
+	}
+
+	// A stale reconciliation is rejected.
+	rec = serveTestRequest(t, reconcilePath, form)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Someone else changed") {
+		t.Errorf("Stale reconcile POST code = %d", rec.Code)
+	}
+}
+
+func TestMatchHistoryRestore(t *testing.T) {
+	store := newTestServer(t)
+	event, _ := seedTestEvent(t, store)
+	const (
+		editPath    = "/event/2012/sdc/match/qualification/1/+edit/4"
+		historyPath = "/event/2012/sdc/match/qualification/1/history/"
+	)
+	mtag := MatchTag{event.Tag(), Qualification, 1}
+
+	for i, high := range []string{"3", "7"} {
+		rec := serveTestRequest(t, editPath, url.Values{"Revision": {strconv.Itoa(i)}, "Teleoperated.High": {high}, "ScoutName": {"Alice"}})
+		if rec.Code != http.StatusFound {
+			t.Fatalf("POST %s code = %d", editPath, rec.Code)
+		}
+	}
+
+	rec := serveTestRequest(t, historyPath, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", historyPath, rec.Code)
+	}
+	if body := rec.Body.String(); strings.Count(body, `value="Restore"`) != 2 || !strings.Contains(body, "Alice") {
+		t.Errorf("History page does not list both changes:\n%s", body)
+	}
+
+	// A match changed by someone else isn't restored.
+	server.datastore = &conflictDatastore{Datastore: store, Conflicts: 1}
+	rec = serveTestRequest(t, historyPath+"0/+restore", url.Values{})
+	if rec.Code != http.StatusFound || !strings.HasSuffix(rec.HeaderMap.Get("Location"), historyPath+"?conflict=1") {
+		t.Fatalf("Conflicting POST restore code = %d, Location = %q", rec.Code, rec.HeaderMap.Get("Location"))
+	}
+	if rec := serveTestRequest(t, historyPath+"?conflict=1", nil); !strings.Contains(rec.Body.String(), `class="error"`) {
+		t.Error("History page after a conflict doesn't show an error")
+	}
+
+	// The restored info is scored by the event's current rules.
+	event.Rules = &ScoringRules{Points: []FieldPoints{{"Teleoperated.High", 10}}}
+	mustUpsertEvent(t, store, event)
+	rec = serveTestRequest(t, historyPath+"0/+restore", url.Values{})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("POST restore code = %d", rec.Code)
+	}
+	m, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if info := m.TeamInfo(4); info.Teleoperated.High != 3 || info.Revision != 3 {
+		t.Errorf("After restore, info = %+v", info)
+	} else if score := eventGame(event).Score(info); info.Score != score || score < 30 {
+		t.Errorf("After restore, info.Score = %d (expected %d)", info.Score, score)
+	}
+	if history, err := store.MatchHistory(mtag); err != nil {
+		t.Errorf("MatchHistory error: %v", err)
+	} else if len(history) != 3 {
+		t.Errorf("len(MatchHistory) after restore = %d (expected 3)", len(history))
+	}
+
+	if rec := serveTestRequest(t, historyPath+"5/+restore", url.Values{}); rec.Code != http.StatusNotFound {
+		t.Errorf("POST restore of missing change code = %d (expected %d)", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestViewEventPredictions(t *testing.T) {
+	store := newTestServer(t)
+	seedTestEvent(t, store)
+
+	const path = "/event/2012/sdc/"
+	rec := serveTestRequest(t, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `id="rankings"`) || !strings.Contains(body, "Projected Standings") || !strings.Contains(body, `<span class="red_prediction">Red 50.0%</span>`) {
+		t.Errorf("Event page is missing predictions:\n%s", body)
+	}
+}
+
+func TestEditMatchTeamGameFields(t *testing.T) {
+	games = append(games, testGame)
+	defer func() { games = games[:len(games)-1] }()
+
+	store := newTestServer(t)
+	event := newTestEvent("sdc", 2099, 3, 15, 1, 2, 3, 4, 5, 6)
+	event.Game = testGame.ID
+	mustUpsertEvent(t, store, event)
+	mustUpsertMatch(t, store, event.Tag(), newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	const path = "/event/2099/sdc/match/qualification/1/+edit/4"
+
+	rec := serveTestRequest(t, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `name="AutoDiscs"`) || !strings.Contains(body, `<select name="Climb"`) || strings.Contains(body, `name="Autonomous.High"`) {
+		t.Errorf("Edit form does not show game fields:\n%s", body)
+	}
+
+	form := url.Values{"Revision": {"0"}, "AutoDiscs": {"2"}, "Moved": {"1"}, "Climb": {"success"}, "ScoutName": {"Alice"}}
+	rec = serveTestRequest(t, path, form)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("POST %s code = %d", path, rec.Code)
+	}
+	m, err := store.FetchMatch(MatchTag{event.Tag(), Qualification, 1})
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	info := m.TeamInfo(4)
+	if info.Values["AutoDiscs"] != 2 || info.Values["Moved"] != 1 || info.Values["Climb"] != AttemptSucceeded || info.Score != 20 {
+		t.Errorf("After POST, info = %+v", info)
+	}
+
+	// Pages that show the info use the game's fields.
+	for _, page := range []struct {
+		Path     string
+		Expected string
+	}{
+		{"/event/2099/sdc/match/qualification/1/", `<td class="blue_alliance" colspan="4">Success</td>`},
+		{"/event/2099/sdc/match/qualification/1/history/", `<dt>Climb</dt><dd>Success</dd>`},
+		{"/event/2099/sdc/team/4", `Climb&nbsp;Success`},
+	} {
+		rec := serveTestRequest(t, page.Path, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s code = %d", page.Path, rec.Code)
+			continue
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, page.Expected) || strings.Contains(body, "Bridge 1") {
+			t.Errorf("GET %s does not show game fields:\n%s", page.Path, body)
+		}
+	}
+
+	form.Set("Revision", "1")
+	form.Set("Climb", "sort of")
+	if rec := serveTestRequest(t, path, form); rec.Code == http.StatusFound {
+		t.Error("POST with bad attempt value succeeded")
+	}
+}
+
+func TestEventSpreadsheetDistributions(t *testing.T) {
+	store := newTestServer(t)
+	event, _ := seedTestEvent(t, store)
+	m := newTestMatch(Qualification, 2, 1, 2, 3, 4, 5, 6)
+	m.Score = map[string]int{"red": 30, "blue": 20}
+	m.Teams[0].Score = 12
+	mustUpsertMatch(t, store, event.Tag(), m)
+	m = newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6)
+	m.Score = map[string]int{"red": 10, "blue": 20}
+	m.Teams[0].Score = 4
+	mustUpsertMatch(t, store, event.Tag(), m)
+
+	rec := serveTestRequest(t, "/event/2012/sdc/teams.csv", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET teams.csv code = %d", rec.Code)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Reading teams.csv: %v", err)
+	}
+	column := func(name string) int {
+		for i, h := range rows[0] {
+			if h == name {
+				return i
+			}
+		}
+		t.Fatalf("teams.csv has no %q column", name)
+		return -1
+	}
+	// Team 1 scored 4 then 12.
+	row := rows[1]
+	if v := row[column("Median Score")]; v != "8" {
+		t.Errorf("Median Score = %q (expected 8)", v)
+	}
+	if v := row[column("Min Score")]; v != "4" {
+		t.Errorf("Min Score = %q (expected 4)", v)
+	}
+	if v := row[column("Score Trend")]; v != "8.00" {
+		t.Errorf("Score Trend = %q (expected 8.00)", v)
+	}
+}
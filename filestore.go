@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileDatastore persists model objects to a single file on disk.  The entire
+// dataset is kept in memory and the file is rewritten after every change, so
+// it is only suitable for the amount of data collected at a handful of
+// events.  The file is only read when the datastore is opened, so only one
+// process should use a file at a time.
+type fileDatastore struct {
+	*memoryDatastore
+	path   string
+	saveMu sync.Mutex
+}
+
+// fileContents is the on-disk format of a fileDatastore.
+type fileContents struct {
+	Teams   []*Team
+	Events  []*Event
+	Matches []fileEventMatches
+	History []fileMatchHistory
+	Ratings []fileEventRatings
+	Picks   []fileEventPickList
+	Robots  []fileTeamRobot
+}
+
+type fileEventMatches struct {
+	EventTag EventTag
+	Matches  []*Match
+}
+
+type fileMatchHistory struct {
+	MatchTag MatchTag
+	Changes  []MatchChange
+}
+
+type fileEventRatings struct {
+	EventTag EventTag
+	Ratings  []TeamRating
+}
+
+type fileEventPickList struct {
+	EventTag EventTag
+	List     *PickList
+}
+
+type fileTeamRobot struct {
+	Team  int
+	Year  int
+	Robot *Robot
+}
+
+// openFileDatastore opens the datastore stored at path.  If the file does not
+// exist, then the datastore starts out empty and the file is created on the
+// first change.
+func openFileDatastore(path string) (*fileDatastore, error) {
+	store := &fileDatastore{
+		memoryDatastore: newMemoryDatastore(),
+		path:            path,
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var contents fileContents
+	if err := gob.NewDecoder(f).Decode(&contents); err != nil {
+		return nil, err
+	}
+	for _, team := range contents.Teams {
+		store.teams[team.Number] = team
+	}
+	for _, event := range contents.Events {
+		store.events[event.Tag()] = event
+	}
+	for _, em := range contents.Matches {
+		store.matches[em.EventTag] = em.Matches
+	}
+	for _, mh := range contents.History {
+		store.history[mh.MatchTag] = mh.Changes
+	}
+	for _, er := range contents.Ratings {
+		store.ratings[er.EventTag] = er.Ratings
+	}
+	for _, ep := range contents.Picks {
+		store.picks[ep.EventTag] = ep.List
+	}
+	for _, tr := range contents.Robots {
+		store.robots[robotKey{tr.Team, tr.Year}] = tr.Robot
+	}
+	return store, nil
+}
+
+// update makes a change to a copy of the datastore and writes the copy to
+// disk.  The change only replaces the datastore in memory once it has been
+// saved, so a failed save leaves memory matching the file.
+func (store *fileDatastore) update(change func(*memoryDatastore) error) error {
+	store.saveMu.Lock()
+	defer store.saveMu.Unlock()
+
+	next := store.memoryDatastore.clone()
+	if err := change(next); err != nil {
+		return err
+	}
+	if err := store.save(next); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	store.teams = next.teams
+	store.events = next.events
+	store.matches = next.matches
+	store.history = next.history
+	store.ratings = next.ratings
+	store.picks = next.picks
+	store.robots = next.robots
+	store.mu.Unlock()
+	return nil
+}
+
+// save writes the contents of data to disk.  The file is replaced atomically,
+// so a crash will not leave a partially written file.  The caller must hold
+// store.saveMu.
+func (store *fileDatastore) save(data *memoryDatastore) error {
+	tmp, err := os.Create(filepath.Join(filepath.Dir(store.path), "."+filepath.Base(store.path)+".tmp"))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	var contents fileContents
+	for _, team := range data.teams {
+		contents.Teams = append(contents.Teams, team)
+	}
+	for _, event := range data.events {
+		contents.Events = append(contents.Events, event)
+	}
+	for etag, matches := range data.matches {
+		contents.Matches = append(contents.Matches, fileEventMatches{etag, matches})
+	}
+	for mtag, changes := range data.history {
+		contents.History = append(contents.History, fileMatchHistory{mtag, changes})
+	}
+	for etag, ratings := range data.ratings {
+		contents.Ratings = append(contents.Ratings, fileEventRatings{etag, ratings})
+	}
+	for etag, list := range data.picks {
+		contents.Picks = append(contents.Picks, fileEventPickList{etag, list})
+	}
+	for key, robot := range data.robots {
+		contents.Robots = append(contents.Robots, fileTeamRobot{key.Team, key.Year, robot})
+	}
+	if err := gob.NewEncoder(tmp).Encode(&contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), store.path)
+}
+
+func (store *fileDatastore) UpdateMatchScore(tag MatchTag, red int, blue int, editor Editor) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.UpdateMatchScore(tag, red, blue, editor)
+	})
+}
+
+func (store *fileDatastore) UpdateMatchTeam(tag MatchTag, teamNumber int, info TeamInfo, editor Editor) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.UpdateMatchTeam(tag, teamNumber, info, editor)
+	})
+}
+
+func (store *fileDatastore) UpdateScoutReport(tag MatchTag, report TeamInfo) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.UpdateScoutReport(tag, report)
+	})
+}
+
+func (store *fileDatastore) UpdatePickList(tag EventTag, list *PickList) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.UpdatePickList(tag, list)
+	})
+}
+
+func (store *fileDatastore) UpsertRobot(team int, year int, robot *Robot) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.UpsertRobot(team, year, robot)
+	})
+}
+
+func (store *fileDatastore) UpsertTeam(team *Team) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.UpsertTeam(team)
+	})
+}
+
+func (store *fileDatastore) UpsertEvent(event *Event) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.UpsertEvent(event)
+	})
+}
+
+func (store *fileDatastore) UpsertMatch(etag EventTag, match *Match) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.UpsertMatch(etag, match)
+	})
+}
+
+func (store *fileDatastore) DeleteMatch(tag MatchTag) error {
+	return store.update(func(m *memoryDatastore) error {
+		return m.DeleteMatch(tag)
+	})
+}
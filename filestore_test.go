@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileDatastore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scouting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := 0
+	testDatastore(t, func() (Datastore, func()) {
+		path := filepath.Join(dir, fmt.Sprintf("test%d.db", n))
+		n++
+		store, err := openFileDatastore(path)
+		if err != nil {
+			t.Fatalf("openFileDatastore error: %v", err)
+		}
+		return store, func() {}
+	})
+}
+
+func TestFileDatastoreReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scouting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scouting.db")
+
+	store, err := openFileDatastore(path)
+	if err != nil {
+		t.Fatalf("openFileDatastore error: %v", err)
+	}
+	team := &Team{Number: 973, Name: "Greybots", Robot: &Robot{Name: "Ringo"}}
+	if err := store.UpsertTeam(team); err != nil {
+		t.Fatalf("UpsertTeam error: %v", err)
+	}
+	event := new(Event)
+	event.Location.Code = "sdc"
+	event.Location.Name = "San Diego"
+	event.Date.Year = 2012
+	event.Teams = []int{254, 973}
+	if err := store.UpsertEvent(event); err != nil {
+		t.Fatalf("UpsertEvent error: %v", err)
+	}
+	match := &Match{
+		Type:   Qualification,
+		Number: 1,
+		Teams:  []TeamInfo{{Team: 973, Alliance: Red}, {Team: 254, Alliance: Blue}},
+	}
+	if err := store.UpsertMatch(event.Tag(), match); err != nil {
+		t.Fatalf("UpsertMatch error: %v", err)
+	}
+	mtag := MatchTag{event.Tag(), Qualification, 1}
+	if err := store.UpdateMatchScore(mtag, 30, 20, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+
+	store, err = openFileDatastore(path)
+	if err != nil {
+		t.Fatalf("openFileDatastore (reopen) error: %v", err)
+	}
+	if fetched, err := store.FetchTeam(973); err != nil {
+		t.Errorf("FetchTeam error: %v", err)
+	} else if !reflect.DeepEqual(fetched, team) {
+		t.Errorf("FetchTeam(973) = %#v (expected %#v)", fetched, team)
+	}
+	if fetched, err := store.FetchEvent(event.Tag()); err != nil {
+		t.Errorf("FetchEvent error: %v", err)
+	} else if !reflect.DeepEqual(fetched, event) {
+		t.Errorf("FetchEvent(%v) = %#v (expected %#v)", event.Tag(), fetched, event)
+	}
+	if fetched, err := store.FetchMatch(mtag); err != nil {
+		t.Errorf("FetchMatch error: %v", err)
+	} else if fetched.Score["red"] != 30 || fetched.Score["blue"] != 20 || len(fetched.Teams) != 2 {
+		t.Errorf("FetchMatch(%v) = %#v", mtag, fetched)
+	}
+	if ratings, err := store.EventRatings(event.Tag()); err != nil {
+		t.Errorf("EventRatings error: %v", err)
+	} else if len(ratings) != 2 || ratings[0].Team != 254 || ratings[1].OPR != 30 {
+		t.Errorf("EventRatings(%v) = %+v", event.Tag(), ratings)
+	}
+	if _, err := store.FetchTeam(254); err != StoreNotFound {
+		t.Errorf("FetchTeam(254) error = %v (expected %v)", err, StoreNotFound)
+	}
+}
+
+func TestFileDatastoreSaveFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scouting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db", "scouting.db")
+	if err := os.Mkdir(filepath.Dir(path), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openFileDatastore(path)
+	if err != nil {
+		t.Fatalf("openFileDatastore error: %v", err)
+	}
+	if err := store.UpsertTeam(&Team{Number: 973, Name: "Greybots"}); err != nil {
+		t.Fatalf("UpsertTeam error: %v", err)
+	}
+
+	// With the directory gone, saves fail and memory must not change.
+	if err := os.RemoveAll(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpsertTeam(&Team{Number: 973, Name: "Renamed"}); err == nil {
+		t.Fatal("UpsertTeam succeeded without a directory to save in")
+	}
+	if err := store.UpsertTeam(&Team{Number: 254, Name: "Cheesy Poofs"}); err == nil {
+		t.Fatal("UpsertTeam succeeded without a directory to save in")
+	}
+	if team, err := store.FetchTeam(973); err != nil {
+		t.Errorf("FetchTeam(973) error: %v", err)
+	} else if team.Name != "Greybots" {
+		t.Errorf("FetchTeam(973).Name = %q after a failed save (expected %q)", team.Name, "Greybots")
+	}
+	if _, err := store.FetchTeam(254); err != StoreNotFound {
+		t.Errorf("FetchTeam(254) error = %v after a failed save (expected %v)", err, StoreNotFound)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+)
+
+// A Game describes a season's game: what scouts record about each team in
+// a match, how many points each thing is worth, and which totals are
+// summarized in team statistics.
+type Game struct {
+	ID   string
+	Name string
+	Year int
+
+	// Phases lists the match phases in the order that they are played.
+	// Every field belongs to one of them.
+	Phases []string
+	Fields []GameField
+
+	// Aggregates are per-match totals of several fields that are averaged
+	// and maximized in team statistics.
+	Aggregates []GameAggregate
+
+	// Components are per-match totals that are given least-squares ratings
+	// like OPR.
+	Components []GameAggregate
+
+	// Bonuses are points awarded in addition to the fields' points.
+	Bonuses []ScoringBonus
+
+	// Ranking is how teams are ranked in qualification matches.
+	Ranking RankingRules
+}
+
+// A FieldKind is the type of value that scouts record for a field.
+type FieldKind int
+
+const (
+	CountField   FieldKind = iota // number of times something happened
+	FlagField                     // whether something happened
+	AttemptField                  // whether something was attempted and whether it succeeded
+)
+
+func (k FieldKind) String() string {
+	switch k {
+	case CountField:
+		return "count"
+	case FlagField:
+		return "flag"
+	case AttemptField:
+		return "attempt"
+	}
+	return "FieldKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Values of attempt fields
+const (
+	NotAttempted = iota
+	AttemptFailed
+	AttemptSucceeded
+)
+
+// A GameField is a value that scouts record about a team in a match.  Values
+// are stored as integers: a count, 0 or 1 for flags, or one of NotAttempted,
+// AttemptFailed or AttemptSucceeded.
+type GameField struct {
+	// Name is the form field name and the key in TeamInfo.Values.  Names of
+	// fields stored in Values must not contain dots, since they are used as
+	// document keys.
+	Name  string
+	Phase string
+	Label string
+	Short string // label within the phase, used on printed forms
+	Kind  FieldKind
+
+	// Points is the number of points for each count, for a set flag, or for
+	// a successful attempt.
+	Points int
+
+	// get and set access a field that has its own member in TeamInfo.  If
+	// they are nil, the field is stored in TeamInfo.Values.
+	get func(*TeamInfo) int
+	set func(*TeamInfo, int)
+}
+
+// Value returns the field's value in info.
+func (f *GameField) Value(info *TeamInfo) int {
+	if f.get != nil {
+		return f.get(info)
+	}
+	return info.Values[f.Name]
+}
+
+// SetValue changes the field's value in info.
+func (f *GameField) SetValue(info *TeamInfo, v int) {
+	if f.set != nil {
+		f.set(info, v)
+		return
+	}
+	if v == 0 {
+		delete(info.Values, f.Name)
+		return
+	}
+	if info.Values == nil {
+		info.Values = make(map[string]int)
+	}
+	info.Values[f.Name] = v
+}
+
+// ShortLabel returns the field's label within its phase.
+func (f *GameField) ShortLabel() string {
+	if f.Short == "" {
+		return f.Label
+	}
+	return f.Short
+}
+
+// count returns how much a value adds to a total: the count itself, or one
+// for a set flag or successful attempt.
+func (f *GameField) count(v int) int {
+	switch f.Kind {
+	case FlagField:
+		if v != 0 {
+			return 1
+		}
+		return 0
+	case AttemptField:
+		if v == AttemptSucceeded {
+			return 1
+		}
+		return 0
+	}
+	return v
+}
+
+// FormValue returns the form encoding of a value.
+func (f *GameField) FormValue(v int) string {
+	switch f.Kind {
+	case FlagField:
+		if v != 0 {
+			return "1"
+		}
+		return "0"
+	case AttemptField:
+		switch v {
+		case AttemptSucceeded:
+			return "success"
+		case AttemptFailed:
+			return "fail"
+		}
+		return "na"
+	}
+	return strconv.Itoa(v)
+}
+
+// DisplayValue returns the human-readable form of a value.
+func (f *GameField) DisplayValue(v int) string {
+	switch f.Kind {
+	case FlagField:
+		if v != 0 {
+			return "Yes"
+		}
+		return "No"
+	case AttemptField:
+		switch v {
+		case AttemptSucceeded:
+			return "Success"
+		case AttemptFailed:
+			return "Failed"
+		}
+		return "Not Attempted"
+	}
+	return strconv.Itoa(v)
+}
+
+// Display returns the human-readable form of the field's value in info, or
+// the empty string if info is nil, like an empty spot in an alliance.
+func (f *GameField) Display(info *TeamInfo) string {
+	if info == nil {
+		return ""
+	}
+	return f.DisplayValue(f.Value(info))
+}
+
+// ParseFormValue parses the form encoding of a value.  An empty string is
+// the zero value, since browsers omit unchecked boxes.
+func (f *GameField) ParseFormValue(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	switch f.Kind {
+	case FlagField:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return 0, errors.New(f.Label + ": " + err.Error())
+		}
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+	case AttemptField:
+		switch s {
+		case "na":
+			return NotAttempted, nil
+		case "fail":
+			return AttemptFailed, nil
+		case "success":
+			return AttemptSucceeded, nil
+		}
+		return 0, errors.New(f.Label + ": unknown attempt value " + strconv.Quote(s))
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New(f.Label + ": " + err.Error())
+	}
+	if n < 0 {
+		return 0, errors.New(f.Label + ": count must not be negative")
+	}
+	return n, nil
+}
+
+// A GameAggregate is a per-match total of several fields.  Counts are added
+// directly; flags and attempts add one when set or successful.
+type GameAggregate struct {
+	Name   string
+	Label  string
+	Fields []string
+}
+
+// commonFields are recorded for every game.
+var commonFields = []GameField{
+	{
+		Name:  "Failure",
+		Label: "Failure",
+		Kind:  FlagField,
+		get:   func(info *TeamInfo) int { return flagValue(info.Failure) },
+		set:   func(info *TeamInfo, v int) { info.Failure = v != 0 },
+	},
+	{
+		Name:  "NoShow",
+		Label: "No Show",
+		Kind:  FlagField,
+		get:   func(info *TeamInfo) int { return flagValue(info.NoShow) },
+		set:   func(info *TeamInfo, v int) { info.NoShow = v != 0 },
+	},
+}
+
+func flagValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Field returns the game field with the given name, or nil if the game has
+// no such field.
+func (g *Game) Field(name string) *GameField {
+	for i := range g.Fields {
+		if g.Fields[i].Name == name {
+			return &g.Fields[i]
+		}
+	}
+	return nil
+}
+
+// PhaseFields returns the fields in a phase.
+func (g *Game) PhaseFields(phase string) []GameField {
+	var fields []GameField
+	for _, f := range g.Fields {
+		if f.Phase == phase {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// ScoutedFields returns every field that scouts fill in: the game's fields
+// followed by the common fields.
+func (g *Game) ScoutedFields() []GameField {
+	fields := make([]GameField, 0, len(g.Fields)+len(commonFields))
+	fields = append(fields, g.Fields...)
+	return append(fields, commonFields...)
+}
+
+// Score returns the number of points that a team scored by itself.
+func (g *Game) Score(info *TeamInfo) int {
+	score := 0
+	for i := range g.Fields {
+		f := &g.Fields[i]
+		score += f.count(f.Value(info)) * f.Points
+	}
+	for _, b := range g.Bonuses {
+		if b.earned(g, info) {
+			score += b.Points
+		}
+	}
+	return score
+}
+
+// Total returns an aggregate's value for a team in a match.
+func (g *Game) Total(agg GameAggregate, info *TeamInfo) int {
+	total := 0
+	for _, name := range agg.Fields {
+		if f := g.Field(name); f != nil {
+			total += f.count(f.Value(info))
+		}
+	}
+	return total
+}
+
+// games lists the built-in game definitions.
+var games = []*Game{
+	reboundRumble,
+}
+
+// LookupGame returns the game with the given ID, or nil if there is no such
+// game.
+func LookupGame(id string) *Game {
+	for _, g := range games {
+		if g.ID == id {
+			return g
+		}
+	}
+	return nil
+}
+
+// eventGame returns the game played at an event, scored with the event's
+// rules.
+func eventGame(event *Event) *Game {
+	g := baseEventGame(event)
+	if event != nil && event.Rules != nil {
+		g = g.WithRules(event.Rules)
+	}
+	return g
+}
+
+// baseEventGame returns the game played at an event with its built-in
+// scoring.  Events that don't name a game play the game from their year, or
+// Rebound Rumble if no game is defined for the year.
+func baseEventGame(event *Event) *Game {
+	if event == nil {
+		return reboundRumble
+	}
+	if g := LookupGame(event.Game); g != nil {
+		return g
+	}
+	return seasonGame(event.Date.Year)
+}
+
+// seasonGame returns the game played in a year, or Rebound Rumble if no game
+// is defined for the year.
+func seasonGame(year int) *Game {
+	for _, g := range games {
+		if g.Year == year {
+			return g
+		}
+	}
+	return reboundRumble
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+)
+
+// testGame is a game whose fields are stored in TeamInfo.Values.
+var testGame = &Game{
+	ID:     "test",
+	Name:   "Test Game",
+	Year:   2099,
+	Phases: []string{"Autonomous", "Teleoperated"},
+	Fields: []GameField{
+		{Name: "AutoDiscs", Phase: "Autonomous", Label: "Autonomous Discs", Kind: CountField, Points: 4},
+		{Name: "Moved", Phase: "Autonomous", Label: "Moved", Kind: FlagField, Points: 2},
+		{Name: "Discs", Phase: "Teleoperated", Label: "Teleoperated Discs", Kind: CountField, Points: 1},
+		{Name: "Climb", Phase: "Teleoperated", Label: "Climb", Kind: AttemptField, Points: 10},
+	},
+	Aggregates: []GameAggregate{
+		{"TotalDiscs", "Discs", []string{"AutoDiscs", "Discs"}},
+	},
+}
+
+func TestGameValues(t *testing.T) {
+	var info TeamInfo
+	for _, f := range testGame.ScoutedFields() {
+		if v := f.Value(&info); v != 0 {
+			t.Errorf("zero info %s = %d", f.Name, v)
+		}
+	}
+
+	testGame.Field("AutoDiscs").SetValue(&info, 2)
+	testGame.Field("Moved").SetValue(&info, 1)
+	testGame.Field("Discs").SetValue(&info, 5)
+	testGame.Field("Climb").SetValue(&info, AttemptSucceeded)
+	if v := testGame.Field("Discs").Value(&info); v != 5 {
+		t.Errorf("Discs = %d (expected 5)", v)
+	}
+	if score := testGame.Score(&info); score != 25 {
+		t.Errorf("Score = %d (expected 25)", score)
+	}
+	if total := testGame.Total(testGame.Aggregates[0], &info); total != 7 {
+		t.Errorf("Total(TotalDiscs) = %d (expected 7)", total)
+	}
+
+	testGame.Field("Climb").SetValue(&info, AttemptFailed)
+	if score := testGame.Score(&info); score != 15 {
+		t.Errorf("Score with failed climb = %d (expected 15)", score)
+	}
+
+	testGame.Field("Discs").SetValue(&info, 0)
+	if _, ok := info.Values["Discs"]; ok {
+		t.Error("Setting Discs to zero did not remove it from Values")
+	}
+}
+
+func TestReboundRumbleScore(t *testing.T) {
+	info := TeamInfo{
+		Autonomous:   BallCount{High: 1, Low: 1},
+		Teleoperated: BallCount{Mid: 2, Missed: 4},
+		CoopBridge:   Bridge{true, true},
+		TeamBridge1:  Bridge{true, true},
+		TeamBridge2:  Bridge{true, false},
+	}
+	if score := reboundRumble.Score(&info); score != 24 {
+		t.Errorf("Score = %d (expected 24)", score)
+	}
+	if v := reboundRumble.Field("TeamBridge2").Value(&info); v != AttemptFailed {
+		t.Errorf("TeamBridge2 = %d (expected %d)", v, AttemptFailed)
+	}
+	reboundRumble.Field("CoopBridge").SetValue(&info, NotAttempted)
+	if info.CoopBridge != (Bridge{}) {
+		t.Errorf("After clearing, CoopBridge = %v", info.CoopBridge)
+	}
+}
+
+func TestGameFieldFormValue(t *testing.T) {
+	tests := []struct {
+		Kind  FieldKind
+		Value int
+		Form  string
+	}{
+		{CountField, 0, "0"},
+		{CountField, 12, "12"},
+		{FlagField, 0, "0"},
+		{FlagField, 1, "1"},
+		{AttemptField, NotAttempted, "na"},
+		{AttemptField, AttemptFailed, "fail"},
+		{AttemptField, AttemptSucceeded, "success"},
+	}
+	for _, tt := range tests {
+		f := GameField{Name: "Field", Label: "Field", Kind: tt.Kind}
+		if s := f.FormValue(tt.Value); s != tt.Form {
+			t.Errorf("%v FormValue(%d) = %q (expected %q)", tt.Kind, tt.Value, s, tt.Form)
+		}
+		if v, err := f.ParseFormValue(tt.Form); err != nil || v != tt.Value {
+			t.Errorf("%v ParseFormValue(%q) = %d, %v (expected %d)", tt.Kind, tt.Form, v, err, tt.Value)
+		}
+	}
+
+	bad := []struct {
+		Kind FieldKind
+		Form string
+	}{
+		{CountField, "x"},
+		{CountField, "-1"},
+		{FlagField, "maybe"},
+		{AttemptField, "sort of"},
+	}
+	for _, tt := range bad {
+		f := GameField{Name: "Field", Label: "Field", Kind: tt.Kind}
+		if _, err := f.ParseFormValue(tt.Form); err == nil {
+			t.Errorf("%v ParseFormValue(%q) did not return an error", tt.Kind, tt.Form)
+		}
+	}
+}
+
+func TestEventGame(t *testing.T) {
+	games = append(games, testGame)
+	defer func() { games = games[:len(games)-1] }()
+
+	tests := []struct {
+		Event    *Event
+		Expected *Game
+	}{
+		{nil, reboundRumble},
+		{newTestEvent("sdc", 2012, 3, 15), reboundRumble},
+		{newTestEvent("sdc", 2099, 3, 15), testGame},
+		{newTestEvent("sdc", 1999, 3, 15), reboundRumble},
+		{&Event{Game: "test"}, testGame},
+		{&Event{Game: "bogus"}, reboundRumble},
+	}
+	for _, tt := range tests {
+		if g := eventGame(tt.Event); g != tt.Expected {
+			t.Errorf("eventGame(%+v) = %s (expected %s)", tt.Event, g.Name, tt.Expected.Name)
+		}
+	}
+}
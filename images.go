@@ -15,9 +15,22 @@ type Imagestore interface {
 	HasTeamImage(num int) bool
 	TeamImageURL(num int) (*url.URL, error)
 	OpenTeamImage(num int) (io.ReadCloser, error)
+
+	// AddRobotPhoto stores a JPEG photo of a team's robot from a season and
+	// returns the new photo's name.
+	AddRobotPhoto(num int, year int, r io.Reader) (string, error)
+	RobotPhotoURL(name string) (*url.URL, error)
+	OpenRobotPhoto(name string) (io.ReadCloser, error)
 }
 
-const imageNameFormat = "%d.jpg"
+const (
+	imageNameFormat = "%d.jpg"
+
+	// Robot photos are kept in a subdirectory and named by team, season
+	// and sequence number.
+	robotPhotoDir        = "robots"
+	robotPhotoNameFormat = robotPhotoDir + "/%d-%d-%d.jpg"
+)
 
 type directoryImagestore struct {
 	RootDir string
@@ -55,6 +68,42 @@ func (store directoryImagestore) OpenTeamImage(num int) (io.ReadCloser, error) {
 	return f, err
 }
 
+func (store directoryImagestore) AddRobotPhoto(num int, year int, r io.Reader) (string, error) {
+	if err := os.MkdirAll(filepath.Join(store.RootDir, robotPhotoDir), 0777); err != nil {
+		return "", err
+	}
+	for i := 1; ; i++ {
+		name := fmt.Sprintf(robotPhotoNameFormat, num, year, i)
+		f, err := os.OpenFile(filepath.Join(store.RootDir, filepath.FromSlash(name)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(f, r)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		return name, nil
+	}
+}
+
+func (store directoryImagestore) RobotPhotoURL(name string) (*url.URL, error) {
+	return store.RootURL.Parse(name)
+}
+
+func (store directoryImagestore) OpenRobotPhoto(name string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(store.RootDir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil, StoreNotFound
+	}
+	return f, err
+}
+
 // ReadTeamImage opens a team image and decodes it.
 func ReadTeamImage(store Imagestore, num int) (image.Image, error) {
 	f, err := store.OpenTeamImage(num)
@@ -65,3 +114,14 @@ func ReadTeamImage(store Imagestore, num int) (image.Image, error) {
 	img, _, err := image.Decode(f)
 	return img, err
 }
+
+// ReadRobotPhoto opens a robot photo and decodes it.
+func ReadRobotPhoto(store Imagestore, name string) (image.Image, error) {
+	f, err := store.OpenRobotPhoto(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"code.google.com/p/gorilla/mux"
 	"encoding/csv"
 	"flag"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"launchpad.net/mgo"
 	"log"
 	"net/http"
@@ -14,6 +18,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const templatePrefix = "templates/"
@@ -22,12 +27,14 @@ var server *Server
 
 // Flags
 var (
+	storeType string
 	mongoURL  string
 	database  string
 	address   string
 	imagedir  string
 	staticdir string
 	debug     bool
+	gameID    string
 )
 
 func main() {
@@ -46,30 +53,49 @@ func main() {
 			importTeams()
 		case "schedule":
 			importSchedule()
-		case "opr":
-			importOPR()
+		case "rescore":
+			rescore()
+		case "scan":
+			scanFiles()
+		case "offline":
+			ingestOfflineFiles()
 		default:
-			log.Fatal("usage: scouting [teams|schedule|opr]")
+			log.Fatal("usage: scouting [teams|schedule|rescore|scan|offline]")
 		}
 	}
 }
 
 func parseFlags() {
+	flag.StringVar(&storeType, "store", "mongo", "The datastore to use: mongo, memory, or file:PATH")
 	flag.StringVar(&mongoURL, "mongo", "localhost", "The URL for the MongoDB instance")
 	flag.StringVar(&database, "database", "scouting", "The database name in the MongoDB instance to use")
 	flag.StringVar(&address, "address", ":8080", "The address to listen for connections")
 	flag.StringVar(&staticdir, "staticdir", "static", "The directory to serve static files from")
 	flag.StringVar(&imagedir, "imagedir", "images", "The directory to serve team images from")
 	flag.BoolVar(&debug, "debug", false, "Display extra information in-browser about the program")
+	flag.StringVar(&gameID, "game", "", "The game played at the event given to the schedule command (default: the season's game)")
 	flag.Parse()
 }
 
 func openDatastore() (Datastore, error) {
-	session, err := mgo.Dial(mongoURL)
-	if err != nil {
-		return nil, err
+	switch storeType {
+	case "mongo":
+		session, err := mgo.Dial(mongoURL)
+		if err != nil {
+			return nil, err
+		}
+		return mongoDatastore{session.DB(database)}, nil
+	case "memory":
+		return newMemoryDatastore(), nil
+	}
+	if strings.HasPrefix(storeType, "file:") {
+		store, err := openFileDatastore(storeType[len("file:"):])
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
 	}
-	return mongoDatastore{session.DB(database)}, nil
+	return nil, fmt.Errorf("Unknown datastore %q", storeType)
 }
 
 func createServer() {
@@ -98,7 +124,13 @@ func addRoutes() {
 
 	teamRouter := server.PathPrefix("/team").Subrouter()
 	teamRouter.Handle("/", server.Handler(teamIndex)).Name("team.index")
+	teamRouter.Handle("/compare", server.Handler(compareTeams)).Name("team.compare")
 	teamRouter.Handle("/{number:[1-9][0-9]*}/", server.Handler(viewTeam)).Name("team.view")
+	teamRouter.Handle("/{number:[1-9][0-9]*}/{year:[1-9][0-9]*}/+pit", server.Handler(editRobot)).Name("team.pit")
+
+	server.Handle("/scout/", server.Handler(scoutIndex)).Name("scout.index")
+	server.Handle("/scan", server.Handler(scanScoutForms)).Name("scan")
+	server.Handle("/offline", server.Handler(ingestOffline)).Name("offline")
 
 	eventRootRouter := server.PathPrefix("/event").Subrouter()
 	eventRootRouter.Handle("/", server.Handler(eventIndex)).Name("event.index")
@@ -106,7 +138,15 @@ func addRoutes() {
 	eventRouter := eventRootRouter.PathPrefix("/{year:[1-9][0-9]*}/{location:[a-z]+}").Subrouter()
 	eventRouter.Handle("/", server.Handler(viewEvent)).Name("event.view")
 	eventRouter.Handle("/scout-forms.pdf", server.Handler(eventScoutForms)).Name("event.scoutForms")
+	eventRouter.Handle("/offline", server.Handler(eventOfflineForm)).Name("event.offlineForm")
+	eventRouter.Handle("/offline.appcache", server.Handler(eventOfflineManifest)).Name("event.offlineManifest")
+	eventRouter.Handle("/pit-forms.pdf", server.Handler(eventPitForms)).Name("event.pitForms")
 	eventRouter.Handle("/teams.csv", server.Handler(eventSpreadsheet)).Name("event.spreadsheet")
+	eventRouter.Handle("/accuracy", server.Handler(eventAccuracy)).Name("event.accuracy")
+	eventRouter.Handle("/+rules", server.Handler(eventRules)).Name("event.rules")
+	eventRouter.Handle("/+alliances", server.Handler(eventAlliances)).Name("event.alliances")
+	eventRouter.Handle("/+picklist", server.Handler(eventPickList)).Name("event.pickList")
+	eventRouter.Handle("/picklist.pdf", server.Handler(eventPickListPDF)).Name("event.pickListPDF")
 	eventRouter.Handle("/team/{teamNumber:[1-9][0-9]*}", server.Handler(teamMatches)).Name("event.teamMatches")
 
 	matchRouter := eventRouter.PathPrefix("/match/{matchType:qualification|quarter|semifinal|final}/{matchNumber:[1-9][0-9]*}").Subrouter()
@@ -114,6 +154,9 @@ func addRoutes() {
 	matchRouter.Handle("/match-sheet.pdf", server.Handler(matchSheet)).Name("match.sheet")
 	matchRouter.Handle("/+score", server.Handler(scoreMatch)).Name("match.score")
 	matchRouter.Handle("/+edit/{teamNumber:[1-9][0-9]*}", server.Handler(editMatchTeam)).Name("match.editTeam")
+	matchRouter.Handle("/+reconcile/{teamNumber:[1-9][0-9]*}", server.Handler(reconcileMatchTeam)).Name("match.reconcile")
+	matchRouter.Handle("/history/", server.Handler(matchHistory)).Name("match.history")
+	matchRouter.Handle("/history/{change:[0-9]+}/+restore", server.Handler(restoreMatchChange)).Name("match.restore")
 
 	server.Handle("/static{path:/.*}", makeStaticHandler(http.Dir(staticdir))).Name("static")
 	server.Handle("/team/images{path:/.*}", makeStaticHandler(http.Dir(imagedir))).Name("teamImages")
@@ -150,6 +193,25 @@ func jump(server *Server, w http.ResponseWriter, req *http.Request) error {
 			return nil
 		}
 
+		if teams, event, ok := parseCompareQuery(query); ok {
+			// Team comparison
+			u, err := server.GetRoute("team.compare").URL()
+			if err != nil {
+				return err
+			}
+			numbers := make([]string, len(teams))
+			for i, n := range teams {
+				numbers[i] = strconv.Itoa(n)
+			}
+			v := url.Values{"teams": {strings.Join(numbers, ",")}}
+			if event != "" {
+				v.Set("event", event)
+			}
+			u.RawQuery = v.Encode()
+			http.Redirect(w, req, u.String(), http.StatusFound)
+			return nil
+		}
+
 		if eventTag, err := ParseEventTag(query); err == nil {
 			// Event
 			u, err := server.GetRoute("event.view").URL(
@@ -193,6 +255,19 @@ func jump(server *Server, w http.ResponseWriter, req *http.Request) error {
 			http.Redirect(w, req, u.String(), http.StatusFound)
 			return nil
 		}
+
+		if pitTag, err := ParsePitTag(query); err == nil {
+			// Edit Robot
+			u, err := server.GetRoute("team.pit").URL(
+				"number", strconv.FormatUint(uint64(pitTag.TeamNumber), 10),
+				"year", strconv.FormatUint(uint64(pitTag.Year), 10),
+			)
+			if err != nil {
+				return err
+			}
+			http.Redirect(w, req, u.String(), http.StatusFound)
+			return nil
+		}
 	}
 	return server.Templates().ExecuteTemplate(w, "jump.html", map[string]interface{}{
 		"Server":  server,
@@ -288,6 +363,13 @@ func importSchedule() {
 	default:
 		log.Fatal("usage: scouting schedule ( CODE | DATE LOC_CODE LOC_NAME )")
 	}
+	if gameID != "" && LookupGame(gameID) == nil {
+		ids := make([]string, len(games))
+		for i, g := range games {
+			ids[i] = g.ID
+		}
+		log.Fatalf("Unknown game %q: must be one of %s", gameID, strings.Join(ids, ", "))
+	}
 
 	// Open datastore
 	datastore, err := openDatastore()
@@ -358,15 +440,32 @@ func importSchedule() {
 		event.Teams = append(event.Teams, teamNum)
 	}
 	sort.Ints(event.Teams)
+	oldGame := baseEventGame(event)
+	if gameID != "" {
+		event.Game = gameID
+	}
 	if err := datastore.UpsertEvent(event); err != nil {
 		log.Fatal("Upserting event: %v", err)
 	}
+
+	// Scouted teams are scored by the new game.
+	if flag.NArg() == 2 && baseEventGame(event) != oldGame {
+		n, err := rescoreEvent(datastore, event, Editor{Name: "scouting schedule"})
+		if err != nil {
+			log.Fatalf("Rescoring %v: %v", etag, err)
+		}
+		log.Printf("Rescored %d teams for %s", n, baseEventGame(event).Name)
+	}
 }
 
-// importOPR handles the opr command.
-func importOPR() {
-	if flag.NArg() != 1 {
-		log.Fatal("usage: scouting opr")
+// rescore handles the rescore command.
+func rescore() {
+	if flag.NArg() != 2 {
+		log.Fatal("usage: scouting rescore CODE")
+	}
+	etag, err := ParseEventTag(flag.Arg(1))
+	if err != nil {
+		log.Fatalf("Invalid code %q: %v", flag.Arg(1), err)
 	}
 
 	datastore, err := openDatastore()
@@ -374,38 +473,119 @@ func importOPR() {
 		log.Fatalln("Could not connect to database:", err)
 	}
 
-	r := csv.NewReader(os.Stdin)
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			log.Fatal(err)
-		}
+	event, err := datastore.FetchEvent(etag)
+	if err != nil {
+		log.Fatalf("Fetching event %q: %v", flag.Arg(1), err)
+	}
+	n, err := rescoreEvent(datastore, event, Editor{Name: "scouting rescore"})
+	if err != nil {
+		log.Fatalf("Rescoring %v: %v", etag, err)
+	}
+	log.Printf("Rescored %d teams", n)
+}
 
-		if len(row) != 2 {
-			log.Fatal("OPR CSV files must be number,opr")
-		}
+// scanFiles handles the scan command.  Each form read from the scans is
+// printed for review and saved if the user agrees.
+func scanFiles() {
+	if flag.NArg() < 2 {
+		log.Fatal("usage: scouting scan FILE...")
+	}
 
-		num, err := strconv.Atoi(row[0])
+	datastore, err := openDatastore()
+	if err != nil {
+		log.Fatalln("Could not connect to database:", err)
+	}
+
+	answers := bufio.NewReader(os.Stdin)
+	for _, name := range flag.Args()[1:] {
+		data, err := ioutil.ReadFile(name)
 		if err != nil {
-			log.Printf("Row found with bad number: %q (%v)", row[0], err)
-			continue
+			log.Fatal(err)
 		}
-		opr, err := strconv.ParseFloat(row[1], 64)
+		pages, err := decodeScan(name, data)
 		if err != nil {
-			log.Printf("Row found with bad OPR: %q (%v)", row[1], err)
+			log.Print(err)
 			continue
 		}
+		for _, page := range pages {
+			drafts, problems, err := scanForms(datastore, page.Image, page.Source)
+			if err != nil {
+				log.Fatalf("Reading %s: %v", page.Source, err)
+			}
+			for _, p := range problems {
+				log.Print(p)
+			}
+			for _, d := range drafts {
+				fmt.Printf("%s #%d, Team %d (%s)\n", d.Match.Type.DisplayName(), d.Match.Number, d.TeamNumber(), d.Source)
+				for _, f := range d.Fields {
+					fmt.Printf("  %-20s %s\n", f.Label+":", f.DisplayValue(d.Form.Values[f.Name]))
+				}
+				if len(d.Unclear) > 0 {
+					fmt.Printf("  Check: %s\n", strings.Join(d.Unclear, ", "))
+				}
+				fmt.Print("Save? [y/N] ")
+				answer, _ := answers.ReadString('\n')
+				if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+					continue
+				}
+				if _, err := saveScanDraft(datastore, d, Editor{Name: "scouting scan"}); err != nil {
+					log.Printf("Saving %v: %v", d.Tag, err)
+				}
+			}
+		}
+	}
+}
 
-		team, _ := datastore.FetchTeam(num)
-		if team == nil {
-			team = &Team{Number: num}
+// ingestOfflineFiles handles the offline command.  It saves the offline
+// payloads in images of QR codes and in text files, one payload per line, or
+// on standard input if no files are given.
+func ingestOfflineFiles() {
+	datastore, err := openDatastore()
+	if err != nil {
+		log.Fatalln("Could not connect to database:", err)
+	}
+
+	var sources []offlineSource
+	if flag.NArg() < 2 {
+		sources, err = readOfflinePayloads(os.Stdin, "stdin")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, name := range flag.Args()[1:] {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
 		}
-		team.OPR = opr
+		var s []offlineSource
+		if utf8.Valid(data) {
+			s, err = readOfflinePayloads(bytes.NewReader(data), name)
+		} else {
+			s, err = decodeOfflineImage(name, data)
+		}
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		sources = append(sources, s...)
+	}
 
-		if err := datastore.UpsertTeam(team); err != nil {
-			log.Printf("Error updating team: %v", err)
+	saved, drafts, problems, err := applyOfflinePayloads(datastore, sources, func(string) Editor {
+		return Editor{Name: "scouting offline"}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range problems {
+		log.Print(p)
+	}
+	for _, s := range saved {
+		fmt.Printf("Saved %s #%d, Team %d (%s)\n", s.Match.Type.DisplayName(), s.Match.Number, s.TeamNumber(), s.Source)
+		if s.NeedsReconcile {
+			fmt.Println("  Scouts disagree; reconcile their reports.")
 		}
 	}
+	for _, d := range drafts {
+		log.Printf("%s: %s #%d, Team %d was saved by someone else after the tablet loaded the form; paste the payload into the offline page to review it", d.Source, d.Match.Type.DisplayName(), d.Match.Number, d.TeamNumber())
+	}
 }
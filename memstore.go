@@ -0,0 +1,740 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryDatastore keeps model objects in memory.  It is safe to use from
+// multiple goroutines.  Values passed in and returned are copies, so callers
+// may modify them freely.
+type memoryDatastore struct {
+	mu      sync.RWMutex
+	teams   map[int]*Team
+	events  map[EventTag]*Event
+	matches map[EventTag][]*Match
+	history map[MatchTag][]MatchChange
+	ratings map[EventTag][]TeamRating
+	picks   map[EventTag]*PickList
+	robots  map[robotKey]*Robot
+}
+
+// robotKey identifies a team's robot in a season.
+type robotKey struct {
+	Team int
+	Year int
+}
+
+// newMemoryDatastore returns an empty in-memory datastore.
+func newMemoryDatastore() *memoryDatastore {
+	return &memoryDatastore{
+		teams:   make(map[int]*Team),
+		events:  make(map[EventTag]*Event),
+		matches: make(map[EventTag][]*Match),
+		history: make(map[MatchTag][]MatchChange),
+		ratings: make(map[EventTag][]TeamRating),
+		picks:   make(map[EventTag]*PickList),
+		robots:  make(map[robotKey]*Robot),
+	}
+}
+
+// clone returns a deep copy of the datastore.
+func (store *memoryDatastore) clone() *memoryDatastore {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	c := newMemoryDatastore()
+	for number, team := range store.teams {
+		c.teams[number] = copyTeam(team)
+	}
+	for tag, event := range store.events {
+		c.events[tag] = copyEvent(event)
+	}
+	for tag, matches := range store.matches {
+		ms := make([]*Match, len(matches))
+		for i, m := range matches {
+			ms[i] = copyMatch(m)
+		}
+		c.matches[tag] = ms
+	}
+	for tag, changes := range store.history {
+		cs := make([]MatchChange, len(changes))
+		for i := range changes {
+			cs[i] = copyMatchChange(changes[i])
+		}
+		c.history[tag] = cs
+	}
+	for tag, ratings := range store.ratings {
+		rs := make([]TeamRating, len(ratings))
+		copy(rs, ratings)
+		for i := range rs {
+			rs[i].Components = copyComponents(rs[i].Components)
+		}
+		c.ratings[tag] = rs
+	}
+	for tag, list := range store.picks {
+		c.picks[tag] = copyPickList(list)
+	}
+	for key, robot := range store.robots {
+		c.robots[key] = copyRobot(robot)
+	}
+	return c
+}
+
+func (store *memoryDatastore) Teams() Pager {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	teams := make([]Team, 0, len(store.teams))
+	for _, t := range store.teams {
+		teams = append(teams, *copyTeam(t))
+	}
+	sort.Sort(teamsByNumber(teams))
+	return newSlicePager(teams)
+}
+
+func (store *memoryDatastore) Events(year int) Pager {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	events := make([]Event, 0)
+	for _, e := range store.events {
+		if e.Date.Year == year {
+			events = append(events, *copyEvent(e))
+		}
+	}
+	sort.Sort(eventsByDate(events))
+	return newSlicePager(events)
+}
+
+func (store *memoryDatastore) FetchTeam(number int) (*Team, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	team := store.teams[number]
+	if team == nil {
+		return nil, StoreNotFound
+	}
+	return copyTeam(team), nil
+}
+
+func (store *memoryDatastore) FetchTeams(numbers []int) ([]*Team, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var teams []*Team
+	seen := make(map[int]bool, len(numbers))
+	for _, n := range numbers {
+		if team := store.teams[n]; team != nil && !seen[n] {
+			teams = append(teams, copyTeam(team))
+			seen[n] = true
+		}
+	}
+	sort.Sort(teamPtrsByNumber(teams))
+	return teams, nil
+}
+
+func (store *memoryDatastore) FetchEvent(tag EventTag) (*Event, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	event := store.events[tag]
+	if event == nil {
+		return nil, StoreNotFound
+	}
+	return copyEvent(event), nil
+}
+
+func (store *memoryDatastore) FetchMatches(tag EventTag) ([]*Match, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var matches []*Match
+	for _, m := range store.matches[tag] {
+		if len(matches) >= matchLimit {
+			break
+		}
+		matches = append(matches, copyMatch(m))
+	}
+	sort.Sort(byMatchOrder(matches))
+	return matches, nil
+}
+
+func (store *memoryDatastore) FetchMatch(tag MatchTag) (*Match, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	i := store.findMatch(tag.EventTag, tag.MatchType, int(tag.MatchNumber))
+	if i == -1 {
+		return nil, StoreNotFound
+	}
+	return copyMatch(store.matches[tag.EventTag][i]), nil
+}
+
+// findMatch returns the index of a match in store.matches[etag], or -1 if
+// the match is not present.  The caller must hold store.mu.
+func (store *memoryDatastore) findMatch(etag EventTag, matchType MatchType, number int) int {
+	for i, m := range store.matches[etag] {
+		if m.Type == matchType && m.Number == number {
+			return i
+		}
+	}
+	return -1
+}
+
+func (store *memoryDatastore) EventsForTeam(year int, number int) ([]EventTag, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var events []Event
+	for _, e := range store.events {
+		if e.Date.Year != year {
+			continue
+		}
+		for _, n := range e.Teams {
+			if n == number {
+				events = append(events, *e)
+				break
+			}
+		}
+	}
+	sort.Sort(eventsByDate(events))
+
+	tags := make([]EventTag, len(events))
+	for i := range events {
+		tags[i] = events[i].Tag()
+	}
+	return tags, nil
+}
+
+func (store *memoryDatastore) TeamYears(number int) ([]int, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var events []Event
+	for _, e := range store.events {
+		for _, n := range e.Teams {
+			if n == number {
+				events = append(events, *e)
+				break
+			}
+		}
+	}
+	return eventYears(events), nil
+}
+
+func (store *memoryDatastore) EventYears() ([]int, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	events := make([]Event, 0, len(store.events))
+	for _, e := range store.events {
+		events = append(events, *e)
+	}
+	return eventYears(events), nil
+}
+
+func (store *memoryDatastore) TeamEventMatches(tag EventTag, number int) ([]*Match, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var matches []*Match
+	for _, m := range store.matches[tag] {
+		if len(matches) >= matchLimit {
+			break
+		}
+		if m.TeamInfo(number) != nil {
+			matches = append(matches, copyMatch(m))
+		}
+	}
+	sort.Sort(byMatchOrder(matches))
+	return matches, nil
+}
+
+func (store *memoryDatastore) TeamEventStats(tag EventTag, number int) (TeamStats, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var stats TeamStats
+	stats.setRating(store.ratings[tag], number)
+	stats.Components = copyComponents(stats.Components)
+
+	stats.EventTag = tag
+	game := eventGame(store.events[tag])
+	var matches []*Match
+	for _, m := range store.matches[tag] {
+		if m.TeamInfo(number) != nil {
+			matches = append(matches, m)
+		}
+	}
+	sort.Sort(byMatchOrder(matches))
+	for _, m := range matches {
+		stats.addMatch(game, m, number)
+	}
+	return stats, nil
+}
+
+func (store *memoryDatastore) EventRatings(tag EventTag) ([]TeamRating, error) {
+	store.mu.RLock()
+	_, ok := store.ratings[tag]
+	store.mu.RUnlock()
+	if !ok {
+		store.mu.Lock()
+		if _, ok := store.ratings[tag]; !ok && len(store.matches[tag]) > 0 {
+			store.updateRatings(tag)
+		}
+		store.mu.Unlock()
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ratings := make([]TeamRating, len(store.ratings[tag]))
+	copy(ratings, store.ratings[tag])
+	for i := range ratings {
+		ratings[i].Components = copyComponents(ratings[i].Components)
+	}
+	return ratings, nil
+}
+
+// updateRatings recomputes the ratings for an event.  The caller must hold
+// the write lock.
+func (store *memoryDatastore) updateRatings(tag EventTag) {
+	store.ratings[tag] = computeRatings(eventGame(store.events[tag]), store.matches[tag])
+}
+
+func (store *memoryDatastore) MatchHistory(tag MatchTag) ([]MatchChange, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	changes := make([]MatchChange, len(store.history[tag]))
+	for i := range changes {
+		changes[i] = copyMatchChange(store.history[tag][i])
+	}
+	return changes, nil
+}
+
+func (store *memoryDatastore) UpdateMatchScore(tag MatchTag, red int, blue int, editor Editor) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.findMatch(tag.EventTag, tag.MatchType, int(tag.MatchNumber))
+	if i == -1 {
+		return StoreNotFound
+	}
+	m := store.matches[tag.EventTag][i]
+	change := MatchChange{Time: time.Now(), Editor: editor, OldScore: copyScore(m.Score)}
+	if m.Score == nil {
+		m.Score = make(map[string]int, 2)
+	}
+	m.Score[string(Red)] = red
+	m.Score[string(Blue)] = blue
+	change.NewScore = copyScore(m.Score)
+	store.history[tag] = append(store.history[tag], change)
+	store.updateRatings(tag.EventTag)
+	return nil
+}
+
+func (store *memoryDatastore) UpdateMatchTeam(tag MatchTag, teamNumber int, info TeamInfo, editor Editor) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.findMatch(tag.EventTag, tag.MatchType, int(tag.MatchNumber))
+	if i == -1 {
+		return StoreNotFound
+	}
+	ti := store.matches[tag.EventTag][i].TeamInfo(teamNumber)
+	if ti == nil {
+		return StoreNotFound
+	}
+	if ti.Revision != info.Revision {
+		return StoreConflict
+	}
+	old := *ti
+	*ti = copyTeamInfo(info)
+	ti.Revision++
+	newInfo := copyTeamInfo(*ti)
+	store.history[tag] = append(store.history[tag], MatchChange{
+		Time:    time.Now(),
+		Editor:  editor,
+		Team:    teamNumber,
+		OldInfo: &old,
+		NewInfo: &newInfo,
+	})
+	store.updateRatings(tag.EventTag)
+	return nil
+}
+
+func (store *memoryDatastore) UpdateScoutReport(tag MatchTag, report TeamInfo) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.findMatch(tag.EventTag, tag.MatchType, int(tag.MatchNumber))
+	if i == -1 {
+		return StoreNotFound
+	}
+	m := store.matches[tag.EventTag][i]
+	ti := m.TeamInfo(report.Team)
+	if ti == nil {
+		return StoreNotFound
+	}
+	if !ti.SameObservation(&report) || ti.ScoutName != report.ScoutName {
+		ti.Revision++
+	}
+	for j := range m.Reports {
+		if m.Reports[j].Team == report.Team && m.Reports[j].ScoutName == report.ScoutName {
+			m.Reports[j] = copyTeamInfo(report)
+			return nil
+		}
+	}
+	m.Reports = append(m.Reports, copyTeamInfo(report))
+	return nil
+}
+
+func (store *memoryDatastore) FetchPickList(tag EventTag) (*PickList, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	list := store.picks[tag]
+	if list == nil {
+		return nil, StoreNotFound
+	}
+	return copyPickList(list), nil
+}
+
+func (store *memoryDatastore) UpdatePickList(tag EventTag, list *PickList) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	revision := 0
+	if old := store.picks[tag]; old != nil {
+		revision = old.Revision
+	}
+	if list.Revision != revision {
+		return StoreConflict
+	}
+	list = copyPickList(list)
+	list.Revision++
+	store.picks[tag] = list
+	return nil
+}
+
+func (store *memoryDatastore) FetchRobot(team int, year int) (*Robot, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	robot := store.robots[robotKey{team, year}]
+	if robot == nil {
+		return nil, StoreNotFound
+	}
+	return copyRobot(robot), nil
+}
+
+func (store *memoryDatastore) UpsertRobot(team int, year int, robot *Robot) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.robots[robotKey{team, year}] = copyRobot(robot)
+	return nil
+}
+
+func (store *memoryDatastore) UpsertTeam(team *Team) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.teams[team.Number] = copyTeam(team)
+	return nil
+}
+
+func (store *memoryDatastore) UpsertEvent(event *Event) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.events[event.Tag()] = copyEvent(event)
+	store.updateRatings(event.Tag())
+	return nil
+}
+
+func (store *memoryDatastore) UpsertMatch(etag EventTag, match *Match) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if i := store.findMatch(etag, match.Type, match.Number); i != -1 {
+		store.matches[etag][i] = copyMatch(match)
+	} else {
+		store.matches[etag] = append(store.matches[etag], copyMatch(match))
+	}
+	store.updateRatings(etag)
+	return nil
+}
+
+func (store *memoryDatastore) DeleteMatch(tag MatchTag) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.findMatch(tag.EventTag, tag.MatchType, int(tag.MatchNumber))
+	if i == -1 {
+		return StoreNotFound
+	}
+	matches := store.matches[tag.EventTag]
+	store.matches[tag.EventTag] = append(matches[:i], matches[i+1:]...)
+	delete(store.history, tag)
+	store.updateRatings(tag.EventTag)
+	return nil
+}
+
+// copyTeam returns a deep copy of team.
+func copyTeam(team *Team) *Team {
+	t := new(Team)
+	*t = *team
+	if team.Robot != nil {
+		t.Robot = copyRobot(team.Robot)
+	}
+	return t
+}
+
+// copyRobot returns a deep copy of robot.
+func copyRobot(robot *Robot) *Robot {
+	r := new(Robot)
+	*r = *robot
+	if robot.Photos != nil {
+		r.Photos = append([]string(nil), robot.Photos...)
+	}
+	return r
+}
+
+// copyEvent returns a deep copy of event.
+func copyEvent(event *Event) *Event {
+	e := new(Event)
+	*e = *event
+	if event.Teams != nil {
+		e.Teams = make([]int, len(event.Teams))
+		copy(e.Teams, event.Teams)
+	}
+	if event.Rules != nil {
+		rules := new(ScoringRules)
+		rules.Points = append([]FieldPoints(nil), event.Rules.Points...)
+		for _, b := range event.Rules.Bonuses {
+			b.Fields = append([]string(nil), b.Fields...)
+			rules.Bonuses = append(rules.Bonuses, b)
+		}
+		e.Rules = rules
+	}
+	if event.Alliances != nil {
+		e.Alliances = make([]EliminationAlliance, len(event.Alliances))
+		for i, a := range event.Alliances {
+			a.Picks = append([]int(nil), a.Picks...)
+			e.Alliances[i] = a
+		}
+	}
+	return e
+}
+
+// copyPickList returns a deep copy of list.
+func copyPickList(list *PickList) *PickList {
+	l := new(PickList)
+	*l = *list
+	l.Entries = append([]PickEntry(nil), list.Entries...)
+	l.Weights = append([]StatWeight(nil), list.Weights...)
+	return l
+}
+
+// copyMatch returns a deep copy of match.
+func copyMatch(match *Match) *Match {
+	m := new(Match)
+	*m = *match
+	m.Teams = copyTeamInfos(match.Teams)
+	m.Reports = copyTeamInfos(match.Reports)
+	m.Score = copyScore(match.Score)
+	return m
+}
+
+// copyTeamInfos returns a deep copy of a slice of team infos.
+func copyTeamInfos(infos []TeamInfo) []TeamInfo {
+	if infos == nil {
+		return nil
+	}
+	c := make([]TeamInfo, len(infos))
+	for i := range infos {
+		c[i] = copyTeamInfo(infos[i])
+	}
+	return c
+}
+
+// copyTeamInfo returns a deep copy of info.
+func copyTeamInfo(info TeamInfo) TeamInfo {
+	if info.Values != nil {
+		values := make(map[string]int, len(info.Values))
+		for k, v := range info.Values {
+			values[k] = v
+		}
+		info.Values = values
+	}
+	return info
+}
+
+// copyComponents returns a copy of a component ratings map.
+func copyComponents(c ComponentRatings) ComponentRatings {
+	if c == nil {
+		return nil
+	}
+	cc := make(ComponentRatings, len(c))
+	for k, v := range c {
+		cc[k] = v
+	}
+	return cc
+}
+
+// copyScore returns a copy of a match score map.
+func copyScore(score map[string]int) map[string]int {
+	if score == nil {
+		return nil
+	}
+	s := make(map[string]int, len(score))
+	for k, v := range score {
+		s[k] = v
+	}
+	return s
+}
+
+// copyMatchChange returns a deep copy of change.
+func copyMatchChange(change MatchChange) MatchChange {
+	if change.OldInfo != nil {
+		info := copyTeamInfo(*change.OldInfo)
+		change.OldInfo = &info
+	}
+	if change.NewInfo != nil {
+		info := copyTeamInfo(*change.NewInfo)
+		change.NewInfo = &info
+	}
+	change.OldScore = copyScore(change.OldScore)
+	change.NewScore = copyScore(change.NewScore)
+	return change
+}
+
+type teamsByNumber []Team
+
+func (slice teamsByNumber) Len() int {
+	return len(slice)
+}
+
+func (slice teamsByNumber) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice teamsByNumber) Less(i, j int) bool {
+	return slice[i].Number < slice[j].Number
+}
+
+type teamPtrsByNumber []*Team
+
+func (slice teamPtrsByNumber) Len() int {
+	return len(slice)
+}
+
+func (slice teamPtrsByNumber) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice teamPtrsByNumber) Less(i, j int) bool {
+	return slice[i].Number < slice[j].Number
+}
+
+type eventsByDate []Event
+
+func (slice eventsByDate) Len() int {
+	return len(slice)
+}
+
+func (slice eventsByDate) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice eventsByDate) Less(i, j int) bool {
+	di, dj := slice[i].Date, slice[j].Date
+	if di.Month != dj.Month {
+		return di.Month < dj.Month
+	}
+	return di.Day < dj.Day
+}
+
+// slicePager pages over a slice held in memory.
+type slicePager struct {
+	slice  reflect.Value
+	offset int
+	limit  int
+}
+
+// newSlicePager returns a Pager over the elements of slice.  The slice
+// should not be modified after it is passed to newSlicePager.
+func newSlicePager(slice interface{}) slicePager {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice {
+		panic("newSlicePager given non-slice")
+	}
+	return slicePager{slice: v, limit: -1}
+}
+
+// window returns the start and end indices of the pager's results.
+func (pager slicePager) window() (start, end int) {
+	n := pager.slice.Len()
+	start, end = pager.offset, n
+	if start > n {
+		start = n
+	}
+	if pager.limit >= 0 && start+pager.limit < end {
+		end = start + pager.limit
+	}
+	return
+}
+
+func (pager slicePager) Count() (int, error) {
+	start, end := pager.window()
+	return end - start, nil
+}
+
+func (pager slicePager) Offset(n int) Pager {
+	pager.offset = n
+	return pager
+}
+
+func (pager slicePager) Limit(n int) Pager {
+	pager.limit = n
+	return pager
+}
+
+// All stores the results into result, which must be a pointer to a slice of
+// either the pager's element type or pointers to the element type.
+func (pager slicePager) All(result interface{}) error {
+	ptr := reflect.ValueOf(result)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		return errors.New("slicePager.All must be given a pointer to a slice")
+	}
+	dst := ptr.Elem()
+	elemType := pager.slice.Type().Elem()
+	isPtr := false
+	switch dst.Type().Elem() {
+	case elemType:
+	case reflect.PtrTo(elemType):
+		isPtr = true
+	default:
+		return errors.New("slicePager.All given a slice of the wrong type")
+	}
+
+	start, end := pager.window()
+	out := reflect.MakeSlice(dst.Type(), end-start, end-start)
+	for i := start; i < end; i++ {
+		if isPtr {
+			p := reflect.New(elemType)
+			p.Elem().Set(pager.slice.Index(i))
+			out.Index(i - start).Set(p)
+		} else {
+			out.Index(i - start).Set(pager.slice.Index(i))
+		}
+	}
+	dst.Set(out)
+	return nil
+}
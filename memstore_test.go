@@ -0,0 +1,139 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemoryDatastore(t *testing.T) {
+	testDatastore(t, func() (Datastore, func()) {
+		return newMemoryDatastore(), func() {}
+	})
+}
+
+func TestSlicePagerAll(t *testing.T) {
+	pager := newSlicePager([]int{42, -7, 98, 100, 5})
+
+	var vals []int
+	if err := pager.All(&vals); err != nil {
+		t.Fatalf("pager.All(...) error: %v", err)
+	}
+	if expected := []int{42, -7, 98, 100, 5}; !reflect.DeepEqual(vals, expected) {
+		t.Errorf("pager.All(...) != %#v (got %#v)", expected, vals)
+	}
+
+	if err := pager.Offset(1).Limit(2).All(&vals); err != nil {
+		t.Fatalf("pager.Offset(1).Limit(2).All(...) error: %v", err)
+	}
+	if expected := []int{-7, 98}; !reflect.DeepEqual(vals, expected) {
+		t.Errorf("pager.Offset(1).Limit(2).All(...) != %#v (got %#v)", expected, vals)
+	}
+
+	if err := pager.Offset(10).All(&vals); err != nil {
+		t.Fatalf("pager.Offset(10).All(...) error: %v", err)
+	}
+	if len(vals) != 0 {
+		t.Errorf("pager.Offset(10).All(...) != [] (got %#v)", vals)
+	}
+}
+
+func TestSlicePagerCount(t *testing.T) {
+	pager := newSlicePager([]int{42, -7, 98, 100, 5})
+	tests := []struct {
+		Pager    Pager
+		Expected int
+	}{
+		{pager, 5},
+		{pager.Limit(2), 2},
+		{pager.Offset(4), 1},
+		{pager.Offset(4).Limit(2), 1},
+		{pager.Offset(6), 0},
+	}
+	for i, tt := range tests {
+		n, err := tt.Pager.Count()
+		if err != nil {
+			t.Errorf("tests[%d].Count() error: %v", i, err)
+		} else if n != tt.Expected {
+			t.Errorf("tests[%d].Count() != %d (got %d)", i, tt.Expected, n)
+		}
+	}
+}
+
+func TestSlicePagerPointers(t *testing.T) {
+	pager := newSlicePager([]Team{{Number: 973}, {Number: 1538}})
+
+	var teams []*Team
+	if err := pager.All(&teams); err != nil {
+		t.Fatalf("pager.All(...) error: %v", err)
+	}
+	if len(teams) != 2 || teams[0].Number != 973 || teams[1].Number != 1538 {
+		t.Errorf("pager.All(...) gave wrong teams: %v", teams)
+	}
+
+	var nums []int
+	if err := pager.All(&nums); err == nil {
+		t.Error("pager.All(&[]int) did not produce an error")
+	}
+}
+
+func TestMemoryDatastoreCopies(t *testing.T) {
+	store := newMemoryDatastore()
+	etag := EventTag{"sdc", 2012}
+	match := &Match{
+		Type:   Qualification,
+		Number: 1,
+		Teams:  []TeamInfo{{Team: 973, Alliance: Red}, {Team: 254, Alliance: Blue}},
+	}
+	if err := store.UpsertMatch(etag, match); err != nil {
+		t.Fatalf("UpsertMatch error: %v", err)
+	}
+	match.Teams[0].Score = 50
+
+	fetched, err := store.FetchMatch(MatchTag{etag, Qualification, 1})
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if fetched.Teams[0].Score != 0 {
+		t.Error("Modifying upserted match changed stored match")
+	}
+	fetched.Teams[0].Score = 50
+
+	fetched, err = store.FetchMatch(MatchTag{etag, Qualification, 1})
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if fetched.Teams[0].Score != 0 {
+		t.Error("Modifying fetched match changed stored match")
+	}
+}
+
+func TestMemoryDatastoreMissingRatings(t *testing.T) {
+	store := newMemoryDatastore()
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	mtag := MatchTag{etag, Qualification, 1}
+	if err := store.UpdateMatchScore(mtag, 30, 12, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+	expected := store.ratings[etag]
+
+	// Files saved before ratings were stored don't have any.
+	delete(store.ratings, etag)
+	ratings, err := store.EventRatings(etag)
+	if err != nil {
+		t.Fatalf("EventRatings error: %v", err)
+	}
+	if len(ratings) == 0 || !reflect.DeepEqual(ratings, expected) {
+		t.Errorf("EventRatings = %+v (expected %+v)", ratings, expected)
+	}
+	if !reflect.DeepEqual(store.ratings[etag], expected) {
+		t.Errorf("stored ratings = %+v (expected %+v)", store.ratings[etag], expected)
+	}
+
+	if ratings, err := store.EventRatings(EventTag{"ca", 2012}); err != nil || len(ratings) != 0 {
+		t.Errorf("EventRatings of event without matches = %+v, %v", ratings, err)
+	}
+	if _, ok := store.ratings[EventTag{"ca", 2012}]; ok {
+		t.Error("EventRatings stored ratings for an event without matches")
+	}
+}
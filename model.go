@@ -2,19 +2,34 @@ package main
 
 import (
 	"sort"
+	"time"
 )
 
 type Team struct {
 	Number     int `bson:"_id"`
 	Name       string
 	RookieYear int `bson:"rookie_year"`
-	Robot      *Robot
-	OPR        float64
+
+	// Robot is the robot given in the team list.  Pit scouting profiles are
+	// stored for each season; see Datastore.FetchRobot.
+	Robot *Robot
 }
 
+// A Robot describes a team's robot.  Pit scouts fill in everything but the
+// name, which is usually given by the team.
 type Robot struct {
 	Name  string
 	Notes string `bson:",omitempty"`
+
+	Drivetrain          string  `bson:",omitempty"`
+	Shooter             string  `bson:",omitempty"`
+	BridgeManipulator   string  `bson:",omitempty"`
+	Weight              float64 `bson:",omitempty"` // pounds, zero if unknown
+	ProgrammingLanguage string  `bson:",omitempty"`
+
+	// Photos are the names of photos in the Imagestore, in the order that
+	// they were added.
+	Photos []string `bson:",omitempty"`
 }
 
 type MatchType string
@@ -73,6 +88,17 @@ type Event struct {
 		Day   int
 	}
 	Teams []int
+
+	// Game is the ID of the game played at the event.  If empty, the game
+	// is chosen by the event's year.
+	Game string `bson:",omitempty"`
+
+	// Rules changes how the game is scored at the event.  If nil, the
+	// game's built-in scoring is used.
+	Rules *ScoringRules `bson:",omitempty"`
+
+	// Alliances lists the elimination alliances by seed.
+	Alliances []EliminationAlliance `bson:",omitempty"`
 }
 
 func (event *Event) Tag() EventTag {
@@ -108,6 +134,11 @@ type Match struct {
 	Number int
 	Teams  []TeamInfo
 	Score  map[string]int `bson:",omitempty"`
+
+	// Reports holds each scout's own report for a team.  The info in Teams
+	// is the canonical value, which is reconciled from the reports when a
+	// team is scouted more than once.
+	Reports []TeamInfo `bson:",omitempty"`
 }
 
 // AlliancePairs returns pairs of team infos.
@@ -177,6 +208,29 @@ func (match *Match) TeamInfo(teamNum int) *TeamInfo {
 	return nil
 }
 
+// ScoutReports returns the scouting reports for a particular team.
+func (match *Match) ScoutReports(teamNum int) []TeamInfo {
+	var reports []TeamInfo
+	for _, r := range match.Reports {
+		if r.Team == teamNum {
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+// NeedsReconcile reports whether a team was scouted more than once and the
+// reports disagree.
+func (match *Match) NeedsReconcile(teamNum int) bool {
+	reports := match.ScoutReports(teamNum)
+	for i := 1; i < len(reports); i++ {
+		if !reports[0].SameObservation(&reports[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 type byMatchOrder []*Match
 
 func (slice byMatchOrder) Len() int {
@@ -199,6 +253,10 @@ type TeamInfo struct {
 	Alliance Alliance
 	Score    int
 
+	// Revision is incremented every time the info is updated.  It is used
+	// to detect concurrent edits.
+	Revision int
+
 	ScoutName    string `bson:"scout"`
 	Autonomous   BallCount
 	Teleoperated BallCount
@@ -206,11 +264,75 @@ type TeamInfo struct {
 	TeamBridge1  Bridge
 	TeamBridge2  Bridge
 
+	// Values holds the fields of games that don't have their own members,
+	// keyed by field name.  Zero values are omitted.
+	Values map[string]int `bson:",omitempty"`
+
 	// These currently won't be used.
 	Failure bool
 	NoShow  bool
 }
 
+// SameObservation reports whether two infos record the same scouted values,
+// ignoring who scouted them and the revision.
+func (info *TeamInfo) SameObservation(other *TeamInfo) bool {
+	return info.Autonomous == other.Autonomous &&
+		info.Teleoperated == other.Teleoperated &&
+		info.CoopBridge == other.CoopBridge &&
+		info.TeamBridge1 == other.TeamBridge1 &&
+		info.TeamBridge2 == other.TeamBridge2 &&
+		info.Failure == other.Failure &&
+		info.NoShow == other.NoShow &&
+		sameValues(info.Values, other.Values)
+}
+
+// sameValues reports whether two field value maps are equal.  Missing keys
+// are treated as zero.
+func sameValues(v1, v2 map[string]int) bool {
+	for k, v := range v1 {
+		if v2[k] != v {
+			return false
+		}
+	}
+	for k, v := range v2 {
+		if v1[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// An Editor identifies who made a change.
+type Editor struct {
+	Name    string `bson:",omitempty"`
+	Address string `bson:",omitempty"`
+}
+
+func (e Editor) String() string {
+	switch {
+	case e.Name == "":
+		return e.Address
+	case e.Address == "":
+		return e.Name
+	}
+	return e.Name + " (" + e.Address + ")"
+}
+
+// A MatchChange is an entry in a match's edit history.  Either the match's
+// score or a single team's info is changed.
+type MatchChange struct {
+	Time   time.Time
+	Editor Editor
+
+	// Team is the team whose info was changed, or zero if the score was changed.
+	Team    int       `bson:",omitempty"`
+	OldInfo *TeamInfo `bson:",omitempty"`
+	NewInfo *TeamInfo `bson:",omitempty"`
+
+	OldScore map[string]int `bson:",omitempty"`
+	NewScore map[string]int `bson:",omitempty"`
+}
+
 type Hoop int
 
 const (
@@ -270,28 +392,43 @@ type Bridge struct {
 	Success   bool
 }
 
-// CalculateScore computes a team's score.
-func CalculateScore(auto, teleop BallCount, coop, bridge1, bridge2 Bridge) int {
-	const (
-		teleopHighPoints = 3
-		teleopMidPoints  = 2
-		teleopLowPoints  = 1
+func (b Bridge) String() string {
+	switch {
+	case b.Success:
+		return "Success"
+	case b.Attempted:
+		return "Failed"
+	}
+	return "Not Attempted"
+}
 
-		autoHighPoints = teleopHighPoints + 3
-		autoMidPoints  = teleopMidPoints + 3
-		autoLowPoints  = teleopLowPoints + 3
+// value returns the bridge as an attempt field value.
+func (b Bridge) value() int {
+	switch {
+	case b.Success:
+		return AttemptSucceeded
+	case b.Attempted:
+		return AttemptFailed
+	}
+	return NotAttempted
+}
 
-		bridgePoints = 10
-	)
+// bridgeValue returns the bridge for an attempt field value.
+func bridgeValue(v int) Bridge {
+	return Bridge{Attempted: v != NotAttempted, Success: v == AttemptSucceeded}
+}
 
-	autoScore := auto.score(autoHighPoints, autoMidPoints, autoLowPoints)
-	teleopScore := teleop.score(teleopHighPoints, teleopMidPoints, teleopLowPoints)
-	bridgeScore := 0
-	if bridge1.Success {
-		bridgeScore += bridgePoints
+// CalculateScore computes a team's score in Rebound Rumble with the built-in
+// rules.  Use eventGame to score with an event's rules.
+func CalculateScore(auto, teleop BallCount, coop, bridge1, bridge2 Bridge) int {
+	info := TeamInfo{
+		Autonomous:   auto,
+		Teleoperated: teleop,
+		CoopBridge:   coop,
+		TeamBridge1:  bridge1,
+		TeamBridge2:  bridge2,
 	}
-
-	return autoScore + teleopScore + bridgeScore
+	return reboundRumble.Score(&info)
 }
 
 type byTeamNumber []TeamInfo
@@ -317,10 +454,16 @@ type AllianceInfo struct {
 
 // TeamStats holds team statistics.
 type TeamStats struct {
-	EventTag    EventTag
-	MatchCount  int
-	TotalPoints int
-	OPR         float64
+	EventTag      EventTag
+	MatchCount    int
+	TotalPoints   int
+	SquaredPoints int // sum of the squares of each match's points
+
+	// Least-squares ratings computed from the event's official scores
+	OPR        float64
+	DPR        float64
+	CCWM       float64
+	Components ComponentRatings
 
 	NoShowCount  int
 	FailureCount int
@@ -333,6 +476,87 @@ type TeamStats struct {
 	TeleoperatedBalls     BallCount
 	MaxTeleoperatedShot   int
 	MaxTeleoperatedScored int
+
+	// Scores holds the team's score in each match played.
+	Scores Distribution
+
+	// Fields holds statistics for each of the game's fields and aggregates,
+	// keyed by name.
+	Fields map[string]FieldStats
+}
+
+// setRating copies a team's ratings into the stats.
+func (stats *TeamStats) setRating(ratings []TeamRating, number int) {
+	for _, r := range ratings {
+		if r.Team == number {
+			stats.OPR, stats.DPR, stats.CCWM = r.OPR, r.DPR, r.CCWM
+			stats.Components = r.Components
+			return
+		}
+	}
+}
+
+// combineStats adds up statistics from several events, such as every event
+// in a season.  Counts and totals are summed, maxima are kept and
+// distributions are joined in the order given.  Ratings
+// can't be recomputed across events, so each rating is the average of the
+// events' ratings, weighted by the matches played at each event.  The
+// result's EventTag is the zero value.
+func combineStats(all []TeamStats) TeamStats {
+	var combined TeamStats
+	for _, stats := range all {
+		combined.MatchCount += stats.MatchCount
+		combined.TotalPoints += stats.TotalPoints
+		combined.SquaredPoints += stats.SquaredPoints
+		combined.Scores = append(combined.Scores, stats.Scores...)
+		combined.NoShowCount += stats.NoShowCount
+		combined.FailureCount += stats.FailureCount
+		combined.CoopBridge.combine(stats.CoopBridge)
+		combined.TeamBridge1.combine(stats.TeamBridge1)
+		combined.TeamBridge2.combine(stats.TeamBridge2)
+		combined.AutonomousBalls.Add(stats.AutonomousBalls)
+		combined.TeleoperatedBalls.Add(stats.TeleoperatedBalls)
+		if stats.MaxTeleoperatedShot > combined.MaxTeleoperatedShot {
+			combined.MaxTeleoperatedShot = stats.MaxTeleoperatedShot
+		}
+		if stats.MaxTeleoperatedScored > combined.MaxTeleoperatedScored {
+			combined.MaxTeleoperatedScored = stats.MaxTeleoperatedScored
+		}
+		for name, fs := range stats.Fields {
+			if combined.Fields == nil {
+				combined.Fields = make(map[string]FieldStats, len(stats.Fields))
+			}
+			cfs := combined.Fields[name]
+			cfs.combine(fs)
+			combined.Fields[name] = cfs
+		}
+
+		// Weighted ratings
+		w := float64(stats.MatchCount)
+		combined.OPR += stats.OPR * w
+		combined.DPR += stats.DPR * w
+		combined.CCWM += stats.CCWM * w
+		for name, r := range stats.Components {
+			if combined.Components == nil {
+				combined.Components = make(ComponentRatings, len(stats.Components))
+			}
+			combined.Components[name] += r * w
+		}
+	}
+
+	if combined.MatchCount == 0 {
+		combined.OPR, combined.DPR, combined.CCWM = 0, 0, 0
+		combined.Components = nil
+		return combined
+	}
+	n := float64(combined.MatchCount)
+	combined.OPR /= n
+	combined.DPR /= n
+	combined.CCWM /= n
+	for name := range combined.Components {
+		combined.Components[name] /= n
+	}
+	return combined
 }
 
 // AverageScore returns the average score.  Returns 0.0 if match count is zero.
@@ -343,6 +567,17 @@ func (stats TeamStats) AverageScore() float64 {
 	return float64(stats.TotalPoints) / float64(stats.MatchCount)
 }
 
+// ScoreVariance returns the sample variance of the team's score.  Returns 0.0
+// if fewer than two matches have been played.
+func (stats TeamStats) ScoreVariance() float64 {
+	if stats.MatchCount < 2 {
+		return 0.0
+	}
+	n := float64(stats.MatchCount)
+	mean := float64(stats.TotalPoints) / n
+	return (float64(stats.SquaredPoints) - n*mean*mean) / (n - 1)
+}
+
 // FailureRate returns the number of failures divided by the number of matches played.  Returns 0.0 if match count is zero.
 func (stats TeamStats) FailureRate() float64 {
 	if stats.MatchCount == 0 {
@@ -351,36 +586,135 @@ func (stats TeamStats) FailureRate() float64 {
 	return float64(stats.FailureCount) / float64(stats.MatchCount)
 }
 
-// AverageTeleoperatedShot returns the average number of balls shot per match.  Returns 0.0 if match count is zero.
-func (stats TeamStats) AverageTeleoperatedShot() float64 {
-	if stats.MatchCount == 0 {
-		return 0.0
+// addMatch adds a team's performance in a match to stats.  No-shows are
+// counted, but otherwise matches that have not been scored are skipped.
+func (stats *TeamStats) addMatch(game *Game, match *Match, number int) {
+	info := match.TeamInfo(number)
+	if info == nil {
+		// Team not found in match.  This shouldn't be hit.
+		// TODO: Log problem
+		return
+	}
+
+	if info.NoShow {
+		stats.NoShowCount++
+		return
+	}
+
+	if match.Score == nil {
+		return
+	}
+
+	stats.MatchCount++
+	stats.TotalPoints += info.Score
+	stats.SquaredPoints += info.Score * info.Score
+	stats.Scores = append(stats.Scores, info.Score)
+	if info.Failure {
+		stats.FailureCount++
+	}
+	if shot := info.Teleoperated.Total(); shot > stats.MaxTeleoperatedShot {
+		stats.MaxTeleoperatedShot = shot
+	}
+	if scored := info.Teleoperated.TotalScored(); scored > stats.MaxTeleoperatedScored {
+		stats.MaxTeleoperatedScored = scored
+	}
+	stats.AutonomousBalls.Add(info.Autonomous)
+	stats.TeleoperatedBalls.Add(info.Teleoperated)
+	stats.CoopBridge.add(info.CoopBridge)
+	stats.TeamBridge1.add(info.TeamBridge1)
+	stats.TeamBridge2.add(info.TeamBridge2)
+
+	if stats.Fields == nil {
+		stats.Fields = make(map[string]FieldStats, len(game.Fields)+len(game.Aggregates))
+	}
+	for i := range game.Fields {
+		f := &game.Fields[i]
+		fs := stats.Fields[f.Name]
+		fs.add(f.Value(info), f.Kind)
+		stats.Fields[f.Name] = fs
+	}
+	for _, agg := range game.Aggregates {
+		fs := stats.Fields[agg.Name]
+		fs.add(game.Total(agg, info), CountField)
+		stats.Fields[agg.Name] = fs
 	}
-	return float64(stats.TeleoperatedBalls.Total()) / float64(stats.MatchCount)
 }
 
-// AverageTeleoperatedScored returns the average number of balls shot per match.  Returns 0.0 if match count is zero.
-func (stats TeamStats) AverageTeleoperatedScored() float64 {
-	if stats.MatchCount == 0 {
+// Field returns the statistics for a game field or aggregate.
+func (stats TeamStats) Field(name string) FieldStats {
+	return stats.Fields[name]
+}
+
+// FieldStats holds team statistics for a game field or aggregate.
+type FieldStats struct {
+	Total        int // sum of counts, or number of flags set or successes
+	AttemptCount int // number of attempts, for attempt fields
+	Max          int // highest single-match count
+
+	// Values holds the count in each match, or 1 for a set flag or
+	// successful attempt and 0 otherwise.
+	Values Distribution
+}
+
+// add adds a single match's value to stats.
+func (stats *FieldStats) add(v int, kind FieldKind) {
+	switch kind {
+	case FlagField:
+		if v != 0 {
+			v = 1
+			stats.Total++
+		}
+	case AttemptField:
+		if v != NotAttempted {
+			stats.AttemptCount++
+		}
+		if v == AttemptSucceeded {
+			v = 1
+			stats.Total++
+		} else {
+			v = 0
+		}
+	default:
+		stats.Total += v
+		if v > stats.Max {
+			stats.Max = v
+		}
+	}
+	stats.Values = append(stats.Values, v)
+}
+
+// combine adds another event's statistics for the same field.
+func (stats *FieldStats) combine(other FieldStats) {
+	stats.Total += other.Total
+	stats.AttemptCount += other.AttemptCount
+	if other.Max > stats.Max {
+		stats.Max = other.Max
+	}
+	stats.Values = append(stats.Values, other.Values...)
+}
+
+// Average returns the total divided by matchCount.  Returns 0.0 if matchCount is zero.
+func (stats FieldStats) Average(matchCount int) float64 {
+	if matchCount == 0 {
 		return 0.0
 	}
-	return float64(stats.TeleoperatedBalls.TotalScored()) / float64(stats.MatchCount)
+	return float64(stats.Total) / float64(matchCount)
 }
 
-// AverageAutonomousShot returns the average number of balls shot per match.  Returns 0.0 if match count is zero.
-func (stats TeamStats) AverageAutonomousShot() float64 {
-	if stats.MatchCount == 0 {
+// AttemptRate returns the number of attempts divided by matchCount.  Returns 0.0 if matchCount is zero.
+func (stats FieldStats) AttemptRate(matchCount int) float64 {
+	if matchCount == 0 {
 		return 0.0
 	}
-	return float64(stats.AutonomousBalls.Total()) / float64(stats.MatchCount)
+	return float64(stats.AttemptCount) / float64(matchCount)
 }
 
-// AverageAutonomousScored returns the average number of balls shot per match.  Returns 0.0 if match count is zero.
-func (stats TeamStats) AverageAutonomousScored() float64 {
-	if stats.MatchCount == 0 {
+// SuccessRate returns the number of successes divided by the number of attempts.  Returns 0.0 if number of attempts is zero.
+func (stats FieldStats) SuccessRate() float64 {
+	if stats.AttemptCount == 0 {
 		return 0.0
 	}
-	return float64(stats.AutonomousBalls.TotalScored()) / float64(stats.MatchCount)
+	return float64(stats.Total) / float64(stats.AttemptCount)
 }
 
 // BridgeStats holds team statistics for a particular bridge.
@@ -405,6 +739,12 @@ func (stats BridgeStats) SuccessRate() float64 {
 	return float64(stats.SuccessCount) / float64(stats.AttemptCount)
 }
 
+// combine adds another event's statistics for the same bridge.
+func (stats *BridgeStats) combine(other BridgeStats) {
+	stats.AttemptCount += other.AttemptCount
+	stats.SuccessCount += other.SuccessCount
+}
+
 // addBridge adds a single bridge to stats.
 func (stats *BridgeStats) add(b Bridge) {
 	if b.Attempted {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -67,3 +68,87 @@ func TestCalculateScore(t *testing.T) {
 		}
 	}
 }
+
+func TestCombineStats(t *testing.T) {
+	stats := []TeamStats{
+		{
+			EventTag:              EventTag{"sdc", 2012},
+			MatchCount:            3,
+			TotalPoints:           30,
+			SquaredPoints:         350,
+			OPR:                   10,
+			CCWM:                  4,
+			Components:            ComponentRatings{"Teleoperated": 6},
+			FailureCount:          1,
+			CoopBridge:            BridgeStats{2, 1},
+			TeleoperatedBalls:     BallCount{High: 4, Missed: 2},
+			MaxTeleoperatedScored: 3,
+			Scores:                Distribution{10, 5, 15},
+			Fields:                map[string]FieldStats{"Teleoperated.High": {Total: 4, Max: 3, Values: Distribution{1, 0, 3}}},
+		},
+		{
+			EventTag:              EventTag{"ca", 2012},
+			MatchCount:            1,
+			TotalPoints:           20,
+			SquaredPoints:         400,
+			OPR:                   22,
+			CCWM:                  -4,
+			Components:            ComponentRatings{"Teleoperated": 10},
+			NoShowCount:           1,
+			CoopBridge:            BridgeStats{1, 1},
+			TeleoperatedBalls:     BallCount{High: 5, Low: 1},
+			MaxTeleoperatedScored: 6,
+			Scores:                Distribution{20},
+			Fields:                map[string]FieldStats{"Teleoperated.High": {Total: 5, Max: 5, Values: Distribution{5}}},
+		},
+		{
+			// An event with no matches played doesn't change the ratings.
+			EventTag: EventTag{"nv", 2012},
+			OPR:      100,
+		},
+	}
+	c := combineStats(stats)
+	if c.EventTag != (EventTag{}) {
+		t.Errorf("EventTag = %v (expected zero)", c.EventTag)
+	}
+	if c.MatchCount != 4 || c.TotalPoints != 50 || c.SquaredPoints != 750 {
+		t.Errorf("MatchCount, TotalPoints, SquaredPoints = %d, %d, %d (expected 4, 50, 750)", c.MatchCount, c.TotalPoints, c.SquaredPoints)
+	}
+	if c.FailureCount != 1 || c.NoShowCount != 1 {
+		t.Errorf("FailureCount, NoShowCount = %d, %d (expected 1, 1)", c.FailureCount, c.NoShowCount)
+	}
+	if c.CoopBridge != (BridgeStats{3, 2}) {
+		t.Errorf("CoopBridge = %+v (expected {3 2})", c.CoopBridge)
+	}
+	if c.TeleoperatedBalls != (BallCount{High: 9, Low: 1, Missed: 2}) {
+		t.Errorf("TeleoperatedBalls = %+v", c.TeleoperatedBalls)
+	}
+	if c.MaxTeleoperatedScored != 6 {
+		t.Errorf("MaxTeleoperatedScored = %d (expected 6)", c.MaxTeleoperatedScored)
+	}
+	if expected := (Distribution{10, 5, 15, 20}); !reflect.DeepEqual(c.Scores, expected) {
+		t.Errorf("Scores = %v (expected %v)", c.Scores, expected)
+	}
+	if fs, expected := c.Field("Teleoperated.High"), (FieldStats{Total: 9, Max: 5, Values: Distribution{1, 0, 3, 5}}); !reflect.DeepEqual(fs, expected) {
+		t.Errorf("Field(Teleoperated.High) = %+v (expected %+v)", fs, expected)
+	}
+	if c.OPR != 13 || c.CCWM != 2 || c.Components["Teleoperated"] != 7 {
+		t.Errorf("OPR, CCWM, Teleoperated = %v, %v, %v (expected 13, 2, 7)", c.OPR, c.CCWM, c.Components["Teleoperated"])
+	}
+
+	if c := combineStats(nil); c.MatchCount != 0 || c.OPR != 0 {
+		t.Errorf("combineStats(nil) = %+v", c)
+	}
+}
+
+func TestAddMatchMax(t *testing.T) {
+	// The match being added counts toward the maxima.
+	match := newTestMatch(Qualification, 1, 973, 2, 3, 4, 5, 6)
+	match.Score = map[string]int{"red": 30, "blue": 20}
+	match.Teams[0].Teleoperated = BallCount{High: 2, Low: 1, Missed: 3}
+	var stats TeamStats
+	stats.addMatch(reboundRumble, match, 973)
+	if stats.MaxTeleoperatedShot != 6 || stats.MaxTeleoperatedScored != 3 {
+		t.Errorf("After one match, max teleoperated = %d/%d (expected 3/6)", stats.MaxTeleoperatedScored, stats.MaxTeleoperatedShot)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/greyhound-scouting/barcode"
+	"bufio"
+	"code.google.com/p/gorilla/mux"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Offline payloads carry a scout's form from a tablet that can't reach the
+// server to one that can, as a QR code shown on the tablet.  A payload is
+// separated by spaces:
+//
+//	GS1 <match team tag> <revision> <values> <scout name>
+//
+// The values are the game's scouted fields in order, separated by dots, as
+// they are stored: counts, 0 or 1 for flags, and 0, 1 or 2 for not
+// attempted, failed or succeeded.  The scout name is the rest of the payload.
+// static/js/offline.js writes payloads on the tablet.
+const offlinePrefix = "GS1"
+
+// An offlinePayload is a scout's form read from an offline payload.
+type offlinePayload struct {
+	Tag       MatchTeamTag
+	Revision  int
+	Values    []int
+	ScoutName string
+}
+
+func (p *offlinePayload) String() string {
+	values := make([]string, len(p.Values))
+	for i, v := range p.Values {
+		values[i] = strconv.Itoa(v)
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %v %d %s %s", offlinePrefix, p.Tag, p.Revision, strings.Join(values, "."), p.ScoutName))
+}
+
+var errNotOfflinePayload = errors.New("not an offline scouting form; was it scanned from another QR code?")
+
+// parseOfflinePayload parses an offline payload.  The values aren't checked
+// against the game until the payload's event is known.
+func parseOfflinePayload(s string) (*offlinePayload, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), " ", 5)
+	if len(parts) < 4 || parts[0] != offlinePrefix {
+		return nil, errNotOfflinePayload
+	}
+	p := new(offlinePayload)
+	var err error
+	if p.Tag, err = ParseMatchTeamTag(parts[1]); err != nil {
+		return nil, err
+	}
+	if p.Revision, err = strconv.Atoi(parts[2]); err != nil || p.Revision < 0 {
+		return nil, fmt.Errorf("bad revision %q", parts[2])
+	}
+	if parts[3] != "" {
+		for _, s := range strings.Split(parts[3], ".") {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("bad value %q", s)
+			}
+			p.Values = append(p.Values, v)
+		}
+	}
+	if len(parts) == 5 {
+		p.ScoutName = strings.TrimSpace(parts[4])
+	}
+	return p, nil
+}
+
+// form checks the payload's values against a game's scouted fields and
+// returns them as a team info form.
+func (p *offlinePayload) form(game *Game) (teamInfoForm, error) {
+	fields := game.ScoutedFields()
+	if len(p.Values) != len(fields) {
+		return teamInfoForm{}, fmt.Errorf("has %d values, but %s has %d fields; was the form loaded before the game changed?", len(p.Values), game.Name, len(fields))
+	}
+	form := teamInfoForm{
+		ScoutName: p.ScoutName,
+		Revision:  p.Revision,
+		Values:    make(map[string]int, len(fields)),
+	}
+	for i, f := range fields {
+		v := p.Values[i]
+		switch {
+		case v < 0,
+			f.Kind == FlagField && v > 1,
+			f.Kind == AttemptField && v > AttemptSucceeded:
+			return teamInfoForm{}, fmt.Errorf("%s: bad value %d", f.Label, v)
+		}
+		form.Values[f.Name] = v
+	}
+	return form, nil
+}
+
+// An offlineSource is an offline payload and where it came from.
+type offlineSource struct {
+	Source  string
+	Payload string
+}
+
+// errOfflineConflict is shown for a payload of a team whose info was changed
+// after the tablet loaded the form.
+var errOfflineConflict = errors.New("Someone else saved this team's info after the tablet loaded the form.  Save again to replace it.")
+
+// applyOfflinePayloads validates payloads and saves them the way that
+// saveScanDraft saves a reviewed scan, with the scout's editor.  Payloads that
+// can't be read are described in problems.  Payloads for teams that someone
+// else saved after the tablet loaded the form are returned as drafts to be
+// reviewed, unless the saved info is the same.
+func applyOfflinePayloads(store Datastore, sources []offlineSource, editor func(scoutName string) Editor) (saved []savedScan, drafts []*scanDraft, problems []string, err error) {
+	for _, src := range sources {
+		p, err := parseOfflinePayload(src.Payload)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", src.Source, err))
+			continue
+		}
+		d, err := fetchScanDraft(store, p.Tag, src.Source)
+		if err == StoreNotFound {
+			problems = append(problems, fmt.Sprintf("%s: %v is not a scheduled match team", src.Source, p.Tag))
+			continue
+		} else if err != nil {
+			return nil, nil, nil, err
+		}
+		game := eventGame(d.Event)
+		form, err := p.form(game)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", src.Source, err))
+			continue
+		}
+		d.Form = form
+		d.Fields = d.Form.fields(game)
+
+		// Payloads are often read more than once.
+		current := d.Match.TeamInfo(d.TeamNumber())
+		info := *current
+		d.Form.apply(game, &info)
+		if info.SameObservation(current) && info.ScoutName == current.ScoutName {
+			saved = append(saved, savedScan{scanDraft: d})
+			continue
+		}
+
+		reconcile, err := saveScanDraft(store, d, editor(d.Form.ScoutName))
+		if err == StoreConflict {
+			d.Form.Revision = current.Revision
+			d.Error = errOfflineConflict
+			drafts = append(drafts, d)
+			continue
+		} else if err != nil {
+			return nil, nil, nil, err
+		}
+		saved = append(saved, savedScan{d, reconcile})
+	}
+	return saved, drafts, problems, nil
+}
+
+// readOfflinePayloads returns the non-blank lines of r as payloads.  Each
+// line is the text of one QR code, as a handheld scanner types it.
+func readOfflinePayloads(r io.Reader, source string) ([]offlineSource, error) {
+	var sources []offlineSource
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			sources = append(sources, offlineSource{fmt.Sprintf("%s line %d", source, n), line})
+		}
+		if err == io.EOF {
+			return sources, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decodeOfflineImage reads the payload in each page of an image of a QR
+// code, such as a photo or screenshot of a tablet.
+func decodeOfflineImage(name string, data []byte) ([]offlineSource, error) {
+	pages, err := decodeScan(name, data)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]offlineSource, 0, len(pages))
+	for _, page := range pages {
+		text, err := barcode.DecodeQR(page.Image)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", page.Source, err)
+		}
+		sources = append(sources, offlineSource{page.Source, text})
+	}
+	return sources, nil
+}
+
+// offlineUploads reads the payloads pasted into the ingest form and the QR
+// codes in its uploaded images.  Images that can't be read are described in
+// problems.
+func offlineUploads(pasted string, files []*multipart.FileHeader) (sources []offlineSource, problems []string, err error) {
+	sources, err = readOfflinePayloads(strings.NewReader(pasted), "Pasted")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		s, err := decodeOfflineImage(fh.Filename, data)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		sources = append(sources, s...)
+	}
+	return sources, problems, nil
+}
+
+func ingestOffline(server *Server, w http.ResponseWriter, req *http.Request) error {
+	var saved []savedScan
+	var drafts []*scanDraft
+	var problems []string
+	if req.Method == "POST" {
+		if err := req.ParseMultipartForm(maxScanUpload); err != nil && err != http.ErrNotMultipart {
+			return err
+		}
+		var files []*multipart.FileHeader
+		if req.MultipartForm != nil {
+			files = req.MultipartForm.File["Image"]
+		}
+		sources, p, err := offlineUploads(req.FormValue("Payloads"), files)
+		if err != nil {
+			return err
+		}
+		problems = p
+		if len(sources) == 0 && len(problems) == 0 {
+			problems = append(problems, "Paste some payloads or choose some images.")
+		}
+		saved, drafts, p, err = applyOfflinePayloads(server.Store(), sources, func(scoutName string) Editor {
+			return requestEditor(req, scoutName)
+		})
+		if err != nil {
+			return err
+		}
+		problems = append(problems, p...)
+	}
+	for i, d := range drafts {
+		d.Prefix = "d" + strconv.Itoa(i) + "."
+	}
+
+	return server.Templates().ExecuteTemplate(w, "offline.html", map[string]interface{}{
+		"Server":   server,
+		"Request":  req,
+		"Saved":    saved,
+		"Drafts":   drafts,
+		"Problems": problems,
+	})
+}
+
+// An offlineMatch is a match on the offline event form.
+type offlineMatch struct {
+	Match *Match
+	Teams []offlineMatchTeam
+}
+
+// An offlineMatchTeam is a team that a tablet can scout offline, with the
+// revision of its info when the form was loaded.
+type offlineMatchTeam struct {
+	Tag      MatchTeamTag
+	Alliance Alliance
+	Revision int
+}
+
+func offlineMatches(event *Event, matches []*Match) []offlineMatch {
+	result := make([]offlineMatch, len(matches))
+	for i, m := range matches {
+		mtag := MatchTag{event.Tag(), m.Type, uint(m.Number)}
+		result[i].Match = m
+		result[i].Teams = make([]offlineMatchTeam, len(m.Teams))
+		for j, info := range m.Teams {
+			result[i].Teams[j] = offlineMatchTeam{MatchTeamTag{mtag, uint(info.Team)}, info.Alliance, info.Revision}
+		}
+	}
+	return result
+}
+
+// offlineFormVersion returns a fingerprint of the offline event form.  It
+// changes whenever the game's fields, the schedule or a team's revision
+// changes, so tablets download the form again the next time they are
+// online.
+func offlineFormVersion(game *Game, matches []offlineMatch) string {
+	h := fnv.New32a()
+	fmt.Fprintln(h, game.ID)
+	for _, f := range game.ScoutedFields() {
+		fmt.Fprintln(h, f.Name)
+	}
+	for _, m := range matches {
+		for _, t := range m.Teams {
+			fmt.Fprintln(h, t.Tag, t.Revision)
+		}
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// offlineStaticPaths lists the static files that the offline event form
+// needs.
+var offlineStaticPaths = []string{
+	"/css/reset.css",
+	"/css/generic.css",
+	"/css/style.css",
+	"/css/layout.css",
+	"/js/jquery.js",
+	"/js/jquery.urls.js",
+	"/js/qrcode.js",
+	"/js/offline.js",
+}
+
+// eventOfflineForm shows a form that a tablet can keep to scout every match
+// at an event without reaching the server.  The scout picks a match and team
+// and the form is shown as a QR code.
+func eventOfflineForm(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Fetch matches
+	matches, err := server.Store().FetchMatches(event.Tag())
+	if err != nil {
+		return err
+	}
+
+	var form teamInfoForm
+	return server.Templates().ExecuteTemplate(w, "event-offline.html", map[string]interface{}{
+		"Server":  server,
+		"Request": req,
+		"Event":   event,
+		"Matches": offlineMatches(event, matches),
+		"Fields":  form.fields(eventGame(event)),
+	})
+}
+
+// eventOfflineManifest serves the application cache manifest that keeps the
+// offline event form on a tablet.
+func eventOfflineManifest(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Fetch matches
+	matches, err := server.Store().FetchMatches(event.Tag())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/cache-manifest")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "CACHE MANIFEST\n# %v %s\n\nCACHE:\n", event.Tag(), offlineFormVersion(eventGame(event), offlineMatches(event, matches)))
+	for _, path := range offlineStaticPaths {
+		u, err := server.GetRoute("static").URL("path", path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, u)
+	}
+	_, err = fmt.Fprint(w, "\nNETWORK:\n*\n")
+	return err
+}
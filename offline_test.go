@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/greyhound-scouting/barcode"
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOfflinePayload(t *testing.T) {
+	mtag := MatchTag{EventTag{"sdc", 2012}, Qualification, 1}
+	tests := []struct {
+		Payload string
+		Result  *offlinePayload
+	}{
+		{
+			"GS1 " + MatchTeamTag{mtag, 973}.String() + " 4 2.0.1 Jane Q. Scout",
+			&offlinePayload{MatchTeamTag{mtag, 973}, 4, []int{2, 0, 1}, "Jane Q. Scout"},
+		},
+		{
+			"  GS1 " + MatchTeamTag{mtag, 973}.String() + " 0 7\n",
+			&offlinePayload{MatchTeamTag{mtag, 973}, 0, []int{7}, ""},
+		},
+		{"GS1 " + MatchTeamTag{mtag, 973}.String() + " 0 1.x.2 Scout", nil},
+		{"GS1 " + MatchTeamTag{mtag, 973}.String() + " -1 1 Scout", nil},
+		{"GS1 notatag 0 1 Scout", nil},
+		{"GS2 " + MatchTeamTag{mtag, 973}.String() + " 0 1 Scout", nil},
+		{"http://example.com/", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		p, err := parseOfflinePayload(test.Payload)
+		if test.Result == nil {
+			if err == nil {
+				t.Errorf("parseOfflinePayload(%q) = %+v; want error", test.Payload, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseOfflinePayload(%q) error: %v", test.Payload, err)
+		} else if !reflect.DeepEqual(p, test.Result) {
+			t.Errorf("parseOfflinePayload(%q) = %+v; want %+v", test.Payload, p, test.Result)
+		} else if p2, err := parseOfflinePayload(p.String()); err != nil || !reflect.DeepEqual(p2, p) {
+			t.Errorf("parseOfflinePayload(%q) = %+v, %v; want %+v", p.String(), p2, err, p)
+		}
+	}
+}
+
+func TestOfflinePayloadForm(t *testing.T) {
+	// AutoDiscs, Moved, Discs, Climb, Failure, NoShow
+	tests := []struct {
+		Values []int
+		OK     bool
+	}{
+		{[]int{3, 1, 12, 2, 0, 0}, true},
+		{[]int{3, 1, 12, 2, 0}, false},
+		{[]int{3, 1, 12, 2, 0, 0, 0}, false},
+		{[]int{-1, 1, 12, 2, 0, 0}, false},
+		{[]int{3, 2, 12, 2, 0, 0}, false},
+		{[]int{3, 1, 12, 3, 0, 0}, false},
+	}
+	for _, test := range tests {
+		p := &offlinePayload{Revision: 2, Values: test.Values, ScoutName: "Scout"}
+		form, err := p.form(testGame)
+		switch {
+		case test.OK && err != nil:
+			t.Errorf("form of %v error: %v", test.Values, err)
+		case !test.OK && err == nil:
+			t.Errorf("form of %v succeeded; want error", test.Values)
+		case test.OK:
+			want := teamInfoForm{
+				ScoutName: "Scout",
+				Revision:  2,
+				Values:    map[string]int{"AutoDiscs": 3, "Moved": 1, "Discs": 12, "Climb": AttemptSucceeded, "Failure": 0, "NoShow": 0},
+			}
+			if !reflect.DeepEqual(form, want) {
+				t.Errorf("form of %v = %+v; want %+v", test.Values, form, want)
+			}
+		}
+	}
+}
+
+// offlineTestPayload returns a payload for a team in the Rebound Rumble
+// test match that scored autoHigh in autonomous and balanced the first team
+// bridge.
+func offlineTestPayload(tag MatchTeamTag, revision int, autoHigh int, scout string) string {
+	p := &offlinePayload{Tag: tag, Revision: revision, ScoutName: scout}
+	for _, f := range reboundRumble.ScoutedFields() {
+		switch f.Name {
+		case "Autonomous.High":
+			p.Values = append(p.Values, autoHigh)
+		case "TeamBridge1":
+			p.Values = append(p.Values, AttemptSucceeded)
+		default:
+			p.Values = append(p.Values, 0)
+		}
+	}
+	return p.String()
+}
+
+func TestIngestOffline(t *testing.T) {
+	store := newTestServer(t)
+	event, _ := seedTestEvent(t, store)
+	mtag := MatchTag{event.Tag(), Qualification, 1}
+
+	if rec := serveTestRequest(t, "/offline", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /offline code = %d", rec.Code)
+	}
+
+	// The same payload twice, a payload for a team that isn't in the match
+	// and some noise
+	payload := offlineTestPayload(MatchTeamTag{mtag, 3}, 0, 2, "Carol")
+	pasted := strings.Join([]string{
+		payload,
+		"",
+		payload,
+		offlineTestPayload(MatchTeamTag{mtag, 973}, 0, 1, "Dave"),
+		"hello",
+	}, "\r\n")
+	req := newUploadRequest(t, "/offline", "Image", url.Values{"Payloads": {pasted}}, nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /offline code = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, s := range []string{
+		`Pasted line 4: ` + MatchTeamTag{mtag, 973}.String() + ` is not a scheduled match team`,
+		`Pasted line 5: not an offline scouting form`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Offline page is missing %q", s)
+		}
+	}
+	if n := strings.Count(body, `Qualification Match 1</a>, Team 3`); n != 2 {
+		t.Errorf("Offline page lists team 3 as saved %d times; want 2", n)
+	}
+	match, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	info := match.TeamInfo(3)
+	if info.Autonomous.High != 2 || !info.TeamBridge1.Success || info.ScoutName != "Carol" {
+		t.Errorf("Team 3 info after ingesting = %+v", info)
+	}
+	if info.Revision != 1 {
+		t.Errorf("Team 3 revision after ingesting the same payload twice = %d; want 1", info.Revision)
+	}
+
+	// A corrected form from the same scout and the stale revision is sent
+	// back for review.
+	req = newUploadRequest(t, "/offline", "Image", url.Values{"Payloads": {offlineTestPayload(MatchTeamTag{mtag, 3}, 0, 5, "Carol")}}, nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	body = rec.Body.String()
+	for _, s := range []string{
+		`after the tablet loaded the form`,
+		`action="/scan"`,
+		`name="d0.Autonomous.High" type="text" value="5"`,
+		`name="d0.Revision" type="hidden" value="1"`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Conflict review is missing %q", s)
+		}
+	}
+
+	// A photo of a tablet
+	qr, err := barcode.EncodeQR(offlineTestPayload(MatchTeamTag{mtag, 4}, 0, 3, "Frank"), barcode.QRLevelM)
+	if err != nil {
+		t.Fatalf("EncodeQR error: %v", err)
+	}
+	code := &barcode.QRImage{QRCode: qr, Scale: 4}
+	img := image.NewGray(image.Rect(0, 0, code.Bounds().Dx()+64, code.Bounds().Dy()+64))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+	draw.Draw(img, code.Bounds().Add(image.Pt(32, 32)), code, image.ZP, draw.Src)
+	photo := new(bytes.Buffer)
+	if err := png.Encode(photo, img); err != nil {
+		t.Fatalf("png.Encode error: %v", err)
+	}
+	req = newUploadRequest(t, "/offline", "Image", nil, map[string][]byte{"tablet.png": photo.Bytes(), "blank.jpg": testJPEG(t)})
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	body = rec.Body.String()
+	if !strings.Contains(body, "blank.jpg: barcode: no QR code found") {
+		t.Error("Offline page didn't report an image without a QR code")
+	}
+	match, err = store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if info := match.TeamInfo(4); info.Autonomous.High != 3 || info.ScoutName != "Frank" {
+		t.Errorf("Team 4 info after ingesting a photo = %+v", info)
+	}
+}
+
+func TestEventOfflineForm(t *testing.T) {
+	store := newTestServer(t)
+	event, _ := seedTestEvent(t, store)
+	mtag := MatchTag{event.Tag(), Qualification, 1}
+	const (
+		path         = "/event/2012/sdc/offline"
+		manifestPath = "/event/2012/sdc/offline.appcache"
+	)
+
+	rec := serveTestRequest(t, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+	body := rec.Body.String()
+	for _, s := range []string{
+		`<html manifest="` + manifestPath + `">`,
+		`<option value="` + MatchTeamTag{mtag, 4}.String() + `" data-revision="0">`,
+		`name="Autonomous.High" type="text" value="0"`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Offline form is missing %q", s)
+		}
+	}
+
+	rec = serveTestRequest(t, manifestPath, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", manifestPath, rec.Code)
+	}
+	manifest := rec.Body.String()
+	if ct := rec.HeaderMap.Get("Content-Type"); ct != "text/cache-manifest" {
+		t.Errorf("Manifest Content-Type = %q", ct)
+	}
+	if !strings.HasPrefix(manifest, "CACHE MANIFEST\n") || !strings.Contains(manifest, "\n/static/js/offline.js\n") {
+		t.Errorf("Manifest:\n%s", manifest)
+	}
+
+	// Saving a team changes the manifest, so tablets fetch the new revision.
+	info := TeamInfo{Team: 4, Alliance: Blue, ScoutName: "Alice"}
+	if err := store.UpdateMatchTeam(mtag, 4, info, testEditor); err != nil {
+		t.Fatalf("UpdateMatchTeam error: %v", err)
+	}
+	if m := serveTestRequest(t, manifestPath, nil).Body.String(); m == manifest {
+		t.Error("Manifest didn't change after saving a team")
+	}
+	if body := serveTestRequest(t, path, nil).Body.String(); !strings.Contains(body, `<option value="`+MatchTeamTag{mtag, 4}.String()+`" data-revision="1">`) {
+		t.Error("Offline form doesn't have the saved revision")
+	}
+
+	if rec := serveTestRequest(t, "/event/2012/xyz/offline.appcache", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET manifest of missing event code = %d (expected %d)", rec.Code, http.StatusNotFound)
+	}
+}
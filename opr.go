@@ -0,0 +1,254 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// A TeamRating holds a team's least-squares ratings at an event.
+type TeamRating struct {
+	Team int
+
+	// OPR (offensive power rating) is the team's estimated contribution to
+	// its alliance's score.
+	OPR float64
+
+	// DPR (defensive power rating) is the team's estimated contribution to
+	// the opposing alliance's score.
+	DPR float64
+
+	// CCWM (calculated contribution to winning margin) is OPR - DPR.
+	CCWM float64
+
+	// Components holds the team's estimated contribution to each scouted
+	// metric.
+	Components ComponentRatings
+}
+
+// ComponentRatings holds a team's least-squares contributions to its
+// alliance's scouted totals, keyed by the name of the game's component.
+// Only alliances whose robots were all scouted are used, since a missed
+// robot's points would otherwise be charged to its partners.  A team that
+// was missed in a few matches is still rated from the rest.
+type ComponentRatings map[string]float64
+
+// scouted reports whether anyone has entered data for a team in a match.
+func (info *TeamInfo) scouted() bool {
+	return info.ScoutName != "" || info.Revision > 0
+}
+
+// computeRatings calculates OPR, DPR and CCWM for every team that played in
+// a scored qualification match, and ratings for each of the game's
+// components for every team on a fully scouted alliance in a qualification
+// match.
+// The result is sorted by team number.
+func computeRatings(game *Game, matches []*Match) []TeamRating {
+	var scoredAlliances, scoutedAlliances [][]int
+	var scored, allowed []float64
+	components := make([][]float64, len(game.Components))
+	for _, m := range matches {
+		if m.Type != Qualification {
+			continue
+		}
+		if m.Score != nil {
+			red, blue := float64(m.Score[string(Red)]), float64(m.Score[string(Blue)])
+			scoredAlliances = append(scoredAlliances, allianceTeams(m, Red), allianceTeams(m, Blue))
+			scored = append(scored, red, blue)
+			allowed = append(allowed, blue, red)
+		}
+		for _, alliance := range []Alliance{Red, Blue} {
+			var teams []int
+			sum := make([]float64, len(game.Components))
+			scouted := true
+			for i := range m.Teams {
+				info := &m.Teams[i]
+				if info.Alliance != alliance {
+					continue
+				}
+				teams = append(teams, info.Team)
+				scouted = scouted && info.scouted()
+				for k, c := range game.Components {
+					sum[k] += float64(game.Total(c, info))
+				}
+			}
+			if !scouted || len(teams) == 0 {
+				continue
+			}
+			scoutedAlliances = append(scoutedAlliances, teams)
+			for k := range components {
+				components[k] = append(components[k], sum[k])
+			}
+		}
+	}
+
+	byTeam := make(map[int]*TeamRating)
+	rating := func(team int) *TeamRating {
+		r := byTeam[team]
+		if r == nil {
+			r = &TeamRating{Team: team}
+			byTeam[team] = r
+		}
+		return r
+	}
+	teams, contrib := allianceContributions(scoredAlliances, scored, allowed)
+	for i, t := range teams {
+		r := rating(t)
+		r.OPR = contrib[0][i]
+		r.DPR = contrib[1][i]
+		r.CCWM = contrib[0][i] - contrib[1][i]
+	}
+	teams, contrib = allianceContributions(scoutedAlliances, components...)
+	for i, t := range teams {
+		r := rating(t)
+		r.Components = make(ComponentRatings, len(game.Components))
+		for k, c := range game.Components {
+			r.Components[c.Name] = contrib[k][i]
+		}
+	}
+
+	ratings := make([]TeamRating, 0, len(byTeam))
+	for _, r := range byTeam {
+		ratings = append(ratings, *r)
+	}
+	sort.Sort(ratingsByTeam(ratings))
+	return ratings
+}
+
+type ratingsByTeam []TeamRating
+
+func (slice ratingsByTeam) Len() int {
+	return len(slice)
+}
+
+func (slice ratingsByTeam) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice ratingsByTeam) Less(i, j int) bool {
+	return slice[i].Team < slice[j].Team
+}
+
+// allianceTeams returns the team numbers on an alliance in a match.
+func allianceTeams(match *Match, alliance Alliance) []int {
+	var teams []int
+	for _, info := range match.Teams {
+		if info.Alliance == alliance {
+			teams = append(teams, info.Team)
+		}
+	}
+	return teams
+}
+
+// allianceContributions finds each team's contribution to alliance totals.
+// alliances lists the teams on each alliance and each values slice gives a
+// total for each alliance.  For each values slice, the contributions x are
+// the least-squares solution to: for every alliance a, the sum of x[t] for
+// teams t in a equals values[a].  teams is the sorted list of every team
+// that appears in alliances; contrib[i][j] is the contribution of teams[j]
+// to values[i].
+func allianceContributions(alliances [][]int, values ...[]float64) (teams []int, contrib [][]float64) {
+	// Index teams
+	index := make(map[int]int)
+	for _, a := range alliances {
+		for _, t := range a {
+			if _, ok := index[t]; !ok {
+				index[t] = -1
+				teams = append(teams, t)
+			}
+		}
+	}
+	sort.Ints(teams)
+	for i, t := range teams {
+		index[t] = i
+	}
+
+	// Build normal equations: (AᵀA)x = Aᵀb, where A has a row for each
+	// alliance with a 1 in each member team's column.
+	n := len(teams)
+	ata := make([][]float64, n)
+	for i := range ata {
+		ata[i] = make([]float64, n)
+	}
+	atb := make([][]float64, len(values))
+	for k := range atb {
+		atb[k] = make([]float64, n)
+	}
+	for r, a := range alliances {
+		for _, t1 := range a {
+			i := index[t1]
+			for _, t2 := range a {
+				ata[i][index[t2]]++
+			}
+			for k := range values {
+				atb[k][i] += values[k][r]
+			}
+		}
+	}
+
+	contrib = solveLinear(ata, atb)
+	return teams, contrib
+}
+
+// solveLinear solves the system mx = b for each b in bs using Gaussian
+// elimination with partial pivoting.  m is overwritten.  If m is singular
+// (as it is early in an event, before every team has played enough
+// matches), then the free variables are set to zero, which still gives a
+// solution to the normal equations.
+func solveLinear(m [][]float64, bs [][]float64) [][]float64 {
+	const epsilon = 1e-9
+
+	n := len(m)
+	b := make([][]float64, len(bs))
+	for k := range bs {
+		b[k] = make([]float64, n)
+		copy(b[k], bs[k])
+	}
+
+	// Forward elimination
+	pivotCols := make([]int, 0, n)
+	row := 0
+	for col := 0; col < n && row < n; col++ {
+		p := row
+		for i := row + 1; i < n; i++ {
+			if math.Abs(m[i][col]) > math.Abs(m[p][col]) {
+				p = i
+			}
+		}
+		if math.Abs(m[p][col]) < epsilon {
+			continue
+		}
+		m[row], m[p] = m[p], m[row]
+		for k := range b {
+			b[k][row], b[k][p] = b[k][p], b[k][row]
+		}
+		for i := row + 1; i < n; i++ {
+			f := m[i][col] / m[row][col]
+			if f == 0 {
+				continue
+			}
+			for j := col; j < n; j++ {
+				m[i][j] -= f * m[row][j]
+			}
+			for k := range b {
+				b[k][i] -= f * b[k][row]
+			}
+		}
+		pivotCols = append(pivotCols, col)
+		row++
+	}
+
+	// Back substitution
+	x := make([][]float64, len(b))
+	for k := range b {
+		x[k] = make([]float64, n)
+		for r := len(pivotCols) - 1; r >= 0; r-- {
+			col := pivotCols[r]
+			sum := b[k][r]
+			for j := col + 1; j < n; j++ {
+				sum -= m[r][j] * x[k][j]
+			}
+			x[k][col] = sum / m[r][col]
+		}
+	}
+	return x
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+const ratingEpsilon = 1e-6
+
+// newRatingTestMatches returns qualification matches where each alliance
+// scores exactly the sum of its teams' contributions.
+func newRatingTestMatches(contrib map[int]float64, schedule [][6]int) []*Match {
+	matches := make([]*Match, len(schedule))
+	for i, s := range schedule {
+		m := newTestMatch(Qualification, i+1, s[0], s[1], s[2], s[3], s[4], s[5])
+		m.Score = make(map[string]int, 2)
+		for _, t := range s[:3] {
+			m.Score[string(Red)] += int(contrib[t])
+		}
+		for _, t := range s[3:] {
+			m.Score[string(Blue)] += int(contrib[t])
+		}
+		matches[i] = m
+	}
+	return matches
+}
+
+func TestComputeRatings(t *testing.T) {
+	contrib := map[int]float64{1: 10, 2: 20, 3: 5, 4: 15, 5: 8, 6: 12}
+	matches := newRatingTestMatches(contrib, [][6]int{
+		{1, 2, 3, 4, 5, 6},
+		{1, 4, 5, 2, 3, 6},
+		{1, 2, 6, 3, 4, 5},
+		{2, 4, 6, 1, 3, 5},
+		{1, 3, 4, 2, 5, 6},
+	})
+
+	// Unscored and elimination matches are ignored.
+	matches = append(matches, newTestMatch(Qualification, 6, 1, 2, 3, 4, 5, 6))
+	elim := newTestMatch(Final, 1, 1, 2, 3, 4, 5, 6)
+	elim.Score = map[string]int{"red": 500, "blue": 0}
+	matches = append(matches, elim)
+
+	ratings := computeRatings(reboundRumble, matches)
+	if len(ratings) != len(contrib) {
+		t.Fatalf("len(computeRatings(...)) = %d (expected %d)", len(ratings), len(contrib))
+	}
+	for i, r := range ratings {
+		if r.Team != i+1 {
+			t.Errorf("ratings[%d].Team = %d (expected %d)", i, r.Team, i+1)
+			continue
+		}
+		if math.Abs(r.OPR-contrib[r.Team]) > ratingEpsilon {
+			t.Errorf("team %d OPR = %.3f (expected %.3f)", r.Team, r.OPR, contrib[r.Team])
+		}
+		if math.Abs(r.CCWM-(r.OPR-r.DPR)) > ratingEpsilon {
+			t.Errorf("team %d CCWM = %.3f (expected %.3f)", r.Team, r.CCWM, r.OPR-r.DPR)
+		}
+	}
+
+	// DPR is OPR computed from the opposing alliance's score.
+	for _, m := range matches {
+		if m.Score != nil {
+			m.Score[string(Red)], m.Score[string(Blue)] = m.Score[string(Blue)], m.Score[string(Red)]
+		}
+	}
+	for i, r := range computeRatings(reboundRumble, matches) {
+		if math.Abs(r.OPR-ratings[i].DPR) > ratingEpsilon {
+			t.Errorf("team %d DPR = %.3f (expected %.3f)", r.Team, ratings[i].DPR, r.OPR)
+		}
+	}
+}
+
+func TestComputeRatingsUnderdetermined(t *testing.T) {
+	// With one match, there are many solutions, but each must reproduce the
+	// alliance scores.
+	matches := newRatingTestMatches(map[int]float64{1: 10, 2: 20, 3: 5, 4: 15, 5: 8, 6: 12}, [][6]int{
+		{1, 2, 3, 4, 5, 6},
+	})
+	ratings := computeRatings(reboundRumble, matches)
+	if len(ratings) != 6 {
+		t.Fatalf("len(computeRatings(...)) = %d (expected 6)", len(ratings))
+	}
+	for _, alliance := range []Alliance{Red, Blue} {
+		var total float64
+		for _, team := range allianceTeams(matches[0], alliance) {
+			total += ratings[team-1].OPR
+		}
+		if expected := float64(matches[0].Score[string(alliance)]); math.Abs(total-expected) > ratingEpsilon {
+			t.Errorf("%s OPR total = %.3f (expected %.3f)", alliance, total, expected)
+		}
+	}
+	for _, r := range ratings {
+		if math.IsNaN(r.OPR) || math.IsInf(r.OPR, 0) {
+			t.Errorf("team %d OPR = %v", r.Team, r.OPR)
+		}
+	}
+}
+
+func TestComputeRatingsEmpty(t *testing.T) {
+	if ratings := computeRatings(reboundRumble, nil); len(ratings) != 0 {
+		t.Errorf("computeRatings(nil) = %+v (expected none)", ratings)
+	}
+}
+
+func TestComputeComponentRatings(t *testing.T) {
+	highs := map[int]int{1: 3, 2: 0, 3: 1, 4: 2, 5: 4, 6: 1}
+	schedule := [][6]int{
+		{1, 2, 3, 4, 5, 6},
+		{1, 4, 5, 2, 3, 6},
+		{1, 2, 6, 3, 4, 5},
+		{2, 4, 6, 1, 3, 5},
+		{1, 3, 4, 2, 5, 6},
+	}
+	var matches []*Match
+	for i, s := range schedule {
+		m := newTestMatch(Qualification, i+1, s[0], s[1], s[2], s[3], s[4], s[5])
+		for j := range m.Teams {
+			info := &m.Teams[j]
+			info.ScoutName = "Alice"
+			info.Teleoperated.High = highs[info.Team]
+			info.TeamBridge1.Success = info.Team == 2
+		}
+		matches = append(matches, m)
+	}
+	// An unscouted alliance is skipped.
+	m := newTestMatch(Qualification, len(schedule)+1, 1, 2, 3, 4, 5, 6)
+	matches = append(matches, m)
+	// So is an alliance with a missed robot, but not its opponents.
+	m = newTestMatch(Qualification, len(schedule)+2, 1, 2, 3, 4, 5, 6)
+	for j := range m.Teams {
+		info := &m.Teams[j]
+		if info.Team != 1 {
+			info.ScoutName = "Bob"
+			info.Teleoperated.High = highs[info.Team]
+			info.TeamBridge1.Success = info.Team == 2
+		}
+	}
+	matches = append(matches, m)
+
+	ratings := computeRatings(reboundRumble, matches)
+	if len(ratings) != len(highs) {
+		t.Fatalf("len(computeRatings(...)) = %d (expected %d)", len(ratings), len(highs))
+	}
+	for _, r := range ratings {
+		if r.OPR != 0 {
+			t.Errorf("team %d OPR = %.3f without scores (expected 0)", r.Team, r.OPR)
+		}
+		if math.Abs(r.Components["TeleoperatedHigh"]-float64(highs[r.Team])) > ratingEpsilon {
+			t.Errorf("team %d teleoperated high = %.3f (expected %d)", r.Team, r.Components["TeleoperatedHigh"], highs[r.Team])
+		}
+		var bridge float64
+		if r.Team == 2 {
+			bridge = 1
+		}
+		if math.Abs(r.Components["TeamBridge"]-bridge) > ratingEpsilon {
+			t.Errorf("team %d team bridge = %.3f (expected %.0f)", r.Team, r.Components["TeamBridge"], bridge)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/gopdf/pdf"
+	"code.google.com/p/gorilla/mux"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A PickList is a strategist's ordering of the teams at an event for
+// alliance selection.
+type PickList struct {
+	// Entries are grouped by tier, best first.
+	Entries []PickEntry
+
+	// Weights are the stat weights that the list was last seeded with.
+	Weights []StatWeight `bson:",omitempty"`
+
+	Revision int
+}
+
+// A PickTier is a section of a pick list.
+type PickTier string
+
+const (
+	FirstPick  PickTier = "first"
+	SecondPick PickTier = "second"
+	DoNotPick  PickTier = "dnp"
+)
+
+// pickTiers lists the tiers in the order that they appear in a pick list.
+var pickTiers = []PickTier{FirstPick, SecondPick, DoNotPick}
+
+func (t PickTier) String() string {
+	return string(t)
+}
+
+func (t PickTier) DisplayName() string {
+	switch t {
+	case FirstPick:
+		return "First Pick"
+	case SecondPick:
+		return "Second Pick"
+	case DoNotPick:
+		return "Do Not Pick"
+	}
+	return string(t)
+}
+
+// A PickEntry is a team's place on a pick list.
+type PickEntry struct {
+	Team  int
+	Tier  PickTier
+	Notes string `bson:",omitempty"`
+
+	// Picked is set by hand during alliance selection, before the alliances
+	// are saved.
+	Picked bool `bson:",omitempty"`
+}
+
+// A StatWeight is the weight of a team statistic in a pick list's seeding
+// score.
+type StatWeight struct {
+	Stat   string
+	Weight float64
+}
+
+// groupByTier stably reorders the entries so that each tier's entries are
+// together.  Entries with an unknown tier are moved to the second pick tier.
+func (list *PickList) groupByTier() {
+	known := make(map[PickTier]bool, len(pickTiers))
+	for _, t := range pickTiers {
+		known[t] = true
+	}
+	for i := range list.Entries {
+		if !known[list.Entries[i].Tier] {
+			list.Entries[i].Tier = SecondPick
+		}
+	}
+	entries := make([]PickEntry, 0, len(list.Entries))
+	for _, t := range pickTiers {
+		for _, e := range list.Entries {
+			if e.Tier == t {
+				entries = append(entries, e)
+			}
+		}
+	}
+	list.Entries = entries
+}
+
+// syncTeams makes the list hold exactly the teams at an event.  Teams that
+// aren't on the list yet are added to the end of the second pick tier.
+func (list *PickList) syncTeams(teams []int) {
+	atEvent := make(map[int]bool, len(teams))
+	for _, t := range teams {
+		atEvent[t] = true
+	}
+	onList := make(map[int]bool, len(list.Entries))
+	entries := make([]PickEntry, 0, len(teams))
+	for _, e := range list.Entries {
+		if atEvent[e.Team] && !onList[e.Team] {
+			entries = append(entries, e)
+			onList[e.Team] = true
+		}
+	}
+	for _, t := range teams {
+		if !onList[t] {
+			entries = append(entries, PickEntry{Team: t, Tier: SecondPick})
+		}
+	}
+	list.Entries = entries
+	list.groupByTier()
+}
+
+// firstPickTierSize is the number of teams put in the first pick tier when a
+// list is seeded: enough for every alliance's first pick.
+const firstPickTierSize = maxAlliances
+
+// A pickStat is a team statistic that can be weighted to seed a pick list.
+type pickStat struct {
+	Name  string
+	Label string
+	value func(TeamStats) float64
+}
+
+// pickStats returns the statistics that can seed a pick list for a game.
+func pickStats(game *Game) []pickStat {
+	stats := []pickStat{
+		{"OPR", "OPR", func(s TeamStats) float64 { return s.OPR }},
+		{"CCWM", "CCWM", func(s TeamStats) float64 { return s.CCWM }},
+		{"AverageScore", "Average Score", TeamStats.AverageScore},
+		{"FailureRate", "Failure Rate", TeamStats.FailureRate},
+	}
+	for _, agg := range game.Aggregates {
+		name := agg.Name
+		stats = append(stats, pickStat{"Average." + name, "Average " + agg.Label, func(s TeamStats) float64 {
+			return s.Field(name).Average(s.MatchCount)
+		}})
+	}
+	for _, f := range game.Fields {
+		if f.Kind != AttemptField {
+			continue
+		}
+		name := f.Name
+		stats = append(stats, pickStat{"SuccessRate." + name, f.Label + " Success Rate", func(s TeamStats) float64 {
+			return s.Field(name).SuccessRate()
+		}})
+	}
+	for _, c := range game.Components {
+		name := c.Name
+		stats = append(stats, pickStat{"OPR." + name, c.Label + " OPR", func(s TeamStats) float64 {
+			return s.Components[name]
+		}})
+	}
+	return stats
+}
+
+// defaultPickWeights are used to seed a list that has never been seeded.
+var defaultPickWeights = []StatWeight{{"OPR", 1}}
+
+// pickScores returns each team's seeding score: the weighted sum of its
+// statistics, each converted to a standard score across the teams so that
+// weights are comparable between statistics with different scales.
+func pickScores(game *Game, stats map[int]TeamStats, weights []StatWeight) map[int]float64 {
+	scores := make(map[int]float64, len(stats))
+	for team := range stats {
+		scores[team] = 0
+	}
+	n := float64(len(stats))
+	for _, ps := range pickStats(game) {
+		var weight float64
+		for _, w := range weights {
+			if w.Stat == ps.Name {
+				weight = w.Weight
+			}
+		}
+		if weight == 0 || n == 0 {
+			continue
+		}
+
+		values := make(map[int]float64, len(stats))
+		var sum, sumSquares float64
+		for team, s := range stats {
+			v := ps.value(s)
+			values[team] = v
+			sum += v
+			sumSquares += v * v
+		}
+		mean := sum / n
+		sd := math.Sqrt(sumSquares/n - mean*mean)
+		if sd == 0 || math.IsNaN(sd) {
+			continue
+		}
+		for team, v := range values {
+			scores[team] += weight * (v - mean) / sd
+		}
+	}
+	return scores
+}
+
+// seed reorders the list by the teams' seeding scores.  The best teams fill
+// the first pick tier and the rest go in the second pick tier.  Teams marked
+// do not pick stay in that tier, and notes are kept.
+func (list *PickList) seed(game *Game, stats map[int]TeamStats, weights []StatWeight) {
+	scores := pickScores(game, stats, weights)
+	var ranked, dnp []PickEntry
+	for _, e := range list.Entries {
+		if e.Tier == DoNotPick {
+			dnp = append(dnp, e)
+		} else {
+			ranked = append(ranked, e)
+		}
+	}
+	sort.Sort(entriesByScore{ranked, scores})
+	for i := range ranked {
+		if i < firstPickTierSize {
+			ranked[i].Tier = FirstPick
+		} else {
+			ranked[i].Tier = SecondPick
+		}
+	}
+	list.Entries = append(ranked, dnp...)
+	list.Weights = weights
+}
+
+type entriesByScore struct {
+	entries []PickEntry
+	scores  map[int]float64
+}
+
+func (s entriesByScore) Len() int {
+	return len(s.entries)
+}
+
+func (s entriesByScore) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+}
+
+func (s entriesByScore) Less(i, j int) bool {
+	a, b := s.scores[s.entries[i].Team], s.scores[s.entries[j].Team]
+	if a != b {
+		return a > b
+	}
+	return s.entries[i].Team < s.entries[j].Team
+}
+
+// parsePickListForm parses the pick list form.  Each entry has a "Team",
+// "Tier" and "Notes" value, in list order, and picked teams are listed in
+// "Picked".  Weights are named "Weight." followed by the stat name.
+func parsePickListForm(req *http.Request, game *Game) (*PickList, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	list := new(PickList)
+	if s := req.Form.Get("Revision"); s != "" {
+		rev, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("Bad revision: " + err.Error())
+		}
+		list.Revision = rev
+	}
+
+	teams, tiers, notes := req.Form["Team"], req.Form["Tier"], req.Form["Notes"]
+	if len(tiers) != len(teams) || len(notes) != len(teams) {
+		return nil, errors.New("Every team must have a tier and notes")
+	}
+	picked := make(map[string]bool)
+	for _, s := range req.Form["Picked"] {
+		picked[s] = true
+	}
+	for i, s := range teams {
+		team, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("Bad team number " + strconv.Quote(s))
+		}
+		list.Entries = append(list.Entries, PickEntry{
+			Team:   team,
+			Tier:   PickTier(tiers[i]),
+			Notes:  strings.TrimSpace(notes[i]),
+			Picked: picked[s],
+		})
+	}
+
+	for _, ps := range pickStats(game) {
+		s := strings.TrimSpace(req.Form.Get("Weight." + ps.Name))
+		if s == "" {
+			continue
+		}
+		w, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errors.New(ps.Label + " weight: " + err.Error())
+		}
+		if w != 0 {
+			list.Weights = append(list.Weights, StatWeight{ps.Name, w})
+		}
+	}
+	return list, nil
+}
+
+// fetchPickList returns an event's pick list, with every team at the event
+// on it.  An event without a stored list gets a new list seeded with the
+// default weights.
+func fetchPickList(store Datastore, event *Event) (*PickList, error) {
+	list, err := store.FetchPickList(event.Tag())
+	if err == StoreNotFound {
+		list = new(PickList)
+		list.syncTeams(event.Teams)
+		stats, err := fetchTeamStats(store, event.Tag(), event.Teams)
+		if err != nil {
+			return nil, err
+		}
+		list.seed(eventGame(event), stats, defaultPickWeights)
+		return list, nil
+	} else if err != nil {
+		return nil, err
+	}
+	list.syncTeams(event.Teams)
+	return list, nil
+}
+
+// allianceMembers returns the set of teams on an event's elimination
+// alliances.
+func allianceMembers(event *Event) map[int]bool {
+	members := make(map[int]bool)
+	for _, a := range event.Alliances {
+		for _, t := range a.Teams() {
+			members[t] = true
+		}
+	}
+	return members
+}
+
+// A pickListRow is a row of the pick list form.
+type pickListRow struct {
+	Entry PickEntry
+	Rank  int // position within the tier
+	Team  *Team
+	Stats TeamStats
+	Score float64
+	Taken bool // picked by hand or on an alliance
+}
+
+// pickListRows returns the rows of the pick list form.
+func pickListRows(list *PickList, event *Event, teams map[int]*Team, stats map[int]TeamStats, scores map[int]float64) []pickListRow {
+	members := allianceMembers(event)
+	rows := make([]pickListRow, len(list.Entries))
+	rank := 0
+	for i, e := range list.Entries {
+		if i == 0 || list.Entries[i-1].Tier != e.Tier {
+			rank = 0
+		}
+		rank++
+		team := teams[e.Team]
+		if team == nil {
+			team = &Team{Number: e.Team}
+		}
+		rows[i] = pickListRow{
+			Entry: e,
+			Rank:  rank,
+			Team:  team,
+			Stats: stats[e.Team],
+			Score: scores[e.Team],
+			Taken: e.Picked || members[e.Team],
+		}
+	}
+	return rows
+}
+
+// A pickWeightField is a weight on the pick list form.
+type pickWeightField struct {
+	Name   string
+	Label  string
+	Weight float64
+}
+
+// eventPickList shows and edits an event's pick list.
+func eventPickList(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	game := eventGame(event)
+	stats, err := fetchTeamStats(server.Store(), event.Tag(), event.Teams)
+	if err != nil {
+		return err
+	}
+
+	var formError error
+	if req.Method == "POST" {
+		var list *PickList
+		list, formError = parsePickListForm(req, game)
+		if formError == nil {
+			if req.FormValue("Seed") != "" {
+				list.syncTeams(event.Teams)
+				list.seed(game, stats, list.Weights)
+			}
+			if s := req.FormValue("Toggle"); s != "" {
+				team, _ := strconv.Atoi(s)
+				for i := range list.Entries {
+					if list.Entries[i].Team == team {
+						list.Entries[i].Picked = !list.Entries[i].Picked
+					}
+				}
+			}
+			list.groupByTier()
+
+			// Save
+			err := server.Store().UpdatePickList(event.Tag(), list)
+			if err == nil {
+				u, err := server.GetRoute("event.pickList").URL("year", strconv.Itoa(event.Date.Year), "location", event.Location.Code)
+				if err != nil {
+					return err
+				}
+				http.Redirect(w, req, u.String(), http.StatusFound)
+				return nil
+			} else if err != StoreConflict {
+				return err
+			}
+			formError = errors.New("Someone else changed the pick list while you were editing it.  Your changes were not saved; this is the latest version.")
+		}
+	}
+
+	list, err := fetchPickList(server.Store(), event)
+	if err != nil {
+		return err
+	}
+	teamList, err := server.Store().FetchTeams(event.Teams)
+	if err != nil {
+		return err
+	}
+	teams := make(map[int]*Team, len(teamList))
+	for _, t := range teamList {
+		teams[t.Number] = t
+	}
+	weights := list.Weights
+	if len(weights) == 0 {
+		weights = defaultPickWeights
+	}
+	var weightFields []pickWeightField
+	for _, ps := range pickStats(game) {
+		f := pickWeightField{Name: ps.Name, Label: ps.Label}
+		for _, w := range weights {
+			if w.Stat == ps.Name {
+				f.Weight = w.Weight
+			}
+		}
+		weightFields = append(weightFields, f)
+	}
+
+	return server.Templates().ExecuteTemplate(w, "event-picklist.html", map[string]interface{}{
+		"Server":  server,
+		"Request": req,
+		"Event":   event,
+		"List":    list,
+		"Rows":    pickListRows(list, event, teams, stats, pickScores(game, stats, weights)),
+		"Tiers":   pickTiers,
+		"Weights": weightFields,
+		"Error":   formError,
+	})
+}
+
+// eventPickListPDF prints an event's pick list.
+func eventPickListPDF(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	list, err := fetchPickList(server.Store(), event)
+	if err != nil {
+		return err
+	}
+	stats, err := fetchTeamStats(server.Store(), event.Tag(), event.Teams)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	doc := pdf.New()
+	renderPickList(doc, pdf.USLetterWidth, pdf.USLetterHeight, event, list, stats)
+	return doc.Encode(w)
+}
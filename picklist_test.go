@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func pickListTeams(list *PickList) []int {
+	teams := make([]int, len(list.Entries))
+	for i, e := range list.Entries {
+		teams[i] = e.Team
+	}
+	return teams
+}
+
+func TestPickListSyncTeams(t *testing.T) {
+	list := &PickList{Entries: []PickEntry{
+		{Team: 5, Tier: DoNotPick},
+		{Team: 1, Tier: SecondPick},
+		{Team: 9, Tier: FirstPick},
+		{Team: 2, Tier: FirstPick},
+		{Team: 3, Tier: "bogus"},
+	}}
+	list.syncTeams([]int{1, 2, 3, 4, 5})
+	expected := []PickEntry{
+		{Team: 2, Tier: FirstPick},
+		{Team: 1, Tier: SecondPick},
+		{Team: 3, Tier: SecondPick},
+		{Team: 4, Tier: SecondPick},
+		{Team: 5, Tier: DoNotPick},
+	}
+	if !reflect.DeepEqual(list.Entries, expected) {
+		t.Errorf("Entries = %+v (expected %+v)", list.Entries, expected)
+	}
+}
+
+func TestPickScores(t *testing.T) {
+	stats := map[int]TeamStats{
+		1: {OPR: 10, CCWM: 0},
+		2: {OPR: 20, CCWM: 12},
+		3: {OPR: 30, CCWM: 3},
+	}
+	// Standard scores: OPR -1.22, 0, 1.22; CCWM -0.98, 1.37, -0.39.
+	scores := pickScores(reboundRumble, stats, []StatWeight{{"OPR", 1}, {"CCWM", 1}})
+	if !(scores[2] > scores[3] && scores[3] > scores[1]) {
+		t.Errorf("scores = %v (expected 2 > 3 > 1)", scores)
+	}
+	scores = pickScores(reboundRumble, stats, []StatWeight{{"OPR", 1}, {"CCWM", 0.5}})
+	if !(scores[3] > scores[2] && scores[2] > scores[1]) {
+		t.Errorf("scores with lower CCWM weight = %v (expected 3 > 2 > 1)", scores)
+	}
+	if scores := pickScores(reboundRumble, stats, []StatWeight{{"FailureRate", 1}}); scores[1] != 0 || scores[2] != 0 || scores[3] != 0 {
+		t.Errorf("scores with constant stat = %v (expected all zero)", scores)
+	}
+}
+
+func TestPickListSeed(t *testing.T) {
+	teams := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	stats := make(map[int]TeamStats)
+	for _, t := range teams {
+		stats[t] = TeamStats{OPR: float64(t)}
+	}
+	list := &PickList{Entries: []PickEntry{{Team: 11, Tier: DoNotPick, Notes: "tips over"}}}
+	list.syncTeams(teams)
+	list.seed(reboundRumble, stats, []StatWeight{{"OPR", 1}})
+
+	if got, expected := pickListTeams(list), []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 11}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("teams = %v (expected %v)", got, expected)
+	}
+	for i, e := range list.Entries {
+		tier := SecondPick
+		switch {
+		case e.Team == 11:
+			tier = DoNotPick
+		case i < firstPickTierSize:
+			tier = FirstPick
+		}
+		if e.Tier != tier {
+			t.Errorf("team %d tier = %v (expected %v)", e.Team, e.Tier, tier)
+		}
+	}
+	if e := list.Entries[len(list.Entries)-1]; e.Notes != "tips over" {
+		t.Errorf("seeding lost notes: %+v", e)
+	}
+}
+
+func TestParsePickListForm(t *testing.T) {
+	req := newFormRequest(t, url.Values{
+		"Revision":    {"3"},
+		"Team":        {"254", "973"},
+		"Tier":        {"first", "dnp"},
+		"Notes":       {" fast ", ""},
+		"Picked":      {"973"},
+		"Weight.OPR":  {"2"},
+		"Weight.CCWM": {"0"},
+	})
+	list, err := parsePickListForm(req, reboundRumble)
+	if err != nil {
+		t.Fatalf("parsePickListForm error: %v", err)
+	}
+	expected := &PickList{
+		Entries: []PickEntry{
+			{Team: 254, Tier: FirstPick, Notes: "fast"},
+			{Team: 973, Tier: DoNotPick, Picked: true},
+		},
+		Weights:  []StatWeight{{"OPR", 2}},
+		Revision: 3,
+	}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("parsePickListForm = %+v (expected %+v)", list, expected)
+	}
+
+	badForms := []url.Values{
+		{"Team": {"254"}, "Tier": {"first"}},
+		{"Team": {"abc"}, "Tier": {"first"}, "Notes": {""}},
+		{"Weight.OPR": {"lots"}},
+	}
+	for _, form := range badForms {
+		if _, err := parsePickListForm(newFormRequest(t, form), reboundRumble); err == nil {
+			t.Errorf("parsePickListForm(%v) returned no error", form)
+		}
+	}
+}
+
+func TestEventPickListForm(t *testing.T) {
+	store := newTestServer(t)
+	event, _ := seedTestEvent(t, store)
+	event.Alliances = []EliminationAlliance{{Captain: 1, Picks: []int{2}}, {Captain: 3}}
+	mustUpsertEvent(t, store, event)
+	const path = "/event/2012/sdc/+picklist"
+
+	rec := serveTestRequest(t, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+	if body := rec.Body.String(); strings.Count(body, `class="pick_entry taken"`) != 3 {
+		t.Errorf("Pick list should strike alliance members:\n%s", body)
+	}
+
+	form := url.Values{
+		"Revision": {"0"},
+		"Team":     {"6", "5", "4", "3", "2", "1"},
+		"Tier":     {"first", "first", "dnp", "second", "second", "second"},
+		"Notes":    {"", "", "slow", "", "", ""},
+		"Toggle":   {"5"},
+	}
+	if rec := serveTestRequest(t, path, form); rec.Code != http.StatusFound {
+		t.Fatalf("POST %s code = %d", path, rec.Code)
+	}
+	list, err := store.FetchPickList(event.Tag())
+	if err != nil {
+		t.Fatalf("FetchPickList error: %v", err)
+	}
+	if got, expected := pickListTeams(list), []int{6, 5, 3, 2, 1, 4}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("teams = %v (expected %v)", got, expected)
+	}
+	if !list.Entries[1].Picked || list.Entries[5].Notes != "slow" {
+		t.Errorf("Entries = %+v", list.Entries)
+	}
+
+	// Resubmitting the old revision conflicts.
+	rec = serveTestRequest(t, path, form)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `class="error"`) {
+		t.Errorf("POST with stale revision code = %d", rec.Code)
+	}
+}
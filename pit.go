@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/gopdf/pdf"
+	"code.google.com/p/gorilla/mux"
+	"errors"
+	"image"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Choices offered for the pit scouting fields.  Scouts pick "Other" and
+// explain in the notes when a robot doesn't fit.
+var (
+	drivetrains          = []string{"Tank", "Mecanum", "Swerve", "Omni", "Other"}
+	shooterTypes         = []string{"None", "Single Wheel", "Dual Wheel", "Turret", "Catapult", "Other"}
+	bridgeManipulators   = []string{"None", "Arm", "Wedge", "Other"}
+	programmingLanguages = []string{"C++", "Java", "LabVIEW", "Other"}
+)
+
+// A pitChoice is a multiple-choice pit scouting field.
+type pitChoice struct {
+	Name    string
+	Label   string
+	Choices []string
+	value   func(*Robot) *string
+}
+
+// pitChoices lists the multiple-choice fields in the order that they appear
+// on the forms.
+var pitChoices = []pitChoice{
+	{"Drivetrain", "Drivetrain", drivetrains, func(r *Robot) *string { return &r.Drivetrain }},
+	{"Shooter", "Shooter", shooterTypes, func(r *Robot) *string { return &r.Shooter }},
+	{"BridgeManipulator", "Bridge Manipulator", bridgeManipulators, func(r *Robot) *string { return &r.BridgeManipulator }},
+	{"ProgrammingLanguage", "Language", programmingLanguages, func(r *Robot) *string { return &r.ProgrammingLanguage }},
+}
+
+// Value returns the field's value in robot.
+func (c pitChoice) Value(robot *Robot) string {
+	return *c.value(robot)
+}
+
+// maxRobotWeight is the heaviest weight accepted on the pit scouting form, in
+// pounds.  It is well over the limit to allow for bumpers and batteries.
+const maxRobotWeight = 250
+
+// maxPhotoUpload is the most memory used to hold uploaded photos while
+// parsing the pit scouting form.  Larger uploads are kept on disk.
+const maxPhotoUpload = 8 << 20
+
+// parseRobotForm parses the pit scouting form into a copy of robot.  Blank
+// fields are unknown.  Photos named by "RemovePhoto" values are dropped from
+// the profile; uploads are handled separately.
+func parseRobotForm(req *http.Request, robot *Robot) (*Robot, error) {
+	r := copyRobot(robot)
+	r.Name = strings.TrimSpace(req.FormValue("Name"))
+	r.Notes = strings.TrimSpace(req.FormValue("Notes"))
+	for _, c := range pitChoices {
+		v := req.FormValue(c.Name)
+		if v != "" && !containsString(c.Choices, v) {
+			return nil, errors.New(c.Label + ": unknown choice " + strconv.Quote(v))
+		}
+		*c.value(r) = v
+	}
+
+	r.Weight = 0
+	if s := strings.TrimSpace(req.FormValue("Weight")); s != "" {
+		w, err := strconv.ParseFloat(s, 64)
+		if err != nil || w <= 0 || w > maxRobotWeight {
+			return nil, errors.New("Weight must be a number of pounds between 0 and " + strconv.Itoa(maxRobotWeight))
+		}
+		r.Weight = w
+	}
+
+	if removed := req.Form["RemovePhoto"]; len(removed) > 0 {
+		photos := r.Photos[:0]
+		for _, name := range r.Photos {
+			if !containsString(removed, name) {
+				photos = append(photos, name)
+			}
+		}
+		r.Photos = photos
+	}
+	return r, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, t := range list {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPhoto returns an error if an uploaded file isn't a JPEG image.
+func checkPhoto(fh *multipart.FileHeader) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, format, err := image.DecodeConfig(f); err != nil || format != "jpeg" {
+		return errors.New("Photo " + strconv.Quote(fh.Filename) + " is not a JPEG image")
+	}
+	return nil
+}
+
+// addRobotPhotos stores uploaded photos of a team's robot and returns their
+// names.  Nothing is stored unless every upload is a JPEG image.
+func addRobotPhotos(store Imagestore, team int, year int, files []*multipart.FileHeader) ([]string, error) {
+	for _, fh := range files {
+		if err := checkPhoto(fh); err != nil {
+			return nil, err
+		}
+	}
+	names := make([]string, 0, len(files))
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			return names, err
+		}
+		name, err := store.AddRobotPhoto(team, year, f)
+		f.Close()
+		if err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// fetchRobot returns a team's pit scouting profile for a season, or nil if
+// the team hasn't been pit scouted.
+func fetchRobot(store Datastore, team int, year int) (*Robot, error) {
+	robot, err := store.FetchRobot(team, year)
+	if err == StoreNotFound {
+		return nil, nil
+	}
+	return robot, err
+}
+
+// fetchRobots returns the pit scouting profiles of several teams for a
+// season.  Teams that haven't been pit scouted are left out.
+func fetchRobots(store Datastore, year int, teams []int) (map[int]*Robot, error) {
+	robots := make(map[int]*Robot, len(teams))
+	for _, t := range teams {
+		robot, err := fetchRobot(store, t, year)
+		if err != nil {
+			return nil, err
+		}
+		if robot != nil {
+			robots[t] = robot
+		}
+	}
+	return robots, nil
+}
+
+// robotSummary returns a line for each of the robot's known pit scouting
+// fields, for the printed reports.
+func robotSummary(robot *Robot) []string {
+	if robot == nil {
+		return nil
+	}
+	var lines []string
+	for _, c := range pitChoices {
+		if v := c.Value(robot); v != "" {
+			lines = append(lines, c.Label+": "+v)
+		}
+	}
+	if robot.Weight != 0 {
+		lines = append(lines, "Weight: "+strconv.FormatFloat(robot.Weight, 'f', -1, 64)+" lb")
+	}
+	return lines
+}
+
+// editRobot lets a pit scout fill in a team's robot profile for a season.
+func editRobot(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+	number, _ := strconv.Atoi(vars["number"])
+	year, _ := strconv.Atoi(vars["year"])
+
+	// Fetch team
+	team, err := server.Store().FetchTeam(number)
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	robot, err := fetchRobot(server.Store(), number, year)
+	if err != nil {
+		return err
+	}
+	if robot == nil {
+		robot = new(Robot)
+		if team.Robot != nil {
+			robot.Name = team.Robot.Name
+		}
+	}
+
+	var formError error
+	if req.Method == "POST" {
+		if err := req.ParseMultipartForm(maxPhotoUpload); err != nil && err != http.ErrNotMultipart {
+			return err
+		}
+		var r *Robot
+		r, formError = parseRobotForm(req, robot)
+		if formError == nil && req.MultipartForm != nil {
+			var names []string
+			names, formError = addRobotPhotos(server.imagestore, number, year, req.MultipartForm.File["Photo"])
+			if formError == nil {
+				r.Photos = append(r.Photos, names...)
+			}
+		}
+		if formError == nil {
+			// Save
+			if err := server.Store().UpsertRobot(number, year, r); err != nil {
+				return err
+			}
+
+			// Redirect
+			u, err := server.GetRoute("team.view").URL("number", strconv.Itoa(number))
+			if err != nil {
+				return err
+			}
+			u.Fragment = "robot"
+			http.Redirect(w, req, u.String(), http.StatusFound)
+			return nil
+		}
+	}
+
+	return server.Templates().ExecuteTemplate(w, "team-pit.html", map[string]interface{}{
+		"Server":  server,
+		"Request": req,
+		"Team":    team,
+		"Year":    year,
+		"Robot":   robot,
+		"Choices": pitChoices,
+		"Error":   formError,
+	})
+}
+
+// eventPitForms prints a pit scouting form for every team at an event.
+func eventPitForms(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	doc := pdf.New()
+	renderPitForms(doc, pdf.USLetterWidth, pdf.USLetterHeight, event)
+	return doc.Encode(w)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRobotForm(t *testing.T) {
+	old := &Robot{Name: "Ringo", Weight: 100, Photos: []string{"a.jpg", "b.jpg", "c.jpg"}}
+	req := newFormRequest(t, url.Values{
+		"Name":                {" Ringo "},
+		"Drivetrain":          {"Mecanum"},
+		"Shooter":             {"Turret"},
+		"BridgeManipulator":   {""},
+		"ProgrammingLanguage": {"Java"},
+		"Weight":              {"118.5"},
+		"Notes":               {"Low center of gravity"},
+		"RemovePhoto":         {"b.jpg"},
+	})
+	robot, err := parseRobotForm(req, old)
+	if err != nil {
+		t.Fatalf("parseRobotForm error: %v", err)
+	}
+	expected := &Robot{
+		Name:                "Ringo",
+		Notes:               "Low center of gravity",
+		Drivetrain:          "Mecanum",
+		Shooter:             "Turret",
+		Weight:              118.5,
+		ProgrammingLanguage: "Java",
+		Photos:              []string{"a.jpg", "c.jpg"},
+	}
+	if !reflect.DeepEqual(robot, expected) {
+		t.Errorf("parseRobotForm = %+v (expected %+v)", robot, expected)
+	}
+	if len(old.Photos) != 3 || old.Photos[1] != "b.jpg" {
+		t.Errorf("parseRobotForm changed the old robot's photos: %v", old.Photos)
+	}
+
+	bad := []url.Values{
+		{"Drivetrain": {"Hovercraft"}},
+		{"Weight": {"heavy"}},
+		{"Weight": {"-5"}},
+		{"Weight": {"900"}},
+	}
+	for _, form := range bad {
+		if _, err := parseRobotForm(newFormRequest(t, form), old); err == nil {
+			t.Errorf("parseRobotForm(%v) did not return an error", form)
+		}
+	}
+}
+
+func TestRobotSummary(t *testing.T) {
+	robot := &Robot{Drivetrain: "Tank", ProgrammingLanguage: "C++", Weight: 112.5, Notes: "not shown"}
+	expected := []string{"Drivetrain: Tank", "Language: C++", "Weight: 112.5 lb"}
+	if lines := robotSummary(robot); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("robotSummary = %q (expected %q)", lines, expected)
+	}
+	if lines := robotSummary(nil); lines != nil {
+		t.Errorf("robotSummary(nil) = %q", lines)
+	}
+}
+
+// newPhotoRequest returns a multipart POST of the pit scouting form with the
+// given files uploaded as photos.
+func newPhotoRequest(t *testing.T, path string, form url.Values, files map[string][]byte) *http.Request {
+	return newUploadRequest(t, path, "Photo", form, files)
+}
+
+// newUploadRequest returns a multipart POST of a form with the given files
+// uploaded in field.
+func newUploadRequest(t *testing.T, path string, field string, form url.Values, files map[string][]byte) *http.Request {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for k, vs := range form {
+		for _, v := range vs {
+			mw.WriteField(k, v)
+		}
+	}
+	for name, data := range files {
+		w, err := mw.CreateFormFile(field, name)
+		if err != nil {
+			t.Fatalf("CreateFormFile error: %v", err)
+		}
+		w.Write(data)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("multipart Close error: %v", err)
+	}
+	req, err := http.NewRequest("POST", path, body)
+	if err != nil {
+		t.Fatalf("NewRequest(%q) error: %v", path, err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func testJPEG(t *testing.T) []byte {
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 4, 3)), nil); err != nil {
+		t.Fatalf("jpeg.Encode error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestEditRobot(t *testing.T) {
+	store := newTestServer(t)
+	dir, err := ioutil.TempDir("", "scouting-images")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	server.imagestore = directoryImagestore{dir, &url.URL{Path: "/team/images/"}}
+	mustUpsertTeams(t, store, 973)
+	const path = "/team/973/2012/+pit"
+
+	rec := serveTestRequest(t, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+
+	// Photos that aren't JPEGs are rejected without saving anything.
+	req := newPhotoRequest(t, path, url.Values{"Drivetrain": {"Swerve"}}, map[string][]byte{"robot.txt": []byte("not a photo")})
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "not a JPEG image") {
+		t.Errorf("POST with bad photo code = %d, body missing error", rec.Code)
+	}
+	if _, err := store.FetchRobot(973, 2012); err != StoreNotFound {
+		t.Errorf("After bad photo, FetchRobot error = %v (expected %v)", err, StoreNotFound)
+	}
+
+	req = newPhotoRequest(t, path, url.Values{"Drivetrain": {"Swerve"}, "Weight": {"120"}}, map[string][]byte{"robot.jpg": testJPEG(t)})
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("POST %s code = %d", path, rec.Code)
+	}
+	robot, err := store.FetchRobot(973, 2012)
+	if err != nil {
+		t.Fatalf("FetchRobot error: %v", err)
+	}
+	if robot.Drivetrain != "Swerve" || robot.Weight != 120 || len(robot.Photos) != 1 {
+		t.Fatalf("Saved robot = %+v", robot)
+	}
+	if _, err := ReadRobotPhoto(server.imagestore, robot.Photos[0]); err != nil {
+		t.Errorf("ReadRobotPhoto(%q) error: %v", robot.Photos[0], err)
+	}
+
+	// A plain form keeps the photos.
+	rec = serveTestRequest(t, path, url.Values{"Drivetrain": {"Swerve"}, "Notes": {"Fast"}})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Second POST %s code = %d", path, rec.Code)
+	}
+	if robot, err := store.FetchRobot(973, 2012); err != nil {
+		t.Errorf("FetchRobot error: %v", err)
+	} else if robot.Notes != "Fast" || len(robot.Photos) != 1 {
+		t.Errorf("After second POST, robot = %+v", robot)
+	}
+	if _, err := store.FetchRobot(973, 2013); err != StoreNotFound {
+		t.Errorf("FetchRobot for other season error = %v (expected %v)", err, StoreNotFound)
+	}
+}
+
+func TestJumpPitTag(t *testing.T) {
+	newTestServer(t)
+	rec := serveTestRequest(t, "/jump?q=sdc2012p973", nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Jump code = %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/team/973/2012/+pit" {
+		t.Errorf("Jump location = %q (expected %q)", loc, "/team/973/2012/+pit")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// A Prediction estimates the outcome of a match.
+type Prediction struct {
+	Red  AlliancePrediction
+	Blue AlliancePrediction
+}
+
+// An AlliancePrediction estimates an alliance's result in a match.
+type AlliancePrediction struct {
+	Alliance       Alliance
+	Score          float64 // expected score
+	StdDev         float64
+	WinProbability float64
+}
+
+// teamExpectation returns the mean and variance of a team's contribution to
+// its alliance's score.  The team's OPR is used as the mean because it
+// accounts for points that scouts can't attribute to a robot.  Early in an
+// event, before any scored matches, the average scouted score is used
+// instead.
+func teamExpectation(stats TeamStats) (mean, variance float64) {
+	mean = stats.OPR
+	if mean == 0 {
+		mean = stats.AverageScore()
+	}
+	return mean, stats.ScoreVariance()
+}
+
+// predictMatch predicts the outcome of a match from each team's event
+// statistics.  The alliance scores are assumed to be normally distributed
+// with the sum of the teams' means and variances.
+func predictMatch(match *Match, stats map[int]TeamStats) *Prediction {
+	p := &Prediction{
+		Red:  AlliancePrediction{Alliance: Red},
+		Blue: AlliancePrediction{Alliance: Blue},
+	}
+	var redVar, blueVar float64
+	for _, info := range match.Teams {
+		mean, variance := teamExpectation(stats[info.Team])
+		switch info.Alliance {
+		case Red:
+			p.Red.Score += mean
+			redVar += variance
+		case Blue:
+			p.Blue.Score += mean
+			blueVar += variance
+		}
+	}
+	p.Red.StdDev = math.Sqrt(redVar)
+	p.Blue.StdDev = math.Sqrt(blueVar)
+
+	diff := p.Red.Score - p.Blue.Score
+	sd := math.Sqrt(redVar + blueVar)
+	switch {
+	case sd != 0:
+		p.Red.WinProbability = normalCDF(diff / sd)
+	case diff > 0:
+		p.Red.WinProbability = 1
+	case diff < 0:
+		p.Red.WinProbability = 0
+	default:
+		p.Red.WinProbability = 0.5
+	}
+	p.Blue.WinProbability = 1 - p.Red.WinProbability
+	return p
+}
+
+// Favorite returns the alliance more likely to win.
+func (p *Prediction) Favorite() AlliancePrediction {
+	if p.Blue.WinProbability > p.Red.WinProbability {
+		return p.Blue
+	}
+	return p.Red
+}
+
+// normalCDF returns the cumulative distribution function of the standard
+// normal distribution.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// A ProjectedRecord is a team's qualification record so far, plus its
+// expected results after its remaining matches.
+type ProjectedRecord struct {
+	Team                  int
+	Wins                  int
+	Losses                int
+	Ties                  int
+	RankingPoints         int
+	Remaining             int
+	ExpectedWins          float64
+	ExpectedRankingPoints float64
+
+	// AverageRank is the team's mean final rank over simulations of the
+	// remaining matches.
+	AverageRank float64
+}
+
+// projectStandings projects the final qualification rankings.  Each team's
+// win probability for its unscored matches is added to its current record,
+// and the remaining matches are simulated to find each team's average final
+// rank.  The result is sorted by average rank.
+func projectStandings(game *Game, matches []*Match, stats map[int]TeamStats) []ProjectedRecord {
+	standings := computeStandings(game, matches)
+	records := make([]ProjectedRecord, len(standings))
+	index := make(map[int]int, len(standings))
+	for i, s := range standings {
+		records[i] = ProjectedRecord{
+			Team:                  s.Team,
+			Wins:                  s.Wins,
+			Losses:                s.Losses,
+			Ties:                  s.Ties,
+			RankingPoints:         s.RankingPoints,
+			ExpectedWins:          float64(s.Wins),
+			ExpectedRankingPoints: float64(s.RankingPoints),
+		}
+		index[s.Team] = i
+	}
+
+	var remaining []*Match
+	for _, m := range matches {
+		if m.Type != Qualification || m.Score != nil {
+			continue
+		}
+		remaining = append(remaining, m)
+		p := predictMatch(m, stats)
+		for _, info := range m.Teams {
+			r := &records[index[info.Team]]
+			r.Remaining++
+			if info.Alliance == Red {
+				r.ExpectedWins += p.Red.WinProbability
+			} else {
+				r.ExpectedWins += p.Blue.WinProbability
+			}
+		}
+	}
+
+	// Seed with a constant so that the projection doesn't change each time
+	// the page is loaded.
+	ranks := simulateRanks(game, standings, remaining, stats, projectionRuns, rand.New(rand.NewSource(1)))
+	for i := range records {
+		r := &records[i]
+		r.ExpectedRankingPoints += (r.ExpectedWins - float64(r.Wins)) * float64(game.Ranking.WinPoints)
+		r.AverageRank = ranks[r.Team]
+	}
+	sort.Sort(byAverageRank(records))
+	return records
+}
+
+type byAverageRank []ProjectedRecord
+
+func (slice byAverageRank) Len() int {
+	return len(slice)
+}
+
+func (slice byAverageRank) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice byAverageRank) Less(i, j int) bool {
+	if slice[i].AverageRank != slice[j].AverageRank {
+		return slice[i].AverageRank < slice[j].AverageRank
+	}
+	return slice[i].Team < slice[j].Team
+}
+
+// matchTeams returns the numbers of the teams in a match.
+func matchTeams(match *Match) []int {
+	teams := make([]int, len(match.Teams))
+	for i := range match.Teams {
+		teams[i] = match.Teams[i].Team
+	}
+	return teams
+}
+
+// fetchTeamStats returns the event statistics for several teams.
+func fetchTeamStats(store teamEventStatser, tag EventTag, teams []int) (map[int]TeamStats, error) {
+	stats := make(map[int]TeamStats, len(teams))
+	for _, team := range teams {
+		s, err := store.TeamEventStats(tag, team)
+		if err != nil {
+			return nil, err
+		}
+		stats[team] = s
+	}
+	return stats, nil
+}
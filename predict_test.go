@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPredictMatch(t *testing.T) {
+	match := newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6)
+	stats := map[int]TeamStats{
+		1: {OPR: 20, MatchCount: 2, TotalPoints: 30, SquaredPoints: 500},
+		2: {OPR: 10},
+		3: {MatchCount: 2, TotalPoints: 10, SquaredPoints: 50},
+		4: {OPR: 15},
+		5: {OPR: 5},
+	}
+	p := predictMatch(match, stats)
+
+	// Team 1: variance (500 - 2*15²)/1 = 50.  Team 3 has no OPR, so its
+	// average score of 5 is used with variance (50 - 2*5²)/1 = 0.
+	if p.Red.Alliance != Red || p.Red.Score != 35 || math.Abs(p.Red.StdDev-math.Sqrt(50)) > 1e-9 {
+		t.Errorf("p.Red = %+v", p.Red)
+	}
+	if p.Blue.Alliance != Blue || p.Blue.Score != 20 || p.Blue.StdDev != 0 {
+		t.Errorf("p.Blue = %+v", p.Blue)
+	}
+	expected := normalCDF(15 / math.Sqrt(50))
+	if math.Abs(p.Red.WinProbability-expected) > 1e-9 || math.Abs(p.Red.WinProbability+p.Blue.WinProbability-1) > 1e-9 {
+		t.Errorf("win probabilities = %.3f/%.3f (expected red %.3f)", p.Red.WinProbability, p.Blue.WinProbability, expected)
+	}
+	if f := p.Favorite(); f.Alliance != Red {
+		t.Errorf("p.Favorite() = %v (expected %v)", f.Alliance, Red)
+	}
+}
+
+func TestPredictMatchNoVariance(t *testing.T) {
+	match := newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6)
+	tests := []struct {
+		Stats map[int]TeamStats
+		Red   float64
+	}{
+		{map[int]TeamStats{}, 0.5},
+		{map[int]TeamStats{1: {OPR: 1}}, 1},
+		{map[int]TeamStats{4: {OPR: 1}}, 0},
+	}
+	for i, tt := range tests {
+		p := predictMatch(match, tt.Stats)
+		if p.Red.WinProbability != tt.Red || p.Blue.WinProbability != 1-tt.Red {
+			t.Errorf("tests[%d] win probabilities = %v/%v (expected red %v)", i, p.Red.WinProbability, p.Blue.WinProbability, tt.Red)
+		}
+	}
+}
+
+func TestProjectStandings(t *testing.T) {
+	played := newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6)
+	played.Score = map[string]int{"red": 30, "blue": 20}
+	tied := newTestMatch(Qualification, 2, 1, 4, 5, 2, 3, 6)
+	tied.Score = map[string]int{"red": 10, "blue": 10}
+	upcoming := newTestMatch(Qualification, 3, 1, 2, 6, 3, 4, 5)
+	elim := newTestMatch(Final, 1, 1, 2, 3, 4, 5, 6)
+	elim.Score = map[string]int{"red": 30, "blue": 20}
+	stats := map[int]TeamStats{1: {OPR: 10}}
+
+	// Red is certain to win the upcoming match, so every simulation ends
+	// with the same ranks.
+	standings := projectStandings(reboundRumble, []*Match{played, tied, upcoming, elim}, stats)
+	expected := []ProjectedRecord{
+		{Team: 1, Wins: 1, Ties: 1, RankingPoints: 3, Remaining: 1, ExpectedWins: 2, ExpectedRankingPoints: 5, AverageRank: 1},
+		{Team: 2, Wins: 1, Ties: 1, RankingPoints: 3, Remaining: 1, ExpectedWins: 2, ExpectedRankingPoints: 5, AverageRank: 2},
+		{Team: 3, Wins: 1, Ties: 1, RankingPoints: 3, Remaining: 1, ExpectedWins: 1, ExpectedRankingPoints: 3, AverageRank: 3},
+		{Team: 6, Losses: 1, Ties: 1, RankingPoints: 1, Remaining: 1, ExpectedWins: 1, ExpectedRankingPoints: 3, AverageRank: 4},
+		{Team: 4, Losses: 1, Ties: 1, RankingPoints: 1, Remaining: 1, ExpectedWins: 0, ExpectedRankingPoints: 1, AverageRank: 5},
+		{Team: 5, Losses: 1, Ties: 1, RankingPoints: 1, Remaining: 1, ExpectedWins: 0, ExpectedRankingPoints: 1, AverageRank: 6},
+	}
+	if !reflect.DeepEqual(standings, expected) {
+		t.Errorf("projectStandings(...) = %+v (expected %+v)", standings, expected)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// RankingRules describes how teams are ranked by their qualification match
+// results.  Ties in ranking points are broken by bonus ranking points, then by
+// the points that each team's alliances scored in each of the tiebreaker
+// phases, and finally by team number.
+type RankingRules struct {
+	WinPoints int
+	TiePoints int
+
+	// Bonuses award ranking points to every team in a match, win or lose.
+	Bonuses []RankingBonus
+
+	// TiebreakerPhases lists the game phases whose alliance points break
+	// ties, in order.
+	TiebreakerPhases []string
+}
+
+// A RankingBonus awards ranking points to every team in a scored match when
+// any team in the match has a nonzero count, is set, or succeeded in a field.
+type RankingBonus struct {
+	Label  string
+	Field  string
+	Points int
+}
+
+// matchBonus returns the bonus ranking points that every team earns in a
+// match.
+func (rules *RankingRules) matchBonus(g *Game, match *Match) int {
+	points := 0
+	for _, b := range rules.Bonuses {
+		f := g.Field(b.Field)
+		if f == nil {
+			continue
+		}
+		for i := range match.Teams {
+			if f.count(f.Value(&match.Teams[i])) != 0 {
+				points += b.Points
+				break
+			}
+		}
+	}
+	return points
+}
+
+// phasePoints returns the points that an alliance's teams scored in each of
+// the tiebreaker phases, as recorded by scouts.
+func (g *Game) phasePoints(match *Match, alliance Alliance) []int {
+	points := make([]int, len(g.Ranking.TiebreakerPhases))
+	for i := range match.Teams {
+		info := &match.Teams[i]
+		if info.Alliance != alliance {
+			continue
+		}
+		for k, phase := range g.Ranking.TiebreakerPhases {
+			for j := range g.Fields {
+				f := &g.Fields[j]
+				if f.Phase == phase {
+					points[k] += f.count(f.Value(info)) * f.Points
+				}
+			}
+		}
+	}
+	return points
+}
+
+// A Standing is a team's qualification ranking at an event.
+type Standing struct {
+	Team   int
+	Rank   int
+	Played int
+	Wins   int
+	Losses int
+	Ties   int
+
+	// RankingPoints includes BonusPoints.
+	RankingPoints int
+	BonusPoints   int
+
+	// PhasePoints holds the total alliance points in each of the game's
+	// tiebreaker phases.
+	PhasePoints []int
+}
+
+// computeStandings ranks every team in a qualification match by its results
+// in the scored qualification matches.  The result is sorted by rank.
+func computeStandings(game *Game, matches []*Match) []Standing {
+	byTeam := make(map[int]*Standing)
+	var standings []*Standing
+	standing := func(team int) *Standing {
+		s := byTeam[team]
+		if s == nil {
+			s = &Standing{Team: team, PhasePoints: make([]int, len(game.Ranking.TiebreakerPhases))}
+			byTeam[team] = s
+			standings = append(standings, s)
+		}
+		return s
+	}
+
+	rules := &game.Ranking
+	for _, m := range matches {
+		if m.Type != Qualification {
+			continue
+		}
+		if m.Score == nil {
+			for _, info := range m.Teams {
+				standing(info.Team)
+			}
+			continue
+		}
+		winner := m.Winner()
+		bonus := rules.matchBonus(game, m)
+		points := map[Alliance][]int{
+			Red:  game.phasePoints(m, Red),
+			Blue: game.phasePoints(m, Blue),
+		}
+		for _, info := range m.Teams {
+			s := standing(info.Team)
+			s.Played++
+			switch winner {
+			case info.Alliance:
+				s.Wins++
+				s.RankingPoints += rules.WinPoints
+			case "":
+				s.Ties++
+				s.RankingPoints += rules.TiePoints
+			default:
+				s.Losses++
+			}
+			s.RankingPoints += bonus
+			s.BonusPoints += bonus
+			for k, p := range points[info.Alliance] {
+				s.PhasePoints[k] += p
+			}
+		}
+	}
+
+	result := make([]Standing, len(standings))
+	for i := range standings {
+		result[i] = *standings[i]
+	}
+	rankStandings(result)
+	return result
+}
+
+// rankStandings sorts standings by the ranking rules and numbers them.
+func rankStandings(standings []Standing) {
+	sort.Sort(byRanking(standings))
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+}
+
+type byRanking []Standing
+
+func (slice byRanking) Len() int {
+	return len(slice)
+}
+
+func (slice byRanking) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice byRanking) Less(i, j int) bool {
+	a, b := &slice[i], &slice[j]
+	if a.RankingPoints != b.RankingPoints {
+		return a.RankingPoints > b.RankingPoints
+	}
+	if a.BonusPoints != b.BonusPoints {
+		return a.BonusPoints > b.BonusPoints
+	}
+	for k := range a.PhasePoints {
+		if a.PhasePoints[k] != b.PhasePoints[k] {
+			return a.PhasePoints[k] > b.PhasePoints[k]
+		}
+	}
+	return a.Team < b.Team
+}
+
+// standingColumn returns the value of a rankings table column, or nil if the
+// key doesn't name a column.  Phase columns are named by the phase.
+func standingColumn(game *Game, key string) func(*Standing) int {
+	switch key {
+	case "team":
+		return func(s *Standing) int { return -s.Team }
+	case "played":
+		return func(s *Standing) int { return s.Played }
+	case "wins":
+		return func(s *Standing) int { return s.Wins }
+	case "losses":
+		return func(s *Standing) int { return s.Losses }
+	case "ties":
+		return func(s *Standing) int { return s.Ties }
+	case "bonus":
+		return func(s *Standing) int { return s.BonusPoints }
+	}
+	for k, phase := range game.Ranking.TiebreakerPhases {
+		if key == phase {
+			k := k
+			return func(s *Standing) int { return s.PhasePoints[k] }
+		}
+	}
+	return nil
+}
+
+// sortStandings sorts ranked standings by a rankings table column, highest
+// first (team numbers are sorted lowest first).  Rows with the same value
+// stay in rank order.  Unknown keys sort by rank.
+func sortStandings(game *Game, standings []Standing, key string) {
+	column := standingColumn(game, key)
+	if column == nil {
+		sort.Sort(standingsByRank(standings))
+		return
+	}
+	sort.Sort(standingsByColumn{standings, column})
+}
+
+type standingsByRank []Standing
+
+func (slice standingsByRank) Len() int {
+	return len(slice)
+}
+
+func (slice standingsByRank) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice standingsByRank) Less(i, j int) bool {
+	return slice[i].Rank < slice[j].Rank
+}
+
+type standingsByColumn struct {
+	standings []Standing
+	column    func(*Standing) int
+}
+
+func (s standingsByColumn) Len() int {
+	return len(s.standings)
+}
+
+func (s standingsByColumn) Swap(i, j int) {
+	s.standings[i], s.standings[j] = s.standings[j], s.standings[i]
+}
+
+func (s standingsByColumn) Less(i, j int) bool {
+	a, b := s.column(&s.standings[i]), s.column(&s.standings[j])
+	if a != b {
+		return a > b
+	}
+	return s.standings[i].Rank < s.standings[j].Rank
+}
+
+// projectionRuns is the number of times that the remaining qualification
+// matches are simulated to project the final rankings.
+const projectionRuns = 1000
+
+// simulateRanks plays out the unscored qualification matches runs times,
+// picking each winner with the predicted win probability, and returns each
+// team's average final rank.  Simulated matches award win points only; bonus
+// and tiebreaker points are left as they stand.
+func simulateRanks(game *Game, standings []Standing, remaining []*Match, stats map[int]TeamStats, runs int, rng *rand.Rand) map[int]float64 {
+	redWins := make([]float64, len(remaining))
+	for i, m := range remaining {
+		redWins[i] = predictMatch(m, stats).Red.WinProbability
+	}
+	index := make(map[int]int, len(standings))
+	for i := range standings {
+		index[standings[i].Team] = i
+	}
+
+	rankSums := make(map[int]int, len(standings))
+	sim := make([]Standing, len(standings))
+	for run := 0; run < runs; run++ {
+		copy(sim, standings)
+		for i, m := range remaining {
+			winner := Blue
+			if rng.Float64() < redWins[i] {
+				winner = Red
+			}
+			for _, info := range m.Teams {
+				if info.Alliance == winner {
+					sim[index[info.Team]].RankingPoints += game.Ranking.WinPoints
+				}
+			}
+		}
+		sort.Sort(byRanking(sim))
+		for i := range sim {
+			rankSums[sim[i].Team] += i + 1
+		}
+	}
+
+	ranks := make(map[int]float64, len(rankSums))
+	for team, sum := range rankSums {
+		ranks[team] = float64(sum) / float64(runs)
+	}
+	return ranks
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestComputeStandings(t *testing.T) {
+	// Red wins and the coop bridge is balanced.
+	coop := newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6)
+	coop.Score = map[string]int{"red": 30, "blue": 20}
+	coop.Teams[3].CoopBridge = Bridge{Attempted: true, Success: true}
+	coop.Teams[0].Autonomous.High = 1
+	// Blue wins; team 6 balances its alliance's bridge.
+	bridge := newTestMatch(Qualification, 2, 1, 4, 5, 2, 3, 6)
+	bridge.Score = map[string]int{"red": 10, "blue": 20}
+	bridge.Teams[5].TeamBridge1 = Bridge{Attempted: true, Success: true}
+	upcoming := newTestMatch(Qualification, 3, 1, 2, 6, 3, 4, 7)
+	elim := newTestMatch(Final, 1, 1, 2, 3, 4, 5, 6)
+	elim.Score = map[string]int{"red": 30, "blue": 20}
+
+	standings := computeStandings(reboundRumble, []*Match{coop, bridge, upcoming, elim})
+	expected := []Standing{
+		{Team: 2, Rank: 1, Played: 2, Wins: 2, RankingPoints: 6, BonusPoints: 2, PhasePoints: []int{autoHighPoints, bridgePoints, 0}},
+		{Team: 3, Rank: 2, Played: 2, Wins: 2, RankingPoints: 6, BonusPoints: 2, PhasePoints: []int{autoHighPoints, bridgePoints, 0}},
+		{Team: 1, Rank: 3, Played: 2, Wins: 1, Losses: 1, RankingPoints: 4, BonusPoints: 2, PhasePoints: []int{autoHighPoints, 0, 0}},
+		{Team: 6, Rank: 4, Played: 2, Wins: 1, Losses: 1, RankingPoints: 4, BonusPoints: 2, PhasePoints: []int{0, bridgePoints, 0}},
+		{Team: 4, Rank: 5, Played: 2, Losses: 2, RankingPoints: 2, BonusPoints: 2, PhasePoints: []int{0, 0, 0}},
+		{Team: 5, Rank: 6, Played: 2, Losses: 2, RankingPoints: 2, BonusPoints: 2, PhasePoints: []int{0, 0, 0}},
+		{Team: 7, Rank: 7, PhasePoints: []int{0, 0, 0}},
+	}
+	if !reflect.DeepEqual(standings, expected) {
+		t.Errorf("computeStandings(...) = %+v (expected %+v)", standings, expected)
+	}
+}
+
+func TestSortStandings(t *testing.T) {
+	standings := []Standing{
+		{Team: 254, Rank: 1, Wins: 3, PhasePoints: []int{0, 10, 0}},
+		{Team: 1, Rank: 2, Wins: 2, PhasePoints: []int{12, 0, 0}},
+		{Team: 8, Rank: 3, Wins: 2, PhasePoints: []int{6, 0, 0}},
+	}
+	tests := []struct {
+		Key   string
+		Teams []int
+	}{
+		{"team", []int{1, 8, 254}},
+		{"wins", []int{254, 1, 8}},
+		{"Autonomous", []int{1, 8, 254}},
+		{"Bridges", []int{254, 1, 8}},
+		{"", []int{254, 1, 8}},
+		{"bogus", []int{254, 1, 8}},
+	}
+	for _, tt := range tests {
+		sortStandings(reboundRumble, standings, tt.Key)
+		teams := make([]int, len(standings))
+		for i := range standings {
+			teams[i] = standings[i].Team
+		}
+		if !reflect.DeepEqual(teams, tt.Teams) {
+			t.Errorf("sortStandings(%q) teams = %v (expected %v)", tt.Key, teams, tt.Teams)
+		}
+	}
+}
+
+func TestSimulateRanks(t *testing.T) {
+	standings := []Standing{
+		{Team: 1, Rank: 1, RankingPoints: 2, PhasePoints: []int{0, 0, 0}},
+		{Team: 2, Rank: 2, PhasePoints: []int{autoHighPoints, 0, 0}},
+	}
+	remaining := []*Match{{
+		Type:   Qualification,
+		Number: 2,
+		Teams:  []TeamInfo{{Team: 1, Alliance: Red}, {Team: 2, Alliance: Blue}},
+	}}
+	// Team 1 is certain to win.
+	ranks := simulateRanks(reboundRumble, standings, remaining, map[int]TeamStats{1: {OPR: 10}}, 100, rand.New(rand.NewSource(1)))
+	if ranks[1] != 1 || ranks[2] != 2 {
+		t.Errorf("ranks = %v (expected map[1:1 2:2])", ranks)
+	}
+
+	// Team 2 is certain to win, ties team 1 on ranking points and wins the
+	// autonomous tiebreaker.
+	ranks = simulateRanks(reboundRumble, standings, remaining, map[int]TeamStats{2: {OPR: 10}}, 100, rand.New(rand.NewSource(1)))
+	if ranks[1] != 2 || ranks[2] != 1 {
+		t.Errorf("ranks = %v (expected map[1:2 2:1])", ranks)
+	}
+}
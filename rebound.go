@@ -0,0 +1,95 @@
+package main
+
+// reboundRumble is the 2012 game.  Its fields are stored in TeamInfo's
+// BallCount and Bridge members.
+var reboundRumble = &Game{
+	ID:     "rebound-rumble",
+	Name:   "Rebound Rumble",
+	Year:   2012,
+	Phases: []string{"Autonomous", "Teleoperated", "Bridges"},
+	Fields: []GameField{
+		countField("Autonomous.High", "Autonomous", "High", autoHighPoints, func(info *TeamInfo) *int { return &info.Autonomous.High }),
+		countField("Autonomous.Mid", "Autonomous", "Mid", autoMidPoints, func(info *TeamInfo) *int { return &info.Autonomous.Mid }),
+		countField("Autonomous.Low", "Autonomous", "Low", autoLowPoints, func(info *TeamInfo) *int { return &info.Autonomous.Low }),
+		countField("Autonomous.Missed", "Autonomous", "Missed", 0, func(info *TeamInfo) *int { return &info.Autonomous.Missed }),
+		countField("Teleoperated.High", "Teleoperated", "High", teleopHighPoints, func(info *TeamInfo) *int { return &info.Teleoperated.High }),
+		countField("Teleoperated.Mid", "Teleoperated", "Mid", teleopMidPoints, func(info *TeamInfo) *int { return &info.Teleoperated.Mid }),
+		countField("Teleoperated.Low", "Teleoperated", "Low", teleopLowPoints, func(info *TeamInfo) *int { return &info.Teleoperated.Low }),
+		countField("Teleoperated.Missed", "Teleoperated", "Missed", 0, func(info *TeamInfo) *int { return &info.Teleoperated.Missed }),
+
+		// Only the first team bridge earns points for the team; the coop
+		// bridge and a second balance are shared with other robots.
+		bridgeField("CoopBridge", "Coop Bridge", "Coop", 0, func(info *TeamInfo) *Bridge { return &info.CoopBridge }),
+		bridgeField("TeamBridge1", "Bridge 1", "Bridge 1", bridgePoints, func(info *TeamInfo) *Bridge { return &info.TeamBridge1 }),
+		bridgeField("TeamBridge2", "Bridge 2", "Bridge 2", 0, func(info *TeamInfo) *Bridge { return &info.TeamBridge2 }),
+	},
+	Aggregates: []GameAggregate{
+		{"TeleoperatedScored", "Teleop Scored", []string{"Teleoperated.High", "Teleoperated.Mid", "Teleoperated.Low"}},
+		{"TeleoperatedShot", "Teleop Shot", []string{"Teleoperated.High", "Teleoperated.Mid", "Teleoperated.Low", "Teleoperated.Missed"}},
+		{"AutonomousScored", "Auto Scored", []string{"Autonomous.High", "Autonomous.Mid", "Autonomous.Low"}},
+		{"AutonomousShot", "Auto Shot", []string{"Autonomous.High", "Autonomous.Mid", "Autonomous.Low", "Autonomous.Missed"}},
+	},
+	Components: []GameAggregate{
+		{"AutonomousHigh", "Auto High", []string{"Autonomous.High"}},
+		{"AutonomousMid", "Auto Mid", []string{"Autonomous.Mid"}},
+		{"AutonomousLow", "Auto Low", []string{"Autonomous.Low"}},
+		{"TeleoperatedHigh", "Teleop High", []string{"Teleoperated.High"}},
+		{"TeleoperatedMid", "Teleop Mid", []string{"Teleoperated.Mid"}},
+		{"TeleoperatedLow", "Teleop Low", []string{"Teleoperated.Low"}},
+		{"CoopBridge", "Coop Bridge", []string{"CoopBridge"}},
+		{"TeamBridge", "Team Bridge", []string{"TeamBridge1", "TeamBridge2"}},
+	},
+
+	// Balancing the coopertition bridge earns both alliances ranking
+	// points.  Ties are broken by hybrid (autonomous), bridge and then
+	// teleoperated points.
+	Ranking: RankingRules{
+		WinPoints: 2,
+		TiePoints: 1,
+		Bonuses: []RankingBonus{
+			{"Coopertition", "CoopBridge", 2},
+		},
+		TiebreakerPhases: []string{"Autonomous", "Bridges", "Teleoperated"},
+	},
+}
+
+// Rebound Rumble point values
+const (
+	teleopHighPoints = 3
+	teleopMidPoints  = 2
+	teleopLowPoints  = 1
+
+	autoHighPoints = teleopHighPoints + 3
+	autoMidPoints  = teleopMidPoints + 3
+	autoLowPoints  = teleopLowPoints + 3
+
+	bridgePoints = 10
+)
+
+// countField returns a count field stored in a TeamInfo member.
+func countField(name, phase, short string, points int, member func(*TeamInfo) *int) GameField {
+	return GameField{
+		Name:   name,
+		Phase:  phase,
+		Label:  phase + " " + short,
+		Short:  short,
+		Kind:   CountField,
+		Points: points,
+		get:    func(info *TeamInfo) int { return *member(info) },
+		set:    func(info *TeamInfo, v int) { *member(info) = v },
+	}
+}
+
+// bridgeField returns an attempt field stored in a TeamInfo Bridge member.
+func bridgeField(name, label, short string, points int, member func(*TeamInfo) *Bridge) GameField {
+	return GameField{
+		Name:   name,
+		Phase:  "Bridges",
+		Label:  label,
+		Short:  short,
+		Kind:   AttemptField,
+		Points: points,
+		get:    func(info *TeamInfo) int { return member(info).value() },
+		set:    func(info *TeamInfo, v int) { *member(info) = bridgeValue(v) },
+	}
+}
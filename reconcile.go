@@ -0,0 +1,255 @@
+package main
+
+import (
+	"code.google.com/p/gorilla/mux"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// otherScoutReports reports whether a team in match has reports from scouts
+// other than scoutName.
+func otherScoutReports(match *Match, teamNumber int, scoutName string) bool {
+	for _, r := range match.ScoutReports(teamNumber) {
+		if r.ScoutName != scoutName {
+			return true
+		}
+	}
+	return false
+}
+
+// scoutNames returns the names of the scouts that wrote reports, separated by commas.
+func scoutNames(reports []TeamInfo) string {
+	names := make([]string, len(reports))
+	for i := range reports {
+		names[i] = reports[i].ScoutName
+	}
+	return strings.Join(names, ", ")
+}
+
+// settleScoutReports updates the canonical info for a team from its scouting
+// reports if they all agree.  It reports whether the reports still need to be
+// reconciled by hand.  A team without any reports is left alone.
+func settleScoutReports(store Datastore, mtag MatchTag, teamNumber int, editor Editor) (bool, error) {
+	match, err := store.FetchMatch(mtag)
+	if err != nil {
+		return false, err
+	}
+	current := match.TeamInfo(teamNumber)
+	if current == nil {
+		return false, StoreNotFound
+	}
+	if match.NeedsReconcile(teamNumber) {
+		return true, nil
+	}
+
+	reports := match.ScoutReports(teamNumber)
+	if len(reports) == 0 {
+		return false, nil
+	}
+	info := reports[0]
+	info.Team, info.Alliance = current.Team, current.Alliance
+	info.ScoutName = scoutNames(reports)
+	info.Revision = current.Revision
+	err = store.UpdateMatchTeam(mtag, teamNumber, info, editor)
+	if err == StoreConflict {
+		return true, nil
+	}
+	return false, err
+}
+
+// reconcileScoutReports updates the canonical info for a team from its
+// scouting reports if they all agree, then redirects to the match page.  If
+// the reports disagree, then it redirects to the reconciliation page instead.
+func reconcileScoutReports(server *Server, w http.ResponseWriter, req *http.Request, event *Event, mtag MatchTag, teamNumber int, editor Editor) error {
+	needsReconcile, err := settleScoutReports(server.Store(), mtag, teamNumber, editor)
+	if err != nil {
+		return err
+	}
+
+	// Redirect
+	pairs := []string{
+		"year", strconv.Itoa(event.Date.Year),
+		"location", event.Location.Code,
+		"matchType", string(mtag.MatchType),
+		"matchNumber", strconv.FormatUint(uint64(mtag.MatchNumber), 10),
+	}
+	route := "match.view"
+	if needsReconcile {
+		route = "match.reconcile"
+		pairs = append(pairs, "teamNumber", strconv.Itoa(teamNumber))
+	}
+	u, err := server.GetRoute(route).URL(pairs...)
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, req, u.String(), http.StatusFound)
+	return nil
+}
+
+// A reconcileField is a row on the reconciliation page.
+type reconcileField struct {
+	Name     string
+	Label    string
+	Values   []string // display value from each report
+	Choices  []reconcileChoice
+	Disagree bool
+}
+
+// A reconcileChoice is a candidate canonical value for a field.
+type reconcileChoice struct {
+	Label    string
+	Value    string
+	Selected bool
+}
+
+// reconcileFields compares a team's scouting reports field by field.  The
+// choices for each field are the values that scouts reported and, for
+// counts that scouts disagree on, their average.  The canonical value is
+// selected if it is one of the choices.
+func reconcileFields(game *Game, reports []TeamInfo, canonical *TeamInfo) []reconcileField {
+	scoutedFields := game.ScoutedFields()
+	fields := make([]reconcileField, len(scoutedFields))
+	for i := range scoutedFields {
+		sf := &scoutedFields[i]
+		f := &fields[i]
+		f.Name, f.Label = sf.Name, sf.Label
+		f.Values = make([]string, len(reports))
+
+		var scouts [][]string
+		sum := 0
+		for j := range reports {
+			v := sf.Value(&reports[j])
+			f.Values[j] = sf.DisplayValue(v)
+			sum += v
+
+			fv := sf.FormValue(v)
+			k := 0
+			for k < len(f.Choices) && f.Choices[k].Value != fv {
+				k++
+			}
+			if k == len(f.Choices) {
+				f.Choices = append(f.Choices, reconcileChoice{Label: f.Values[j], Value: fv})
+				scouts = append(scouts, nil)
+			}
+			scouts[k] = append(scouts[k], reports[j].ScoutName)
+		}
+		for k := range f.Choices {
+			f.Choices[k].Label += " (" + strings.Join(scouts[k], ", ") + ")"
+		}
+		f.Disagree = len(f.Choices) > 1
+
+		if sf.Kind == CountField && f.Disagree {
+			mean := float64(sum) / float64(len(reports))
+			f.Choices = append(f.Choices, reconcileChoice{
+				Label: "Average (" + strconv.FormatFloat(mean, 'f', -1, 64) + ")",
+				Value: strconv.Itoa(int(math.Floor(mean + 0.5))),
+			})
+		}
+
+		cv := sf.FormValue(sf.Value(canonical))
+		selected := false
+		for k := range f.Choices {
+			if f.Choices[k].Value == cv {
+				f.Choices[k].Selected = true
+				selected = true
+				break
+			}
+		}
+		if !selected && len(f.Choices) > 0 {
+			f.Choices[0].Selected = true
+		}
+	}
+	return fields
+}
+
+// reconcileMatchTeam lets a lead scout pick the canonical info for a team
+// that was scouted more than once.
+func reconcileMatchTeam(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+	var changed bool
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Fetch match
+	match, err := server.Store().FetchMatch(routeMatchTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	mtag := MatchTag{event.Tag(), match.Type, uint(match.Number)}
+	game := eventGame(event)
+
+	// Get team info
+	teamNumber, _ := strconv.Atoi(vars["teamNumber"])
+	teamInfo := match.TeamInfo(teamNumber)
+	if teamInfo == nil {
+		http.NotFound(w, req)
+		return nil
+	}
+
+	if req.Method == "POST" {
+		form, err := decodeTeamInfoForm(req, game)
+		if err != nil {
+			// TODO: Bad request status code
+			return err
+		}
+
+		// Save
+		info := *teamInfo
+		form.apply(game, &info)
+		err = server.Store().UpdateMatchTeam(mtag, teamNumber, info, requestEditor(req, ""))
+		if err == nil {
+			// Redirect
+			u, err := server.GetRoute("match.view").URL("year", strconv.Itoa(event.Date.Year), "location", event.Location.Code, "matchType", string(match.Type), "matchNumber", strconv.Itoa(match.Number))
+			if err != nil {
+				return err
+			}
+			http.Redirect(w, req, u.String(), http.StatusFound)
+			return nil
+		} else if err != StoreConflict {
+			return err
+		}
+
+		// Someone changed the info or added a report.  Show the latest.
+		changed = true
+		match, err = server.Store().FetchMatch(mtag)
+		if err != nil {
+			return err
+		}
+		teamInfo = match.TeamInfo(teamNumber)
+		if teamInfo == nil {
+			http.NotFound(w, req)
+			return nil
+		}
+	}
+
+	reports := match.ScoutReports(teamNumber)
+	scoutName := teamInfo.ScoutName
+	if len(reports) > 0 {
+		scoutName = scoutNames(reports)
+	} else {
+		reports = []TeamInfo{*teamInfo}
+	}
+	return server.Templates().ExecuteTemplate(w, "match-reconcile.html", map[string]interface{}{
+		"Server":    server,
+		"Request":   req,
+		"Event":     event,
+		"Match":     match,
+		"TeamInfo":  teamInfo,
+		"Reports":   reports,
+		"Fields":    reconcileFields(game, reports, teamInfo),
+		"ScoutName": scoutName,
+		"Changed":   changed,
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReconcileFields(t *testing.T) {
+	reports := []TeamInfo{
+		{ScoutName: "Alice", Teleoperated: BallCount{High: 2}, TeamBridge1: Bridge{true, true}},
+		{ScoutName: "Bob", Teleoperated: BallCount{High: 3}, TeamBridge1: Bridge{true, false}},
+		{ScoutName: "Carol", Teleoperated: BallCount{High: 2}, TeamBridge1: Bridge{true, true}},
+	}
+	canonical := &TeamInfo{Teleoperated: BallCount{High: 3}}
+	fields := reconcileFields(reboundRumble, reports, canonical)
+	if len(fields) != len(reboundRumble.ScoutedFields()) {
+		t.Fatalf("len(reconcileFields(...)) = %d (expected %d)", len(fields), len(reboundRumble.ScoutedFields()))
+	}
+	byName := make(map[string]reconcileField, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+
+	high := byName["Teleoperated.High"]
+	if !high.Disagree {
+		t.Error("Teleoperated.High does not disagree")
+	}
+	if expected := []string{"2", "3", "2"}; !reflect.DeepEqual(high.Values, expected) {
+		t.Errorf("Teleoperated.High values = %v (expected %v)", high.Values, expected)
+	}
+	expectedChoices := []reconcileChoice{
+		{Label: "2 (Alice, Carol)", Value: "2"},
+		{Label: "3 (Bob)", Value: "3", Selected: true},
+		{Label: "Average (2.3333333333333335)", Value: "2"},
+	}
+	if !reflect.DeepEqual(high.Choices, expectedChoices) {
+		t.Errorf("Teleoperated.High choices = %+v (expected %+v)", high.Choices, expectedChoices)
+	}
+
+	bridge := byName["TeamBridge1"]
+	expectedChoices = []reconcileChoice{
+		{Label: "Success (Alice, Carol)", Value: "success", Selected: true},
+		{Label: "Failed (Bob)", Value: "fail"},
+	}
+	if !bridge.Disagree || !reflect.DeepEqual(bridge.Choices, expectedChoices) {
+		t.Errorf("TeamBridge1 = %+v (expected choices %+v)", bridge, expectedChoices)
+	}
+
+	low := byName["Teleoperated.Low"]
+	expectedChoices = []reconcileChoice{{Label: "0 (Alice, Bob, Carol)", Value: "0", Selected: true}}
+	if low.Disagree || !reflect.DeepEqual(low.Choices, expectedChoices) {
+		t.Errorf("Teleoperated.Low = %+v (expected choices %+v)", low, expectedChoices)
+	}
+}
+
+func TestSettleScoutReportsWithoutReports(t *testing.T) {
+	store := newMemoryDatastore()
+	event, match := seedTestEvent(t, store)
+	mtag := MatchTag{event.Tag(), match.Type, uint(match.Number)}
+	needsReconcile, err := settleScoutReports(store, mtag, 1, testEditor)
+	if err != nil || needsReconcile {
+		t.Errorf("settleScoutReports(...) = %t, %v (expected false, <nil>)", needsReconcile, err)
+	}
+}
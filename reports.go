@@ -3,7 +3,9 @@ package main
 import (
 	"bitbucket.org/zombiezen/gopdf/pdf"
 	"bitbucket.org/zombiezen/greyhound-scouting/barcode"
+	"bitbucket.org/zombiezen/greyhound-scouting/chart"
 	"fmt"
+	"image"
 )
 
 const reportMargin = 0.5 * pdf.Inch
@@ -19,11 +21,15 @@ const (
 	barcodeFontSize = 12
 )
 
-const scoutFormsPerPage = 3
+const scoutFormsPerPage = 2
 
-func renderMultipleScoutForms(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *Event, matches []*Match) {
+// renderMultipleScoutForms renders a scout form for every team in every match,
+// grouped by team.  If editURL is not nil, each form has a QR code linking to
+// the URL that it returns for the form's tag, unless it returns "".
+func renderMultipleScoutForms(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *Event, game *Game, matches []*Match, editURL func(MatchTeamTag) string) {
 	n := 0
 	sizeX, sizeY := pageWidth-reportMargin*2, (pageHeight-reportMargin*2)/scoutFormsPerPage
+	layout := newScoutFormLayout(game, float64(sizeX))
 
 	// Get map of teams to matches
 	teamMatches := make(map[int][]*Match, len(event.Teams))
@@ -41,7 +47,7 @@ func renderMultipleScoutForms(doc *pdf.Document, pageWidth, pageHeight pdf.Unit,
 				canvas = doc.NewPage(pageWidth, pageHeight)
 				canvas.Translate(reportMargin, pageHeight-sizeY-reportMargin)
 			}
-			renderScoutForm(canvas, sizeX, sizeY, event, match, info.Team)
+			renderScoutForm(canvas, sizeX, sizeY, event, layout, match, info.Team, editURL)
 			if n == scoutFormsPerPage-1 {
 				canvas.Close()
 				canvas = nil
@@ -49,7 +55,7 @@ func renderMultipleScoutForms(doc *pdf.Document, pageWidth, pageHeight pdf.Unit,
 				// Page divider
 				// TODO: set dash
 				canvas.DrawLine(pdf.Point{0, 0}, pdf.Point{sizeX, 0})
-				canvas.Translate(0, -pageHeight/scoutFormsPerPage)
+				canvas.Translate(0, -sizeY)
 			}
 			n = (n + 1) % scoutFormsPerPage
 		}
@@ -68,12 +74,10 @@ func renderMultipleScoutForms(doc *pdf.Document, pageWidth, pageHeight pdf.Unit,
 	}
 }
 
-const (
-	scoutFormAllianceLine = 1.0 * pdf.Inch
-)
-
-// this will assume that both position and margins have already been transformed for.
-func renderScoutForm(canvas *pdf.Canvas, w, h pdf.Unit, event *Event, match *Match, teamNum int) {
+// renderScoutForm draws a scout form with the boxes placed by layout, so that
+// scans of it can be read by readScoutForm.  Like renderPitForm, it assumes
+// that the position and margins have already been transformed for.
+func renderScoutForm(canvas *pdf.Canvas, w, h pdf.Unit, event *Event, layout *scoutFormLayout, match *Match, teamNum int, editURL func(MatchTeamTag) string) {
 	// Determine alliance
 	var alliance Alliance
 	for _, teamInfo := range match.Teams {
@@ -87,72 +91,157 @@ func renderScoutForm(canvas *pdf.Canvas, w, h pdf.Unit, event *Event, match *Mat
 		return
 	}
 
-	// Match number
+	// The layout's y increases downward from the top of the form.
+	pt := func(x, y float64) pdf.Point {
+		return pdf.Point{pdf.Unit(x), h - pdf.Unit(y)}
+	}
+
+	// Title
+	titleStyle := textStyle{matchNumberFontName, matchNumberFontSize, 0, 0, 0}
+	titleStyle.Drawf(canvas, pt(0, matchNumberFontSize), "%s #%d", match.Type.DisplayName(), match.Number)
+	titleStyle.Drawf(canvas, pt(0, matchNumberFontSize*2+2), "Team %d", teamNum)
+	textStyle{scoreFontName, 12, 0, 0, 0}.Draw(canvas, pt(0, matchNumberFontSize*2+18), event.Location.Name)
+	textStyle{pdf.Helvetica, 9, 0, 0, 0}.Draw(canvas, pt(0, scoutFormHeaderHeight-8),
+		"Fill in one box each time the robot scores or misses.  Fill boxes completely; checked boxes may be misread.")
+
+	// Barcode
+	tag := MatchTeamTag{MatchTag{event.Tag(), match.Type, uint(match.Number)}, uint(teamNum)}
+	code, bcX, bcY := scoutFormBarcode(tag, layout.Width)
+	barcodeStyle{scoutFormModule, scoutFormBarcodeHeight}.Draw(canvas, pt(bcX, bcY), code, tag.String())
+
+	// Rows of boxes
+	headingStyle := textStyle{pdf.HelveticaBold, 12, 0, 0, 0}
+	labelStyle := textStyle{pdf.Helvetica, 11, 0, 0, 0}
+	choiceStyle := textStyle{pdf.Helvetica, 9, 0, 0, 0}
+	boxStyle := strokeStyle{0.75, 0, 0, 0}
+	for _, row := range layout.Rows {
+		baseline := row.Y + scoutFormRowHeight - 4
+		if row.Heading {
+			headingStyle.Draw(canvas, pt(0, baseline), row.Label)
+			continue
+		}
+		labelStyle.Draw(canvas, pt(8, baseline), row.Label)
+		for _, box := range row.Boxes {
+			boxStyle.Rect(canvas, pdf.Rectangle{pt(box.X, box.Y+scoutFormBoxSize), pt(box.X+scoutFormBoxSize, box.Y)})
+			if box.Label != "" {
+				choiceStyle.Draw(canvas, pt(box.X+scoutFormBoxSize+4, box.Y+scoutFormBoxSize-1), box.Label)
+			}
+		}
+	}
+
+	// Scout name and comments, beside the boxes
+	notesY := float64(scoutFormHeaderHeight + scoutFormRowHeight - 4)
+	renderFields(canvas, pt(scoutFormNotesX, notesY), pdf.Helvetica, 11, w-scoutFormNotesX-80, "Scout Name:")
+	labelStyle.Draw(canvas, pt(scoutFormNotesX, notesY+2*scoutFormRowHeight), "Comments:")
+
+	// QR code to the edit page, in the bottom-right corner of the comments
+	if editURL == nil {
+		return
+	}
+	u := editURL(tag)
+	if u == "" {
+		return
+	}
+	qr, err := barcode.EncodeQR(u, barcode.QRLevelM)
+	if err != nil {
+		// TODO: log error?
+		return
+	}
+	qrX, qrY := scoutFormQR(qr, layout.Width, float64(h))
+	canvas.DrawImage(&barcode.QRImage{QRCode: qr, Scale: 1}, pdf.Rectangle{
+		pt(qrX, qrY+scoutFormQRSize),
+		pt(qrX+scoutFormQRSize, qrY),
+	})
+	quiet := 4 * scoutFormQRSize / float64(qr.Size)
+	choiceStyle.Draw(canvas, pt(qrX, qrY-quiet-12), "Scan to enter")
+	choiceStyle.Draw(canvas, pt(qrX, qrY-quiet-2), "on a phone:")
+}
+
+const pitFormsPerPage = 2
+
+// renderPitForms renders a pit scouting form for every team at an event.
+func renderPitForms(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *Event) {
+	sizeX, sizeY := pageWidth-reportMargin*2, (pageHeight-reportMargin*2)/pitFormsPerPage
+
+	var canvas *pdf.Canvas
+	for i, team := range event.Teams {
+		if i%pitFormsPerPage == 0 {
+			if canvas != nil {
+				canvas.Close()
+			}
+			canvas = doc.NewPage(pageWidth, pageHeight)
+			canvas.Translate(reportMargin, pageHeight-sizeY-reportMargin)
+		} else {
+			canvas.DrawLine(pdf.Point{0, sizeY}, pdf.Point{sizeX, sizeY})
+		}
+		renderPitForm(canvas, sizeX, sizeY, event, team)
+		canvas.Translate(0, -sizeY)
+	}
+	if canvas != nil {
+		canvas.Close()
+	}
+}
+
+// renderPitForm renders a pit scouting form.  It assumes that the position
+// and margins have already been transformed for.
+func renderPitForm(canvas *pdf.Canvas, w, h pdf.Unit, event *Event, teamNum int) {
+	const (
+		boxSize    = 10
+		boxPadding = 4
+		choiceGap  = 0.2 * pdf.Inch
+		labelWidth = 1.6 * pdf.Inch
+	)
+
+	// Title
 	baseline := h - matchNumberFontSize
 	canvas.Push()
 	canvas.Translate(0, baseline)
 	text := new(pdf.Text)
-	text.SetFont(pdf.HelveticaBold, matchNumberFontSize)
-	// TODO: Em dash
-	text.Text(fmt.Sprintf("%s #%d - %s", match.Type.DisplayName(), match.Number, event.Location.Name))
+	text.SetFont(matchNumberFontName, matchNumberFontSize)
+	text.Text(fmt.Sprintf("Pit Scouting - %s %d", event.Location.Name, event.Date.Year))
 	text.NextLine()
 	text.Text(fmt.Sprintf("Team %d", teamNum))
 	canvas.DrawText(text)
 	canvas.Pop()
 
-	// Barcode
-	bc := &barcode.Image{
-		Barcode: barcode.Encode(MatchTeamTag{MatchTag{event.Tag(), match.Type, uint(match.Number)}, uint(teamNum)}.String()),
-		Scale:   1,
-		Height:  24,
+	// Barcode, in the top-right corner below the first line of the title so
+	// that a long event name can't run into its quiet zone
+	ptag := PitTag{event.Tag(), uint(teamNum)}.String()
+	code := barcode.Encode(ptag)
+	bcStyle := barcodeStyle{1, 24}
+	bcStyle.Draw(canvas, pdf.Point{w - bcStyle.QuietWidth() - bcStyle.Width(code), h - matchNumberFontSize - 6}, code, ptag)
+
+	// Robot name
+	baseline += text.Y() - 0.3*pdf.Inch - scoreFontSize
+	renderFields(canvas, pdf.Point{0, baseline}, pdf.Helvetica, scoreFontSize, 3.0*pdf.Inch, "Robot Name:")
+
+	// Choices, each followed by a row of boxes
+	labelStyle := textStyle{pdf.Helvetica, scoreFontSize, 0, 0, 0}
+	choiceStyle := textStyle{pdf.Helvetica, 11, 0, 0, 0}
+	boxStyle := strokeStyle{1, 0, 0, 0}
+	for _, c := range pitChoices {
+		baseline -= scoreFontSize + 0.15*pdf.Inch
+		labelStyle.Draw(canvas, pdf.Point{0, baseline}, c.Label+":")
+		x := labelWidth
+		for _, choice := range c.Choices {
+			boxStyle.Rect(canvas, pdf.Rectangle{pdf.Point{x, baseline}, pdf.Point{x + boxSize, baseline + boxSize}})
+			x += boxSize + boxPadding
+			var t pdf.Text
+			t.SetFont(choiceStyle.FontName, choiceStyle.FontSize)
+			t.Text(choice)
+			choiceStyle.Draw(canvas, pdf.Point{x, baseline}, choice)
+			x += t.X() + choiceGap
+		}
 	}
-	var bcRect pdf.Rectangle
-	bcRect.Min.X = w - pdf.Unit(bc.Bounds().Dx())
-	bcRect.Min.Y = h - pdf.Unit(bc.Bounds().Dy())
-	bcRect.Max.X = bcRect.Min.X + pdf.Unit(bc.Bounds().Dx())
-	bcRect.Max.Y = bcRect.Min.Y + pdf.Unit(bc.Bounds().Dy())
-	canvas.DrawImage(bc, bcRect)
-	// TODO: Text
-
-	// Scores
-	headingBaseline := baseline + text.Y() - 0.25*pdf.Inch - scoreFontSize
-	baseline = headingBaseline - 0.1*pdf.Inch - scoreFontSize
-	formPt1 := renderFields(canvas, pdf.Point{0, baseline}, pdf.Helvetica, scoreFontSize, 0.5*pdf.Inch, "High:", "Mid:", "Low:")
-	formPt2 := renderFields(canvas, pdf.Point{formPt1.X + 0.25*pdf.Inch, baseline}, pdf.Helvetica, scoreFontSize, 0.5*pdf.Inch, "High", "Mid:", "Low:")
-	formPt3 := renderFields(canvas, pdf.Point{formPt2.X + 0.5*pdf.Inch, baseline}, pdf.Helvetica, scoreFontSize, 0.5*pdf.Inch, "Coop Attempt:", "Bridge 1 Attempt:", "Bridge 2 Attempt:")
-	formPt4 := renderFields(canvas, pdf.Point{formPt3.X + 0.25*pdf.Inch, baseline}, pdf.Helvetica, scoreFontSize, 0.5*pdf.Inch, "Success:", "Success:", "Success:")
-	_ = formPt4
-
-	canvas.Push()
-	canvas.Translate(0, headingBaseline)
-	text = new(pdf.Text)
-	text.SetFont(pdf.HelveticaBold, scoreFontSize)
-	text.Text("Autonomous")
-	canvas.DrawText(text)
-	canvas.Pop()
 
-	canvas.Push()
-	canvas.Translate(formPt1.X+0.25*pdf.Inch, headingBaseline)
-	text = new(pdf.Text)
-	text.SetFont(pdf.HelveticaBold, scoreFontSize)
-	text.Text("Teleop")
-	canvas.DrawText(text)
-	canvas.Pop()
+	// Weight and scout name
+	baseline -= scoreFontSize + 0.3*pdf.Inch
+	pt := renderFields(canvas, pdf.Point{0, baseline}, pdf.Helvetica, scoreFontSize, 1.0*pdf.Inch, "Weight (lb):")
+	renderFields(canvas, pdf.Point{pt.X + 0.5*pdf.Inch, baseline}, pdf.Helvetica, scoreFontSize, 2.5*pdf.Inch, "Scout Name:")
 
-	// Scout name
-	// TODO: don't assume formPt1.Y is the lowest
-	baseline += formPt1.Y - (scoreFontSize + 0.4*pdf.Inch)
-	namePt := renderFields(canvas, pdf.Point{0, baseline}, pdf.Helvetica, scoreFontSize, 3.0*pdf.Inch, "Scout Name:")
-
-	// Comments
-	baseline += namePt.Y - (scoreFontSize + 0.05*pdf.Inch)
-	canvas.Push()
-	canvas.Translate(0, baseline)
-	text = new(pdf.Text)
-	text.SetFont(pdf.Helvetica, scoreFontSize)
-	text.Text("Comments:")
-	canvas.DrawText(text)
-	canvas.Pop()
+	// Notes
+	baseline -= scoreFontSize + 0.3*pdf.Inch
+	labelStyle.Draw(canvas, pdf.Point{0, baseline}, "Notes:")
 }
 
 const (
@@ -202,15 +291,17 @@ type teamEventStatser interface {
 }
 
 // renderMatchSheet creates a PDF document for a single match sheet.
-func renderMatchSheet(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *Event, match *Match, statser teamEventStatser, imagestore Imagestore) error {
+func renderMatchSheet(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *Event, match *Match, statser teamEventStatser, robots map[int]*Robot, imagestore Imagestore) error {
 	const numEntryColumns = 3
 	entryWidth := (pageWidth - reportMargin*2) / numEntryColumns
 
 	canvas := doc.NewPage(pageWidth, pageHeight)
 	defer canvas.Close()
 
+	game := eventGame(event)
 	red := match.AllianceInfo(Red)
 	blue := match.AllianceInfo(Blue)
+	allStats := make(map[int]TeamStats, len(match.Teams))
 
 	// Teams
 	for i, teamInfo := range red.Teams {
@@ -221,14 +312,17 @@ func renderMatchSheet(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *
 		if err != nil {
 			return err
 		}
+		allStats[teamInfo.Team] = stats
 		renderMatchSheetTeam(
 			canvas,
 			pdf.Rectangle{
 				pdf.Point{reportMargin + pdf.Unit(i)*entryWidth, pageHeight / 2},
 				pdf.Point{reportMargin + pdf.Unit(i+1)*entryWidth, pageHeight - reportMargin},
 			},
+			game,
 			teamInfo,
 			stats,
+			robots[teamInfo.Team],
 			imagestore,
 		)
 	}
@@ -240,14 +334,17 @@ func renderMatchSheet(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *
 		if err != nil {
 			return err
 		}
+		allStats[teamInfo.Team] = stats
 		renderMatchSheetTeam(
 			canvas,
 			pdf.Rectangle{
 				pdf.Point{reportMargin + pdf.Unit(i)*entryWidth, reportMargin},
 				pdf.Point{reportMargin + pdf.Unit(i+1)*entryWidth, pageHeight / 2},
 			},
+			game,
 			teamInfo,
 			stats,
+			robots[teamInfo.Team],
 			imagestore,
 		)
 	}
@@ -264,15 +361,33 @@ func renderMatchSheet(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *
 	canvas.DrawText(&textObj)
 	canvas.Pop()
 
+	// Prediction
+	prediction := predictMatch(match, allStats)
+	predictionStyle := textStyle{pdf.Helvetica, 10, 0, 0, 0}
+	var predictionText pdf.Text
+	predictionText.SetFont(predictionStyle.FontName, predictionStyle.FontSize)
+	predictionText.Text(fmt.Sprintf("Predicted: Red %.0f (%.0f%%), Blue %.0f (%.0f%%)",
+		prediction.Red.Score, prediction.Red.WinProbability*100,
+		prediction.Blue.Score, prediction.Blue.WinProbability*100))
+
+	canvas.SetColor(predictionStyle.R, predictionStyle.G, predictionStyle.B)
+	canvas.Push()
+	canvas.Translate(pageWidth-reportMargin-predictionText.X(), reportMargin+matchStyle.FontSize)
+	canvas.DrawText(&predictionText)
+	canvas.Pop()
+
 	return nil
 }
 
-// renderMatchSheetTeam renders a single team onto a match sheet.
-func renderMatchSheetTeam(canvas *pdf.Canvas, rect pdf.Rectangle, info TeamInfo, stats TeamStats, imagestore Imagestore) {
+// renderMatchSheetTeam renders a single team onto a match sheet.  The robot's
+// first pit scouting photo is shown if it has one, otherwise the team image.
+// The team's statistics are the game's aggregates and attempt fields.
+func renderMatchSheetTeam(canvas *pdf.Canvas, rect pdf.Rectangle, game *Game, info TeamInfo, stats TeamStats, robot *Robot, imagestore Imagestore) {
 	const (
-		padding     = 0.0625 * pdf.Inch
-		statPadding = 0.0625 * pdf.Inch
-		imageHeight = 2.5 * pdf.Inch
+		padding        = 0.0625 * pdf.Inch
+		statPadding    = 0.0625 * pdf.Inch
+		imageHeight    = 2.5 * pdf.Inch
+		timelineHeight = 0.875 * pdf.Inch
 	)
 
 	rect.Min.X += padding
@@ -296,7 +411,14 @@ func renderMatchSheetTeam(canvas *pdf.Canvas, rect pdf.Rectangle, info TeamInfo,
 
 	// Image
 	if imagestore != nil {
-		if img, err := ReadTeamImage(imagestore, info.Team); err == nil {
+		var img image.Image
+		var err error
+		if robot != nil && len(robot.Photos) > 0 {
+			img, err = ReadRobotPhoto(imagestore, robot.Photos[0])
+		} else {
+			img, err = ReadTeamImage(imagestore, info.Team)
+		}
+		if err == nil {
 			var ir pdf.Rectangle
 			placeAspect := float32(imageBorderRect.Dx()) / float32(imageBorderRect.Dy())
 			imageAspect := float32(img.Bounds().Dx()) / float32(img.Bounds().Dy())
@@ -328,13 +450,21 @@ func renderMatchSheetTeam(canvas *pdf.Canvas, rect pdf.Rectangle, info TeamInfo,
 	textObj.Text(fmt.Sprintf("Matches Played: %d", stats.MatchCount))
 	textObj.NextLine()
 	if stats.MatchCount != 0 {
-		textObj.Text(fmt.Sprintf("Avg Teleop Balls: %.1f / %.1f", stats.AverageTeleoperatedScored(), stats.AverageTeleoperatedShot()))
-		textObj.NextLine()
-		textObj.Text(fmt.Sprintf("Avg Auto Balls: %.1f / %.1f", stats.AverageAutonomousScored(), stats.AverageAutonomousShot()))
-		textObj.NextLine()
-		textObj.Text(fmt.Sprintf("Max Teleop Balls: %d / %d", stats.MaxTeleoperatedScored, stats.MaxTeleoperatedShot))
-		textObj.NextLine()
-		textObj.Text(fmt.Sprintf("Bridge: %d / %d", stats.CoopBridge.SuccessCount+stats.TeamBridge1.SuccessCount, stats.CoopBridge.AttemptCount+stats.TeamBridge1.SuccessCount))
+		for _, agg := range game.Aggregates {
+			fs := stats.Field(agg.Name)
+			textObj.Text(fmt.Sprintf("Avg %s: %.1f (max %d)", agg.Label, fs.Average(stats.MatchCount), fs.Max))
+			textObj.NextLine()
+		}
+		for _, f := range game.Fields {
+			if f.Kind == AttemptField {
+				fs := stats.Field(f.Name)
+				textObj.Text(fmt.Sprintf("%s: %d / %d", f.Label, fs.Total, fs.AttemptCount))
+				textObj.NextLine()
+			}
+		}
+	}
+	for _, line := range robotSummary(robot) {
+		textObj.Text(line)
 		textObj.NextLine()
 	}
 
@@ -343,6 +473,79 @@ func renderMatchSheetTeam(canvas *pdf.Canvas, rect pdf.Rectangle, info TeamInfo,
 	canvas.Translate(rect.Min.X, imageBorderRect.Min.Y-(statStyle.FontSize+statPadding))
 	canvas.DrawText(&textObj)
 	canvas.Pop()
+
+	// Score timeline
+	if len(stats.Scores) > 1 {
+		drawChart(canvas, pdf.Rectangle{rect.Min, pdf.Point{rect.Max.X, rect.Min.Y + timelineHeight}}, scoreTimeline(stats))
+	}
+}
+
+// renderPickList creates a PDF document for an event's pick list.  Teams
+// that have been picked are struck through.
+func renderPickList(doc *pdf.Document, pageWidth, pageHeight pdf.Unit, event *Event, list *PickList, stats map[int]TeamStats) {
+	const (
+		lineHeight  = 16
+		tierPadding = 0.2 * pdf.Inch
+		teamColumn  = 0.4 * pdf.Inch
+		oprColumn   = 1.1 * pdf.Inch
+	)
+	titleStyle := textStyle{pdf.HelveticaBold, matchNumberFontSize, 0, 0, 0}
+	tierStyle := textStyle{pdf.HelveticaBold, scoreFontSize, 0, 0, 0}
+	entryStyle := textStyle{pdf.Helvetica, 11, 0, 0, 0}
+	takenStyle := textStyle{pdf.Helvetica, 11, 0.5, 0.5, 0.5}
+	strikeStyle := strokeStyle{1, 0.5, 0.5, 0.5}
+
+	members := allianceMembers(event)
+	var canvas *pdf.Canvas
+	var y pdf.Unit
+	newPage := func() {
+		if canvas != nil {
+			canvas.Close()
+		}
+		canvas = doc.NewPage(pageWidth, pageHeight)
+		y = pageHeight - reportMargin - titleStyle.FontSize
+		titleStyle.Drawf(canvas, pdf.Point{reportMargin, y}, "%s Pick List", event.Location.Name)
+		y -= titleStyle.FontSize
+	}
+	newPage()
+
+	rank := 0
+	for i, e := range list.Entries {
+		if i == 0 || list.Entries[i-1].Tier != e.Tier {
+			if y-tierPadding-lineHeight*2 < reportMargin {
+				newPage()
+			}
+			y -= tierPadding + tierStyle.FontSize
+			tierStyle.Draw(canvas, pdf.Point{reportMargin, y}, e.Tier.DisplayName())
+			rank = 0
+		}
+		rank++
+		if y-lineHeight < reportMargin {
+			newPage()
+		}
+		y -= lineHeight
+
+		style := entryStyle
+		taken := e.Picked || members[e.Team]
+		if taken {
+			style = takenStyle
+		}
+		x := reportMargin
+		style.Drawf(canvas, pdf.Point{x, y}, "%d.", rank)
+		x += teamColumn
+		style.Drawf(canvas, pdf.Point{x, y}, "%d", e.Team)
+		x += teamColumn * 2
+		style.Drawf(canvas, pdf.Point{x, y}, "OPR %.1f", stats[e.Team].OPR)
+		if taken {
+			strikeY := y + style.FontSize/3
+			strikeStyle.Line(canvas, pdf.Point{reportMargin, strikeY}, pdf.Point{x + oprColumn - teamColumn, strikeY})
+		}
+		x += oprColumn
+		if e.Notes != "" {
+			style.Draw(canvas, pdf.Point{x, y}, e.Notes)
+		}
+	}
+	canvas.Close()
 }
 
 type textStyle struct {
@@ -406,3 +609,118 @@ func (style fillStyle) Rect(canvas *pdf.Canvas, rect pdf.Rectangle) {
 	canvas.SetColor(style.R, style.G, style.B)
 	canvas.Fill(&path)
 }
+
+// barcodeStyle draws barcodes as filled bars, which stay sharp at any module
+// width and on any printer.
+type barcodeStyle struct {
+	Module pdf.Unit // width of the narrowest bar
+	Height pdf.Unit
+}
+
+// Width returns the width of a barcode's bars, without its quiet zones.
+func (style barcodeStyle) Width(code barcode.Barcode) pdf.Unit {
+	return pdf.Unit(len(code)) * style.Module
+}
+
+// QuietWidth returns the width of the blank space that a barcode needs on
+// either side of its bars.
+func (style barcodeStyle) QuietWidth() pdf.Unit {
+	return barcode.QuietZone * style.Module
+}
+
+// Draw renders a barcode with the top-left corner of its bars at pt and text
+// centered beneath it in barcodeFontName.  The caller's layout must leave
+// QuietWidth blank on either side of the bars.
+func (style barcodeStyle) Draw(canvas *pdf.Canvas, pt pdf.Point, code barcode.Barcode, text string) {
+	var path pdf.Path
+	x := pt.X
+	for i, n := range code.Widths() {
+		w := pdf.Unit(n) * style.Module
+		if i%2 == 0 && n > 0 {
+			path.Rectangle(pdf.Rectangle{pdf.Point{x, pt.Y - style.Height}, pdf.Point{x + w, pt.Y}})
+		}
+		x += w
+	}
+	canvas.SetColor(0, 0, 0)
+	canvas.Fill(&path)
+
+	if text == "" {
+		return
+	}
+	var t pdf.Text
+	t.SetFont(barcodeFontName, barcodeFontSize)
+	t.Text(text)
+	canvas.Push()
+	canvas.Translate(pt.X+(style.Width(code)-t.X())/2, pt.Y-style.Height-barcodeFontSize)
+	canvas.DrawText(&t)
+	canvas.Pop()
+}
+
+// drawChart draws a chart to fill rect.
+func drawChart(canvas *pdf.Canvas, rect pdf.Rectangle, c chart.Chart) {
+	d := c.Draw(float64(rect.Dx()), float64(rect.Dy()))
+
+	// Charts are laid out with y increasing downward, so flip them.
+	pt := func(p chart.Point) pdf.Point {
+		return pdf.Point{rect.Min.X + pdf.Unit(p.X), rect.Max.Y - pdf.Unit(p.Y)}
+	}
+	for _, shape := range d.Shapes {
+		switch s := shape.(type) {
+		case chart.Line:
+			strokeStyle{pdf.Unit(s.Width), pdfColor(s.Color.R), pdfColor(s.Color.G), pdfColor(s.Color.B)}.Line(canvas, pt(s.Start), pt(s.End))
+		case chart.Rect:
+			r := pdf.Rectangle{pt(chart.Point{s.Min.X, s.Max.Y}), pt(chart.Point{s.Max.X, s.Min.Y})}
+			fillStyle{pdfColor(s.Fill.R), pdfColor(s.Fill.G), pdfColor(s.Fill.B)}.Rect(canvas, r)
+		case chart.Polyline:
+			var path pdf.Path
+			for i, p := range s.Points {
+				if i == 0 {
+					path.Move(pt(p))
+				} else {
+					path.Line(pt(p))
+				}
+			}
+			canvas.SetLineWidth(pdf.Unit(s.Width))
+			canvas.SetStrokeColor(pdfColor(s.Color.R), pdfColor(s.Color.G), pdfColor(s.Color.B))
+			canvas.Stroke(&path)
+		case chart.Circle:
+			canvas.SetColor(pdfColor(s.Fill.R), pdfColor(s.Fill.G), pdfColor(s.Fill.B))
+			canvas.Fill(circlePath(pt(s.Center), pdf.Unit(s.Radius)))
+		case chart.Text:
+			var text pdf.Text
+			text.SetFont(pdf.Helvetica, pdf.Unit(s.Size))
+			text.Text(s.Text)
+			p := pt(s.Pos)
+			switch s.Anchor {
+			case chart.Middle:
+				p.X -= text.X() / 2
+			case chart.End:
+				p.X -= text.X()
+			}
+			canvas.SetColor(pdfColor(s.Color.R), pdfColor(s.Color.G), pdfColor(s.Color.B))
+			canvas.Push()
+			canvas.Translate(p.X, p.Y)
+			canvas.DrawText(&text)
+			canvas.Pop()
+		}
+	}
+}
+
+// pdfColor converts an 8-bit color component to the PDF range of 0 to 1.
+func pdfColor(c uint8) float32 {
+	return float32(c) / 0xff
+}
+
+// circlePath approximates a circle with four Bézier curves.
+func circlePath(center pdf.Point, r pdf.Unit) *pdf.Path {
+	const k = 0.5523 // control point distance for a quarter circle
+	kr := k * r
+	path := new(pdf.Path)
+	path.Move(pdf.Point{center.X + r, center.Y})
+	path.Curve(pdf.Point{center.X + r, center.Y + kr}, pdf.Point{center.X + kr, center.Y + r}, pdf.Point{center.X, center.Y + r})
+	path.Curve(pdf.Point{center.X - kr, center.Y + r}, pdf.Point{center.X - r, center.Y + kr}, pdf.Point{center.X - r, center.Y})
+	path.Curve(pdf.Point{center.X - r, center.Y - kr}, pdf.Point{center.X - kr, center.Y - r}, pdf.Point{center.X, center.Y - r})
+	path.Curve(pdf.Point{center.X + kr, center.Y - r}, pdf.Point{center.X + r, center.Y - kr}, pdf.Point{center.X + r, center.Y})
+	path.Close()
+	return path
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"code.google.com/p/gorilla/mux"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ScoringRules changes how a game is scored at an event.  The rules of a
+// game change during the season through team updates, so each event keeps
+// its own copy.
+type ScoringRules struct {
+	// Points overrides the game's point values for some fields.  A field
+	// that is worth no points doesn't count toward a team's score.
+	Points []FieldPoints `bson:",omitempty"`
+
+	// Bonuses replaces the game's bonuses.
+	Bonuses []ScoringBonus `bson:",omitempty"`
+}
+
+// FieldPoints sets the point value of a game field.
+type FieldPoints struct {
+	Field  string
+	Points int
+}
+
+// A ScoringBonus awards points to a team in a match when every one of the
+// bonus's fields has a nonzero count, is set, or succeeded.
+type ScoringBonus struct {
+	Label  string
+	Fields []string
+	Points int
+}
+
+// earned reports whether a team earned the bonus in a match.
+func (b ScoringBonus) earned(g *Game, info *TeamInfo) bool {
+	if len(b.Fields) == 0 {
+		return false
+	}
+	for _, name := range b.Fields {
+		f := g.Field(name)
+		if f == nil || f.count(f.Value(info)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FieldPoints returns the point value of a field under the rules, or the
+// field's own value if the rules don't change it.
+func (rules *ScoringRules) FieldPoints(f *GameField) int {
+	if rules != nil {
+		for _, fp := range rules.Points {
+			if fp.Field == f.Name {
+				return fp.Points
+			}
+		}
+	}
+	return f.Points
+}
+
+// WithRules returns a copy of the game that is scored with rules.
+func (g *Game) WithRules(rules *ScoringRules) *Game {
+	gg := new(Game)
+	*gg = *g
+	gg.Fields = make([]GameField, len(g.Fields))
+	for i := range g.Fields {
+		gg.Fields[i] = g.Fields[i]
+		gg.Fields[i].Points = rules.FieldPoints(&g.Fields[i])
+	}
+	gg.Bonuses = rules.Bonuses
+	return gg
+}
+
+// validate checks that the rules only refer to fields in a game.
+func (rules *ScoringRules) validate(g *Game) error {
+	for _, fp := range rules.Points {
+		if g.Field(fp.Field) == nil {
+			return errors.New("Unknown field " + strconv.Quote(fp.Field))
+		}
+	}
+	for _, b := range rules.Bonuses {
+		if b.Label == "" {
+			return errors.New("Bonus must have a name")
+		}
+		if len(b.Fields) == 0 {
+			return errors.New("Bonus " + strconv.Quote(b.Label) + " must have at least one field")
+		}
+		for _, name := range b.Fields {
+			if g.Field(name) == nil {
+				return errors.New("Bonus " + strconv.Quote(b.Label) + " has unknown field " + strconv.Quote(name))
+			}
+		}
+	}
+	return nil
+}
+
+// parseRulesForm parses the scoring rules form for a game.  Point values are
+// named "Points." followed by the field name.  Bonuses are numbered from zero
+// and bonuses with an empty label are dropped.
+func parseRulesForm(req *http.Request, g *Game) (*ScoringRules, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	rules := new(ScoringRules)
+	for _, f := range g.Fields {
+		s := strings.TrimSpace(req.Form.Get("Points." + f.Name))
+		if s == "" {
+			continue
+		}
+		points, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New(f.Label + " points: " + err.Error())
+		}
+		rules.Points = append(rules.Points, FieldPoints{f.Name, points})
+	}
+	for i := 0; ; i++ {
+		prefix := "Bonus." + strconv.Itoa(i) + "."
+		if _, ok := req.Form[prefix+"Label"]; !ok {
+			break
+		}
+		b := ScoringBonus{
+			Label:  strings.TrimSpace(req.Form.Get(prefix + "Label")),
+			Fields: req.Form[prefix+"Fields"],
+		}
+		if b.Label == "" {
+			continue
+		}
+		points, err := strconv.Atoi(strings.TrimSpace(req.Form.Get(prefix + "Points")))
+		if err != nil {
+			return nil, errors.New(b.Label + " points: " + err.Error())
+		}
+		b.Points = points
+		rules.Bonuses = append(rules.Bonuses, b)
+	}
+	if err := rules.validate(g); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// rescoreAttempts is the number of times rescoreEvent tries to save a team
+// that someone else keeps changing.
+const rescoreAttempts = 3
+
+// A rescoreConflictError lists the teams that rescoreEvent couldn't save
+// because they were changed by someone else while the event was rescored.
+type rescoreConflictError struct {
+	Skipped []MatchTeamTag
+}
+
+func (e *rescoreConflictError) Error() string {
+	tags := make([]string, len(e.Skipped))
+	for i := range e.Skipped {
+		tags[i] = e.Skipped[i].String()
+	}
+	return "These teams were changed while rescoring and kept their old scores: " + strings.Join(tags, ", ") + ".  Rescore again."
+}
+
+// rescoreEvent recalculates every stored team score at an event with the
+// event's current rules.  Changed canonical infos are saved through
+// UpdateMatchTeam, so the changes appear in the match history.  A team that
+// someone else changes while rescoring is fetched again and rescored; if it
+// still can't be saved, the rest of the event is rescored and a
+// *rescoreConflictError is returned.  It returns the number of canonical
+// infos that changed.
+func rescoreEvent(store Datastore, event *Event, editor Editor) (int, error) {
+	game := eventGame(event)
+	matches, err := store.FetchMatches(event.Tag())
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	var skipped []MatchTeamTag
+	for _, m := range matches {
+		mtag := MatchTag{event.Tag(), m.Type, uint(m.Number)}
+		for _, info := range m.Teams {
+			changed, err := rescoreMatchTeam(store, game, mtag, info, editor)
+			if err == StoreConflict {
+				skipped = append(skipped, MatchTeamTag{mtag, uint(info.Team)})
+				continue
+			} else if err != nil {
+				return n, err
+			}
+			if changed {
+				n++
+			}
+		}
+		for _, report := range m.Reports {
+			score := game.Score(&report)
+			if score == report.Score {
+				continue
+			}
+			report.Score = score
+			if err := store.UpdateScoutReport(mtag, report); err != nil {
+				return n, err
+			}
+		}
+	}
+	if len(skipped) > 0 {
+		return n, &rescoreConflictError{skipped}
+	}
+	return n, nil
+}
+
+// rescoreMatchTeam saves a team's info with its score under game, if the
+// score changed.  If someone else changed the info first, the info is
+// fetched again and rescored, up to rescoreAttempts times.
+func rescoreMatchTeam(store Datastore, game *Game, mtag MatchTag, info TeamInfo, editor Editor) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		score := game.Score(&info)
+		if score == info.Score {
+			return false, nil
+		}
+		info.Score = score
+		err := store.UpdateMatchTeam(mtag, info.Team, info, editor)
+		if err != StoreConflict || attempt >= rescoreAttempts {
+			return err == nil, err
+		}
+
+		m, err := store.FetchMatch(mtag)
+		if err != nil {
+			return false, err
+		}
+		current := m.TeamInfo(info.Team)
+		if current == nil {
+			return false, StoreNotFound
+		}
+		info = *current
+	}
+}
+
+// A rulesField is a row of the scoring rules form.
+type rulesField struct {
+	GameField
+	Default int // the game's point value
+}
+
+// A rulesBonus is a bonus on the scoring rules form.
+type rulesBonus struct {
+	Index  int
+	Label  string
+	Points int
+	Fields map[string]bool
+}
+
+// eventRules lets an admin change the point values and bonuses used to score
+// an event.
+func eventRules(server *Server, w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	// Fetch event
+	event, err := server.Store().FetchEvent(routeEventTag(vars))
+	if err == StoreNotFound {
+		http.NotFound(w, req)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	base := baseEventGame(event)
+
+	var formError error
+	if req.Method == "POST" {
+		var rules *ScoringRules
+		if req.FormValue("Reset") == "" {
+			rules, formError = parseRulesForm(req, base)
+		}
+		if formError == nil {
+			// Save
+			event.Rules = rules
+			if err := server.Store().UpsertEvent(event); err != nil {
+				return err
+			}
+			if req.FormValue("Rescore") != "" {
+				_, err := rescoreEvent(server.Store(), event, requestEditor(req, ""))
+				if _, ok := err.(*rescoreConflictError); ok {
+					formError = err
+				} else if err != nil {
+					return err
+				}
+			}
+		}
+		if formError == nil {
+			// Redirect
+			u, err := server.GetRoute("event.view").URL("year", strconv.Itoa(event.Date.Year), "location", event.Location.Code)
+			if err != nil {
+				return err
+			}
+			http.Redirect(w, req, u.String(), http.StatusFound)
+			return nil
+		}
+	}
+
+	game := eventGame(event)
+	fields := make([]rulesField, len(base.Fields))
+	for i := range base.Fields {
+		fields[i] = rulesField{game.Fields[i], base.Fields[i].Points}
+	}
+	bonuses := make([]rulesBonus, len(game.Bonuses)+1)
+	for i := range bonuses {
+		bonuses[i] = rulesBonus{Index: i, Fields: make(map[string]bool)}
+		if i < len(game.Bonuses) {
+			b := game.Bonuses[i]
+			bonuses[i].Label, bonuses[i].Points = b.Label, b.Points
+			for _, name := range b.Fields {
+				bonuses[i].Fields[name] = true
+			}
+		}
+	}
+	return server.Templates().ExecuteTemplate(w, "event-rules.html", map[string]interface{}{
+		"Server":  server,
+		"Request": req,
+		"Event":   event,
+		"Game":    base,
+		"Fields":  fields,
+		"Bonuses": bonuses,
+		"Error":   formError,
+	})
+}
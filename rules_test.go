@@ -0,0 +1,227 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGameWithRules(t *testing.T) {
+	rules := &ScoringRules{
+		Points: []FieldPoints{
+			{"TeamBridge1", 20},
+			{"TeamBridge2", 10},
+		},
+		Bonuses: []ScoringBonus{
+			{"Double Balance", []string{"TeamBridge1", "TeamBridge2"}, 5},
+		},
+	}
+	game := reboundRumble.WithRules(rules)
+	if game.Field("TeamBridge1").Points != 20 || game.Field("Autonomous.High").Points != autoHighPoints {
+		t.Errorf("WithRules points = %d, %d", game.Field("TeamBridge1").Points, game.Field("Autonomous.High").Points)
+	}
+	if reboundRumble.Field("TeamBridge1").Points != bridgePoints {
+		t.Error("WithRules changed the built-in game")
+	}
+
+	tests := []struct {
+		Info     TeamInfo
+		Expected int
+	}{
+		{TeamInfo{}, 0},
+		{TeamInfo{Teleoperated: BallCount{High: 1}, TeamBridge1: Bridge{true, true}}, 23},
+		{TeamInfo{TeamBridge1: Bridge{true, true}, TeamBridge2: Bridge{true, false}}, 20},
+		{TeamInfo{TeamBridge1: Bridge{true, true}, TeamBridge2: Bridge{true, true}}, 35},
+	}
+	for _, tt := range tests {
+		if score := game.Score(&tt.Info); score != tt.Expected {
+			t.Errorf("Score(%+v) = %d (expected %d)", tt.Info, score, tt.Expected)
+		}
+	}
+}
+
+func TestParseRulesForm(t *testing.T) {
+	form := url.Values{
+		"Points.Teleoperated.High": {"4"},
+		"Points.CoopBridge":        {" 10 "},
+		"Bonus.0.Label":            {"Double Balance"},
+		"Bonus.0.Fields":           {"TeamBridge1", "TeamBridge2"},
+		"Bonus.0.Points":           {"5"},
+		"Bonus.1.Label":            {""},
+		"Bonus.1.Points":           {""},
+	}
+	rules, err := parseRulesForm(newFormRequest(t, form), reboundRumble)
+	if err != nil {
+		t.Fatalf("parseRulesForm error: %v", err)
+	}
+	if len(rules.Points) != 2 || rules.FieldPoints(reboundRumble.Field("CoopBridge")) != 10 || rules.FieldPoints(reboundRumble.Field("Teleoperated.High")) != 4 {
+		t.Errorf("rules.Points = %+v", rules.Points)
+	}
+	if len(rules.Bonuses) != 1 || rules.Bonuses[0].Points != 5 || len(rules.Bonuses[0].Fields) != 2 {
+		t.Errorf("rules.Bonuses = %+v", rules.Bonuses)
+	}
+
+	form.Set("Bonus.0.Fields", "Bogus")
+	if _, err := parseRulesForm(newFormRequest(t, form), reboundRumble); err == nil {
+		t.Error("parseRulesForm accepted a bonus with an unknown field")
+	}
+}
+
+// newFormRequest returns a POST request with form as its body.
+func newFormRequest(t *testing.T, form url.Values) *http.Request {
+	req, err := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("NewRequest error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestRescoreEvent(t *testing.T) {
+	store := newMemoryDatastore()
+	event, match := seedTestEvent(t, store)
+	mtag := MatchTag{event.Tag(), match.Type, uint(match.Number)}
+	info := *match.TeamInfo(4)
+	info.TeamBridge1 = Bridge{true, true}
+	info.Score = reboundRumble.Score(&info)
+	if err := store.UpdateMatchTeam(mtag, 4, info, testEditor); err != nil {
+		t.Fatalf("UpdateMatchTeam error: %v", err)
+	}
+	report := info
+	report.ScoutName = "Alice"
+	if err := store.UpdateScoutReport(mtag, report); err != nil {
+		t.Fatalf("UpdateScoutReport error: %v", err)
+	}
+
+	event.Rules = &ScoringRules{Points: []FieldPoints{{"TeamBridge1", 15}}}
+	mustUpsertEvent(t, store, event)
+	n, err := rescoreEvent(store, event, testEditor)
+	if err != nil {
+		t.Fatalf("rescoreEvent error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("rescoreEvent changed %d teams (expected 1)", n)
+	}
+	m, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if score := m.TeamInfo(4).Score; score != 15 {
+		t.Errorf("After rescore, score = %d (expected 15)", score)
+	}
+	if reports := m.ScoutReports(4); len(reports) != 1 || reports[0].Score != 15 {
+		t.Errorf("After rescore, reports = %+v", reports)
+	}
+	if history, _ := store.MatchHistory(mtag); len(history) != 2 {
+		t.Errorf("len(MatchHistory) = %d (expected 2)", len(history))
+	}
+
+	// Rescoring again changes nothing.
+	if n, err := rescoreEvent(store, event, testEditor); n != 0 || err != nil {
+		t.Errorf("Second rescoreEvent = %d, %v (expected 0, nil)", n, err)
+	}
+}
+
+func TestRescoreEventConflict(t *testing.T) {
+	store := newMemoryDatastore()
+	event, match := seedTestEvent(t, store)
+	mtag := MatchTag{event.Tag(), match.Type, uint(match.Number)}
+	for _, team := range []int{3, 4} {
+		info := *match.TeamInfo(team)
+		info.TeamBridge1 = Bridge{true, true}
+		info.Score = reboundRumble.Score(&info)
+		if err := store.UpdateMatchTeam(mtag, team, info, testEditor); err != nil {
+			t.Fatalf("UpdateMatchTeam(%d) error: %v", team, err)
+		}
+	}
+	event.Rules = &ScoringRules{Points: []FieldPoints{{"TeamBridge1", 15}}}
+	mustUpsertEvent(t, store, event)
+
+	// A team changed once while rescoring is fetched again and saved.
+	n, err := rescoreEvent(&conflictDatastore{Datastore: store, Conflicts: 1}, event, testEditor)
+	if n != 2 || err != nil {
+		t.Errorf("rescoreEvent with one conflict = %d, %v (expected 2, <nil>)", n, err)
+	}
+
+	// A team that keeps changing is skipped, but the rest are rescored.
+	event.Rules = &ScoringRules{Points: []FieldPoints{{"TeamBridge1", 20}}}
+	mustUpsertEvent(t, store, event)
+	n, err = rescoreEvent(&conflictDatastore{Datastore: store, Conflicts: rescoreAttempts}, event, testEditor)
+	if n != 1 {
+		t.Errorf("rescoreEvent with a skipped team changed %d teams (expected 1)", n)
+	}
+	if e, ok := err.(*rescoreConflictError); !ok || len(e.Skipped) != 1 || e.Skipped[0] != (MatchTeamTag{mtag, 3}) {
+		t.Errorf("rescoreEvent with a skipped team error = %v (expected team 3 skipped)", err)
+	}
+	m, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if s3, s4 := m.TeamInfo(3).Score, m.TeamInfo(4).Score; s3 != 15 || s4 != 20 {
+		t.Errorf("After rescore with a skipped team, scores = %d, %d (expected 15, 20)", s3, s4)
+	}
+}
+
+func TestEventRulesForm(t *testing.T) {
+	store := newTestServer(t)
+	event, match := seedTestEvent(t, store)
+	const (
+		path     = "/event/2012/sdc/+rules"
+		editPath = "/event/2012/sdc/match/qualification/1/+edit/4"
+	)
+	mtag := MatchTag{event.Tag(), match.Type, uint(match.Number)}
+	score := func() int {
+		m, err := store.FetchMatch(mtag)
+		if err != nil {
+			t.Fatalf("FetchMatch error: %v", err)
+		}
+		return m.TeamInfo(4).Score
+	}
+
+	if rec := serveTestRequest(t, editPath, url.Values{"Revision": {"0"}, "TeamBridge2": {"success"}}); rec.Code != http.StatusFound {
+		t.Fatalf("POST %s code = %d", editPath, rec.Code)
+	}
+	if s := score(); s != 0 {
+		t.Fatalf("Score with default rules = %d (expected 0)", s)
+	}
+
+	rec := serveTestRequest(t, path, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s code = %d", path, rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `name="Points.TeamBridge2" type="text" value="0"`) {
+		t.Errorf("Rules form is missing points:\n%s", body)
+	}
+
+	rec = serveTestRequest(t, path, url.Values{"Points.TeamBridge2": {"10"}, "Rescore": {"1"}})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("POST %s code = %d", path, rec.Code)
+	}
+	e, err := store.FetchEvent(event.Tag())
+	if err != nil {
+		t.Fatalf("FetchEvent error: %v", err)
+	}
+	if e.Rules == nil || e.Rules.FieldPoints(reboundRumble.Field("TeamBridge2")) != 10 {
+		t.Errorf("After POST, rules = %+v", e.Rules)
+	}
+	if s := score(); s != 10 {
+		t.Errorf("Score after changing rules = %d (expected 10)", s)
+	}
+
+	rec = serveTestRequest(t, path, url.Values{"Points.TeamBridge2": {"ten"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `class="error"`) {
+		t.Errorf("POST with bad points code = %d", rec.Code)
+	}
+
+	rec = serveTestRequest(t, path, url.Values{"Reset": {"1"}, "Rescore": {"1"}})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("POST %s reset code = %d", path, rec.Code)
+	}
+	if e, err := store.FetchEvent(event.Tag()); err != nil || e.Rules != nil {
+		t.Errorf("After reset, rules = %+v, %v", e.Rules, err)
+	}
+	if s := score(); s != 0 {
+		t.Errorf("Score after reset = %d (expected 0)", s)
+	}
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/gopdf/pdf"
+	"bitbucket.org/zombiezen/greyhound-scouting/barcode"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"math"
+	"math/cmplx"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// scoutFormWidth is the width of a printed scout form.  Forms are printed on
+// US Letter paper, and the barcode's position depends on the width.
+const scoutFormWidth = float64(pdf.USLetterWidth - reportMargin*2)
+
+// Darkness of a box's inside, from 0 for blank paper to 1 for black.  Boxes
+// between the thresholds are counted as blank but their fields are flagged
+// for review.
+const (
+	markThreshold  = 0.3
+	faintThreshold = 0.12
+)
+
+// maxScanUpload is the most memory used to hold uploaded scans while parsing
+// the scan form.  Larger uploads are kept on disk.
+const maxScanUpload = 32 << 20
+
+// A formTransform maps points on a printed form to positions in a scanned
+// image.  Scans are assumed to be undistorted, so the mapping is a rotation
+// and scale followed by a translation, which complex multiplication does.
+type formTransform struct {
+	Scale  complex128
+	Offset complex128
+}
+
+// newFormTransform returns the transform that maps the form points a and b to
+// the image positions a1 and b1.
+func newFormTransform(a, b, a1, b1 complex128) formTransform {
+	scale := (b1 - a1) / (b - a)
+	return formTransform{Scale: scale, Offset: a1 - scale*a}
+}
+
+// Map returns the image position of a form point.
+func (t formTransform) Map(x, y float64) (float64, float64) {
+	p := t.Scale*complex(x, y) + t.Offset
+	return real(p), imag(p)
+}
+
+// luminance returns the gray level of a pixel, with pixels outside the image
+// treated as white.
+func luminance(img image.Image, x, y int) float64 {
+	if !(image.Point{x, y}).In(img.Bounds()) {
+		return 0xff
+	}
+	return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+}
+
+// locateScoutForm finds where a scout form is in a scanned image from its
+// barcode.  The centers of the wide bars at either end of the barcode fix the
+// form's position, scale and rotation, even if the page is upside down.  It
+// also returns the gray level of the paper next to the barcode.
+func locateScoutForm(img image.Image, r barcode.Result, tag MatchTeamTag) (t formTransform, paper float64, err error) {
+	code, bcX, bcY := scoutFormBarcode(tag, scoutFormWidth)
+	modulePx := float64(r.Bounds.Dx()) / float64(len(code))
+	if modulePx <= 0 {
+		return formTransform{}, 0, errors.New("barcode has no width")
+	}
+	yc := (r.Bounds.Min.Y + r.Bounds.Max.Y) / 2
+
+	// Paper from the quiet zones on either side
+	sum, n := 0.0, 0
+	for y := yc - 1; y <= yc+1; y++ {
+		for i := int(2 * modulePx); i < int(8*modulePx); i++ {
+			for _, x := range []int{r.Bounds.Min.X - i, r.Bounds.Max.X + i} {
+				if (image.Point{x, y}).In(img.Bounds()) {
+					sum += luminance(img, x, y)
+					n++
+				}
+			}
+		}
+	}
+	paper = 0xff
+	if n > 0 {
+		paper = sum / float64(n)
+	}
+
+	// Centers of the end bars, which are two modules wide
+	limit := int(math.Ceil(1.5 * scoutFormBarcodeHeight / scoutFormModule * modulePx))
+	a1, ok1 := barCenter(img, float64(r.Bounds.Min.X)+modulePx, yc, paper, int(2*modulePx)+2, limit)
+	b1, ok2 := barCenter(img, float64(r.Bounds.Max.X)-modulePx, yc, paper, int(2*modulePx)+2, limit)
+	if !ok1 || !ok2 {
+		return formTransform{}, 0, errors.New("couldn't find the ends of the barcode")
+	}
+
+	w := float64(len(code)) * scoutFormModule
+	midY := bcY + scoutFormBarcodeHeight/2
+	start, stop := complex(bcX+scoutFormModule, midY), complex(bcX+w-scoutFormModule, midY)
+	if r.Reversed {
+		a1, b1 = b1, a1
+	}
+	return newFormTransform(start, stop, a1, b1), paper, nil
+}
+
+// barCenter returns the center of a bar that covers x on row yc.  The bar's
+// edges are searched for up to width pixels away horizontally and height
+// pixels away vertically.
+func barCenter(img image.Image, x float64, yc int, paper float64, width, height int) (complex128, bool) {
+	row := func(x int) float64 {
+		return (luminance(img, x, yc-1) + luminance(img, x, yc) + luminance(img, x, yc+1)) / 3
+	}
+	xi := int(math.Floor(x))
+	dark := row(xi)
+	if paper-dark < 0x20 {
+		return 0, false
+	}
+	level := (dark + paper) / 2
+	left, right, ok := darkSpan(row, xi, level, width)
+	if !ok {
+		return 0, false
+	}
+
+	xi = int(math.Floor((left + right) / 2))
+	column := func(y int) float64 {
+		return (luminance(img, xi-1, y) + luminance(img, xi, y) + luminance(img, xi+1, y)) / 3
+	}
+	top, bottom, ok := darkSpan(column, yc, level, height)
+	if !ok {
+		return 0, false
+	}
+	return complex((left+right)/2, (top+bottom)/2), true
+}
+
+// darkSpan finds the run of samples darker than level around sample i,
+// searching up to limit samples away.  Sample j covers positions j to j+1, and
+// the returned edges are placed where the level is crossed between samples.
+func darkSpan(sample func(int) float64, i int, level float64, limit int) (lo, hi float64, ok bool) {
+	if sample(i) >= level {
+		return 0, 0, false
+	}
+	start := i
+	for start > i-limit && sample(start-1) < level {
+		start--
+	}
+	end := i
+	for end < i+limit && sample(end+1) < level {
+		end++
+	}
+	if start == i-limit || end == i+limit {
+		return 0, 0, false
+	}
+
+	l0, l1 := sample(start-1), sample(start)
+	lo = float64(start) - 0.5 + (l0-level)/(l0-l1)
+	l1, l2 := sample(end), sample(end+1)
+	hi = float64(end) + 0.5 + (level-l1)/(l2-l1)
+	return lo, hi, true
+}
+
+// boxDarkness returns how much darker than the paper the inside of a box is,
+// from 0 for blank to 1 for black.  The box's outline is left out.
+func boxDarkness(img image.Image, t formTransform, paper float64, box scoutFormBox) float64 {
+	const inset = scoutFormBoxSize / 4
+	const size = scoutFormBoxSize - 2*inset
+	n := int(math.Ceil(size * cmplx.Abs(t.Scale)))
+	if n < 3 {
+		n = 3
+	}
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			x, y := t.Map(box.X+inset+size*(float64(i)+0.5)/float64(n), box.Y+inset+size*(float64(j)+0.5)/float64(n))
+			sum += luminance(img, int(math.Floor(x)), int(math.Floor(y)))
+		}
+	}
+	d := 1 - sum/float64(n*n)/paper
+	return math.Max(0, math.Min(1, d))
+}
+
+// readScoutForm reads the boxes of a scanned scout form.  It returns the
+// fields' values and the labels of fields with faint or contradictory marks,
+// which should be checked before the values are saved.
+func readScoutForm(img image.Image, t formTransform, paper float64, layout *scoutFormLayout) (map[string]int, []string) {
+	values := make(map[string]int)
+	var unclear []string
+	for _, row := range layout.Rows {
+		if row.Heading {
+			continue
+		}
+		faint, darkest, marked := false, 0.0, 0
+		for _, box := range row.Boxes {
+			d := boxDarkness(img, t, paper, box)
+			switch {
+			case d >= markThreshold:
+				marked++
+				if row.Field.Kind == CountField {
+					values[box.Field]++
+				} else if d > darkest {
+					values[box.Field] = box.Value
+					darkest = d
+				}
+			case d >= faintThreshold:
+				faint = true
+			}
+		}
+		// Only one choice of an attempt can be marked.
+		if faint || (marked > 1 && row.Field.Kind == AttemptField) {
+			unclear = append(unclear, row.Field.Label)
+		}
+	}
+	return values, unclear
+}
+
+// A scanDraft is a team's info read from a scanned scout form, waiting to be
+// reviewed before it is saved.
+type scanDraft struct {
+	Tag     MatchTeamTag
+	Source  string // where the form was scanned from
+	Event   *Event
+	Match   *Match
+	Form    teamInfoForm
+	Unclear []string // labels of fields that should be checked
+	Error   error    // why the draft couldn't be saved
+
+	// Prefix starts the names of the draft's fields on the review form.
+	Prefix string
+	Fields []formField
+}
+
+// TeamNumber returns the draft's team number.
+func (d *scanDraft) TeamNumber() int {
+	return int(d.Tag.TeamNumber)
+}
+
+// scanForms reads every scout form in a scanned image.  Forms that can't be
+// read, like ones for matches that aren't in the store, are described in the
+// returned problems rather than failing the whole scan.
+func scanForms(store Datastore, img image.Image, source string) (drafts []*scanDraft, problems []string, err error) {
+	for _, r := range barcode.FindAll(img) {
+		tag, err := ParseMatchTeamTag(r.Text)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: barcode %q is not from a scout form", source, r.Text))
+			continue
+		}
+		d, err := fetchScanDraft(store, tag, source)
+		if err == StoreNotFound {
+			problems = append(problems, fmt.Sprintf("%s: %v is not a scheduled match team", source, tag))
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		t, paper, err := locateScoutForm(img, r, tag)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v: %v", source, tag, err))
+			continue
+		}
+		game := eventGame(d.Event)
+		d.Form.Values, d.Unclear = readScoutForm(img, t, paper, newScoutFormLayout(game, scoutFormWidth))
+		d.Fields = d.Form.fields(game)
+		drafts = append(drafts, d)
+	}
+	if len(drafts) == 0 && len(problems) == 0 {
+		problems = append(problems, source+": no scout forms found")
+	}
+	return drafts, problems, nil
+}
+
+// fetchScanDraft returns an empty draft for a team in a match, based on the
+// team's current revision.  It returns StoreNotFound if the team isn't in the
+// match.
+func fetchScanDraft(store Datastore, tag MatchTeamTag, source string) (*scanDraft, error) {
+	event, err := store.FetchEvent(tag.EventTag)
+	if err != nil {
+		return nil, err
+	}
+	match, err := store.FetchMatch(tag.MatchTag)
+	if err != nil {
+		return nil, err
+	}
+	info := match.TeamInfo(int(tag.TeamNumber))
+	if info == nil {
+		return nil, StoreNotFound
+	}
+	return &scanDraft{
+		Tag:    tag,
+		Source: source,
+		Event:  event,
+		Match:  match,
+		Form:   teamInfoForm{Revision: info.Revision},
+	}, nil
+}
+
+// saveScanDraft stores a reviewed draft the same way that editMatchTeam
+// stores a scout's form.  It reports whether the team was double-scouted and
+// its reports need to be reconciled.
+func saveScanDraft(store Datastore, d *scanDraft, editor Editor) (bool, error) {
+	teamNumber := d.TeamNumber()
+	current := d.Match.TeamInfo(teamNumber)
+	if current == nil {
+		return false, StoreNotFound
+	}
+	info := *current
+	d.Form.apply(eventGame(d.Event), &info)
+
+	report := info
+	report.Revision = 0
+	if d.Form.ScoutName != "" && otherScoutReports(d.Match, teamNumber, d.Form.ScoutName) {
+		if err := store.UpdateScoutReport(d.Tag.MatchTag, report); err != nil {
+			return false, err
+		}
+		return settleScoutReports(store, d.Tag.MatchTag, teamNumber, editor)
+	}
+	if err := store.UpdateMatchTeam(d.Tag.MatchTag, teamNumber, info, editor); err != nil {
+		return false, err
+	}
+	if d.Form.ScoutName != "" {
+		if err := store.UpdateScoutReport(d.Tag.MatchTag, report); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// A scannedPage is an image from an uploaded scan.
+type scannedPage struct {
+	Source string
+	Image  image.Image
+}
+
+// decodeScan decodes the pages of a scan, which is either a PNG or JPEG
+// image or a PDF file of scanned pages.
+func decodeScan(name string, data []byte) ([]scannedPage, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.New(name + " is not a PNG, JPEG or PDF file")
+		}
+		return []scannedPage{{name, img}}, nil
+	}
+
+	jpegs := pdfJPEGs(data)
+	if len(jpegs) == 0 {
+		return nil, errors.New(name + " has no scanned pages; save the pages as PNG or JPEG images instead")
+	}
+	pages := make([]scannedPage, 0, len(jpegs))
+	for i, b := range jpegs {
+		img, _, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s page %d: %v", name, i+1, err)
+		}
+		pages = append(pages, scannedPage{fmt.Sprintf("%s page %d", name, i+1), img})
+	}
+	return pages, nil
+}
+
+// pdfJPEGs returns the JPEG images embedded in a PDF file.  Scanners store
+// each page as a single JPEG image, so the pages can be read without
+// rendering the PDF.  Images stored any other way are skipped.
+func pdfJPEGs(data []byte) [][]byte {
+	var jpegs [][]byte
+	for {
+		i := bytes.Index(data, []byte("/DCTDecode"))
+		if i == -1 {
+			break
+		}
+		data = data[i:]
+		i = bytes.Index(data, []byte("stream"))
+		if i == -1 {
+			break
+		}
+		data = data[i+len("stream"):]
+
+		// The stream data starts after the end of the line.
+		if bytes.HasPrefix(data, []byte("\r\n")) {
+			data = data[2:]
+		} else if bytes.HasPrefix(data, []byte("\n")) {
+			data = data[1:]
+		}
+		i = bytes.Index(data, []byte("endstream"))
+		if i == -1 {
+			break
+		}
+		jpegs = append(jpegs, data[:i])
+		data = data[i:]
+	}
+	return jpegs
+}
+
+// scanUploads reads the scout forms in uploaded scans.
+func scanUploads(store Datastore, files []*multipart.FileHeader) (drafts []*scanDraft, problems []string, err error) {
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		pages, err := decodeScan(fh.Filename, data)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		for _, page := range pages {
+			d, p, err := scanForms(store, page.Image, page.Source)
+			if err != nil {
+				return nil, nil, err
+			}
+			drafts = append(drafts, d...)
+			problems = append(problems, p...)
+		}
+	}
+	return drafts, problems, nil
+}
+
+// parseScanDrafts parses the drafts that were marked to be saved on the
+// review form.
+func parseScanDrafts(store Datastore, form url.Values) ([]*scanDraft, error) {
+	n, _ := strconv.Atoi(form.Get("Drafts"))
+	var drafts []*scanDraft
+	for i := 0; i < n; i++ {
+		prefix := "d" + strconv.Itoa(i) + "."
+		if form.Get(prefix+"Save") == "" {
+			continue
+		}
+		tag, err := ParseMatchTeamTag(form.Get(prefix + "Tag"))
+		if err != nil {
+			return nil, err
+		}
+		d, err := fetchScanDraft(store, tag, form.Get(prefix+"Source"))
+		if err != nil {
+			return nil, err
+		}
+		game := eventGame(d.Event)
+		d.Error = d.Form.parse(form, prefix, game)
+		d.Fields = d.Form.fields(game)
+		drafts = append(drafts, d)
+	}
+	return drafts, nil
+}
+
+// errScanConflict is shown for a draft of a team whose info was changed
+// after the form was scanned.
+var errScanConflict = errors.New("Someone else saved this team's info after the form was scanned.  Save again to replace it.")
+
+// A savedScan is a draft that was saved from the review form.
+type savedScan struct {
+	*scanDraft
+	NeedsReconcile bool
+}
+
+func scanScoutForms(server *Server, w http.ResponseWriter, req *http.Request) error {
+	var drafts []*scanDraft
+	var problems []string
+	var saved []savedScan
+	if req.Method == "POST" {
+		err := req.ParseMultipartForm(maxScanUpload)
+		switch {
+		case err == http.ErrNotMultipart:
+			// Save the reviewed drafts.  Drafts that can't be saved are
+			// shown again.
+			all, err := parseScanDrafts(server.Store(), req.Form)
+			if err == StoreNotFound {
+				http.NotFound(w, req)
+				return nil
+			} else if err != nil {
+				return err
+			}
+			for _, d := range all {
+				if d.Error != nil {
+					drafts = append(drafts, d)
+					continue
+				}
+				reconcile, err := saveScanDraft(server.Store(), d, requestEditor(req, d.Form.ScoutName))
+				if err == StoreConflict {
+					// Base a resubmission on the latest revision, which
+					// was fetched with the draft.
+					d.Form.Revision = d.Match.TeamInfo(d.TeamNumber()).Revision
+					d.Error = errScanConflict
+					drafts = append(drafts, d)
+					continue
+				} else if err != nil {
+					return err
+				}
+				saved = append(saved, savedScan{d, reconcile})
+			}
+		case err != nil:
+			return err
+		default:
+			drafts, problems, err = scanUploads(server.Store(), req.MultipartForm.File["Scan"])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, d := range drafts {
+		d.Prefix = "d" + strconv.Itoa(i) + "."
+	}
+
+	return server.Templates().ExecuteTemplate(w, "scan.html", map[string]interface{}{
+		"Server":   server,
+		"Request":  req,
+		"Drafts":   drafts,
+		"Problems": problems,
+		"Saved":    saved,
+	})
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/gopdf/pdf"
+	"bitbucket.org/zombiezen/greyhound-scouting/barcode"
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScoutFormLayout(t *testing.T) {
+	layout := newScoutFormLayout(reboundRumble, scoutFormWidth)
+	formHeight := float64(pdf.USLetterHeight-reportMargin*2) / scoutFormsPerPage
+	if layout.Height > formHeight {
+		t.Errorf("layout.Height = %v (expected at most %v)", layout.Height, formHeight)
+	}
+
+	var headings []string
+	for _, row := range layout.Rows {
+		if row.Heading {
+			headings = append(headings, row.Label)
+			continue
+		}
+		for _, box := range row.Boxes {
+			if box.X < scoutFormLabelWidth || box.X+scoutFormBoxSize > scoutFormNotesX {
+				t.Errorf("%s box at x=%v is outside the box column", row.Field.Label, box.X)
+			}
+		}
+		n := len(row.Boxes)
+		switch row.Field.Kind {
+		case CountField:
+			if n != scoutFormTallyBoxes {
+				t.Errorf("%s has %d boxes (expected %d)", row.Field.Label, n, scoutFormTallyBoxes)
+			}
+		case AttemptField:
+			if n != 2 {
+				t.Errorf("%s has %d boxes (expected 2)", row.Field.Label, n)
+			}
+		case FlagField:
+			if n != 1 {
+				t.Errorf("%s has %d boxes (expected 1)", row.Field.Label, n)
+			}
+		}
+	}
+	if expected := []string{"Autonomous", "Teleoperated", "Bridges", scoutFormCommonPhase}; !reflect.DeepEqual(headings, expected) {
+		t.Errorf("headings = %q (expected %q)", headings, expected)
+	}
+
+	// The barcode's quiet zones fit in the form, clear of the title.
+	tag := MatchTeamTag{MatchTag{EventTag{"sdc", 2012}, QuarterFinal, 120}, 9999}
+	code, bcX, _ := scoutFormBarcode(tag, scoutFormWidth)
+	quiet := barcode.QuietZone * scoutFormModule
+	if right := bcX + float64(len(code))*scoutFormModule + quiet; right > scoutFormWidth {
+		t.Errorf("barcode's right quiet zone ends at x=%v (expected at most %v)", right, scoutFormWidth)
+	}
+	if left := bcX - quiet; left < scoutFormLabelWidth*2 {
+		t.Errorf("barcode's left quiet zone starts at x=%v (expected at least %v)", left, scoutFormLabelWidth*2)
+	}
+}
+
+// testFormTransform returns the transform that scans a form at dpi, rotated
+// clockwise by angle degrees and optionally upside down, with a margin of
+// blank paper around it.  It also returns the size of the scan.
+func testFormTransform(dpi, angle float64, upsideDown bool, height float64) (formTransform, image.Point) {
+	const margin = 40
+	theta := angle * math.Pi / 180
+	if upsideDown {
+		theta += math.Pi
+	}
+	t := formTransform{Scale: cmplx.Rect(dpi/72, theta)}
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range [][2]float64{{0, 0}, {scoutFormWidth, 0}, {0, height}, {scoutFormWidth, height}} {
+		x, y := t.Map(c[0], c[1])
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	t.Offset = complex(margin-minX, margin-minY)
+	return t, image.Pt(int(maxX-minX)+margin*2, int(maxY-minY)+margin*2)
+}
+
+const testPaper = 235
+
+// newTestScan returns a blank scan.
+func newTestScan(size image.Point) *image.Gray {
+	img := image.NewGray(image.Rectangle{Max: size})
+	for i := range img.Pix {
+		img.Pix[i] = testPaper
+	}
+	return img
+}
+
+// fillTestRect fills a rectangle of a form, given by its corners, in a scan.
+func fillTestRect(img *image.Gray, t formTransform, x0, y0, x1, y1 float64, gray uint8) {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range [][2]float64{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}} {
+		x, y := t.Map(c[0], c[1])
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	inv := 1 / t.Scale
+	for y := int(minY); y <= int(maxY)+1; y++ {
+		for x := int(minX); x <= int(maxX)+1; x++ {
+			p := (complex(float64(x)+0.5, float64(y)+0.5) - t.Offset) * inv
+			if real(p) >= x0 && real(p) < x1 && imag(p) >= y0 && imag(p) < y1 {
+				if (image.Point{x, y}).In(img.Bounds()) {
+					img.SetGray(x, y, color.Gray{gray})
+				}
+			}
+		}
+	}
+}
+
+// drawTestForm draws a scout form's barcode and boxes into a scan.  The boxes
+// in a row are filled in by their indices in marks, or lightly in faint.
+func drawTestForm(img *image.Gray, t formTransform, tag MatchTeamTag, marks, faint map[string][]int) {
+	code, bcX, bcY := scoutFormBarcode(tag, scoutFormWidth)
+	for i, bar := range code {
+		if bar {
+			x := bcX + float64(i)*scoutFormModule
+			fillTestRect(img, t, x, bcY, x+scoutFormModule, bcY+scoutFormBarcodeHeight, 20)
+		}
+	}
+
+	const outline = 0.75
+	isMarked := func(indices []int, i int) bool {
+		for _, j := range indices {
+			if i == j {
+				return true
+			}
+		}
+		return false
+	}
+	for _, row := range newScoutFormLayout(reboundRumble, scoutFormWidth).Rows {
+		for i, box := range row.Boxes {
+			fillTestRect(img, t, box.X, box.Y, box.X+scoutFormBoxSize, box.Y+scoutFormBoxSize, 40)
+			var gray uint8 = testPaper
+			if isMarked(marks[box.Field], i) {
+				gray = 70
+			} else if isMarked(faint[box.Field], i) {
+				gray = 200
+			}
+			fillTestRect(img, t, box.X+outline, box.Y+outline, box.X+scoutFormBoxSize-outline, box.Y+scoutFormBoxSize-outline, gray)
+		}
+	}
+}
+
+// addTestNoise adds sensor noise to a scan.
+func addTestNoise(img *image.Gray, noise int) {
+	r := rand.New(rand.NewSource(1))
+	for i, v := range img.Pix {
+		n := int(v) + r.Intn(2*noise+1) - noise
+		if n < 0 {
+			n = 0
+		} else if n > 0xff {
+			n = 0xff
+		}
+		img.Pix[i] = uint8(n)
+	}
+}
+
+var testScanMarks = map[string][]int{
+	"Autonomous.High":     {0, 1},
+	"Teleoperated.Mid":    {0, 1, 2, 3, 4, 5, 6},
+	"Teleoperated.Missed": {3, 19},
+	"CoopBridge":          {0},
+	"TeamBridge1":         {1},
+	"Failure":             {0},
+}
+
+var testScanValues = map[string]int{
+	"Autonomous.High":     2,
+	"Teleoperated.Mid":    7,
+	"Teleoperated.Missed": 2,
+	"CoopBridge":          AttemptFailed,
+	"TeamBridge1":         AttemptSucceeded,
+	"Failure":             1,
+}
+
+func TestReadScoutForm(t *testing.T) {
+	store := newMemoryDatastore()
+	event, _ := seedTestEvent(t, store)
+	tag := MatchTeamTag{MatchTag{event.Tag(), Qualification, 1}, 3}
+	layout := newScoutFormLayout(reboundRumble, scoutFormWidth)
+	faint := map[string][]int{"Teleoperated.Low": {5}, "TeamBridge2": {0, 1}}
+
+	tests := []struct {
+		DPI        float64
+		Angle      float64
+		UpsideDown bool
+		Noise      int
+	}{
+		{150, 0, false, 0},
+		{100, 0.8, false, 10},
+		{200, -1.5, true, 10},
+		{300, 0.3, true, 20},
+	}
+	for _, test := range tests {
+		tr, size := testFormTransform(test.DPI, test.Angle, test.UpsideDown, layout.Height)
+		img := newTestScan(size)
+		drawTestForm(img, tr, tag, testScanMarks, faint)
+		addTestNoise(img, test.Noise)
+
+		drafts, problems, err := scanForms(store, img, "test.png")
+		if err != nil {
+			t.Errorf("scanForms at %+v error: %v", test, err)
+			continue
+		}
+		if len(drafts) != 1 {
+			t.Errorf("scanForms at %+v found %d forms; problems: %q", test, len(drafts), problems)
+			continue
+		}
+		d := drafts[0]
+		if d.Tag != tag {
+			t.Errorf("scanForms at %+v tag = %v (expected %v)", test, d.Tag, tag)
+		}
+		for _, f := range reboundRumble.ScoutedFields() {
+			if v := d.Form.Values[f.Name]; v != testScanValues[f.Name] {
+				t.Errorf("scanForms at %+v %s = %d (expected %d)", test, f.Label, v, testScanValues[f.Name])
+			}
+		}
+		if expected := []string{"Teleoperated Low", "Bridge 2"}; !reflect.DeepEqual(d.Unclear, expected) {
+			t.Errorf("scanForms at %+v unclear = %q (expected %q)", test, d.Unclear, expected)
+		}
+	}
+}
+
+func TestScanFormsProblems(t *testing.T) {
+	store := newMemoryDatastore()
+	event, _ := seedTestEvent(t, store)
+	tr, size := testFormTransform(150, 0, false, 100)
+	img := newTestScan(size)
+	drawTestForm(img, tr, MatchTeamTag{MatchTag{event.Tag(), Qualification, 1}, 973}, nil, nil)
+
+	drafts, problems, err := scanForms(store, img, "scan.png")
+	if err != nil {
+		t.Fatalf("scanForms error: %v", err)
+	}
+	if len(drafts) != 0 || len(problems) != 1 || !strings.Contains(problems[0], "not a scheduled match team") {
+		t.Errorf("scanForms of a team that isn't in the match = %d drafts, problems %q", len(drafts), problems)
+	}
+
+	_, problems, err = scanForms(store, newTestScan(size), "blank.png")
+	if err != nil {
+		t.Fatalf("scanForms error: %v", err)
+	}
+	if expected := []string{"blank.png: no scout forms found"}; !reflect.DeepEqual(problems, expected) {
+		t.Errorf("scanForms of a blank page problems = %q (expected %q)", problems, expected)
+	}
+}
+
+func TestDecodeScanPDF(t *testing.T) {
+	var jpegs [][]byte
+	for _, w := range []int{4, 7} {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, w, 3)), nil); err != nil {
+			t.Fatalf("jpeg.Encode error: %v", err)
+		}
+		jpegs = append(jpegs, buf.Bytes())
+	}
+	doc := new(bytes.Buffer)
+	doc.WriteString("%PDF-1.4\n")
+	doc.WriteString("1 0 obj\n<< /Length 8 /Filter /FlateDecode >>\nstream\nxxxxxxxx\nendstream\nendobj\n")
+	for i, b := range jpegs {
+		doc.WriteString("2 0 obj\n<< /Type /XObject /Subtype /Image /Filter /DCTDecode >>\nstream\r\n")
+		doc.Write(b)
+		doc.WriteString("\nendstream\nendobj\n")
+		if i == 0 {
+			doc.WriteString("3 0 obj\n<< /Type /Page >>\nendobj\n")
+		}
+	}
+	doc.WriteString("%%EOF\n")
+
+	pages, err := decodeScan("scan.pdf", doc.Bytes())
+	if err != nil {
+		t.Fatalf("decodeScan error: %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("decodeScan found %d pages (expected 2)", len(pages))
+	}
+	if pages[1].Source != "scan.pdf page 2" || pages[1].Image.Bounds().Dx() != 7 {
+		t.Errorf("Second page = %q, %v", pages[1].Source, pages[1].Image.Bounds())
+	}
+
+	if _, err := decodeScan("empty.pdf", []byte("%PDF-1.4\n%%EOF\n")); err == nil {
+		t.Error("decodeScan of a PDF without images succeeded")
+	}
+	if _, err := decodeScan("notes.txt", []byte("hello")); err == nil {
+		t.Error("decodeScan of a text file succeeded")
+	}
+}
+
+func TestScanScoutForms(t *testing.T) {
+	store := newTestServer(t)
+	event, _ := seedTestEvent(t, store)
+	mtag := MatchTag{event.Tag(), Qualification, 1}
+	tag1, tag2 := MatchTeamTag{mtag, 1}.String(), MatchTeamTag{mtag, 2}.String()
+	layout := newScoutFormLayout(reboundRumble, scoutFormWidth)
+
+	// Two forms on one page
+	tr, size := testFormTransform(150, 0, false, layout.Height)
+	formHeight := size.Y
+	size.Y *= 2
+	img := newTestScan(size)
+	drawTestForm(img, tr, MatchTeamTag{mtag, 1}, testScanMarks, nil)
+	tr.Offset += complex(0, float64(formHeight))
+	drawTestForm(img, tr, MatchTeamTag{mtag, 2}, nil, nil)
+	scan := new(bytes.Buffer)
+	if err := png.Encode(scan, img); err != nil {
+		t.Fatalf("png.Encode error: %v", err)
+	}
+
+	if rec := serveTestRequest(t, "/scan", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /scan code = %d", rec.Code)
+	}
+
+	req := newUploadRequest(t, "/scan", "Scan", nil, map[string][]byte{"scan.png": scan.Bytes(), "notes.txt": []byte("hello")})
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /scan code = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, s := range []string{
+		`name="d0.Autonomous.High" type="text" value="2"`,
+		`name="d0.Tag" type="hidden" value="` + tag1 + `"`,
+		`name="d1.Tag" type="hidden" value="` + tag2 + `"`,
+		`notes.txt is not a PNG, JPEG or PDF file`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Review page is missing %q", s)
+		}
+	}
+
+	// Save the first draft only
+	form := url.Values{
+		"Drafts":              {"2"},
+		"d0.Save":             {"1"},
+		"d0.Tag":              {tag1},
+		"d0.Revision":         {"0"},
+		"d0.ScoutName":        {"Alice"},
+		"d0.Autonomous.High":  {"2"},
+		"d0.Teleoperated.Mid": {"7"},
+		"d1.Tag":              {tag2},
+		"d1.Revision":         {"0"},
+		"d1.Autonomous.High":  {"5"},
+	}
+	rec = serveTestRequest(t, "/scan", form)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Saving drafts code = %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `<a href="/event/2012/sdc/match/qualification/1/">Qualification Match 1</a>, Team 1`) {
+		t.Errorf("Saved page doesn't link to the match:\n%s", rec.Body.String())
+	}
+	match, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if info := match.TeamInfo(1); info.Autonomous.High != 2 || info.Teleoperated.Mid != 7 || info.ScoutName != "Alice" {
+		t.Errorf("Team 1 info after saving = %+v", info)
+	}
+	if info := match.TeamInfo(2); info.Autonomous.High != 0 {
+		t.Errorf("Unchecked draft was saved: %+v", info)
+	}
+
+	// The same draft again is out of date.
+	rec = serveTestRequest(t, "/scan", form)
+	body = rec.Body.String()
+	if !strings.Contains(body, "after the form was scanned") {
+		t.Errorf("Saving a stale draft didn't report a conflict")
+	}
+	if !strings.Contains(body, `name="d0.Revision" type="hidden" value="1"`) {
+		t.Errorf("Stale draft wasn't based on the latest revision")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bitbucket.org/zombiezen/greyhound-scouting/barcode"
+)
+
+// Printed scout form dimensions, in points.  The form is laid out from its
+// top-left corner with y increasing downward, the same way that a scan is
+// read, so that the renderer and the reader share one layout.
+const (
+	scoutFormModule        = 1.5 // width of a barcode module
+	scoutFormBarcodeHeight = 36
+	scoutFormHeaderHeight  = 80 // title, barcode and instructions
+	scoutFormRowHeight     = 16
+	scoutFormLabelWidth    = 96
+	scoutFormBoxSize       = 10
+	scoutFormBoxPitch      = 13
+	scoutFormGroupGap      = 5 // extra space after every scoutFormGroupSize tally boxes
+	scoutFormGroupSize     = 5
+	scoutFormTallyBoxes    = 20
+	scoutFormChoiceWidth   = 72 // an attempt choice's box and label
+	scoutFormNotesX        = 390
+	scoutFormQRSize        = 72
+)
+
+// scoutFormCommonPhase is the heading of the fields that every game records.
+const scoutFormCommonPhase = "Robot"
+
+// A scoutFormLayout places the boxes of a printed scout form.
+type scoutFormLayout struct {
+	Width  float64
+	Height float64 // bottom of the last row
+	Rows   []scoutFormRow
+}
+
+// A scoutFormRow is a phase heading or a field's row of boxes.
+type scoutFormRow struct {
+	Label   string
+	Heading bool
+	Y       float64 // top of the row
+	Field   GameField
+	Boxes   []scoutFormBox
+}
+
+// A scoutFormBox is a box that scouts fill in.  A count field's value is the
+// number of its boxes that are filled; a filled flag or attempt box sets the
+// field to the box's value.
+type scoutFormBox struct {
+	Field string
+	Value int
+	Label string  // printed after the box
+	X, Y  float64 // top-left corner
+}
+
+// newScoutFormLayout lays out a scout form for a game.  Fields are grouped
+// under their phases, followed by the fields common to every game.
+func newScoutFormLayout(game *Game, width float64) *scoutFormLayout {
+	layout := &scoutFormLayout{Width: width}
+	y := float64(scoutFormHeaderHeight)
+	addPhase := func(heading string, fields []GameField) {
+		if len(fields) == 0 {
+			return
+		}
+		layout.Rows = append(layout.Rows, scoutFormRow{Label: heading, Heading: true, Y: y})
+		y += scoutFormRowHeight
+		for _, f := range fields {
+			layout.Rows = append(layout.Rows, scoutFormRow{Label: f.ShortLabel(), Y: y, Field: f, Boxes: scoutFormBoxes(f, y)})
+			y += scoutFormRowHeight
+		}
+	}
+	for _, phase := range game.Phases {
+		addPhase(phase, game.PhaseFields(phase))
+	}
+	addPhase(scoutFormCommonPhase, commonFields)
+	layout.Height = y
+	return layout
+}
+
+// scoutFormBoxes returns the boxes for a field in a row starting at y.
+func scoutFormBoxes(f GameField, y float64) []scoutFormBox {
+	top := y + (scoutFormRowHeight-scoutFormBoxSize)/2
+	switch f.Kind {
+	case FlagField:
+		return []scoutFormBox{{Field: f.Name, Value: 1, X: scoutFormLabelWidth, Y: top}}
+	case AttemptField:
+		return []scoutFormBox{
+			{Field: f.Name, Value: AttemptFailed, Label: "Failed", X: scoutFormLabelWidth, Y: top},
+			{Field: f.Name, Value: AttemptSucceeded, Label: "Succeeded", X: scoutFormLabelWidth + scoutFormChoiceWidth, Y: top},
+		}
+	}
+	boxes := make([]scoutFormBox, scoutFormTallyBoxes)
+	for i := range boxes {
+		x := scoutFormLabelWidth + float64(i*scoutFormBoxPitch+i/scoutFormGroupSize*scoutFormGroupGap)
+		boxes[i] = scoutFormBox{Field: f.Name, Value: 1, X: x, Y: top}
+	}
+	return boxes
+}
+
+// scoutFormBarcode returns the barcode printed on a form and the position of
+// the top-left corner of its bars.  The barcode is in the form's top-right
+// corner with its right quiet zone inside the form, so its position depends
+// on the tag's length.  The tag is printed beneath it.
+func scoutFormBarcode(tag MatchTeamTag, width float64) (code barcode.Barcode, x, y float64) {
+	code = barcode.Encode(tag.String())
+	return code, width - float64(len(code)+barcode.QuietZone)*scoutFormModule, 0
+}
+
+// scoutFormQR returns the position of the top-left corner of a form's QR code.
+// The QR code is in the bottom-right corner of a form of the given height,
+// above a quiet zone of four modules.
+func scoutFormQR(qr *barcode.QRCode, width, height float64) (x, y float64) {
+	module := float64(scoutFormQRSize) / float64(qr.Size)
+	return width - scoutFormQRSize, height - scoutFormQRSize - 4*module
+}
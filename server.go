@@ -7,6 +7,7 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -60,6 +61,26 @@ func NewServer(datastore Datastore) *Server {
 			}
 			return u, err
 		},
+		"robotphoto": func(name string) (*url.URL, error) {
+			return server.imagestore.RobotPhotoURL(name)
+		},
+		"scorechart": func(stats TeamStats) template.HTML {
+			return svgChart(scoreTimeline(stats), chartWidth, chartHeight)
+		},
+		"countchart": func(game *Game, stats TeamStats) template.HTML {
+			c := countChart(game, stats)
+			if len(c.Categories) == 0 {
+				return ""
+			}
+			return svgChart(c, chartWidth, chartHeight)
+		},
+		"attemptchart": func(game *Game, stats TeamStats) template.HTML {
+			c := attemptChart(game, stats)
+			if len(c.Bars) == 0 {
+				return ""
+			}
+			return svgChart(c, chartWidth, float64(len(c.Bars)*rateBarHeight))
+		},
 		"intsum": func(xs ...int) (sum int) {
 			for _, x := range xs {
 				sum += x
@@ -134,6 +155,16 @@ func (server *Server) routeFunc() func(string, ...interface{}) (template.URL, er
 	}
 }
 
+// requestEditor returns the editor responsible for a request.  name is the
+// name that the editor gave, if any.
+func requestEditor(req *http.Request, name string) Editor {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return Editor{Name: name, Address: host}
+}
+
 // A serverHandler wraps a ServerHandlerFunc to implement the http.Handler interface.
 type serverHandler struct {
 	server *Server
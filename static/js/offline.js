@@ -0,0 +1,98 @@
+/*
+ *  offline.js
+ *
+ *  Shows a team info form as a QR code when the tablet can't reach the
+ *  server, so that the head scout can scan it in.  The payload is described
+ *  in offline.go.  The offline event form is kept by the tablet's
+ *  application cache and fills in the match team tag and revision itself.
+ */
+
+var OfflineForm = (function() {
+    var attemptValues = {na: 0, fail: 1, success: 2};
+
+    // payload returns the offline payload of a team info form, or null if a
+    // field is filled in wrong.
+    function payload(form) {
+        if (!form.attr('data-tag')) {
+            alert('Pick a match and team.');
+            return null;
+        }
+        var values = [];
+        var ok = true;
+        form.find('table.formtable').find('input[type=checkbox], input[type=text], select').each(function() {
+            var field = $(this);
+            if (!ok || field.attr('name') == 'ScoutName') {
+                return;
+            }
+            if (field.is('select')) {
+                values.push(attemptValues[field.val()] || 0);
+            } else if (field.attr('type') == 'checkbox') {
+                values.push(this.checked ? 1 : 0);
+            } else {
+                var n = $.trim(field.val());
+                if (!/^[0-9]+$/.test(n)) {
+                    alert(field.closest('tr').find('th').text() + ' must be a number.');
+                    field.focus();
+                    ok = false;
+                    return;
+                }
+                values.push(parseInt(n, 10));
+            }
+        });
+        if (!ok) {
+            return null;
+        }
+        var scoutName = $.trim(form.find('input[name=ScoutName]').val()).replace(/\s+/g, ' ');
+        return $.trim(['GS1', form.attr('data-tag'), form.find('input[name=Revision]').val(), values.join('.'), scoutName].join(' '));
+    }
+
+    // show draws a form's QR code in panel.
+    function show(form, panel) {
+        var text = payload(form);
+        if (text == null) {
+            return;
+        }
+        QRCode.draw(QRCode.encode(text), panel.find('canvas').get(0));
+        panel.find('.offline_payload').text(text);
+        panel.show();
+        panel.get(0).scrollIntoView();
+    }
+
+    // attach shows the QR code instead of submitting the form when the tablet
+    // is offline, or whenever button is clicked.
+    function attach(form, button, panel) {
+        form.submit(function(e) {
+            if (navigator.onLine === false) {
+                e.preventDefault();
+                show(form, panel);
+            }
+        });
+        button.click(function(e) {
+            e.preventDefault();
+            show(form, panel);
+        });
+    }
+
+    // attachEvent fills in form for the match team chosen with picker and
+    // shows the QR code whenever the form is submitted.  Picking another
+    // team clears the form, except for the scout's name.
+    function attachEvent(form, picker, panel) {
+        function pick() {
+            var option = picker.find('option:selected');
+            var scoutName = form.find('input[name=ScoutName]').val();
+            form.get(0).reset();
+            form.find('input[name=ScoutName]').val(scoutName);
+            form.attr('data-tag', option.val() || '');
+            form.find('input[name=Revision]').val(option.attr('data-revision') || '0');
+            panel.hide();
+        }
+        picker.change(pick);
+        pick();
+        form.submit(function(e) {
+            e.preventDefault();
+            show(form, panel);
+        });
+    }
+
+    return {attach: attach, attachEvent: attachEvent};
+})();
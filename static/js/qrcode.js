@@ -0,0 +1,417 @@
+/*
+ *  qrcode.js
+ *
+ *  Draws QR codes for tablets that can't reach the server.  This follows
+ *  barcode/qr.go, but only encodes bytes at level M.
+ */
+
+var QRCode = (function() {
+    // Error correction codewords per block and number of blocks at level M,
+    // by version.  Index 0 is unused.
+    var eccPerBlock = [0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28];
+    var numBlocks = [0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49];
+
+    // Level M's two-bit code in the format information
+    var levelBits = 0;
+
+    function rawModules(version) {
+        var n = (16 * version + 128) * version + 64;
+        if (version >= 2) {
+            var align = Math.floor(version / 7) + 2;
+            n -= (25 * align - 10) * align - 55;
+            if (version >= 7) {
+                n -= 36;
+            }
+        }
+        return n;
+    }
+
+    function dataCodewords(version) {
+        return Math.floor(rawModules(version) / 8) - eccPerBlock[version] * numBlocks[version];
+    }
+
+    function gfMul(x, y) {
+        var z = 0;
+        for (var i = 7; i >= 0; i--) {
+            z = (z << 1) ^ ((z >>> 7) * 0x11d);
+            z ^= ((y >>> i) & 1) * x;
+        }
+        return z;
+    }
+
+    function rsGenerator(degree) {
+        var result = [];
+        for (var i = 0; i < degree; i++) {
+            result.push(0);
+        }
+        result[degree - 1] = 1;
+        var root = 1;
+        for (var i = 0; i < degree; i++) {
+            for (var j = 0; j < result.length; j++) {
+                result[j] = gfMul(result[j], root);
+                if (j + 1 < result.length) {
+                    result[j] ^= result[j + 1];
+                }
+            }
+            root = gfMul(root, 0x02);
+        }
+        return result;
+    }
+
+    function rsRemainder(data, divisor) {
+        var result = [];
+        for (var i = 0; i < divisor.length; i++) {
+            result.push(0);
+        }
+        for (var k = 0; k < data.length; k++) {
+            var factor = data[k] ^ result.shift();
+            result.push(0);
+            for (var i = 0; i < result.length; i++) {
+                result[i] ^= gfMul(divisor[i], factor);
+            }
+        }
+        return result;
+    }
+
+    function interleave(data, version) {
+        var blockCount = numBlocks[version];
+        var eccLen = eccPerBlock[version];
+        var raw = Math.floor(rawModules(version) / 8);
+        var numShort = blockCount - raw % blockCount;
+        var shortLen = Math.floor(raw / blockCount);
+
+        var divisor = rsGenerator(eccLen);
+        var blocks = [];
+        for (var i = 0, k = 0; i < blockCount; i++) {
+            var n = shortLen - eccLen + (i >= numShort ? 1 : 0);
+            var block = data.slice(k, k + n);
+            k += n;
+            var ecc = rsRemainder(block, divisor);
+            if (i < numShort) {
+                block.push(0);
+            }
+            blocks.push(block.concat(ecc));
+        }
+
+        var result = [];
+        for (var i = 0; i <= shortLen; i++) {
+            for (var j = 0; j < blocks.length; j++) {
+                if (i != shortLen - eccLen || j >= numShort) {
+                    result.push(blocks[j][i]);
+                }
+            }
+        }
+        return result;
+    }
+
+    function alignment(version) {
+        if (version == 1) {
+            return [];
+        }
+        var n = Math.floor(version / 7) + 2;
+        var step = Math.floor((version * 8 + n * 3 + 5) / (n * 4 - 4)) * 2;
+        var result = [6];
+        for (var i = n - 1, pos = version * 4 + 17 - 7; i >= 1; i--, pos -= step) {
+            result[i] = pos;
+        }
+        return result;
+    }
+
+    function formatBits(mask) {
+        var data = (levelBits << 3) | mask;
+        var rem = data;
+        for (var i = 0; i < 10; i++) {
+            rem = (rem << 1) ^ ((rem >>> 9) * 0x537);
+        }
+        return ((data << 10) | rem) ^ 0x5412;
+    }
+
+    function versionBits(version) {
+        var rem = version;
+        for (var i = 0; i < 12; i++) {
+            rem = (rem << 1) ^ ((rem >>> 11) * 0x1f25);
+        }
+        return (version << 12) | rem;
+    }
+
+    function masked(mask, x, y) {
+        switch (mask) {
+        case 0: return (x + y) % 2 == 0;
+        case 1: return y % 2 == 0;
+        case 2: return x % 3 == 0;
+        case 3: return (x + y) % 3 == 0;
+        case 4: return (Math.floor(x / 3) + Math.floor(y / 2)) % 2 == 0;
+        case 5: return x * y % 2 + x * y % 3 == 0;
+        case 6: return (x * y % 2 + x * y % 3) % 2 == 0;
+        }
+        return ((x + y) % 2 + x * y % 3) % 2 == 0;
+    }
+
+    var finderLike = [
+        [true, false, true, true, true, false, true, false, false, false, false],
+        [false, false, false, false, true, false, true, true, true, false, true]
+    ];
+
+    function linePenalty(line) {
+        var penalty = 0;
+        var run = 1;
+        for (var i = 1; i <= line.length; i++) {
+            if (i < line.length && line[i] == line[i - 1]) {
+                run++;
+                continue;
+            }
+            if (run >= 5) {
+                penalty += 3 + run - 5;
+            }
+            run = 1;
+        }
+        for (var start = -4; start < line.length; start++) {
+            for (var p = 0; p < finderLike.length; p++) {
+                var match = true;
+                for (var j = 0; j < finderLike[p].length; j++) {
+                    var i = start + j;
+                    if ((i >= 0 && i < line.length && line[i]) != finderLike[p][j]) {
+                        match = false;
+                        break;
+                    }
+                }
+                if (match) {
+                    penalty += 40;
+                }
+            }
+        }
+        return penalty;
+    }
+
+    function QRSymbol(version) {
+        this.version = version;
+        this.size = version * 4 + 17;
+        this.modules = [];
+        this.reserved = [];
+        for (var i = 0; i < this.size * this.size; i++) {
+            this.modules.push(false);
+            this.reserved.push(false);
+        }
+    }
+
+    QRSymbol.prototype.dark = function(x, y) {
+        return this.modules[y * this.size + x];
+    };
+
+    QRSymbol.prototype.setFunction = function(x, y, dark) {
+        this.modules[y * this.size + x] = dark;
+        this.reserved[y * this.size + x] = true;
+    };
+
+    QRSymbol.prototype.drawFunctions = function() {
+        var size = this.size;
+        for (var i = 0; i < size; i++) {
+            this.setFunction(6, i, i % 2 == 0);
+            this.setFunction(i, 6, i % 2 == 0);
+        }
+        var finders = [[3, 3], [size - 4, 3], [3, size - 4]];
+        for (var f = 0; f < finders.length; f++) {
+            for (var dy = -4; dy <= 4; dy++) {
+                for (var dx = -4; dx <= 4; dx++) {
+                    var x = finders[f][0] + dx, y = finders[f][1] + dy;
+                    if (x >= 0 && x < size && y >= 0 && y < size) {
+                        var d = Math.max(Math.abs(dx), Math.abs(dy));
+                        this.setFunction(x, y, d != 2 && d != 4);
+                    }
+                }
+            }
+        }
+
+        var align = alignment(this.version);
+        for (var i = 0; i < align.length; i++) {
+            for (var j = 0; j < align.length; j++) {
+                if ((i == 0 && j == 0) || (i == 0 && j == align.length - 1) || (i == align.length - 1 && j == 0)) {
+                    continue;
+                }
+                for (var dy = -2; dy <= 2; dy++) {
+                    for (var dx = -2; dx <= 2; dx++) {
+                        this.setFunction(align[j] + dx, align[i] + dy, Math.max(Math.abs(dx), Math.abs(dy)) != 1);
+                    }
+                }
+            }
+        }
+
+        this.drawFormat(0);
+        if (this.version >= 7) {
+            var bits = versionBits(this.version);
+            for (var i = 0; i < 18; i++) {
+                var a = size - 11 + i % 3, b = Math.floor(i / 3);
+                var dark = ((bits >>> i) & 1) != 0;
+                this.setFunction(a, b, dark);
+                this.setFunction(b, a, dark);
+            }
+        }
+    };
+
+    QRSymbol.prototype.drawFormat = function(mask) {
+        var bits = formatBits(mask);
+        var size = this.size;
+        var self = this;
+        function set(x, y, i) {
+            self.setFunction(x, y, ((bits >>> i) & 1) != 0);
+        }
+        for (var i = 0; i <= 5; i++) {
+            set(8, i, i);
+        }
+        set(8, 7, 6);
+        set(8, 8, 7);
+        set(7, 8, 8);
+        for (var i = 9; i < 15; i++) {
+            set(14 - i, 8, i);
+        }
+        for (var i = 0; i < 8; i++) {
+            set(size - 1 - i, 8, i);
+        }
+        for (var i = 8; i < 15; i++) {
+            set(8, size - 15 + i, i);
+        }
+        this.setFunction(8, size - 8, true);
+    };
+
+    QRSymbol.prototype.drawCodewords = function(codewords) {
+        var size = this.size;
+        var i = 0;
+        for (var right = size - 1; right >= 1; right -= 2) {
+            if (right == 6) {
+                right = 5;
+            }
+            for (var vert = 0; vert < size; vert++) {
+                for (var j = 0; j < 2; j++) {
+                    var x = right - j;
+                    var y = ((right + 1) & 2) == 0 ? size - 1 - vert : vert;
+                    if (!this.reserved[y * size + x] && i < codewords.length * 8) {
+                        this.modules[y * size + x] = ((codewords[i >>> 3] >>> (7 - (i & 7))) & 1) != 0;
+                        i++;
+                    }
+                }
+            }
+        }
+    };
+
+    QRSymbol.prototype.applyMask = function(mask) {
+        for (var y = 0; y < this.size; y++) {
+            for (var x = 0; x < this.size; x++) {
+                if (masked(mask, x, y) && !this.reserved[y * this.size + x]) {
+                    this.modules[y * this.size + x] = !this.modules[y * this.size + x];
+                }
+            }
+        }
+    };
+
+    QRSymbol.prototype.penalty = function() {
+        var size = this.size;
+        var penalty = 0;
+        for (var i = 0; i < size; i++) {
+            var row = [], column = [];
+            for (var j = 0; j < size; j++) {
+                row.push(this.dark(j, i));
+                column.push(this.dark(i, j));
+            }
+            penalty += linePenalty(row) + linePenalty(column);
+        }
+
+        var dark = 0;
+        for (var y = 0; y < size; y++) {
+            for (var x = 0; x < size; x++) {
+                var c = this.dark(x, y);
+                if (c) {
+                    dark++;
+                }
+                if (x + 1 < size && y + 1 < size && c == this.dark(x + 1, y) && c == this.dark(x, y + 1) && c == this.dark(x + 1, y + 1)) {
+                    penalty += 3;
+                }
+            }
+        }
+        var total = size * size;
+        var k = Math.floor((Math.abs(dark * 20 - total * 10) + total - 1) / total) - 1;
+        return penalty + k * 10;
+    };
+
+    // encode returns the smallest QR code that holds text as UTF-8 bytes, or
+    // null if the text is too long.
+    function encode(text) {
+        var utf8 = unescape(encodeURIComponent(text));
+        var version = 0;
+        for (var v = 1; v <= 40; v++) {
+            var n = v < 10 ? 8 : 16;
+            if (utf8.length < (1 << n) && 4 + n + utf8.length * 8 <= dataCodewords(v) * 8) {
+                version = v;
+                break;
+            }
+        }
+        if (version == 0) {
+            return null;
+        }
+
+        // Segment, terminator and padding
+        var bits = [];
+        function append(value, n) {
+            for (var i = n - 1; i >= 0; i--) {
+                bits.push((value >>> i) & 1);
+            }
+        }
+        append(0x4, 4);
+        append(utf8.length, version < 10 ? 8 : 16);
+        for (var i = 0; i < utf8.length; i++) {
+            append(utf8.charCodeAt(i), 8);
+        }
+        var capacity = dataCodewords(version) * 8;
+        append(0, Math.min(4, capacity - bits.length));
+        append(0, (8 - bits.length % 8) % 8);
+        for (var pad = 0xec; bits.length < capacity; pad ^= 0xec ^ 0x11) {
+            append(pad, 8);
+        }
+        var data = [];
+        for (var i = 0; i < bits.length; i += 8) {
+            var b = 0;
+            for (var j = 0; j < 8; j++) {
+                b = (b << 1) | bits[i + j];
+            }
+            data.push(b);
+        }
+
+        var qr = new QRSymbol(version);
+        qr.drawFunctions();
+        qr.drawCodewords(interleave(data, version));
+        var best = 0, bestPenalty = -1;
+        for (var mask = 0; mask < 8; mask++) {
+            qr.applyMask(mask);
+            qr.drawFormat(mask);
+            var p = qr.penalty();
+            if (bestPenalty < 0 || p < bestPenalty) {
+                best = mask;
+                bestPenalty = p;
+            }
+            qr.applyMask(mask);
+        }
+        qr.applyMask(best);
+        qr.drawFormat(best);
+        return qr;
+    }
+
+    // draw draws a QR code on a canvas with a four-module quiet zone, scaled
+    // to whole pixels per module.
+    function draw(qr, canvas) {
+        var n = qr.size + 8;
+        var scale = Math.max(1, Math.floor(Math.min(canvas.width, canvas.height) / n));
+        canvas.width = canvas.height = n * scale;
+        var ctx = canvas.getContext('2d');
+        ctx.fillStyle = '#fff';
+        ctx.fillRect(0, 0, canvas.width, canvas.height);
+        ctx.fillStyle = '#000';
+        for (var y = 0; y < qr.size; y++) {
+            for (var x = 0; x < qr.size; x++) {
+                if (qr.dark(x, y)) {
+                    ctx.fillRect((x + 4) * scale, (y + 4) * scale, scale, scale);
+                }
+            }
+        }
+    }
+
+    return {encode: encode, draw: draw};
+})();
@@ -5,10 +5,14 @@ import (
 	"launchpad.net/mgo"
 	"launchpad.net/mgo/bson"
 	"sort"
+	"time"
 )
 
 var StoreNotFound = errors.New("Not found in datastore")
 
+// StoreConflict is returned when an update is based on an outdated revision.
+var StoreConflict = errors.New("Datastore entry was changed by someone else")
+
 // A Datastore can retrieve and store model objects.
 type Datastore interface {
 	Teams() Pager
@@ -22,20 +26,77 @@ type Datastore interface {
 
 	EventsForTeam(year int, number int) ([]EventTag, error)
 
+	// TeamYears returns the seasons in which a team was registered for an
+	// event, newest first.
+	TeamYears(number int) ([]int, error)
+
+	// EventYears returns the seasons that have an event, newest first.
+	EventYears() ([]int, error)
+
 	TeamEventMatches(EventTag, int) ([]*Match, error)
 	TeamEventStats(EventTag, int) (TeamStats, error)
 
-	UpdateMatchScore(MatchTag, int, int) error
-	UpdateMatchTeam(MatchTag, int, TeamInfo) error
+	// EventRatings returns the OPR, DPR, CCWM and component ratings of the
+	// teams at an event, sorted by team number.  The ratings are recomputed
+	// whenever a match at the event is changed or scored, and are computed
+	// and stored the first time they are asked for if the event has matches
+	// but no stored ratings.
+	EventRatings(EventTag) ([]TeamRating, error)
+
+	// MatchHistory returns every change made to a match, oldest first.
+	MatchHistory(MatchTag) ([]MatchChange, error)
+
+	// UpdateMatchScore sets a match's red and blue scores, records the
+	// change in the match's history and recomputes the event's ratings.
+	UpdateMatchScore(MatchTag, int, int, Editor) error
+
+	// UpdateMatchTeam replaces a team's info in a match, records the change
+	// in the match's history and recomputes the event's ratings.  The info's
+	// revision must match the stored revision, otherwise StoreConflict is
+	// returned.  The stored revision is incremented.
+	UpdateMatchTeam(MatchTag, int, TeamInfo, Editor) error
+
+	// UpdateScoutReport stores a scout's report for a team in a match,
+	// replacing any earlier report by the same scout for that team.  The
+	// report's Team and ScoutName fields identify it.  The canonical team
+	// info is not changed, but unless the report is the canonical info (as
+	// for a team with only one scout), its revision is incremented so that
+	// an update based on the info from before the report returns
+	// StoreConflict.
+	UpdateScoutReport(MatchTag, TeamInfo) error
+
+	// FetchPickList returns an event's pick list, or StoreNotFound if the
+	// event doesn't have one yet.
+	FetchPickList(EventTag) (*PickList, error)
+
+	// UpdatePickList stores an event's pick list.  The list's revision must
+	// match the stored revision (zero if no list is stored), otherwise
+	// StoreConflict is returned.  The stored revision is incremented.
+	UpdatePickList(EventTag, *PickList) error
+
+	// FetchRobot returns the robot that pit scouts recorded for a team in a
+	// season, or StoreNotFound if the team hasn't been pit scouted.
+	FetchRobot(team int, year int) (*Robot, error)
+
+	// UpsertRobot stores a team's pit scouting profile for a season,
+	// replacing any earlier profile.
+	UpsertRobot(team int, year int, robot *Robot) error
 
 	UpsertTeam(*Team) error
 	UpsertEvent(*Event) error
 	UpsertMatch(EventTag, *Match) error
+
+	// DeleteMatch removes a match and its history and recomputes the
+	// event's ratings.  StoreNotFound is returned if there is no such match.
+	DeleteMatch(MatchTag) error
 }
 
 const (
-	teamCollection  = "teams"
-	eventCollection = "events"
+	teamCollection    = "teams"
+	eventCollection   = "events"
+	ratingsCollection = "ratings"
+	pickCollection    = "picklists"
+	robotCollection   = "robots"
 )
 
 // mongoDatastore persists model objects using MongoDB.
@@ -123,6 +184,38 @@ func (store mongoDatastore) EventsForTeam(year int, number int) ([]EventTag, err
 	return tags, nil
 }
 
+func (store mongoDatastore) TeamYears(number int) ([]int, error) {
+	query := store.C(eventCollection).Find(bson.M{"teams": number}).Select(bson.M{"date.year": 1})
+	var events []Event
+	if err := query.All(&events); err != nil {
+		return nil, err
+	}
+	return eventYears(events), nil
+}
+
+func (store mongoDatastore) EventYears() ([]int, error) {
+	query := store.C(eventCollection).Find(nil).Select(bson.M{"date.year": 1})
+	var events []Event
+	if err := query.All(&events); err != nil {
+		return nil, err
+	}
+	return eventYears(events), nil
+}
+
+// eventYears returns the distinct years of events, newest first.
+func eventYears(events []Event) []int {
+	seen := make(map[int]bool)
+	var years []int
+	for i := range events {
+		if y := events[i].Date.Year; !seen[y] {
+			seen[y] = true
+			years = append(years, y)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(years)))
+	return years
+}
+
 func (store mongoDatastore) TeamEventMatches(tag EventTag, number int) ([]*Match, error) {
 	query := store.C(matchCollection(tag)).Find(bson.M{"teams.team": number}).Limit(matchLimit)
 	var matches []*Match
@@ -137,55 +230,29 @@ func (store mongoDatastore) TeamEventMatches(tag EventTag, number int) ([]*Match
 func (store mongoDatastore) TeamEventStats(tag EventTag, number int) (TeamStats, error) {
 	var stats TeamStats
 
-	var team Team
-	if err := store.fetchOne(teamCollection, bson.M{"_id": number}, &team); err == nil {
-		stats.OPR = team.OPR
+	ratings, err := store.EventRatings(tag)
+	if err != nil {
+		return stats, err
 	}
+	stats.setRating(ratings, number)
 
-	iter := store.C(matchCollection(tag)).Find(bson.M{"teams.team": number}).Limit(matchLimit).Iter()
-
-	var match Match
-	stats.EventTag = tag
-	for iter.Next(&match) {
-		var i int
-		for i = 0; i < len(match.Teams); i++ {
-			if match.Teams[i].Team == number {
-				break
-			}
-		}
-		if i >= len(match.Teams) {
-			// Team not found in match.  This shouldn't be hit.
-			// TODO: Log problem
-			continue
-		}
-
-		if match.Teams[i].NoShow {
-			stats.NoShowCount++
-			continue
-		}
+	game, err := store.eventGame(tag)
+	if err != nil {
+		return stats, err
+	}
 
-		if match.Score == nil {
-			continue
-		}
+	// Matches are added in the order they were played, so that the
+	// distributions' trends are meaningful.
+	matches, err := store.TeamEventMatches(tag, number)
+	if err != nil {
+		return stats, err
+	}
 
-		stats.MatchCount++
-		stats.TotalPoints += match.Teams[i].Score
-		if match.Teams[i].Failure {
-			stats.FailureCount++
-		}
-		if shot := stats.TeleoperatedBalls.Total(); shot > stats.MaxTeleoperatedShot {
-			stats.MaxTeleoperatedShot = shot
-		}
-		if scored := stats.TeleoperatedBalls.TotalScored(); scored > stats.MaxTeleoperatedScored {
-			stats.MaxTeleoperatedScored = scored
-		}
-		stats.AutonomousBalls.Add(match.Teams[i].Autonomous)
-		stats.TeleoperatedBalls.Add(match.Teams[i].Teleoperated)
-		stats.CoopBridge.add(match.Teams[i].CoopBridge)
-		stats.TeamBridge1.add(match.Teams[i].TeamBridge1)
-		stats.TeamBridge2.add(match.Teams[i].TeamBridge2)
+	stats.EventTag = tag
+	for _, match := range matches {
+		stats.addMatch(game, match, number)
 	}
-	return stats, iter.Err()
+	return stats, nil
 }
 
 func (store mongoDatastore) UpsertTeam(team *Team) error {
@@ -195,24 +262,270 @@ func (store mongoDatastore) UpsertTeam(team *Team) error {
 
 func (store mongoDatastore) UpsertEvent(event *Event) error {
 	_, err := store.C(eventCollection).Upsert(bson.M{"location.code": event.Location.Code, "date.year": event.Date.Year}, event)
-	return err
+	if err != nil {
+		return err
+	}
+	return store.updateRatings(event.Tag())
 }
 
 func (store mongoDatastore) UpsertMatch(etag EventTag, match *Match) error {
 	_, err := store.C(matchCollection(etag)).Upsert(bson.M{"type": match.Type, "number": match.Number}, match)
+	if err != nil {
+		return err
+	}
+	return store.updateRatings(etag)
+}
+
+func (store mongoDatastore) DeleteMatch(tag MatchTag) error {
+	selector := bson.M{"type": tag.MatchType, "number": tag.MatchNumber}
+	if err := store.C(matchCollection(tag.EventTag)).Remove(selector); err == mgo.NotFound {
+		return StoreNotFound
+	} else if err != nil {
+		return err
+	}
+	if _, err := store.C(historyCollection(tag.EventTag)).RemoveAll(selector); err != nil {
+		return err
+	}
+	return store.updateRatings(tag.EventTag)
+}
+
+// eventRatings is the document stored for an event's ratings.
+type eventRatings struct {
+	Event string `bson:"_id"`
+	Teams []TeamRating
+}
+
+func (store mongoDatastore) EventRatings(tag EventTag) ([]TeamRating, error) {
+	var doc eventRatings
+	err := store.fetchOne(ratingsCollection, bson.M{"_id": tag.String()}, &doc)
+	if err == StoreNotFound {
+		// Events imported before ratings were stored don't have any yet.
+		n, err := store.C(matchCollection(tag)).Find(nil).Count()
+		if err != nil {
+			return nil, err
+		} else if n == 0 {
+			return []TeamRating{}, nil
+		}
+		if err := store.updateRatings(tag); err != nil {
+			return nil, err
+		}
+		err = store.fetchOne(ratingsCollection, bson.M{"_id": tag.String()}, &doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if doc.Teams == nil {
+		return []TeamRating{}, nil
+	}
+	return doc.Teams, nil
+}
+
+// updateRatings recomputes the ratings for an event.
+func (store mongoDatastore) updateRatings(tag EventTag) error {
+	game, err := store.eventGame(tag)
+	if err != nil {
+		return err
+	}
+	matches, err := store.FetchMatches(tag)
+	if err != nil {
+		return err
+	}
+	_, err = store.C(ratingsCollection).Upsert(bson.M{"_id": tag.String()}, eventRatings{tag.String(), computeRatings(game, matches)})
+	return err
+}
+
+// eventPickListDoc is the document stored for an event's pick list.
+type eventPickListDoc struct {
+	Event string `bson:"_id"`
+	List  PickList
+}
+
+func (store mongoDatastore) FetchPickList(tag EventTag) (*PickList, error) {
+	var doc eventPickListDoc
+	if err := store.fetchOne(pickCollection, bson.M{"_id": tag.String()}, &doc); err != nil {
+		return nil, err
+	}
+	return &doc.List, nil
+}
+
+func (store mongoDatastore) UpdatePickList(tag EventTag, list *PickList) error {
+	doc := eventPickListDoc{tag.String(), *list}
+	doc.List.Revision++
+	if list.Revision == 0 {
+		if _, err := store.FetchPickList(tag); err == nil {
+			return StoreConflict
+		} else if err != StoreNotFound {
+			return err
+		}
+		return store.C(pickCollection).Insert(doc)
+	}
+	err := store.update(pickCollection, bson.M{"_id": doc.Event, "list.revision": list.Revision}, doc)
+	if err == StoreNotFound {
+		// Changed since fetch
+		return StoreConflict
+	}
+	return err
+}
+
+// teamRobotDoc is the document stored for a team's robot in a season.
+type teamRobotDoc struct {
+	Team  int
+	Year  int
+	Robot Robot
+}
+
+func (store mongoDatastore) FetchRobot(team int, year int) (*Robot, error) {
+	var doc teamRobotDoc
+	if err := store.fetchOne(robotCollection, bson.M{"team": team, "year": year}, &doc); err != nil {
+		return nil, err
+	}
+	return &doc.Robot, nil
+}
+
+func (store mongoDatastore) UpsertRobot(team int, year int, robot *Robot) error {
+	_, err := store.C(robotCollection).Upsert(bson.M{"team": team, "year": year}, teamRobotDoc{team, year, *robot})
+	return err
+}
+
+// eventGame returns the game played at an event.  Matches may be stored
+// before their event, so a missing event plays the default game.
+func (store mongoDatastore) eventGame(tag EventTag) (*Game, error) {
+	event, err := store.FetchEvent(tag)
+	if err == StoreNotFound {
+		return eventGame(nil), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return eventGame(event), nil
+}
+
+func (store mongoDatastore) update(collection string, selector interface{}, change interface{}) error {
+	err := store.C(collection).Update(selector, change)
+	if err == mgo.NotFound {
+		err = StoreNotFound
+	}
 	return err
 }
 
-func (store mongoDatastore) UpdateMatchScore(tag MatchTag, red int, blue int) error {
-	return store.C(matchCollection(tag.EventTag)).Update(
+func (store mongoDatastore) UpdateMatchScore(tag MatchTag, red int, blue int, editor Editor) error {
+	old, err := store.FetchMatch(tag)
+	if err != nil {
+		return err
+	}
+	err = store.update(
+		matchCollection(tag.EventTag),
 		bson.M{"type": tag.MatchType, "number": tag.MatchNumber},
 		bson.M{"$set": bson.M{"score.red": red, "score.blue": blue}},
 	)
+	if err != nil {
+		return err
+	}
+	if err := store.updateRatings(tag.EventTag); err != nil {
+		return err
+	}
+	return store.appendHistory(tag, MatchChange{
+		Time:     time.Now(),
+		Editor:   editor,
+		OldScore: old.Score,
+		NewScore: map[string]int{string(Red): red, string(Blue): blue},
+	})
 }
 
-func (store mongoDatastore) UpdateMatchTeam(tag MatchTag, teamNumber int, info TeamInfo) error {
-	return store.C(matchCollection(tag.EventTag)).Update(
-		bson.M{"type": tag.MatchType, "number": tag.MatchNumber, "teams.team": teamNumber},
+func (store mongoDatastore) UpdateMatchTeam(tag MatchTag, teamNumber int, info TeamInfo, editor Editor) error {
+	match, err := store.FetchMatch(tag)
+	if err != nil {
+		return err
+	}
+	old := match.TeamInfo(teamNumber)
+	if old == nil {
+		return StoreNotFound
+	} else if old.Revision != info.Revision {
+		return StoreConflict
+	}
+
+	var revision interface{} = info.Revision
+	if info.Revision == 0 {
+		// Entries created before revisions were added won't have the field.
+		revision = bson.M{"$in": []interface{}{0, nil}}
+	}
+	info.Revision++
+	err = store.update(
+		matchCollection(tag.EventTag),
+		bson.M{
+			"type":   tag.MatchType,
+			"number": tag.MatchNumber,
+			"teams":  bson.M{"$elemMatch": bson.M{"team": teamNumber, "revision": revision}},
+		},
 		bson.M{"$set": bson.M{"teams.$": info}},
 	)
+	if err == StoreNotFound {
+		// Changed since fetch
+		return StoreConflict
+	} else if err != nil {
+		return err
+	}
+	if err := store.updateRatings(tag.EventTag); err != nil {
+		return err
+	}
+	return store.appendHistory(tag, MatchChange{
+		Time:    time.Now(),
+		Editor:  editor,
+		Team:    teamNumber,
+		OldInfo: old,
+		NewInfo: &info,
+	})
+}
+
+func (store mongoDatastore) UpdateScoutReport(tag MatchTag, report TeamInfo) error {
+	match, err := store.FetchMatch(tag)
+	if err != nil {
+		return err
+	}
+	info := match.TeamInfo(report.Team)
+	if info == nil {
+		return StoreNotFound
+	}
+
+	selector := bson.M{"type": tag.MatchType, "number": tag.MatchNumber, "teams.team": report.Team}
+	err = store.update(
+		matchCollection(tag.EventTag),
+		selector,
+		bson.M{"$pull": bson.M{"reports": bson.M{"team": report.Team, "scout": report.ScoutName}}},
+	)
+	if err != nil {
+		return err
+	}
+	change := bson.M{"$push": bson.M{"reports": report}}
+	if !info.SameObservation(&report) || info.ScoutName != report.ScoutName {
+		change["$inc"] = bson.M{"teams.$.revision": 1}
+	}
+	return store.update(matchCollection(tag.EventTag), selector, change)
+}
+
+func historyCollection(tag EventTag) string {
+	return "history." + tag.String()
+}
+
+// historyEntry is the document stored for a MatchChange.
+type historyEntry struct {
+	Type   MatchType
+	Number int
+	Change MatchChange
+}
+
+func (store mongoDatastore) appendHistory(tag MatchTag, change MatchChange) error {
+	return store.C(historyCollection(tag.EventTag)).Insert(historyEntry{tag.MatchType, int(tag.MatchNumber), change})
+}
+
+func (store mongoDatastore) MatchHistory(tag MatchTag) ([]MatchChange, error) {
+	query := store.C(historyCollection(tag.EventTag)).Find(bson.M{"type": tag.MatchType, "number": tag.MatchNumber}).Sort(bson.D{{"_id", 1}})
+	var entries []historyEntry
+	if err := query.All(&entries); err != nil {
+		return nil, err
+	}
+	changes := make([]MatchChange, len(entries))
+	for i := range entries {
+		changes[i] = entries[i].Change
+	}
+	return changes, nil
 }
@@ -0,0 +1,1053 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"launchpad.net/mgo"
+	"reflect"
+	"testing"
+)
+
+var testMongoURL = flag.String("mongo", "", "MongoDB instance to run datastore tests against (skipped if empty)")
+
+func TestMongoDatastore(t *testing.T) {
+	if *testMongoURL == "" {
+		t.Skip("-mongo not given")
+	}
+	session, err := mgo.Dial(*testMongoURL)
+	if err != nil {
+		t.Fatalf("Could not connect to MongoDB: %v", err)
+	}
+	defer session.Close()
+
+	n := 0
+	testDatastore(t, func() (Datastore, func()) {
+		db := session.DB(fmt.Sprintf("scouting_test%d", n))
+		n++
+		db.DropDatabase()
+		return mongoDatastore{db}, func() { db.DropDatabase() }
+	})
+}
+
+var testEditor = Editor{Name: "Test", Address: "127.0.0.1"}
+
+// A datastoreTest checks one aspect of a Datastore's behavior.  The store
+// given to it is empty.
+type datastoreTest struct {
+	Name string
+	F    func(*testing.T, Datastore)
+}
+
+var datastoreTests = []datastoreTest{
+	{"Teams", testStoreTeams},
+	{"Events", testStoreEvents},
+	{"FetchTeam", testStoreFetchTeam},
+	{"FetchTeams", testStoreFetchTeams},
+	{"UpsertTeam", testStoreUpsertTeam},
+	{"FetchEvent", testStoreFetchEvent},
+	{"UpsertEvent", testStoreUpsertEvent},
+	{"FetchMatches", testStoreFetchMatches},
+	{"FetchMatch", testStoreFetchMatch},
+	{"UpsertMatch", testStoreUpsertMatch},
+	{"DeleteMatch", testStoreDeleteMatch},
+	{"EventsForTeam", testStoreEventsForTeam},
+	{"TeamYears", testStoreTeamYears},
+	{"EventYears", testStoreEventYears},
+	{"TeamEventMatches", testStoreTeamEventMatches},
+	{"TeamEventStats", testStoreTeamEventStats},
+	{"UpdateMatchScore", testStoreUpdateMatchScore},
+	{"UpdateMatchTeam", testStoreUpdateMatchTeam},
+	{"MatchHistory", testStoreMatchHistory},
+	{"UpdateScoutReport", testStoreUpdateScoutReport},
+	{"UpdateScoutReportRevision", testStoreUpdateScoutReportRevision},
+	{"EventRatings", testStoreEventRatings},
+	{"PickList", testStorePickList},
+	{"Robot", testStoreRobot},
+}
+
+// testDatastore runs the datastore conformance tests.  newStore is called
+// once per test and must return an empty datastore along with a function to
+// release it.
+func testDatastore(t *testing.T, newStore func() (Datastore, func())) {
+	for _, test := range datastoreTests {
+		t.Run(test.Name, func(t *testing.T) {
+			store, done := newStore()
+			defer done()
+			test.F(t, store)
+		})
+	}
+}
+
+func newTestEvent(code string, year, month, day int, teams ...int) *Event {
+	event := new(Event)
+	event.Location.Code = code
+	event.Location.Name = code + " Regional"
+	event.Date.Year = year
+	event.Date.Month = month
+	event.Date.Day = day
+	event.Teams = teams
+	return event
+}
+
+func newTestMatch(matchType MatchType, number int, red1, red2, red3, blue1, blue2, blue3 int) *Match {
+	return &Match{
+		Type:   matchType,
+		Number: number,
+		Teams: []TeamInfo{
+			{Team: red1, Alliance: Red},
+			{Team: red2, Alliance: Red},
+			{Team: red3, Alliance: Red},
+			{Team: blue1, Alliance: Blue},
+			{Team: blue2, Alliance: Blue},
+			{Team: blue3, Alliance: Blue},
+		},
+	}
+}
+
+func mustUpsertTeams(t *testing.T, store Datastore, numbers ...int) {
+	for _, n := range numbers {
+		if err := store.UpsertTeam(&Team{Number: n, Name: fmt.Sprintf("Team %d", n)}); err != nil {
+			t.Fatalf("UpsertTeam(%d) error: %v", n, err)
+		}
+	}
+}
+
+func mustUpsertEvent(t *testing.T, store Datastore, event *Event) {
+	if err := store.UpsertEvent(event); err != nil {
+		t.Fatalf("UpsertEvent(%v) error: %v", event.Tag(), err)
+	}
+}
+
+func mustUpsertMatch(t *testing.T, store Datastore, etag EventTag, match *Match) {
+	if err := store.UpsertMatch(etag, match); err != nil {
+		t.Fatalf("UpsertMatch(%v, %s %d) error: %v", etag, match.Type, match.Number, err)
+	}
+}
+
+// A conflictDatastore fails the next Conflicts match updates with
+// StoreConflict, as if someone else had saved the match first.
+type conflictDatastore struct {
+	Datastore
+	Conflicts int
+}
+
+func (store *conflictDatastore) UpdateMatchScore(tag MatchTag, red int, blue int, editor Editor) error {
+	if store.Conflicts > 0 {
+		store.Conflicts--
+		return StoreConflict
+	}
+	return store.Datastore.UpdateMatchScore(tag, red, blue, editor)
+}
+
+func (store *conflictDatastore) UpdateMatchTeam(tag MatchTag, teamNumber int, info TeamInfo, editor Editor) error {
+	if store.Conflicts > 0 {
+		store.Conflicts--
+		return StoreConflict
+	}
+	return store.Datastore.UpdateMatchTeam(tag, teamNumber, info, editor)
+}
+
+func teamNumbers(teams []*Team) []int {
+	nums := make([]int, len(teams))
+	for i := range teams {
+		nums[i] = teams[i].Number
+	}
+	return nums
+}
+
+func matchTags(etag EventTag, matches []*Match) []MatchTag {
+	tags := make([]MatchTag, len(matches))
+	for i, m := range matches {
+		tags[i] = MatchTag{etag, m.Type, uint(m.Number)}
+	}
+	return tags
+}
+
+func testStoreTeams(t *testing.T, store Datastore) {
+	mustUpsertTeams(t, store, 973, 254, 1538, 8, 330)
+
+	if n, err := store.Teams().Count(); err != nil {
+		t.Errorf("Teams().Count() error: %v", err)
+	} else if n != 5 {
+		t.Errorf("Teams().Count() = %d (expected 5)", n)
+	}
+
+	var teams []Team
+	if err := store.Teams().All(&teams); err != nil {
+		t.Fatalf("Teams().All(...) error: %v", err)
+	}
+	var nums []int
+	for _, team := range teams {
+		nums = append(nums, team.Number)
+	}
+	if expected := []int{8, 254, 330, 973, 1538}; !reflect.DeepEqual(nums, expected) {
+		t.Errorf("Teams().All(...) numbers = %v (expected %v)", nums, expected)
+	}
+
+	teams = nil
+	if err := store.Teams().Offset(1).Limit(2).All(&teams); err != nil {
+		t.Fatalf("Teams().Offset(1).Limit(2).All(...) error: %v", err)
+	}
+	nums = nil
+	for _, team := range teams {
+		nums = append(nums, team.Number)
+	}
+	if expected := []int{254, 330}; !reflect.DeepEqual(nums, expected) {
+		t.Errorf("Teams().Offset(1).Limit(2).All(...) numbers = %v (expected %v)", nums, expected)
+	}
+
+	p, err := NewPaginator(store.Teams(), 2)
+	if err != nil {
+		t.Fatalf("NewPaginator error: %v", err)
+	}
+	if p.NPage() != 3 {
+		t.Errorf("NPage() = %d (expected 3)", p.NPage())
+	}
+	teams = nil
+	if err := p.Page(3).Get(&teams); err != nil {
+		t.Errorf("Page(3).Get(...) error: %v", err)
+	} else if len(teams) != 1 || teams[0].Number != 1538 {
+		t.Errorf("Page(3).Get(...) = %v (expected team 1538)", teams)
+	}
+}
+
+func testStoreEvents(t *testing.T, store Datastore) {
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2012, 3, 15))
+	mustUpsertEvent(t, store, newTestEvent("ca", 2012, 3, 1))
+	mustUpsertEvent(t, store, newTestEvent("cmp", 2012, 4, 25))
+	mustUpsertEvent(t, store, newTestEvent("nv", 2012, 3, 8))
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2011, 3, 10))
+
+	if n, err := store.Events(2012).Count(); err != nil {
+		t.Errorf("Events(2012).Count() error: %v", err)
+	} else if n != 4 {
+		t.Errorf("Events(2012).Count() = %d (expected 4)", n)
+	}
+
+	var events []Event
+	if err := store.Events(2012).All(&events); err != nil {
+		t.Fatalf("Events(2012).All(...) error: %v", err)
+	}
+	var codes []string
+	for _, e := range events {
+		codes = append(codes, e.Location.Code)
+	}
+	if expected := []string{"ca", "nv", "sdc", "cmp"}; !reflect.DeepEqual(codes, expected) {
+		t.Errorf("Events(2012).All(...) codes = %v (expected %v)", codes, expected)
+	}
+
+	events = nil
+	if err := store.Events(2012).Limit(2).All(&events); err != nil {
+		t.Fatalf("Events(2012).Limit(2).All(...) error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("len(Events(2012).Limit(2).All(...)) = %d (expected 2)", len(events))
+	}
+
+	events = nil
+	if err := store.Events(2010).All(&events); err != nil {
+		t.Fatalf("Events(2010).All(...) error: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Events(2010).All(...) = %v (expected none)", events)
+	}
+}
+
+func testStoreFetchTeam(t *testing.T, store Datastore) {
+	team := &Team{Number: 973, Name: "Greybots", RookieYear: 2002, Robot: &Robot{Name: "Ringo", Notes: "Shoots high"}}
+	if err := store.UpsertTeam(team); err != nil {
+		t.Fatalf("UpsertTeam error: %v", err)
+	}
+
+	if fetched, err := store.FetchTeam(973); err != nil {
+		t.Errorf("FetchTeam(973) error: %v", err)
+	} else if !reflect.DeepEqual(fetched, team) {
+		t.Errorf("FetchTeam(973) = %#v (expected %#v)", fetched, team)
+	}
+
+	if fetched, err := store.FetchTeam(254); err != StoreNotFound {
+		t.Errorf("FetchTeam(254) = %#v, %v (expected %v)", fetched, err, StoreNotFound)
+	}
+}
+
+func testStoreFetchTeams(t *testing.T, store Datastore) {
+	mustUpsertTeams(t, store, 973, 254, 1538, 8)
+
+	teams, err := store.FetchTeams([]int{1538, 973, 100, 8})
+	if err != nil {
+		t.Fatalf("FetchTeams error: %v", err)
+	}
+	if nums, expected := teamNumbers(teams), []int{8, 973, 1538}; !reflect.DeepEqual(nums, expected) {
+		t.Errorf("FetchTeams numbers = %v (expected %v)", nums, expected)
+	}
+	if len(teams) > 0 && teams[0].Name != "Team 8" {
+		t.Errorf("FetchTeams[0].Name = %q (expected %q)", teams[0].Name, "Team 8")
+	}
+
+	teams, err = store.FetchTeams([]int{100})
+	if err != nil {
+		t.Fatalf("FetchTeams error: %v", err)
+	}
+	if len(teams) != 0 {
+		t.Errorf("FetchTeams([100]) = %v (expected none)", teamNumbers(teams))
+	}
+}
+
+func testStoreUpsertTeam(t *testing.T, store Datastore) {
+	mustUpsertTeams(t, store, 973)
+	if err := store.UpsertTeam(&Team{Number: 973, Name: "Greybots"}); err != nil {
+		t.Fatalf("UpsertTeam error: %v", err)
+	}
+
+	team, err := store.FetchTeam(973)
+	if err != nil {
+		t.Fatalf("FetchTeam error: %v", err)
+	}
+	if team.Name != "Greybots" {
+		t.Errorf("team.Name = %q (expected %q)", team.Name, "Greybots")
+	}
+	if n, err := store.Teams().Count(); err != nil {
+		t.Errorf("Teams().Count() error: %v", err)
+	} else if n != 1 {
+		t.Errorf("Teams().Count() = %d (expected 1)", n)
+	}
+}
+
+func testStoreFetchEvent(t *testing.T, store Datastore) {
+	event := newTestEvent("sdc", 2012, 3, 15, 254, 973)
+	mustUpsertEvent(t, store, event)
+
+	if fetched, err := store.FetchEvent(EventTag{"sdc", 2012}); err != nil {
+		t.Errorf("FetchEvent error: %v", err)
+	} else if !reflect.DeepEqual(fetched, event) {
+		t.Errorf("FetchEvent = %#v (expected %#v)", fetched, event)
+	}
+
+	for _, etag := range []EventTag{{"sdc", 2011}, {"ca", 2012}} {
+		if fetched, err := store.FetchEvent(etag); err != StoreNotFound {
+			t.Errorf("FetchEvent(%v) = %#v, %v (expected %v)", etag, fetched, err, StoreNotFound)
+		}
+	}
+}
+
+func testStoreUpsertEvent(t *testing.T, store Datastore) {
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2012, 3, 15, 254))
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2011, 3, 10, 330))
+
+	// Upserts are keyed on location code and year only.
+	replacement := newTestEvent("sdc", 2012, 3, 16, 254, 973)
+	replacement.Location.Name = "San Diego"
+	mustUpsertEvent(t, store, replacement)
+
+	if fetched, err := store.FetchEvent(EventTag{"sdc", 2012}); err != nil {
+		t.Errorf("FetchEvent error: %v", err)
+	} else if !reflect.DeepEqual(fetched, replacement) {
+		t.Errorf("FetchEvent = %#v (expected %#v)", fetched, replacement)
+	}
+	if fetched, err := store.FetchEvent(EventTag{"sdc", 2011}); err != nil {
+		t.Errorf("FetchEvent error: %v", err)
+	} else if !reflect.DeepEqual(fetched.Teams, []int{330}) {
+		t.Errorf("Upserting sdc2012 changed sdc2011 teams to %v", fetched.Teams)
+	}
+	if n, err := store.Events(2012).Count(); err != nil {
+		t.Errorf("Events(2012).Count() error: %v", err)
+	} else if n != 1 {
+		t.Errorf("Events(2012).Count() = %d (expected 1)", n)
+	}
+}
+
+func testStoreFetchMatches(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Final, 1, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 10, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(SemiFinal, 2, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 2, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(QuarterFinal, 1, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(SemiFinal, 1, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, EventTag{"ca", 2012}, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+
+	matches, err := store.FetchMatches(etag)
+	if err != nil {
+		t.Fatalf("FetchMatches error: %v", err)
+	}
+	expected := []MatchTag{
+		{etag, Qualification, 2},
+		{etag, Qualification, 10},
+		{etag, QuarterFinal, 1},
+		{etag, SemiFinal, 1},
+		{etag, SemiFinal, 2},
+		{etag, Final, 1},
+	}
+	if tags := matchTags(etag, matches); !reflect.DeepEqual(tags, expected) {
+		t.Errorf("FetchMatches = %v (expected %v)", tags, expected)
+	}
+
+	matches, err = store.FetchMatches(EventTag{"nv", 2012})
+	if err != nil {
+		t.Fatalf("FetchMatches for empty event error: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("FetchMatches for empty event = %v", matchTags(EventTag{"nv", 2012}, matches))
+	}
+}
+
+func testStoreFetchMatch(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	match := newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6)
+	match.Score = map[string]int{"red": 10, "blue": 20}
+	mustUpsertMatch(t, store, etag, match)
+
+	if fetched, err := store.FetchMatch(MatchTag{etag, Qualification, 1}); err != nil {
+		t.Errorf("FetchMatch error: %v", err)
+	} else if !reflect.DeepEqual(fetched, match) {
+		t.Errorf("FetchMatch = %#v (expected %#v)", fetched, match)
+	}
+
+	notFound := []MatchTag{
+		{etag, Qualification, 2},
+		{etag, QuarterFinal, 1},
+		{EventTag{"ca", 2012}, Qualification, 1},
+	}
+	for _, mtag := range notFound {
+		if fetched, err := store.FetchMatch(mtag); err != StoreNotFound {
+			t.Errorf("FetchMatch(%v) = %#v, %v (expected %v)", mtag, fetched, err, StoreNotFound)
+		}
+	}
+}
+
+func testStoreUpsertMatch(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 2, 1, 2, 3, 4, 5, 6))
+	replacement := newTestMatch(Qualification, 1, 7, 8, 9, 10, 11, 12)
+	mustUpsertMatch(t, store, etag, replacement)
+
+	if fetched, err := store.FetchMatch(MatchTag{etag, Qualification, 1}); err != nil {
+		t.Errorf("FetchMatch error: %v", err)
+	} else if !reflect.DeepEqual(fetched, replacement) {
+		t.Errorf("FetchMatch = %#v (expected %#v)", fetched, replacement)
+	}
+	if matches, err := store.FetchMatches(etag); err != nil {
+		t.Errorf("FetchMatches error: %v", err)
+	} else if len(matches) != 2 {
+		t.Errorf("len(FetchMatches) = %d (expected 2)", len(matches))
+	}
+}
+
+func testStoreDeleteMatch(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 2, 1, 2, 3, 4, 5, 6))
+	mtag := MatchTag{etag, Qualification, 1}
+	if err := store.UpdateMatchScore(mtag, 30, 12, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+
+	if err := store.DeleteMatch(mtag); err != nil {
+		t.Fatalf("DeleteMatch error: %v", err)
+	}
+	if _, err := store.FetchMatch(mtag); err != StoreNotFound {
+		t.Errorf("FetchMatch after delete error = %v (expected %v)", err, StoreNotFound)
+	}
+	if matches, err := store.FetchMatches(etag); err != nil {
+		t.Errorf("FetchMatches error: %v", err)
+	} else if len(matches) != 1 || matches[0].Number != 2 {
+		t.Errorf("FetchMatches after delete = %+v (expected match 2)", matches)
+	}
+	if history, err := store.MatchHistory(mtag); err != nil || len(history) != 0 {
+		t.Errorf("MatchHistory after delete = %+v, %v (expected none)", history, err)
+	}
+	if ratings, err := store.EventRatings(etag); err != nil || len(ratings) != 0 {
+		t.Errorf("EventRatings after delete = %+v, %v (expected none)", ratings, err)
+	}
+
+	if err := store.DeleteMatch(mtag); err != StoreNotFound {
+		t.Errorf("Second DeleteMatch error = %v (expected %v)", err, StoreNotFound)
+	}
+}
+
+func testStoreEventsForTeam(t *testing.T, store Datastore) {
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2012, 3, 15, 254, 973))
+	mustUpsertEvent(t, store, newTestEvent("ca", 2012, 3, 1, 973))
+	mustUpsertEvent(t, store, newTestEvent("nv", 2012, 3, 8, 254))
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2011, 3, 10, 973))
+
+	tags, err := store.EventsForTeam(2012, 973)
+	if err != nil {
+		t.Fatalf("EventsForTeam error: %v", err)
+	}
+	if expected := []EventTag{{"ca", 2012}, {"sdc", 2012}}; !reflect.DeepEqual(tags, expected) {
+		t.Errorf("EventsForTeam(2012, 973) = %v (expected %v)", tags, expected)
+	}
+
+	tags, err = store.EventsForTeam(2012, 1538)
+	if err != nil {
+		t.Fatalf("EventsForTeam error: %v", err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("EventsForTeam(2012, 1538) = %v (expected none)", tags)
+	}
+}
+
+func testStoreTeamYears(t *testing.T, store Datastore) {
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2012, 3, 15, 254, 973))
+	mustUpsertEvent(t, store, newTestEvent("ca", 2012, 3, 1, 973))
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2010, 3, 10, 973))
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2011, 3, 10, 254))
+
+	years, err := store.TeamYears(973)
+	if err != nil {
+		t.Fatalf("TeamYears error: %v", err)
+	}
+	if expected := []int{2012, 2010}; !reflect.DeepEqual(years, expected) {
+		t.Errorf("TeamYears(973) = %v (expected %v)", years, expected)
+	}
+
+	years, err = store.TeamYears(1538)
+	if err != nil {
+		t.Fatalf("TeamYears error: %v", err)
+	}
+	if len(years) != 0 {
+		t.Errorf("TeamYears(1538) = %v (expected none)", years)
+	}
+}
+
+func testStoreEventYears(t *testing.T, store Datastore) {
+	years, err := store.EventYears()
+	if err != nil {
+		t.Fatalf("EventYears error: %v", err)
+	}
+	if len(years) != 0 {
+		t.Errorf("EventYears() of empty store = %v (expected none)", years)
+	}
+
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2012, 3, 15, 254, 973))
+	mustUpsertEvent(t, store, newTestEvent("ca", 2012, 3, 1, 973))
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2010, 3, 10, 973))
+	years, err = store.EventYears()
+	if err != nil {
+		t.Fatalf("EventYears error: %v", err)
+	}
+	if expected := []int{2012, 2010}; !reflect.DeepEqual(years, expected) {
+		t.Errorf("EventYears() = %v (expected %v)", years, expected)
+	}
+}
+
+func testStoreTeamEventMatches(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(SemiFinal, 1, 973, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 3, 1, 2, 3, 973, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 2, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 973, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, EventTag{"ca", 2012}, newTestMatch(Qualification, 1, 973, 2, 3, 4, 5, 6))
+
+	matches, err := store.TeamEventMatches(etag, 973)
+	if err != nil {
+		t.Fatalf("TeamEventMatches error: %v", err)
+	}
+	expected := []MatchTag{
+		{etag, Qualification, 1},
+		{etag, Qualification, 3},
+		{etag, SemiFinal, 1},
+	}
+	if tags := matchTags(etag, matches); !reflect.DeepEqual(tags, expected) {
+		t.Errorf("TeamEventMatches = %v (expected %v)", tags, expected)
+	}
+}
+
+func testStoreTeamEventStats(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+
+	// Scored match
+	m := newTestMatch(Qualification, 1, 973, 2, 3, 4, 5, 6)
+	m.Score = map[string]int{"red": 30, "blue": 20}
+	m.Teams[0].Score = 18
+	m.Teams[0].Autonomous = BallCount{High: 1, Mid: 1, Missed: 1}
+	m.Teams[0].Teleoperated = BallCount{High: 2, Low: 1, Missed: 3}
+	m.Teams[0].TeamBridge1 = Bridge{true, true}
+	mustUpsertMatch(t, store, etag, m)
+
+	// Scored match with failure
+	m = newTestMatch(Qualification, 2, 1, 2, 3, 973, 5, 6)
+	m.Score = map[string]int{"red": 30, "blue": 20}
+	m.Teams[3].Score = 6
+	m.Teams[3].Teleoperated = BallCount{High: 2, Missed: 1}
+	m.Teams[3].CoopBridge = Bridge{true, false}
+	m.Teams[3].Failure = true
+	mustUpsertMatch(t, store, etag, m)
+
+	// No-show
+	m = newTestMatch(Qualification, 3, 973, 2, 3, 4, 5, 6)
+	m.Score = map[string]int{"red": 30, "blue": 20}
+	m.Teams[0].Score = 100
+	m.Teams[0].NoShow = true
+	mustUpsertMatch(t, store, etag, m)
+
+	// Unscored match
+	m = newTestMatch(Qualification, 4, 973, 2, 3, 4, 5, 6)
+	m.Teams[0].Score = 100
+	m.Teams[0].Teleoperated = BallCount{High: 100}
+	mustUpsertMatch(t, store, etag, m)
+
+	// Match at another event
+	m = newTestMatch(Qualification, 1, 973, 2, 3, 4, 5, 6)
+	m.Score = map[string]int{"red": 30, "blue": 20}
+	m.Teams[0].Score = 100
+	mustUpsertMatch(t, store, EventTag{"ca", 2012}, m)
+
+	stats, err := store.TeamEventStats(etag, 973)
+	if err != nil {
+		t.Fatalf("TeamEventStats error: %v", err)
+	}
+	ratings, err := store.EventRatings(etag)
+	if err != nil {
+		t.Fatalf("EventRatings error: %v", err)
+	}
+	var rating TeamRating
+	for _, r := range ratings {
+		if r.Team == 973 {
+			rating = r
+		}
+	}
+	expected := TeamStats{
+		EventTag:              etag,
+		MatchCount:            2,
+		TotalPoints:           24,
+		SquaredPoints:         360,
+		OPR:                   rating.OPR,
+		DPR:                   rating.DPR,
+		CCWM:                  rating.CCWM,
+		Components:            rating.Components,
+		NoShowCount:           1,
+		FailureCount:          1,
+		CoopBridge:            BridgeStats{AttemptCount: 1},
+		TeamBridge1:           BridgeStats{AttemptCount: 1, SuccessCount: 1},
+		AutonomousBalls:       BallCount{High: 1, Mid: 1, Missed: 1},
+		TeleoperatedBalls:     BallCount{High: 4, Low: 1, Missed: 4},
+		MaxTeleoperatedShot:   6,
+		MaxTeleoperatedScored: 3,
+		Scores:                Distribution{18, 6},
+		Fields: map[string]FieldStats{
+			"Autonomous.High":     {Total: 1, Max: 1, Values: Distribution{1, 0}},
+			"Autonomous.Mid":      {Total: 1, Max: 1, Values: Distribution{1, 0}},
+			"Autonomous.Low":      {Values: Distribution{0, 0}},
+			"Autonomous.Missed":   {Total: 1, Max: 1, Values: Distribution{1, 0}},
+			"Teleoperated.High":   {Total: 4, Max: 2, Values: Distribution{2, 2}},
+			"Teleoperated.Mid":    {Values: Distribution{0, 0}},
+			"Teleoperated.Low":    {Total: 1, Max: 1, Values: Distribution{1, 0}},
+			"Teleoperated.Missed": {Total: 4, Max: 3, Values: Distribution{3, 1}},
+			"CoopBridge":          {AttemptCount: 1, Values: Distribution{0, 0}},
+			"TeamBridge1":         {Total: 1, AttemptCount: 1, Values: Distribution{1, 0}},
+			"TeamBridge2":         {Values: Distribution{0, 0}},
+			"TeleoperatedScored":  {Total: 5, Max: 3, Values: Distribution{3, 2}},
+			"TeleoperatedShot":    {Total: 9, Max: 6, Values: Distribution{6, 3}},
+			"AutonomousScored":    {Total: 2, Max: 2, Values: Distribution{2, 0}},
+			"AutonomousShot":      {Total: 3, Max: 3, Values: Distribution{3, 0}},
+		},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("TeamEventStats = %+v (expected %+v)", stats, expected)
+	}
+
+	stats, err = store.TeamEventStats(etag, 1538)
+	if err != nil {
+		t.Fatalf("TeamEventStats for absent team error: %v", err)
+	}
+	if stats.MatchCount != 0 || stats.NoShowCount != 0 || stats.EventTag != etag {
+		t.Errorf("TeamEventStats for absent team = %+v", stats)
+	}
+}
+
+func testStoreUpdateMatchScore(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 2, 1, 2, 3, 4, 5, 6))
+
+	mtag := MatchTag{etag, Qualification, 1}
+	if err := store.UpdateMatchScore(mtag, 30, 20, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+	if m, err := store.FetchMatch(mtag); err != nil {
+		t.Errorf("FetchMatch error: %v", err)
+	} else if expected := map[string]int{"red": 30, "blue": 20}; !reflect.DeepEqual(m.Score, expected) {
+		t.Errorf("Score = %v (expected %v)", m.Score, expected)
+	}
+	if m, err := store.FetchMatch(MatchTag{etag, Qualification, 2}); err != nil {
+		t.Errorf("FetchMatch error: %v", err)
+	} else if m.Score != nil {
+		t.Errorf("Score of other match changed to %v", m.Score)
+	}
+
+	if err := store.UpdateMatchScore(MatchTag{etag, Qualification, 3}, 30, 20, testEditor); err != StoreNotFound {
+		t.Errorf("UpdateMatchScore on missing match error = %v (expected %v)", err, StoreNotFound)
+	}
+}
+
+func testStoreUpdateMatchTeam(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+
+	mtag := MatchTag{etag, Qualification, 1}
+	info := TeamInfo{
+		Team:         5,
+		Alliance:     Blue,
+		Score:        13,
+		ScoutName:    "Ross",
+		Autonomous:   BallCount{High: 1},
+		Teleoperated: BallCount{Mid: 2, Low: 1, Missed: 4},
+		TeamBridge1:  Bridge{true, false},
+		Failure:      true,
+	}
+	if err := store.UpdateMatchTeam(mtag, 5, info, testEditor); err != nil {
+		t.Fatalf("UpdateMatchTeam error: %v", err)
+	}
+
+	m, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	expected := newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6)
+	expected.Teams[4] = info
+	expected.Teams[4].Revision = 1
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("FetchMatch after update = %#v (expected %#v)", m, expected)
+	}
+
+	// Updating from the old revision again is a conflict.
+	stale := info
+	stale.Score = 42
+	if err := store.UpdateMatchTeam(mtag, 5, stale, testEditor); err != StoreConflict {
+		t.Errorf("UpdateMatchTeam with stale revision error = %v (expected %v)", err, StoreConflict)
+	}
+	if m, err := store.FetchMatch(mtag); err != nil {
+		t.Errorf("FetchMatch error: %v", err)
+	} else if m.Teams[4].Score != info.Score {
+		t.Errorf("UpdateMatchTeam with stale revision changed score to %d", m.Teams[4].Score)
+	}
+
+	stale.Revision = 1
+	if err := store.UpdateMatchTeam(mtag, 5, stale, testEditor); err != nil {
+		t.Errorf("UpdateMatchTeam with current revision error: %v", err)
+	}
+	if m, err := store.FetchMatch(mtag); err != nil {
+		t.Errorf("FetchMatch error: %v", err)
+	} else if m.Teams[4].Score != 42 || m.Teams[4].Revision != 2 {
+		t.Errorf("After second update, score = %d, revision = %d (expected 42, 2)", m.Teams[4].Score, m.Teams[4].Revision)
+	}
+
+	if err := store.UpdateMatchTeam(mtag, 973, TeamInfo{Team: 973}, testEditor); err != StoreNotFound {
+		t.Errorf("UpdateMatchTeam on missing team error = %v (expected %v)", err, StoreNotFound)
+	}
+	if err := store.UpdateMatchTeam(MatchTag{etag, Qualification, 2}, 5, info, testEditor); err != StoreNotFound {
+		t.Errorf("UpdateMatchTeam on missing match error = %v (expected %v)", err, StoreNotFound)
+	}
+}
+
+func testStoreMatchHistory(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 2, 1, 2, 3, 4, 5, 6))
+	mtag := MatchTag{etag, Qualification, 1}
+
+	if changes, err := store.MatchHistory(mtag); err != nil {
+		t.Errorf("MatchHistory error: %v", err)
+	} else if len(changes) != 0 {
+		t.Errorf("MatchHistory before changes = %+v (expected none)", changes)
+	}
+
+	info := TeamInfo{Team: 2, Alliance: Red, Score: 3, Teleoperated: BallCount{High: 1}}
+	if err := store.UpdateMatchTeam(mtag, 2, info, Editor{Name: "Alice"}); err != nil {
+		t.Fatalf("UpdateMatchTeam error: %v", err)
+	}
+	if err := store.UpdateMatchScore(mtag, 10, 5, Editor{Name: "Bob"}); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+	info.Revision, info.Score = 1, 6
+	if err := store.UpdateMatchTeam(mtag, 2, info, Editor{Name: "Carol"}); err != nil {
+		t.Fatalf("UpdateMatchTeam error: %v", err)
+	}
+	if err := store.UpdateMatchScore(MatchTag{etag, Qualification, 2}, 1, 1, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+	// Failed updates are not recorded.
+	store.UpdateMatchTeam(mtag, 2, info, testEditor)
+
+	changes, err := store.MatchHistory(mtag)
+	if err != nil {
+		t.Fatalf("MatchHistory error: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("len(MatchHistory) = %d (expected 3)", len(changes))
+	}
+	for i := range changes {
+		if changes[i].Time.IsZero() {
+			t.Errorf("changes[%d].Time is zero", i)
+		}
+		if i > 0 && changes[i].Time.Before(changes[i-1].Time) {
+			t.Errorf("changes[%d] is before changes[%d]", i, i-1)
+		}
+	}
+
+	c := changes[0]
+	if c.Editor.Name != "Alice" || c.Team != 2 || c.OldInfo == nil || c.NewInfo == nil {
+		t.Errorf("changes[0] = %+v", c)
+	} else {
+		if c.OldInfo.Score != 0 || c.OldInfo.Revision != 0 {
+			t.Errorf("changes[0].OldInfo = %+v", c.OldInfo)
+		}
+		if c.NewInfo.Score != 3 || c.NewInfo.Revision != 1 {
+			t.Errorf("changes[0].NewInfo = %+v", c.NewInfo)
+		}
+	}
+
+	c = changes[1]
+	if c.Editor.Name != "Bob" || c.Team != 0 || len(c.OldScore) != 0 {
+		t.Errorf("changes[1] = %+v", c)
+	} else if expected := map[string]int{"red": 10, "blue": 5}; !reflect.DeepEqual(c.NewScore, expected) {
+		t.Errorf("changes[1].NewScore = %v (expected %v)", c.NewScore, expected)
+	}
+
+	c = changes[2]
+	if c.Editor.Name != "Carol" || c.Team != 2 || c.OldInfo == nil || c.NewInfo == nil {
+		t.Errorf("changes[2] = %+v", c)
+	} else if c.OldInfo.Score != 3 || c.NewInfo.Score != 6 || c.NewInfo.Revision != 2 {
+		t.Errorf("changes[2] = %+v -> %+v", c.OldInfo, c.NewInfo)
+	}
+}
+
+func testStoreUpdateScoutReport(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	mtag := MatchTag{etag, Qualification, 1}
+
+	reports := []TeamInfo{
+		{Team: 2, Alliance: Red, ScoutName: "Alice", Teleoperated: BallCount{High: 1}},
+		{Team: 2, Alliance: Red, ScoutName: "Bob", Teleoperated: BallCount{High: 2}},
+		{Team: 5, Alliance: Blue, ScoutName: "Alice", NoShow: true},
+		// Replaces Alice's first report
+		{Team: 2, Alliance: Red, ScoutName: "Alice", Teleoperated: BallCount{High: 3}},
+	}
+	for _, r := range reports {
+		if err := store.UpdateScoutReport(mtag, r); err != nil {
+			t.Fatalf("UpdateScoutReport(%+v) error: %v", r, err)
+		}
+	}
+
+	match, err := store.FetchMatch(mtag)
+	if err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	}
+	if len(match.Reports) != 3 {
+		t.Errorf("len(match.Reports) = %d (expected 3)", len(match.Reports))
+	}
+	highs := make(map[string]int)
+	for _, r := range match.ScoutReports(2) {
+		highs[r.ScoutName] = r.Teleoperated.High
+	}
+	if expected := map[string]int{"Alice": 3, "Bob": 2}; !reflect.DeepEqual(highs, expected) {
+		t.Errorf("team 2 report highs = %v (expected %v)", highs, expected)
+	}
+	if r := match.ScoutReports(5); len(r) != 1 || !r[0].NoShow {
+		t.Errorf("team 5 reports = %+v", r)
+	}
+	if info := match.TeamInfo(2); info.Teleoperated.High != 0 || info.ScoutName != "" {
+		t.Errorf("UpdateScoutReport changed canonical info: %+v", info)
+	}
+
+	if err := store.UpdateScoutReport(mtag, TeamInfo{Team: 973, ScoutName: "Alice"}); err != StoreNotFound {
+		t.Errorf("UpdateScoutReport for team not in match error = %v (expected %v)", err, StoreNotFound)
+	}
+	if err := store.UpdateScoutReport(MatchTag{etag, Qualification, 2}, reports[0]); err != StoreNotFound {
+		t.Errorf("UpdateScoutReport for missing match error = %v (expected %v)", err, StoreNotFound)
+	}
+}
+
+func testStoreUpdateScoutReportRevision(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	mtag := MatchTag{etag, Qualification, 1}
+
+	reports := []TeamInfo{
+		{Team: 2, Alliance: Red, ScoutName: "Alice"},
+		{Team: 2, Alliance: Red, ScoutName: "Bob"},
+		// Replaces Alice's first report
+		{Team: 2, Alliance: Red, ScoutName: "Alice", NoShow: true},
+	}
+	for i, r := range reports {
+		if err := store.UpdateScoutReport(mtag, r); err != nil {
+			t.Fatalf("UpdateScoutReport(%+v) error: %v", r, err)
+		}
+		match, err := store.FetchMatch(mtag)
+		if err != nil {
+			t.Fatalf("FetchMatch error: %v", err)
+		}
+		if rev := match.TeamInfo(2).Revision; rev != i+1 {
+			t.Errorf("after report %d, team 2 revision = %d (expected %d)", i, rev, i+1)
+		}
+		if rev := match.TeamInfo(5).Revision; rev != 0 {
+			t.Errorf("after report %d, team 5 revision = %d (expected 0)", i, rev)
+		}
+	}
+
+	// A report that is the canonical info (a single scout's save) doesn't
+	// change the revision.
+	if err := store.UpdateMatchTeam(mtag, 5, TeamInfo{Team: 5, Alliance: Blue, ScoutName: "Carol"}, testEditor); err != nil {
+		t.Fatalf("UpdateMatchTeam error: %v", err)
+	}
+	if err := store.UpdateScoutReport(mtag, TeamInfo{Team: 5, Alliance: Blue, ScoutName: "Carol"}); err != nil {
+		t.Fatalf("UpdateScoutReport error: %v", err)
+	}
+	if match, err := store.FetchMatch(mtag); err != nil {
+		t.Fatalf("FetchMatch error: %v", err)
+	} else if rev := match.TeamInfo(5).Revision; rev != 1 {
+		t.Errorf("after canonical report, team 5 revision = %d (expected 1)", rev)
+	}
+
+	stale := TeamInfo{Team: 2, Alliance: Red, Revision: 2}
+	if err := store.UpdateMatchTeam(mtag, 2, stale, testEditor); err != StoreConflict {
+		t.Errorf("UpdateMatchTeam from before report error = %v (expected %v)", err, StoreConflict)
+	}
+	current := TeamInfo{Team: 2, Alliance: Red, Revision: 3}
+	if err := store.UpdateMatchTeam(mtag, 2, current, testEditor); err != nil {
+		t.Errorf("UpdateMatchTeam after reports error: %v", err)
+	}
+}
+
+func testStoreEventRatings(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	if ratings, err := store.EventRatings(etag); err != nil {
+		t.Errorf("EventRatings before matches error: %v", err)
+	} else if len(ratings) != 0 {
+		t.Errorf("EventRatings before matches = %+v (expected none)", ratings)
+	}
+
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	mustUpsertMatch(t, store, etag, newTestMatch(Qualification, 2, 1, 4, 5, 2, 3, 6))
+	mustUpsertMatch(t, store, EventTag{"ca", 2012}, newTestMatch(Qualification, 1, 1, 2, 3, 4, 5, 6))
+	if ratings, err := store.EventRatings(etag); err != nil {
+		t.Errorf("EventRatings before scores error: %v", err)
+	} else if len(ratings) != 0 {
+		t.Errorf("EventRatings before scores = %+v (expected none)", ratings)
+	}
+
+	mtag := MatchTag{etag, Qualification, 1}
+	if err := store.UpdateMatchScore(mtag, 30, 12, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+	if err := store.UpdateMatchScore(MatchTag{etag, Qualification, 2}, 20, 22, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+	ratings, err := store.EventRatings(etag)
+	if err != nil {
+		t.Fatalf("EventRatings error: %v", err)
+	}
+	matches, err := store.FetchMatches(etag)
+	if err != nil {
+		t.Fatalf("FetchMatches error: %v", err)
+	}
+	if expected := computeRatings(reboundRumble, matches); !reflect.DeepEqual(ratings, expected) {
+		t.Errorf("EventRatings = %+v (expected %+v)", ratings, expected)
+	}
+
+	// Changing a score recomputes ratings.
+	if err := store.UpdateMatchScore(mtag, 50, 12, testEditor); err != nil {
+		t.Fatalf("UpdateMatchScore error: %v", err)
+	}
+	newRatings, err := store.EventRatings(etag)
+	if err != nil {
+		t.Fatalf("EventRatings error: %v", err)
+	}
+	if len(newRatings) != 6 || reflect.DeepEqual(newRatings, ratings) {
+		t.Errorf("EventRatings after score change = %+v", newRatings)
+	}
+
+	if ratings, err := store.EventRatings(EventTag{"ca", 2012}); err != nil {
+		t.Errorf("EventRatings for other event error: %v", err)
+	} else if len(ratings) != 0 {
+		t.Errorf("EventRatings for other event = %+v (expected none)", ratings)
+	}
+}
+
+func testStorePickList(t *testing.T, store Datastore) {
+	etag := EventTag{"sdc", 2012}
+	if _, err := store.FetchPickList(etag); err != StoreNotFound {
+		t.Errorf("FetchPickList before update error = %v (expected %v)", err, StoreNotFound)
+	}
+
+	list := &PickList{
+		Entries: []PickEntry{
+			{Team: 254, Tier: FirstPick, Notes: "fast shooter"},
+			{Team: 973, Tier: SecondPick, Picked: true},
+		},
+		Weights: []StatWeight{{"OPR", 1}},
+	}
+	if err := store.UpdatePickList(etag, list); err != nil {
+		t.Fatalf("UpdatePickList error: %v", err)
+	}
+	fetched, err := store.FetchPickList(etag)
+	if err != nil {
+		t.Fatalf("FetchPickList error: %v", err)
+	}
+	expected := *list
+	expected.Revision = 1
+	if !reflect.DeepEqual(fetched, &expected) {
+		t.Errorf("FetchPickList = %+v (expected %+v)", fetched, &expected)
+	}
+
+	// A stale revision is rejected.
+	if err := store.UpdatePickList(etag, list); err != StoreConflict {
+		t.Errorf("UpdatePickList with stale revision error = %v (expected %v)", err, StoreConflict)
+	}
+	fetched.Entries[0].Tier = DoNotPick
+	if err := store.UpdatePickList(etag, fetched); err != nil {
+		t.Fatalf("UpdatePickList error: %v", err)
+	}
+	if l, err := store.FetchPickList(etag); err != nil {
+		t.Errorf("FetchPickList error: %v", err)
+	} else if l.Revision != 2 || l.Entries[0].Tier != DoNotPick {
+		t.Errorf("After second update, list = %+v", l)
+	}
+	if _, err := store.FetchPickList(EventTag{"ca", 2012}); err != StoreNotFound {
+		t.Errorf("FetchPickList for other event error = %v (expected %v)", err, StoreNotFound)
+	}
+}
+
+func testStoreRobot(t *testing.T, store Datastore) {
+	if _, err := store.FetchRobot(973, 2012); err != StoreNotFound {
+		t.Errorf("FetchRobot before upsert error = %v (expected %v)", err, StoreNotFound)
+	}
+
+	robot := &Robot{
+		Name:                "Ringo",
+		Drivetrain:          "Tank",
+		Shooter:             "Dual Wheel",
+		Weight:              118.5,
+		ProgrammingLanguage: "Java",
+		Photos:              []string{"robots/973-2012-1.jpg"},
+	}
+	if err := store.UpsertRobot(973, 2012, robot); err != nil {
+		t.Fatalf("UpsertRobot error: %v", err)
+	}
+	fetched, err := store.FetchRobot(973, 2012)
+	if err != nil {
+		t.Fatalf("FetchRobot error: %v", err)
+	}
+	if !reflect.DeepEqual(fetched, robot) {
+		t.Errorf("FetchRobot = %+v (expected %+v)", fetched, robot)
+	}
+
+	// Profiles are kept separately for each season.
+	if _, err := store.FetchRobot(973, 2013); err != StoreNotFound {
+		t.Errorf("FetchRobot for other season error = %v (expected %v)", err, StoreNotFound)
+	}
+	fetched.Photos[0] = "changed.jpg"
+	fetched.Notes = "loose chain"
+	if err := store.UpsertRobot(973, 2012, fetched); err != nil {
+		t.Fatalf("UpsertRobot error: %v", err)
+	}
+	if r, err := store.FetchRobot(973, 2012); err != nil {
+		t.Errorf("FetchRobot error: %v", err)
+	} else if r.Notes != "loose chain" || r.Photos[0] != "changed.jpg" {
+		t.Errorf("After second upsert, robot = %+v", r)
+	}
+}
@@ -13,6 +13,9 @@ const (
 	matchNumberWidth = 3
 )
 
+// pitTagSeparator separates the event and team in a pit tag.
+const pitTagSeparator = 'p'
+
 const (
 	qualificationDigit rune = '0' + iota
 	quarterFinalDigit
@@ -229,3 +232,41 @@ func (tag MatchTeamTag) String() string {
 func (tag MatchTeamTag) GoString() string {
 	return fmt.Sprintf("MatchTeamTag{MatchTag:%#v, TeamNumber:%d}", tag.MatchTag, tag.TeamNumber)
 }
+
+// A PitTag identifies a team's pit scouting form from an event.  The event's
+// year is the season of the robot profile.
+type PitTag struct {
+	EventTag
+	TeamNumber uint
+}
+
+func ParsePitTag(s string) (tag PitTag, err error) {
+	defer func(tag string) {
+		err = wrapTagError(err, tag)
+	}(s)
+
+	tag.EventTag, s, err = parseEvent(s)
+	if err != nil {
+		return
+	}
+	if len(s) == 0 || rune(s[0]) != pitTagSeparator {
+		err = TagError{BadPart: s, Err: fmt.Errorf("Missing %q after event in pit tag", pitTagSeparator)}
+		return
+	}
+	s = s[1:]
+	teamNumber64, err := strconv.ParseUint(s, 10, 0)
+	if err != nil || teamNumber64 == 0 {
+		err = TagError{BadPart: s, Err: errors.New("Pit tag must end with a team number")}
+		return
+	}
+	tag.TeamNumber = uint(teamNumber64)
+	return
+}
+
+func (tag PitTag) String() string {
+	return fmt.Sprintf("%s%c%d", tag.EventTag, pitTagSeparator, tag.TeamNumber)
+}
+
+func (tag PitTag) GoString() string {
+	return fmt.Sprintf("PitTag{EventTag:%#v, TeamNumber:%d}", tag.EventTag, tag.TeamNumber)
+}
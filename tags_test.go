@@ -103,3 +103,28 @@ func TestMatchTeamTagString(t *testing.T) {
 		}
 	}
 }
+
+var pitTagTests = []tagTest{
+	{"sdc2012p973", PitTag{EventTag{"sdc", 2012}, 973}},
+	{"sdc2012p1", PitTag{EventTag{"sdc", 2012}, 1}},
+	{"sdc2012p", nil},
+	{"sdc2012p0", nil},
+	{"sdc2012973", nil},
+	{"sdc2012p97a", nil},
+	{"SDC2012p973", nil},
+}
+
+func TestParsePitTag(t *testing.T) {
+	for _, tt := range pitTagTests {
+		result, err := ParsePitTag(tt.String)
+		checkTagParseResult(t, "ParsePitTag", tt, result, err)
+	}
+}
+
+func TestPitTagString(t *testing.T) {
+	for _, tt := range pitTagTests {
+		if tt.Tag != nil {
+			checkTagString(t, tt)
+		}
+	}
+}
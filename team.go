@@ -40,6 +40,49 @@ func teamIndex(server *Server, w http.ResponseWriter, req *http.Request) error {
 	})
 }
 
+// A seasonStats holds a team's statistics from each event in a season.
+// Games[i] is the game played at Events[i].  Game is the game played at the
+// team's first event of the season, or the season's game if the team didn't
+// attend any.
+type seasonStats struct {
+	Year   int
+	Game   *Game
+	Events []TeamStats
+	Games  []*Game
+	Total  TeamStats
+}
+
+// fetchSeasonStats returns a team's statistics for each of its events in a
+// season, along with the combined statistics.
+func fetchSeasonStats(store Datastore, year int, number int) (seasonStats, error) {
+	season := seasonStats{Year: year, Game: seasonGame(year)}
+	eventTags, err := store.EventsForTeam(year, number)
+	if err != nil {
+		return season, err
+	}
+	season.Events = make([]TeamStats, len(eventTags))
+	season.Games = make([]*Game, len(eventTags))
+	for i := range eventTags {
+		event, err := store.FetchEvent(eventTags[i])
+		if err != nil {
+			return season, err
+		}
+		season.Games[i] = eventGame(event)
+		season.Events[i], err = store.TeamEventStats(eventTags[i], number)
+		if err != nil {
+			return season, err
+		}
+	}
+	if len(season.Games) > 0 {
+		season.Game = season.Games[0]
+	}
+	season.Total = combineStats(season.Events)
+	return season, nil
+}
+
+// viewTeam shows a team's robot and statistics.  The "year" form value picks
+// the season, or "all" for every season; the default is the team's latest
+// season.
 func viewTeam(server *Server, w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 	number, _ := strconv.Atoi(vars["number"])
@@ -53,24 +96,59 @@ func viewTeam(server *Server, w http.ResponseWriter, req *http.Request) error {
 		return err
 	}
 
-	// Stats
-	eventTags, err := server.Store().EventsForTeam(time.Now().Year(), number)
+	// Choose season
+	years, err := server.Store().TeamYears(number)
 	if err != nil {
 		return err
 	}
-	stats := make([]TeamStats, len(eventTags))
-	for i := range eventTags {
-		stats[i], err = server.Store().TeamEventStats(eventTags[i], number)
+	year := time.Now().Year()
+	if len(years) > 0 {
+		year = years[0]
+	}
+	career := req.FormValue("year") == "all"
+	if s := req.FormValue("year"); s != "" && !career {
+		year, err = strconv.Atoi(s)
+		if err != nil || year <= 0 {
+			http.NotFound(w, req)
+			return nil
+		}
+	}
+
+	robot, err := fetchRobot(server.Store(), number, year)
+	if err != nil {
+		return err
+	}
+
+	// Stats
+	seasonYears := []int{year}
+	if career {
+		seasonYears = years
+	}
+	seasons := make([]seasonStats, len(seasonYears))
+	totals := make([]TeamStats, len(seasonYears))
+	for i, y := range seasonYears {
+		seasons[i], err = fetchSeasonStats(server.Store(), y, number)
 		if err != nil {
 			return err
 		}
+		totals[i] = seasons[i].Total
+	}
+
+	game := seasonGame(year)
+	if len(seasons) > 0 {
+		game = seasons[0].Game
 	}
-	// TODO: image
 
 	return server.Templates().ExecuteTemplate(w, "team.html", map[string]interface{}{
 		"Server":  server,
 		"Request": req,
 		"Team":    team,
-		"Stats":   stats,
+		"Years":   years,
+		"Year":    year,
+		"Career":  career,
+		"Robot":   robot,
+		"Game":    game,
+		"Seasons": seasons,
+		"Summary": combineStats(totals),
 	})
 }
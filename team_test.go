@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestViewTeamSeasons(t *testing.T) {
+	store := newTestServer(t)
+	mustUpsertTeams(t, store, 973)
+	mustUpsertEvent(t, store, newTestEvent("sdc", 2012, 3, 15, 973))
+	mustUpsertEvent(t, store, newTestEvent("ca", 2012, 3, 1, 973))
+	mustUpsertEvent(t, store, newTestEvent("nv", 2011, 3, 8, 973))
+
+	tests := []struct {
+		Query    string
+		Contains []string
+		Missing  []string
+	}{
+		// The latest season is shown by default.
+		{"", []string{"2012 Season</h2>", "sdc2012", "ca2012", `<option value="2012" selected>`}, []string{"nv2011"}},
+		{"?year=2011", []string{"2011 Season</h2>", "nv2011", `<option value="2011" selected>`}, []string{"sdc2012"}},
+		{"?year=2010", []string{"2010 Season</h2>", "any events in 2010"}, nil},
+		{"?year=all", []string{"Career</h2>", "?year=2012", "?year=2011", `<option value="all" selected>`}, nil},
+	}
+	for _, test := range tests {
+		path := "/team/973/" + test.Query
+		rec := serveTestRequest(t, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s code = %d", path, rec.Code)
+			continue
+		}
+		body := rec.Body.String()
+		for _, s := range test.Contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("GET %s is missing %q", path, s)
+			}
+		}
+		for _, s := range test.Missing {
+			if strings.Contains(body, s) {
+				t.Errorf("GET %s contains %q", path, s)
+			}
+		}
+	}
+
+	// Teams that have played show the distribution of their scores.
+	m := newTestMatch(Qualification, 1, 973, 2, 3, 4, 5, 6)
+	m.Score = map[string]int{"red": 30, "blue": 20}
+	m.Teams[0].Score = 18
+	mustUpsertMatch(t, store, EventTag{"sdc", 2012}, m)
+	if body := serveTestRequest(t, "/team/973/", nil).Body.String(); !strings.Contains(body, `class="distributions"`) {
+		t.Error("Team page is missing score distributions")
+	}
+
+	if rec := serveTestRequest(t, "/team/973/?year=last", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET with bad year code = %d (expected %d)", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestViewTeamEventGames(t *testing.T) {
+	games = append(games, testGame)
+	defer func() { games = games[:len(games)-1] }()
+
+	store := newTestServer(t)
+	mustUpsertTeams(t, store, 973)
+	event := newTestEvent("sdc", 2012, 3, 15, 973, 2, 3, 4, 5, 6)
+	event.Game = testGame.ID
+	mustUpsertEvent(t, store, event)
+	m := newTestMatch(Qualification, 1, 973, 2, 3, 4, 5, 6)
+	m.Score = map[string]int{"red": 30, "blue": 20}
+	m.Teams[0].Score = 18
+	mustUpsertMatch(t, store, event.Tag(), m)
+
+	// The season's distributions use the game played at the event, not the
+	// game from the event's year.
+	body := serveTestRequest(t, "/team/973/", nil).Body.String()
+	if n := strings.Count(body, "<th>Discs</th>"); n != 2 {
+		t.Errorf("Team page shows %s distributions %d times (expected 2)", testGame.Name, n)
+	}
+	if strings.Contains(body, "<th>Teleop Scored</th>") {
+		t.Errorf("Team page shows %s distributions", reboundRumble.Name)
+	}
+	if !strings.Contains(body, "<th>Average Discs</th>") || !strings.Contains(body, "<th>Climb Attempts</th>") {
+		t.Errorf("Team page is missing %s stats", testGame.Name)
+	}
+	if strings.Contains(body, "Teleop Scored") || strings.Contains(body, "Bridge 1") {
+		t.Errorf("Team page shows %s stats", reboundRumble.Name)
+	}
+}